@@ -0,0 +1,177 @@
+// Command headless runs a ROM without opening a window or an audio device. It is meant for automated testing on
+// machines without a display.
+//
+// Usage:
+//
+//	headless [flags] romfile
+//
+// The ROM file can be an iNES file or an FDS disk image. Disk images need the BIOS of the Famicom Disk System.
+//
+// The NES runs for the given number of frames, until an input movie has been replayed or until a condition on the
+// memory is met. Afterwards, the hash of the last frame is printed and the frame is optionally written as PNG.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/exp625/gones/pkg/cartridge"
+	"github.com/exp625/gones/pkg/console"
+	"github.com/exp625/gones/pkg/movie"
+)
+
+const audioSampleRate = 44100
+
+var (
+	frames  = flag.Int("frames", 0, "number of frames to run, the timeout if -until is given. Defaults to the length of the input movie.")
+	until   = flag.String("until", "", "stop at the end of the first frame where the condition is met, e.g. 6000!=80 or 00F0=1 (hexadecimal)")
+	input   = flag.String("input", "", "input movie to replay (.movie, .fm2 or .bk2)")
+	pngFile = flag.String("png", "", "write the last frame to this PNG file")
+	bios    = flag.String("bios", "", "BIOS of the Famicom Disk System, required for FDS disk images")
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("headless: ")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] romfile\n", filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(flag.Arg(0)); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(romFile string) error {
+	var cond *condition
+	if *until != "" {
+		var err error
+		if cond, err = parseCondition(*until); err != nil {
+			return err
+		}
+	}
+
+	rom, err := os.ReadFile(romFile)
+	if err != nil {
+		return err
+	}
+	c := console.New(audioSampleRate)
+	if cartridge.IsDisk(rom) {
+		if *bios == "" {
+			return errors.New("FDS disk images need the BIOS, use -bios")
+		}
+		biosRom, err := os.ReadFile(*bios)
+		if err != nil {
+			return err
+		}
+		if err := c.LoadDisk(rom, biosRom); err != nil {
+			return fmt.Errorf("%s: %w", romFile, err)
+		}
+	} else if err := c.LoadROM(rom); err != nil {
+		return fmt.Errorf("%s: %w", romFile, err)
+	}
+
+	var player *movie.Player
+	count := *frames
+	if *input != "" {
+		m, err := movie.ReadFile(*input)
+		if err != nil {
+			return err
+		}
+		if player, err = movie.NewPlayer(c.NES(), m); err != nil {
+			return err
+		}
+		if count == 0 {
+			count = len(m.Frames)
+		}
+	}
+	if count <= 0 {
+		return errors.New("number of frames is missing, use -frames or -input")
+	}
+
+	done := 0
+	for done < count {
+		c.StepFrame()
+		done++
+		if player != nil {
+			if err := player.Frame(); err != nil {
+				return err
+			}
+		}
+		if cond != nil && cond.met(c) {
+			break
+		}
+	}
+
+	if *pngFile != "" {
+		if err := writePNG(*pngFile, c); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("%016x\n", movie.FrameHash(c.NES()))
+	if cond != nil && !cond.met(c) {
+		return fmt.Errorf("condition %s not met after %d frames", *until, done)
+	}
+	log.Printf("ran %d frames", done)
+	return nil
+}
+
+func writePNG(name string, c *console.Console) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, c.Framebuffer()); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// condition compares a byte of the CPU address space with a value
+type condition struct {
+	address uint16
+	value   uint8
+	equal   bool
+}
+
+// parseCondition parses a condition of the form ADDRESS=VALUE or ADDRESS!=VALUE with hexadecimal numbers
+func parseCondition(s string) (*condition, error) {
+	c := &condition{equal: true}
+	i := strings.Index(s, "=")
+	if i < 0 {
+		return nil, fmt.Errorf("invalid condition %q", s)
+	}
+	address, value := s[:i], s[i+1:]
+	if strings.HasSuffix(address, "!") {
+		c.equal = false
+		address = address[:len(address)-1]
+	}
+	a, err := strconv.ParseUint(strings.TrimPrefix(address, "$"), 16, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition %q: %v", s, err)
+	}
+	v, err := strconv.ParseUint(strings.TrimPrefix(value, "$"), 16, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition %q: %v", s, err)
+	}
+	c.address, c.value = uint16(a), uint8(v)
+	return c, nil
+}
+
+// met reads the address and checks the condition
+func (cond *condition) met(c *console.Console) bool {
+	return (c.Peek(cond.address) == cond.value) == cond.equal
+}
@@ -0,0 +1,123 @@
+// Package blargg runs test ROMs that report their result like the test ROMs of blargg.
+//
+// From the readme of the blargg test ROMs:
+//
+// The test status is written to $6000. $80 means the test is running, $81 means the test needs the reset button
+// pressed, but delayed by at least 100 msec from now. $00-$7F means the test has completed and given that result code.
+//
+// To allow an emulator to know when one of these tests is running and the data at $6000+ is valid, as opposed to
+// some other NES program, $DE $B0 $61 is written to $6001-$6003.
+//
+// A byte is reported as a series of tones. Text output is written starting at $6004, with a zero byte at the end.
+package blargg
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/exp625/gones/pkg/console"
+)
+
+// Status values written to $6000
+const (
+	StatusPassed  = 0x00
+	StatusRunning = 0x80
+	StatusReset   = 0x81
+)
+
+const (
+	statusAddress = 0x6000
+	textAddress   = 0x6004
+	// The text can fill the rest of the PRG RAM
+	maxTextLength = 0x8000 - textAddress
+	// Frames to wait before pressing the reset button, at least 100 ms
+	resetDelay = 8
+	// Frames to wait for the signature before giving up
+	signatureTimeout = 300
+)
+
+// signature shows that the values at $6000+ are valid
+var signature = [3]uint8{0xDE, 0xB0, 0x61}
+
+var (
+	// ErrNoSignature is returned if the ROM does not write the signature to $6001-$6003
+	ErrNoSignature = errors.New("test ROM does not report its status at $6000")
+	// ErrTimeout is returned if the test did not complete in time
+	ErrTimeout = errors.New("test ROM timed out")
+)
+
+// Result of a completed test
+type Result struct {
+	// Result code of the test, StatusPassed or an error code
+	Status uint8
+	// Text output of the test
+	Text string
+	// Frames the test needed to complete
+	Frames int
+}
+
+// Passed returns true if the test has passed
+func (r *Result) Passed() bool {
+	return r.Status == StatusPassed
+}
+
+// Run runs the test ROM that is loaded into the console until it reports its result. Resets requested by the ROM are
+// executed. If the test does not complete within the given number of frames, ErrTimeout is returned along with the
+// text output so far.
+func Run(c *console.Console, timeout int) (*Result, error) {
+	// Frames to wait until the reset button is pressed
+	resetWait := resetDelay
+	for frame := 1; frame <= timeout; frame++ {
+		c.StepFrame()
+		if !hasSignature(c) {
+			if frame >= signatureTimeout {
+				return nil, ErrNoSignature
+			}
+			continue
+		}
+
+		status := c.Peek(statusAddress)
+		switch {
+		case status < StatusRunning:
+			return &Result{Status: status, Text: Text(c), Frames: frame}, nil
+		case status == StatusReset:
+			resetWait--
+			if resetWait == 0 {
+				c.Reset()
+				// The status keeps its value until the ROM has started again. Wait longer before treating it as
+				// another request.
+				resetWait = 2 * resetDelay
+			}
+		default:
+			resetWait = resetDelay
+		}
+	}
+	text := ""
+	if hasSignature(c) {
+		text = Text(c)
+	}
+	return nil, fmt.Errorf("%w after %d frames: %s", ErrTimeout, timeout, text)
+}
+
+// Text returns the text output of the test ROM
+func Text(c *console.Console) string {
+	var text []byte
+	for i := 0; i < maxTextLength; i++ {
+		b := c.Peek(uint16(textAddress + i))
+		if b == 0 {
+			break
+		}
+		text = append(text, b)
+	}
+	return string(text)
+}
+
+// hasSignature returns true if the test ROM has written the signature
+func hasSignature(c *console.Console) bool {
+	for i, b := range signature {
+		if c.Peek(uint16(statusAddress+1+i)) != b {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+package blargg
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/exp625/gones/internal/testrom"
+	"github.com/exp625/gones/pkg/console"
+)
+
+func load(t *testing.T, rom []byte) *console.Console {
+	c := console.New(44100)
+	if err := c.LoadROM(rom); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestPassed(t *testing.T) {
+	result, err := Run(load(t, testrom.Blargg(0, 0, "\nPassed\n")), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Passed() || result.Text != "\nPassed\n" {
+		t.Fatalf("unexpected result %d: %q", result.Status, result.Text)
+	}
+}
+
+func TestFailed(t *testing.T) {
+	result, err := Run(load(t, testrom.Blargg(3, 0, "Failed #3")), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Passed() || result.Status != 3 || result.Text != "Failed #3" {
+		t.Fatalf("unexpected result %d: %q", result.Status, result.Text)
+	}
+}
+
+func TestReset(t *testing.T) {
+	result, err := Run(load(t, testrom.Blargg(0, 2, "Passed")), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Passed() {
+		t.Fatalf("unexpected result %d: %q", result.Status, result.Text)
+	}
+	// Both resets are delayed by at least 100 ms
+	if result.Frames < 2*resetDelay {
+		t.Fatalf("resets were not delayed, test completed after %d frames", result.Frames)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	_, err := Run(load(t, testrom.Blargg(StatusRunning, 0, "Still running")), 30)
+	if !errors.Is(err, ErrTimeout) || !strings.Contains(err.Error(), "Still running") {
+		t.Fatalf("expected timeout with text, got %v", err)
+	}
+}
+
+func TestNoSignature(t *testing.T) {
+	_, err := Run(load(t, testrom.ROM()), signatureTimeout+10)
+	if !errors.Is(err, ErrNoSignature) {
+		t.Fatalf("expected ErrNoSignature, got %v", err)
+	}
+}
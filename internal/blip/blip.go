@@ -0,0 +1,149 @@
+package blip
+
+import "math"
+
+const (
+	// Number of fractional bits of the sample position
+	timeBits = 32
+	// Number of phases of the band-limited step, selected by the highest fractional bits of the sample position
+	phaseBits  = 6
+	phaseCount = 1 << phaseBits
+	// Number of samples each band-limited step is spread across
+	halfWidth = 8
+	width     = 2 * halfWidth
+	// Fixed point precision of the kernel
+	kernelBits = 15
+)
+
+// kernel holds the impulse response of a windowed sinc low-pass filter for every phase. Adding a kernel scaled by the
+// delta to the buffer and integrating the buffer while reading results in a band-limited step.
+var kernel [phaseCount][width]int64
+
+func init() {
+	for phase := 0; phase < phaseCount; phase++ {
+		var taps [width]float64
+		sum := 0.0
+		for i := 0; i < width; i++ {
+			// Distance of the tap to the exact position of the step
+			x := float64(i-halfWidth+1) - float64(phase)/phaseCount
+			// Cut off slightly below the Nyquist frequency to leave room for the transition band
+			const cutoff = 0.95
+			sinc := 1.0
+			if x != 0 {
+				sinc = math.Sin(math.Pi*x*cutoff) / (math.Pi * x * cutoff)
+			}
+			// Blackman window
+			w := (x + halfWidth) / width
+			window := 0.42 - 0.5*math.Cos(2*math.Pi*w) + 0.08*math.Cos(4*math.Pi*w)
+			if w <= 0 || w >= 1 {
+				window = 0
+			}
+			taps[i] = sinc * window
+			sum += taps[i]
+		}
+		// Normalize every phase, so that every step integrates to exactly its delta
+		total := int64(0)
+		for i := 0; i < width; i++ {
+			kernel[phase][i] = int64(math.Round(taps[i] / sum * (1 << kernelBits)))
+			total += kernel[phase][i]
+		}
+		kernel[phase][halfWidth-1] += (1 << kernelBits) - total
+	}
+}
+
+// Buffer converts a signal given as amplitude changes (deltas) at a high clock rate to band-limited samples at a lower
+// sample rate, similar to Shay Green's blip_buf. Instead of computing every sample of the high rate signal and
+// filtering it, every change in amplitude is added as band-limited step to the output samples.
+type Buffer struct {
+	// Sample positions per clock with timeBits fractional bits
+	factor uint64
+	// Position of the current clock relative to the first sample of the buffer with timeBits fractional bits
+	offset uint64
+	// Running sum of all deltas that have been read
+	integrator int64
+	samples    []int64
+	// Scratch buffer for samples that are dropped on overflow
+	discard []int16
+}
+
+// New creates a new buffer that can hold up to size samples
+func New(size int) *Buffer {
+	return &Buffer{
+		samples: make([]int64, size+width),
+		discard: make([]int16, size/2+1),
+		factor:  1 << timeBits,
+	}
+}
+
+// SetRates sets the clock rate of the input signal and the sample rate of the output samples.
+func (b *Buffer) SetRates(clockRate float64, sampleRate float64) {
+	b.factor = uint64(math.Round(sampleRate / clockRate * (1 << timeBits)))
+}
+
+// Clear removes all samples and resets the buffer to silence
+func (b *Buffer) Clear() {
+	b.offset = 0
+	b.integrator = 0
+	for i := range b.samples {
+		b.samples[i] = 0
+	}
+}
+
+// AddDelta adds a change in amplitude at the given clock time, relative to the end of the last frame.
+func (b *Buffer) AddDelta(time uint64, delta int32) {
+	fixed := b.offset + time*b.factor
+	position := int(fixed >> timeBits)
+	phase := fixed >> (timeBits - phaseBits) & (phaseCount - 1)
+	if position+width > len(b.samples) {
+		// Buffer overflow, the samples have not been read in time
+		return
+	}
+	for i := 0; i < width; i++ {
+		b.samples[position+i] += kernel[phase][i] * int64(delta)
+	}
+}
+
+// EndFrame advances the time of the buffer by the given number of clocks. Samples before that time become available
+// for reading. If the samples are not read in time, the oldest samples are dropped.
+func (b *Buffer) EndFrame(time uint64) {
+	b.offset += time * b.factor
+	if b.SamplesAvailable() > len(b.samples)-width {
+		b.ReadSamples(b.discard)
+	}
+}
+
+// SamplesAvailable returns the number of samples that can be read
+func (b *Buffer) SamplesAvailable() int {
+	return int(b.offset >> timeBits)
+}
+
+// ReadSamples reads up to len(out) samples and removes them from the buffer. Returns the number of samples read.
+func (b *Buffer) ReadSamples(out []int16) int {
+	count := b.SamplesAvailable()
+	if len(out) < count {
+		count = len(out)
+	}
+	for i := 0; i < count; i++ {
+		b.integrator += b.samples[i]
+		sample := b.integrator >> kernelBits
+		if sample > math.MaxInt16 {
+			sample = math.MaxInt16
+		} else if sample < math.MinInt16 {
+			sample = math.MinInt16
+		}
+		out[i] = int16(sample)
+	}
+
+	// Move the remaining samples to the front of the buffer. Only the available samples and the samples the last
+	// steps spread into can be non-zero.
+	used := b.SamplesAvailable() + width
+	if used > len(b.samples) {
+		used = len(b.samples)
+	}
+	remaining := copy(b.samples, b.samples[count:used])
+	for i := remaining; i < used; i++ {
+		b.samples[i] = 0
+	}
+	b.offset -= uint64(count) << timeBits
+	return count
+}
@@ -0,0 +1,93 @@
+package blip
+
+import "testing"
+
+func TestStep(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		time  uint64
+		delta int32
+	}{
+		{"positive", 10, 10000},
+		{"negative", 10, -10000},
+		{"between samples", 33, 32767},
+	} {
+		b := New(1024)
+		// 4 clocks per sample
+		b.SetRates(4, 1)
+		b.AddDelta(test.time, test.delta)
+		b.EndFrame(400)
+		if available := b.SamplesAvailable(); available != 100 {
+			t.Fatalf("%s: expected 100 samples, got %d", test.name, available)
+		}
+		samples := make([]int16, 100)
+		if n := b.ReadSamples(samples); n != 100 {
+			t.Fatalf("%s: expected to read 100 samples, got %d", test.name, n)
+		}
+		// The step is spread across the samples around its position, then settles exactly at the delta
+		if samples[0] != 0 {
+			t.Errorf("%s: expected silence before the step, got %d", test.name, samples[0])
+		}
+		for i := int(test.time/4) + width; i < len(samples); i++ {
+			if int32(samples[i]) != test.delta {
+				t.Errorf("%s: expected sample %d to be %d, got %d", test.name, i, test.delta, samples[i])
+				break
+			}
+		}
+		if b.SamplesAvailable() != 0 {
+			t.Errorf("%s: expected all samples to be read", test.name)
+		}
+	}
+}
+
+func TestReadSamples(t *testing.T) {
+	b := New(1024)
+	b.SetRates(2, 1)
+	b.AddDelta(0, 1000)
+	b.EndFrame(101)
+	if available := b.SamplesAvailable(); available != 50 {
+		t.Fatalf("expected 50 samples, got %d", available)
+	}
+	// The samples can be read in parts, the remaining samples and the fractional clock are kept
+	samples := make([]int16, 30)
+	if n := b.ReadSamples(samples); n != 30 {
+		t.Errorf("expected to read 30 samples, got %d", n)
+	}
+	if n := b.ReadSamples(samples); n != 20 {
+		t.Errorf("expected to read 20 samples, got %d", n)
+	}
+	if samples[19] != 1000 {
+		t.Errorf("expected the last sample to be 1000, got %d", samples[19])
+	}
+	b.EndFrame(1)
+	if available := b.SamplesAvailable(); available != 1 {
+		t.Errorf("expected 1 sample, got %d", available)
+	}
+
+	b.Clear()
+	if available := b.SamplesAvailable(); available != 0 {
+		t.Errorf("expected no samples after clear, got %d", available)
+	}
+	b.EndFrame(20)
+	if n := b.ReadSamples(samples); n != 10 || samples[9] != 0 {
+		t.Errorf("expected 10 silent samples after clear, got %d samples ending in %d", n, samples[9])
+	}
+}
+
+func TestOverflow(t *testing.T) {
+	b := New(100)
+	b.SetRates(1, 1)
+	b.AddDelta(0, 1000)
+	// The oldest samples are dropped, the level of the signal is kept
+	for i := 0; i < 1000; i++ {
+		b.EndFrame(1)
+	}
+	if available := b.SamplesAvailable(); available > 100 {
+		t.Errorf("expected at most 100 samples, got %d", available)
+	}
+	samples := make([]int16, 100)
+	n := b.ReadSamples(samples)
+	if n == 0 || samples[n-1] != 1000 {
+		t.Errorf("expected the samples to keep the level 1000, got %d samples", n)
+	}
+}
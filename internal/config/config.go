@@ -9,6 +9,10 @@ import (
 
 const (
 	LastROMFile = "last_rom_file"
+	// RewindBufferSize is the memory budget of the rewind buffer in megabytes
+	RewindBufferSize = "rewind_buffer_size"
+	// FDSBIOSFile is the path of the 8 KB BIOS of the Famicom Disk System, needed to run FDS disk images
+	FDSBIOSFile = "fds_bios_file"
 )
 
 var config map[string]string
@@ -0,0 +1,279 @@
+// Package golden runs ROMs with scripted input and compares the pictures of chosen frames against checked-in golden
+// hashes. It catches rendering regressions that tests reading a result code from memory can not see.
+//
+// The goldens of a test are stored in a directory: <name>.json maps the frame numbers to the hashes of the frames and
+// <name>_<frame>.png holds the picture of every frame for comparison.
+package golden
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/exp625/gones/internal/plz"
+	"github.com/exp625/gones/pkg/console"
+	"github.com/exp625/gones/pkg/controller"
+	"github.com/exp625/gones/pkg/movie"
+)
+
+// ErrNoGolden is returned by Check if no goldens have been recorded for the test
+var ErrNoGolden = errors.New("no golden hashes")
+
+// Test describes a golden frame test
+type Test struct {
+	Name string `json:"name"`
+	Rom  string `json:"rom"`
+	// Number of frames to run
+	Frames int `json:"frames"`
+	// Frames that are compared against the goldens
+	Capture []int `json:"capture"`
+	// Input applied at the start of the given frames
+	Input []Input `json:"input"`
+	// Movie file that is replayed instead of the input, relative to the directory of the goldens
+	Movie string `json:"movie"`
+}
+
+// Input sets the buttons of a controller at the start of a frame. The buttons are kept until the next input for the
+// same port.
+type Input struct {
+	Frame int `json:"frame"`
+	Port  int `json:"port"`
+	// Names of the pressed buttons, e.g. "START" or "A"
+	Buttons []string `json:"buttons"`
+}
+
+// buttonNames maps the names used by Input to the controller buttons
+var buttonNames = map[string]controller.Button{
+	"A":      controller.ButtonA,
+	"B":      controller.ButtonB,
+	"SELECT": controller.ButtonSELECT,
+	"START":  controller.ButtonSTART,
+	"UP":     controller.ButtonUP,
+	"DOWN":   controller.ButtonDOWN,
+	"LEFT":   controller.ButtonLEFT,
+	"RIGHT":  controller.ButtonRIGHT,
+}
+
+// Frame is a captured picture
+type Frame struct {
+	Number int
+	Hash   uint64
+	Image  *image.RGBA
+}
+
+// Hash returns the hash of a picture. It is the same hash as used by the movies.
+func Hash(img *image.RGBA) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write(img.Pix)
+	return h.Sum64()
+}
+
+func hashString(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+// Run runs the test on the console, which has to be loaded with the ROM of the test. Frame 1 is the first frame
+// completed after power-on.
+func Run(c *console.Console, test Test) ([]Frame, error) {
+	var inputs [2]uint8
+	var player *movie.Player
+	if test.Movie != "" {
+		m, err := movie.ReadFile(test.Movie)
+		if err != nil {
+			return nil, err
+		}
+		if player, err = movie.NewPlayer(c.NES(), m); err != nil {
+			return nil, err
+		}
+	} else {
+		c.Reset()
+	}
+
+	capture := map[int]bool{}
+	for _, frame := range test.Capture {
+		capture[frame] = true
+	}
+	var frames []Frame
+	for frame := 1; frame <= test.Frames; frame++ {
+		for _, input := range test.Input {
+			if input.Frame != frame || player != nil {
+				continue
+			}
+			if input.Port < 0 || input.Port > 1 {
+				return nil, fmt.Errorf("invalid port %d", input.Port)
+			}
+			buttons, err := parseButtons(input.Buttons)
+			if err != nil {
+				return nil, err
+			}
+			inputs[input.Port] = buttons
+			c.SetInput(input.Port, buttons)
+		}
+		c.StepFrame()
+		if player != nil {
+			if err := player.Frame(); err != nil {
+				return nil, err
+			}
+		}
+		if capture[frame] {
+			img := c.Framebuffer()
+			frames = append(frames, Frame{Number: frame, Hash: Hash(img), Image: img})
+		}
+	}
+	return frames, nil
+}
+
+func parseButtons(names []string) (uint8, error) {
+	var buttons uint8
+	for _, name := range names {
+		button, ok := buttonNames[strings.ToUpper(name)]
+		if !ok {
+			return 0, fmt.Errorf("unknown button %q", name)
+		}
+		buttons |= uint8(button)
+	}
+	return buttons, nil
+}
+
+// MismatchError is returned by Check if captured frames differ from the goldens
+type MismatchError struct {
+	Name string
+	// Frames that differ
+	Frames []int
+	// Directory with the pictures of the differing frames and the diff images
+	Output string
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("%s: frames %v differ from the goldens, see %s", e.Name, e.Frames, e.Output)
+}
+
+// Check compares the captured frames against the goldens of the test in dir. For every differing frame, the picture
+// and a diff image against the golden picture are written to out. If update is true, the goldens are replaced by the
+// captured frames instead.
+func Check(dir string, out string, name string, frames []Frame, update bool) error {
+	if update {
+		return write(dir, name, frames)
+	}
+	hashes, err := readHashes(filepath.Join(dir, name+".json"))
+	if err != nil {
+		return err
+	}
+
+	var mismatches []int
+	for _, frame := range frames {
+		expected, ok := hashes[frame.Number]
+		if ok && hashString(frame.Hash) == expected {
+			continue
+		}
+		mismatches = append(mismatches, frame.Number)
+		if err := dump(dir, out, name, frame); err != nil {
+			return err
+		}
+	}
+	if len(mismatches) > 0 {
+		return &MismatchError{Name: name, Frames: mismatches, Output: out}
+	}
+	return nil
+}
+
+func readHashes(file string) (map[int]string, error) {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNoGolden, file)
+	}
+	if err != nil {
+		return nil, err
+	}
+	hashes := map[int]string{}
+	if err := json.Unmarshal(data, &hashes); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return hashes, nil
+}
+
+// write replaces the goldens of the test
+func write(dir string, name string, frames []Frame) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	old, _ := filepath.Glob(filepath.Join(dir, name+"_*.png"))
+	for _, file := range old {
+		if err := os.Remove(file); err != nil {
+			return err
+		}
+	}
+	hashes := map[int]string{}
+	for _, frame := range frames {
+		hashes[frame.Number] = hashString(frame.Hash)
+		if err := writePNG(filepath.Join(dir, fmt.Sprintf("%s_%d.png", name, frame.Number)), frame.Image); err != nil {
+			return err
+		}
+	}
+	data, err := json.MarshalIndent(hashes, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, name+".json"), append(data, '\n'), 0644)
+}
+
+// dump writes the picture of a differing frame and the diff against the golden picture, if there is one
+func dump(dir string, out string, name string, frame Frame) error {
+	if err := os.MkdirAll(out, 0755); err != nil {
+		return err
+	}
+	base := fmt.Sprintf("%s_%d", name, frame.Number)
+	if err := writePNG(filepath.Join(out, base+".png"), frame.Image); err != nil {
+		return err
+	}
+	golden, err := readPNG(filepath.Join(dir, base+".png"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return writePNG(filepath.Join(out, base+"_diff.png"), Diff(golden, frame.Image))
+}
+
+// Diff returns an image that shows the differing pixels in red on top of a darkened copy of the golden image
+func Diff(golden image.Image, got image.Image) *image.RGBA {
+	bounds := golden.Bounds().Union(got.Bounds())
+	diff := image.NewRGBA(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			g := color.RGBAModel.Convert(golden.At(x, y)).(color.RGBA)
+			if g == color.RGBAModel.Convert(got.At(x, y)).(color.RGBA) {
+				diff.SetRGBA(x, y, color.RGBA{R: g.R / 4, G: g.G / 4, B: g.B / 4, A: 0xFF})
+			} else {
+				diff.SetRGBA(x, y, color.RGBA{R: 0xFF, A: 0xFF})
+			}
+		}
+	}
+	return diff
+}
+
+func writePNG(file string, img image.Image) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer plz.Close(f)
+	return png.Encode(f, img)
+}
+
+func readPNG(file string) (image.Image, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer plz.Close(f)
+	return png.Decode(f)
+}
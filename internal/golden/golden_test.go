@@ -0,0 +1,75 @@
+package golden
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/exp625/gones/internal/testrom"
+	"github.com/exp625/gones/pkg/console"
+)
+
+var update = flag.Bool("update-goldens", false, "replace the golden hashes and pictures in testdata")
+
+var test = Test{
+	Name:    "testrom",
+	Frames:  90,
+	Capture: []int{1, 30, 60, 90},
+	Input: []Input{
+		{Frame: 20, Port: 0, Buttons: []string{"RIGHT", "a"}},
+		{Frame: 50, Port: 0, Buttons: []string{"DOWN"}},
+		{Frame: 70, Port: 0},
+	},
+}
+
+func run(t *testing.T, test Test) []Frame {
+	c := console.New(44100)
+	if err := c.LoadROM(testrom.ROM()); err != nil {
+		t.Fatal(err)
+	}
+	frames, err := Run(c, test)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != len(test.Capture) {
+		t.Fatalf("expected %d frames, got %d", len(test.Capture), len(frames))
+	}
+	return frames
+}
+
+func TestGolden(t *testing.T) {
+	frames := run(t, test)
+	if err := Check("testdata", t.TempDir(), test.Name, frames, *update); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMismatch(t *testing.T) {
+	changed := test
+	changed.Input = changed.Input[:1]
+	frames := run(t, changed)
+	out := t.TempDir()
+	err := Check("testdata", out, test.Name, frames, false)
+	var mismatch *MismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("expected mismatch, got %v", err)
+	}
+	// The first frames are equal, the input changes in frame 50
+	if len(mismatch.Frames) != 2 || mismatch.Frames[0] != 60 || mismatch.Frames[1] != 90 {
+		t.Fatalf("unexpected differing frames %v", mismatch.Frames)
+	}
+	for _, file := range []string{"testrom_60.png", "testrom_60_diff.png", "testrom_90.png", "testrom_90_diff.png"} {
+		if _, err := os.Stat(filepath.Join(out, file)); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestNoGolden(t *testing.T) {
+	frames := run(t, test)
+	if err := Check("testdata", t.TempDir(), "missing", frames, false); !errors.Is(err, ErrNoGolden) {
+		t.Fatalf("expected ErrNoGolden, got %v", err)
+	}
+}
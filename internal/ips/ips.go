@@ -0,0 +1,109 @@
+// Package ips creates and applies patches in the IPS format. Patches are used to store the changes to a file without
+// modifying the file itself.
+//
+// An IPS patch starts with "PATCH" and ends with "EOF". In between, every record has a 3-byte offset and a 2-byte
+// size, both big endian, followed by the data. A record with a size of 0 is a run: a 2-byte count and the byte to
+// repeat.
+package ips
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// maxSize is the size of a file that can be addressed by the 3-byte offsets
+const maxSize = 0x1000000
+
+// maxRecord is the largest number of bytes in a record
+const maxRecord = 0xFFFF
+
+// eofOffset is the offset that can not be used in a record, because it reads as "EOF"
+const eofOffset = 0x454F46
+
+var (
+	header = []byte("PATCH")
+	footer = []byte("EOF")
+)
+
+// ErrInvalidPatch is returned by Apply if the patch is not a valid IPS patch
+var ErrInvalidPatch = errors.New("invalid IPS patch")
+
+// Diff returns a patch that turns original into modified. Bytes of modified after the end of original are always
+// written to the patch. The modified file must not be shorter than the original file and must be smaller than 16 MB.
+func Diff(original []byte, modified []byte) ([]byte, error) {
+	if len(modified) < len(original) {
+		return nil, fmt.Errorf("modified file is shorter than the original file (%d < %d bytes)", len(modified), len(original))
+	}
+	if len(modified) > maxSize {
+		return nil, fmt.Errorf("file with %d bytes is too large for an IPS patch", len(modified))
+	}
+
+	patch := append([]byte{}, header...)
+	differs := func(i int) bool {
+		return i >= len(original) || original[i] != modified[i]
+	}
+	for i := 0; i < len(modified); {
+		if !differs(i) {
+			i++
+			continue
+		}
+		start := i
+		if start == eofOffset {
+			// Start one byte earlier, the unchanged byte is written again
+			start--
+		}
+		end := i
+		for end < len(modified) && end-start < maxRecord && differs(end) {
+			end++
+		}
+		patch = append(patch, uint8(start>>16), uint8(start>>8), uint8(start))
+		patch = append(patch, uint8((end-start)>>8), uint8(end-start))
+		patch = append(patch, modified[start:end]...)
+		i = end
+	}
+	return append(patch, footer...), nil
+}
+
+// Apply returns a copy of original with the patch applied. The copy is extended if the patch writes beyond the end of
+// the original. Returns ErrInvalidPatch if the patch is malformed.
+func Apply(original []byte, patch []byte) ([]byte, error) {
+	if !bytes.HasPrefix(patch, header) {
+		return nil, fmt.Errorf("%w: missing header", ErrInvalidPatch)
+	}
+	patched := append([]byte{}, original...)
+	write := func(offset int, data []byte) {
+		if end := offset + len(data); end > len(patched) {
+			patched = append(patched, make([]byte, end-len(patched))...)
+		}
+		copy(patched[offset:], data)
+	}
+
+	ptr := len(header)
+	for {
+		if bytes.Equal(patch[ptr:], footer) {
+			return patched, nil
+		}
+		if len(patch) < ptr+5 {
+			return nil, fmt.Errorf("%w: truncated record at %d", ErrInvalidPatch, ptr)
+		}
+		offset := int(patch[ptr])<<16 | int(patch[ptr+1])<<8 | int(patch[ptr+2])
+		size := int(patch[ptr+3])<<8 | int(patch[ptr+4])
+		ptr += 5
+		if size == 0 {
+			// Run of a single byte
+			if len(patch) < ptr+3 {
+				return nil, fmt.Errorf("%w: truncated run at %d", ErrInvalidPatch, ptr)
+			}
+			count := int(patch[ptr])<<8 | int(patch[ptr+1])
+			write(offset, bytes.Repeat(patch[ptr+2:ptr+3], count))
+			ptr += 3
+			continue
+		}
+		if len(patch) < ptr+size {
+			return nil, fmt.Errorf("%w: truncated record at %d", ErrInvalidPatch, ptr)
+		}
+		write(offset, patch[ptr:ptr+size])
+		ptr += size
+	}
+}
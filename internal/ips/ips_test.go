@@ -0,0 +1,90 @@
+package ips
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestDiffApply(t *testing.T) {
+	original := make([]byte, 0x20000)
+	for i := range original {
+		original[i] = byte(i / 7)
+	}
+	modified := append([]byte{}, original...)
+	modified[0] ^= 0xFF
+	modified[100] ^= 0xFF
+	modified[101] ^= 0xFF
+	// Longer than a record
+	for i := 0x1000; i < 0x1000+0x12345; i++ {
+		modified[i] ^= 0x55
+	}
+	modified = append(modified, 1, 2, 3)
+
+	patch, err := Diff(original, modified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(patch, []byte("PATCH")) || !bytes.HasSuffix(patch, []byte("EOF")) {
+		t.Fatal("patch lacks header or footer")
+	}
+	if len(patch) > 0x12345+100 {
+		t.Errorf("patch is too large: %d bytes", len(patch))
+	}
+	patched, err := Apply(original, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(patched, modified) {
+		t.Error("patched file differs from the modified file")
+	}
+
+	// No changes
+	patch, err = Diff(original, original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(patch) != "PATCHEOF" {
+		t.Errorf("expected an empty patch, got %q", patch)
+	}
+}
+
+func TestDiffEOFOffset(t *testing.T) {
+	original := make([]byte, eofOffset+16)
+	modified := append([]byte{}, original...)
+	modified[eofOffset] = 1
+	patch, err := Diff(original, modified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The record must not start with "EOF"
+	if !bytes.Equal(patch[5:8], []byte{0x45, 0x4F, 0x45}) {
+		t.Errorf("expected the record to start at $454F45, got % X", patch[5:8])
+	}
+	patched, err := Apply(original, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(patched, modified) {
+		t.Error("patched file differs from the modified file")
+	}
+}
+
+func TestApplyRun(t *testing.T) {
+	patch := []byte("PATCH\x00\x00\x02\x00\x00\x00\x04\xAAEOF")
+	patched, err := Apply([]byte{1, 2, 3}, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(patched, []byte{1, 2, 0xAA, 0xAA, 0xAA, 0xAA}) {
+		t.Errorf("unexpected result % X", patched)
+	}
+}
+
+func TestApplyInvalid(t *testing.T) {
+	for _, patch := range []string{"", "PATC", "PATCH", "PATCH\x00\x00\x01\x00\x05\x01EOF"} {
+		if _, err := Apply([]byte{1, 2, 3}, []byte(patch)); !errors.Is(err, ErrInvalidPatch) {
+			t.Errorf("%q: expected ErrInvalidPatch, got %v", patch, err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package rewind
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"log"
+)
+
+// Buffer is a ring buffer of machine snapshots. Only the newest snapshot is kept in full. Every other snapshot is
+// stored as the compressed difference (XOR) to its successor. Consecutive snapshots differ in only a few bytes, so the
+// differences compress very well. If the memory budget is exceeded, the oldest snapshots are dropped.
+type Buffer struct {
+	// Memory budget in bytes
+	budget int
+	// Bytes used by the compressed differences
+	size int
+
+	// Newest snapshot
+	current []byte
+	// Compressed differences to the previous snapshot, oldest first. The first difference is never applied, it only
+	// keeps the number of entries equal to the number of snapshots.
+	deltas [][]byte
+
+	scratch    []byte
+	compressed bytes.Buffer
+	writer     *flate.Writer
+}
+
+// New creates a new rewind buffer that uses up to budget bytes
+func New(budget int) *Buffer {
+	writer, err := flate.NewWriter(nil, flate.BestSpeed)
+	if err != nil {
+		// Only returned for invalid compression levels
+		panic(err)
+	}
+	return &Buffer{
+		budget: budget,
+		writer: writer,
+	}
+}
+
+// Len returns the number of snapshots in the buffer
+func (b *Buffer) Len() int {
+	return len(b.deltas)
+}
+
+// Size returns the number of bytes used by the buffer
+func (b *Buffer) Size() int {
+	return b.size + len(b.current)
+}
+
+// Clear removes all snapshots
+func (b *Buffer) Clear() {
+	b.current = nil
+	b.deltas = nil
+	b.size = 0
+}
+
+// Push adds a new snapshot to the buffer. The buffer keeps its own copy of the snapshot.
+func (b *Buffer) Push(state []byte) {
+	if len(state) != len(b.current) {
+		// Snapshots of different size can not be diffed, e.g. after a new cartridge has been inserted
+		b.Clear()
+	}
+
+	// Difference to the previous snapshot
+	if cap(b.scratch) < len(state) {
+		b.scratch = make([]byte, len(state))
+	}
+	b.scratch = b.scratch[:len(state)]
+	for i := range state {
+		if b.current != nil {
+			b.scratch[i] = state[i] ^ b.current[i]
+		} else {
+			b.scratch[i] = state[i]
+		}
+	}
+
+	b.compressed.Reset()
+	b.writer.Reset(&b.compressed)
+	if _, err := b.writer.Write(b.scratch); err != nil {
+		log.Println("could not compress snapshot: ", err.Error())
+		return
+	}
+	if err := b.writer.Close(); err != nil {
+		log.Println("could not compress snapshot: ", err.Error())
+		return
+	}
+	delta := append([]byte{}, b.compressed.Bytes()...)
+	b.deltas = append(b.deltas, delta)
+	b.size += len(delta)
+
+	if b.current == nil {
+		b.current = make([]byte, len(state))
+	}
+	copy(b.current, state)
+
+	// Drop the oldest snapshots until the buffer fits into the memory budget. The newest snapshot is always kept.
+	for b.Size() > b.budget && len(b.deltas) > 1 {
+		b.size -= len(b.deltas[0])
+		b.deltas[0] = nil
+		b.deltas = b.deltas[1:]
+	}
+}
+
+// Pop removes the newest snapshot from the buffer and returns it. Returns false if the buffer is empty.
+func (b *Buffer) Pop() ([]byte, bool) {
+	if len(b.deltas) == 0 {
+		return nil, false
+	}
+	state := b.current
+	last := len(b.deltas) - 1
+	delta := b.deltas[last]
+	b.deltas[last] = nil
+	b.deltas = b.deltas[:last]
+	b.size -= len(delta)
+
+	if len(b.deltas) == 0 {
+		b.current = nil
+		return state, true
+	}
+
+	// Restore the previous snapshot by applying the difference to the newest snapshot
+	previous := make([]byte, len(state))
+	reader := flate.NewReader(bytes.NewReader(delta))
+	if _, err := io.ReadFull(reader, previous); err != nil {
+		log.Println("could not decompress snapshot: ", err.Error())
+		b.Clear()
+		return state, true
+	}
+	for i := range previous {
+		previous[i] ^= state[i]
+	}
+	b.current = previous
+	return state, true
+}
@@ -0,0 +1,59 @@
+package rewind
+
+import (
+	"bytes"
+	"testing"
+)
+
+func snapshot(n int) []byte {
+	state := make([]byte, 4096)
+	for i := range state {
+		state[i] = byte(i / 64)
+	}
+	// A few bytes change from snapshot to snapshot
+	state[n%len(state)] = byte(n)
+	state[(n*31)%len(state)] = byte(n >> 8)
+	return state
+}
+
+func TestPushPop(t *testing.T) {
+	b := New(1 << 20)
+	for n := 0; n < 100; n++ {
+		b.Push(snapshot(n))
+	}
+	if b.Len() != 100 {
+		t.Fatalf("expected 100 snapshots, got %d", b.Len())
+	}
+	for n := 99; n >= 0; n-- {
+		state, ok := b.Pop()
+		if !ok {
+			t.Fatalf("buffer empty at snapshot %d", n)
+		}
+		if !bytes.Equal(state, snapshot(n)) {
+			t.Fatalf("snapshot %d differs", n)
+		}
+	}
+	if _, ok := b.Pop(); ok {
+		t.Fatal("expected empty buffer")
+	}
+}
+
+func TestBudget(t *testing.T) {
+	b := New(8192)
+	for n := 0; n < 1000; n++ {
+		b.Push(snapshot(n))
+		if b.Size() > 8192 && b.Len() > 1 {
+			t.Fatalf("buffer exceeds budget: %d bytes", b.Size())
+		}
+	}
+	if b.Len() >= 1000 || b.Len() < 2 {
+		t.Fatalf("unexpected number of snapshots: %d", b.Len())
+	}
+	// The newest snapshots are kept
+	for n := 999; b.Len() > 0; n-- {
+		state, _ := b.Pop()
+		if !bytes.Equal(state, snapshot(n)) {
+			t.Fatalf("snapshot %d differs", n)
+		}
+	}
+}
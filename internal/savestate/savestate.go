@@ -0,0 +1,171 @@
+package savestate
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+var (
+	// ErrTruncated is returned if the state ends before all values have been read
+	ErrTruncated = errors.New("save state is truncated")
+	// ErrTrailingData is returned if the state contains more data than was read
+	ErrTrailingData = errors.New("save state contains trailing data")
+)
+
+// Serializer writes or reads the state of the emulator. Every component implements a single Serialize method that
+// passes pointers to its fields to the serializer. When saving, the values are appended to the state. When loading,
+// the values are overwritten with the values read from the state. That way the order of the fields in the save and
+// in the load path can never differ.
+type Serializer struct {
+	loading bool
+	data    []byte
+	pos     int
+	err     error
+}
+
+// NewWriter creates a serializer that saves the state
+func NewWriter() *Serializer {
+	return &Serializer{}
+}
+
+// NewReader creates a serializer that loads the state from data
+func NewReader(data []byte) *Serializer {
+	return &Serializer{loading: true, data: data}
+}
+
+// Loading returns true if the serializer loads a state
+func (s *Serializer) Loading() bool {
+	return s.loading
+}
+
+// Data returns the saved state
+func (s *Serializer) Data() []byte {
+	return s.data
+}
+
+// Remaining returns the number of bytes that have not been read yet
+func (s *Serializer) Remaining() int {
+	return len(s.data) - s.pos
+}
+
+// Err returns the first error that occurred while loading
+func (s *Serializer) Err() error {
+	return s.err
+}
+
+// Finish checks that the whole state has been read. Returns the first error that occurred.
+func (s *Serializer) Finish() error {
+	if s.err == nil && s.loading && s.pos != len(s.data) {
+		s.err = ErrTrailingData
+	}
+	return s.err
+}
+
+// next returns the next n bytes of the state or nil if the state is truncated
+func (s *Serializer) next(n int) []byte {
+	if s.err != nil {
+		return nil
+	}
+	if s.pos+n > len(s.data) {
+		s.err = ErrTruncated
+		return nil
+	}
+	b := s.data[s.pos : s.pos+n]
+	s.pos += n
+	return b
+}
+
+func (s *Serializer) Uint8(v *uint8) {
+	if !s.loading {
+		s.data = append(s.data, *v)
+		return
+	}
+	if b := s.next(1); b != nil {
+		*v = b[0]
+	}
+}
+
+func (s *Serializer) Uint16(v *uint16) {
+	if !s.loading {
+		s.data = append(s.data, byte(*v), byte(*v>>8))
+		return
+	}
+	if b := s.next(2); b != nil {
+		*v = binary.LittleEndian.Uint16(b)
+	}
+}
+
+func (s *Serializer) Uint32(v *uint32) {
+	if !s.loading {
+		var b [4]byte
+		binary.LittleEndian.PutUint32(b[:], *v)
+		s.data = append(s.data, b[:]...)
+		return
+	}
+	if b := s.next(4); b != nil {
+		*v = binary.LittleEndian.Uint32(b)
+	}
+}
+
+func (s *Serializer) Uint64(v *uint64) {
+	if !s.loading {
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], *v)
+		s.data = append(s.data, b[:]...)
+		return
+	}
+	if b := s.next(8); b != nil {
+		*v = binary.LittleEndian.Uint64(b)
+	}
+}
+
+func (s *Serializer) Int32(v *int32) {
+	value := uint32(*v)
+	s.Uint32(&value)
+	*v = int32(value)
+}
+
+func (s *Serializer) Int64(v *int64) {
+	value := uint64(*v)
+	s.Uint64(&value)
+	*v = int64(value)
+}
+
+// Int stores an int as 64 bit value, independent of the size of int on the platform
+func (s *Serializer) Int(v *int) {
+	value := int64(*v)
+	s.Int64(&value)
+	*v = int(value)
+}
+
+func (s *Serializer) Float64(v *float64) {
+	bits := math.Float64bits(*v)
+	s.Uint64(&bits)
+	*v = math.Float64frombits(bits)
+}
+
+func (s *Serializer) Bool(v *bool) {
+	var b uint8
+	if *v {
+		b = 1
+	}
+	s.Uint8(&b)
+	*v = b != 0
+}
+
+// Bytes stores a byte slice of fixed length. When loading, the slice is filled with len(v) bytes of the state.
+func (s *Serializer) Bytes(v []byte) {
+	if !s.loading {
+		s.data = append(s.data, v...)
+		return
+	}
+	if b := s.next(len(v)); b != nil {
+		copy(v, b)
+	}
+}
+
+// Serializable is implemented by every component that is part of the save state
+type Serializable interface {
+	Serialize(s *Serializer)
+}
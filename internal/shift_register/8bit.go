@@ -1,5 +1,7 @@
 package shift_register
 
+import "github.com/exp625/gones/internal/savestate"
+
 type ShiftRegister8 struct {
 	register uint8
 }
@@ -37,3 +39,7 @@ func (r *ShiftRegister8) GetBit(index uint8) uint8 {
 	}
 	return 0
 }
+
+func (r *ShiftRegister8) Serialize(s *savestate.Serializer) {
+	s.Uint8(&r.register)
+}
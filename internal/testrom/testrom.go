@@ -0,0 +1,153 @@
+package testrom
+
+// program fills the palette and the nametables, enables rendering and sound and then keeps changing the scroll
+// position, the sprites and the pulse channel every frame. The buttons of controller 1 are added to the scroll
+// position, so the picture depends on the input.
+var program = []byte{
+	0x78,       // SEI
+	0xD8,       // CLD
+	0xA2, 0xFF, // LDX #$FF
+	0x9A,             // TXS
+	0x2C, 0x02, 0x20, // BIT $2002
+	0x10, 0xFB, // BPL -5
+	0x2C, 0x02, 0x20, // BIT $2002
+	0x10, 0xFB, // BPL -5
+	0xA9, 0x3F, // LDA #$3F
+	0x8D, 0x06, 0x20, // STA $2006
+	0xA9, 0x00, // LDA #$00
+	0x8D, 0x06, 0x20, // STA $2006
+	0xA2, 0x00, // LDX #$00
+	0x8A,             // TXA
+	0x8D, 0x07, 0x20, // STA $2007
+	0xE8,       // INX
+	0xE0, 0x20, // CPX #$20
+	0xD0, 0xF7, // BNE -9
+	0xA9, 0x20, // LDA #$20
+	0x8D, 0x06, 0x20, // STA $2006
+	0xA9, 0x00, // LDA #$00
+	0x8D, 0x06, 0x20, // STA $2006
+	0xA0, 0x04, // LDY #$04
+	0xA2, 0x00, // LDX #$00
+	0x8A,             // TXA
+	0x8D, 0x07, 0x20, // STA $2007
+	0xE8,       // INX
+	0xD0, 0xF9, // BNE -7
+	0x88,       // DEY
+	0xD0, 0xF6, // BNE -10
+	0xA9, 0x01, // LDA #$01
+	0x8D, 0x15, 0x40, // STA $4015
+	0xA9, 0xBF, // LDA #$BF
+	0x8D, 0x00, 0x40, // STA $4000
+	0xA9, 0x00, // LDA #$00
+	0x8D, 0x03, 0x40, // STA $4003
+	0xA9, 0x80, // LDA #$80
+	0x8D, 0x00, 0x20, // STA $2000
+	0xA9, 0x1E, // LDA #$1E
+	0x8D, 0x01, 0x20, // STA $2001
+	0xE6, 0x10, // main: INC $10
+	0xA6, 0x11, // LDX $11
+	0xA5, 0x10, // LDA $10
+	0x9D, 0x00, 0x02, // STA $0200,X
+	0x4C, 0x55, 0xC0, // JMP main
+	0x48,       // nmi: PHA
+	0xA9, 0x01, // LDA #$01
+	0x8D, 0x16, 0x40, // STA $4016
+	0xA9, 0x00, // LDA #$00
+	0x8D, 0x16, 0x40, // STA $4016
+	0xA2, 0x08, // LDX #$08
+	0xAD, 0x16, 0x40, // LDA $4016
+	0x4A,       // LSR A
+	0x26, 0x12, // ROL $12
+	0xCA,       // DEX
+	0xD0, 0xF7, // BNE -9
+	0xE6, 0x11, // INC $11
+	0xA5, 0x11, // LDA $11
+	0x18,       // CLC
+	0x65, 0x12, // ADC $12
+	0x85, 0x11, // STA $11
+	0x8D, 0x05, 0x20, // STA $2005
+	0x8D, 0x05, 0x20, // STA $2005
+	0x8D, 0x02, 0x40, // STA $4002
+	0xA9, 0x02, // LDA #$02
+	0x8D, 0x14, 0x40, // STA $4014
+	0x68, // PLA
+	0x40, // irq: RTI
+}
+
+// Vectors of the program
+const (
+	nmi   = 0xC061
+	reset = 0xC000
+	irq   = 0xC08F
+)
+
+// ROM builds an iNES file with mapper 0 for tests. It has one 16 KB PRG ROM bank containing a small test program and
+// one 8 KB CHR ROM bank filled with a pattern.
+func ROM() []byte {
+	rom := make([]byte, 0x10+0x4000+0x2000)
+	copy(rom, "NES\x1a")
+	rom[4] = 1
+	rom[5] = 1
+	prg := rom[0x10 : 0x10+0x4000]
+	copy(prg, program)
+	copy(prg[0x3FFA:], []byte{nmi & 0xFF, nmi >> 8, reset & 0xFF, reset >> 8, irq & 0xFF, irq >> 8})
+	chr := rom[0x10+0x4000:]
+	for i := range chr {
+		chr[i] = byte(i*7) ^ byte(i>>5)
+	}
+	return rom
+}
+
+// blarggProgram reports a result with the protocol of the blargg test ROMs. It writes the signature, the text and
+// the running status to $6000-$6004. If less than the configured number of resets have been requested, it requests
+// another reset and waits. Otherwise, it writes the configured status and stops. The number of requested resets is
+// counted at $6100, which keeps its value during a reset.
+var blarggProgram = []byte{
+	0x78,       // SEI
+	0xD8,       // CLD
+	0xA2, 0xFF, // LDX #$FF
+	0x9A,       // TXS
+	0xA9, 0xDE, // LDA #$DE
+	0x8D, 0x01, 0x60, // STA $6001
+	0xA9, 0xB0, // LDA #$B0
+	0x8D, 0x02, 0x60, // STA $6002
+	0xA9, 0x61, // LDA #$61
+	0x8D, 0x03, 0x60, // STA $6003
+	0xA9, 0x80, // LDA #$80
+	0x8D, 0x00, 0x60, // STA $6000
+	0xA2, 0x00, // LDX #$00
+	0xBD, 0x44, 0xC0, // copy: LDA text,X
+	0x9D, 0x04, 0x60, // STA $6004,X
+	0xF0, 0x03, // BEQ +3
+	0xE8,       // INX
+	0xD0, 0xF5, // BNE copy
+	0xAD, 0x00, 0x61, // LDA $6100
+	0xCD, 0x42, 0xC0, // CMP resets
+	0xB0, 0x0B, // BCS done
+	0xEE, 0x00, 0x61, // INC $6100
+	0xA9, 0x81, // LDA #$81
+	0x8D, 0x00, 0x60, // STA $6000
+	0x4C, 0x36, 0xC0, // wait: JMP wait
+	0xAD, 0x43, 0xC0, // done: LDA status
+	0x8D, 0x00, 0x60, // STA $6000
+	0x4C, 0x3F, 0xC0, // stop: JMP stop
+}
+
+// Blargg builds an iNES file with mapper 0 that reports the given status and text like the blargg test ROMs after
+// requesting the given number of resets. A status of 0x80 keeps the test running forever.
+func Blargg(status uint8, resets uint8, text string) []byte {
+	rom := make([]byte, 0x10+0x4000+0x2000)
+	copy(rom, "NES\x1a")
+	rom[4] = 1
+	rom[5] = 1
+	prg := rom[0x10 : 0x10+0x4000]
+	copy(prg, blarggProgram)
+	// Data of the program: resets, status and the zero terminated text
+	prg[0x42] = resets
+	prg[0x43] = status
+	copy(prg[0x44:0xFE], text)
+	// The interrupts are never enabled
+	prg[0xFF] = 0x40 // RTI
+	copy(prg[0x3FFA:], []byte{0xFF, 0xC0, reset & 0xFF, reset >> 8, 0xFF, 0xC0})
+	return rom
+}
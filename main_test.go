@@ -4,8 +4,13 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"github.com/exp625/gones/internal/blargg"
+	"github.com/exp625/gones/internal/golden"
 	"github.com/exp625/gones/internal/plz"
+	"github.com/exp625/gones/pkg/console"
 	"github.com/exp625/gones/pkg/cpu"
 	"github.com/exp625/gones/pkg/emulator"
 	"github.com/exp625/gones/pkg/logger"
@@ -13,18 +18,32 @@ import (
 	"log"
 	"os"
 	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
 )
 
+var (
+	updateGoldens = flag.Bool("update-goldens", false, "replace the golden frames in test/golden with the current output")
+	goldenOutput  = flag.String("golden-output", "test/golden/failed", "directory for the pictures of differing frames")
+)
+
+// ProtocolBlargg marks tests that report their result at $6000, see package blargg. For these tests, frames is the
+// timeout and output is not used.
+const ProtocolBlargg = "blargg"
+
 type TestConfig struct {
 	Tests []struct {
-		Rom     string `json:"rom"`
-		Frames  int    `json:"frames"`
-		Output  string `json:"output"`
-		Results []struct {
+		Rom      string `json:"rom"`
+		Protocol string `json:"protocol"`
+		// Submapper converts the iNES header of the ROM to a NES 2.0 header with the submapper, e.g. to select the
+		// revision of a chip
+		Submapper *uint8 `json:"submapper"`
+		Frames    int    `json:"frames"`
+		Output    string `json:"output"`
+		Results   []struct {
 			Code    int    `json:"code"`
 			Pass    bool   `json:"pass"`
 			Message string `json:"message"`
@@ -162,7 +181,16 @@ func TestFromConfig(t *testing.T) {
 			rom := strings.Split(test.Rom, "/")
 			testname := fmt.Sprintf("Test_%s_%s", rom[len(rom)-2], rom[len(rom)-1])
 			t.Run(testname, func(t *testing.T) {
-				e, err := emulator.New("./test/"+test.Rom, false)
+				if test.Protocol == ProtocolBlargg {
+					runBlarggTest(t, "./test/"+test.Rom, test.Frames)
+					return
+				}
+
+				romFile := "./test/" + test.Rom
+				if test.Submapper != nil {
+					romFile = withSubmapper(t, romFile, *test.Submapper)
+				}
+				e, err := emulator.New(romFile, false)
 				if err != nil {
 					t.Fatal(err)
 				}
@@ -199,3 +227,92 @@ func TestFromConfig(t *testing.T) {
 		}
 	}
 }
+
+// withSubmapper writes a copy of the ROM with a NES 2.0 header and the submapper and returns its file name. The
+// ROM gets 8 KB of PRG RAM and 8 KB of CHR RAM if it has no CHR ROM.
+func withSubmapper(t *testing.T, romFile string, submapper uint8) string {
+	rom, err := os.ReadFile(romFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rom[7] = rom[7]&0b1111_0011 | 0b1000
+	rom[8] = submapper << 4
+	rom[9] = 0
+	rom[10] = 0x07
+	rom[11] = 0
+	if rom[5] == 0 {
+		rom[11] = 0x07
+	}
+	copy(rom[12:16], []byte{0, 0, 0, 0})
+	patched := filepath.Join(t.TempDir(), filepath.Base(romFile))
+	if err := os.WriteFile(patched, rom, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return patched
+}
+
+// runBlarggTest runs a test ROM that follows the blargg protocol. The text output of the ROM is the failure message.
+func runBlarggTest(t *testing.T, romFile string, timeout int) {
+	rom, err := os.ReadFile(romFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := console.New(emulator.AudioSampleRate)
+	if err := c.LoadROM(rom); err != nil {
+		t.Fatal(err)
+	}
+	result, err := blargg.Run(c, timeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Passed() {
+		t.Fatalf("failed with code %d:\n%s", result.Status, result.Text)
+	}
+}
+
+// TestGoldenFrames runs the ROMs listed in test/golden/tests.json and compares the captured frames against the golden
+// hashes. Every test needs recorded goldens. Run with -update-goldens to record the goldens of a new test or after an
+// intended change of the rendering.
+func TestGoldenFrames(t *testing.T) {
+	const dir = "test/golden"
+	data, err := os.ReadFile(filepath.Join(dir, "tests.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config struct {
+		Tests []golden.Test `json:"tests"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range config.Tests {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			rom, err := os.ReadFile(filepath.Join("test", test.Rom))
+			if os.IsNotExist(err) {
+				t.Fatal(err.Error() + ", check out the test ROMs with git submodule update --init")
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := console.New(emulator.AudioSampleRate)
+			if err := c.LoadROM(rom); err != nil {
+				t.Fatal(err)
+			}
+			if test.Movie != "" {
+				test.Movie = filepath.Join(dir, test.Movie)
+			}
+			frames, err := golden.Run(c, test)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = golden.Check(dir, *goldenOutput, test.Name, frames, *updateGoldens)
+			if errors.Is(err, golden.ErrNoGolden) {
+				t.Fatal(err.Error() + ", run with -update-goldens to record them")
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
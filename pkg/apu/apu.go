@@ -1,31 +1,279 @@
 package apu
 
-import "github.com/exp625/gones/pkg/bus"
+import (
+	"math"
 
+	"github.com/exp625/gones/internal/blip"
+	"github.com/exp625/gones/internal/savestate"
+	"github.com/exp625/gones/pkg/bus"
+)
+
+// Number of samples the audio buffer can hold before the oldest samples are dropped
+const audioBufferSize = 4096
+
+// APU or Audio Processing Unit, generates the sound of the NES.
+// The APU is clocked with the CPU frequency. Most of its units are clocked on every second CPU cycle (one APU cycle).
 type APU struct {
 	Cycle uint64
 
+	Pulse1   Pulse
+	Pulse2   Pulse
+	Triangle Triangle
+	Noise    Noise
+	DMC      DMC
+
+	FrameCounter FrameCounter
+
+	// Expansion is the sound chip of the cartridge, nil if the cartridge has none
+	Expansion ExpansionAudio
+
 	Bus bus.Bus
+
+	// The mixer output is resampled to the sample rate of the host and passed through the filters of the NES
+	audioBuffer *blip.Buffer
+	audioLevel  int32
+	audioSample int32
+	filters     [3]filter
 }
 
+// ExpansionAudio is a sound chip on the cartridge. The Famicom mixes its output with the APU channels. The NES only
+// passes it to the expansion port, but it is mixed as well, like on a Famicom.
+type ExpansionAudio interface {
+	// ClockAudio is called on every CPU cycle
+	ClockAudio()
+	// AudioOutput returns the current output level in the scale of the APU mixer, where all APU channels at full volume
+	// add up to about 1.0
+	AudioOutput() float64
+}
+
+// PulseStep is the output level of one volume step of an APU pulse, the slope of the linear approximation of the pulse
+// mixer. Sound chips scale their output by it so that their loudness compares to the APU pulses, 15 * PulseStep is a
+// pulse at full volume.
+const PulseStep = 0.00752
+
+// New creates a new APU instance
 func New() *APU {
-	return &APU{}
+	return &APU{
+		Pulse1:      Pulse{OnesComplement: true},
+		Noise:       NewNoise(),
+		DMC:         NewDMC(),
+		audioBuffer: blip.New(audioBufferSize),
+	}
+}
+
+// SetSampleRate sets the clock rate of the APU (the CPU frequency) and the sample rate of the generated audio samples
+func (apu *APU) SetSampleRate(clockRate float64, sampleRate float64) {
+	apu.audioBuffer.SetRates(clockRate, sampleRate)
+	apu.filters = [3]filter{
+		newHighPassFilter(sampleRate, 90),
+		newHighPassFilter(sampleRate, 440),
+		newLowPassFilter(sampleRate, 14000),
+	}
+	apu.audioBuffer.Clear()
 }
 
+// Clock clocks the APU for one CPU cycle
 func (apu *APU) Clock() {
 	apu.Cycle++
-	if apu.Cycle > 14914*2 {
-		//apu.Bus.IRQ()
+
+	// The triangle and DMC timers are clocked on every CPU cycle
+	apu.Triangle.ClockTimer()
+	apu.DMC.ClockTimer()
+	if apu.Expansion != nil {
+		apu.Expansion.ClockAudio()
+	}
+
+	// The pulse and noise channel timers are clocked on every APU cycle
+	if apu.Cycle%2 == 0 {
+		apu.Pulse1.ClockTimer()
+		apu.Pulse2.ClockTimer()
+		apu.Noise.ClockTimer()
+	}
+
+	// The DMC memory reader fetches the next sample byte as soon as the sample buffer is empty.
+	// The CPU is stalled for up to 4 cycles while the byte is fetched.
+	if apu.DMC.NeedsSample() {
+		apu.Bus.Stall(4)
+		apu.DMC.FillSampleBuffer(apu.Bus.CPURead(apu.DMC.CurrentAddress))
+	}
+
+	// The frame counter drives the envelopes, sweeps and length counters
+	quarter, half := apu.FrameCounter.Clock()
+	if quarter {
+		apu.clockQuarterFrame()
 	}
-	if apu.Cycle == 14915*2 {
-		apu.Cycle = 0
+	if half {
+		apu.clockHalfFrame()
 	}
+
+	// The IRQ line stays asserted as long as one of the interrupt flags is set
+	if apu.FrameCounter.Interrupt || apu.DMC.Interrupt {
+		apu.Bus.IRQ()
+	}
+
+	// Only changes of the output level are passed to the audio buffer
+	level := int32(apu.mix() * math.MaxInt16)
+	if level != apu.audioLevel {
+		apu.audioBuffer.AddDelta(0, level-apu.audioLevel)
+		apu.audioLevel = level
+	}
+	apu.audioBuffer.EndFrame(1)
+}
+
+// clockQuarterFrame clocks the envelopes and the triangle's linear counter
+func (apu *APU) clockQuarterFrame() {
+	apu.Pulse1.Envelope.Clock()
+	apu.Pulse2.Envelope.Clock()
+	apu.Noise.Envelope.Clock()
+	apu.Triangle.ClockLinearCounter()
 }
 
+// clockHalfFrame clocks the length counters and sweep units
+func (apu *APU) clockHalfFrame() {
+	apu.Pulse1.LengthCounter.Clock()
+	apu.Pulse2.LengthCounter.Clock()
+	apu.Triangle.LengthCounter.Clock()
+	apu.Noise.LengthCounter.Clock()
+	apu.Pulse1.ClockSweep()
+	apu.Pulse2.ClockSweep()
+}
+
+// Reset silences all channels and resets the APU
 func (apu *APU) Reset() {
 	apu.Cycle = 0
+	apu.Pulse1.Reset()
+	apu.Pulse2.Reset()
+	apu.Triangle.Reset()
+	apu.Noise.Reset()
+	apu.DMC.Reset()
+	apu.FrameCounter.Reset()
+	apu.audioBuffer.Clear()
+	apu.audioLevel = 0
+}
+
+// CPUWrite performs a write operation coming from the cpu bus
+func (apu *APU) CPUWrite(location uint16, data uint8) {
+	switch {
+	case 0x4000 <= location && location <= 0x4003:
+		apu.Pulse1.Write(location-0x4000, data)
+	case 0x4004 <= location && location <= 0x4007:
+		apu.Pulse2.Write(location-0x4004, data)
+	case 0x4008 <= location && location <= 0x400B:
+		apu.Triangle.Write(location-0x4008, data)
+	case 0x400C <= location && location <= 0x400F:
+		apu.Noise.Write(location-0x400C, data)
+	case 0x4010 <= location && location <= 0x4013:
+		apu.DMC.Write(location-0x4010, data)
+	case location == 0x4015:
+		// Status ($4015 write)
+		// 7  bit  0
+		// ---- ----
+		// ---D NT21
+		//    | ||||
+		//    | |||+- Enable pulse 1 length counter
+		//    | ||+-- Enable pulse 2 length counter
+		//    | |+--- Enable triangle length counter
+		//    | +---- Enable noise length counter
+		//    +------ Enable DMC
+		// Writing a zero to any of the channel enable bits will silence that channel and immediately set its length
+		// counter to 0.
+		apu.Pulse1.LengthCounter.SetEnabled(data&0b1 == 0b1)
+		apu.Pulse2.LengthCounter.SetEnabled(data>>1&0b1 == 0b1)
+		apu.Triangle.LengthCounter.SetEnabled(data>>2&0b1 == 0b1)
+		apu.Noise.LengthCounter.SetEnabled(data>>3&0b1 == 0b1)
+		apu.DMC.SetEnabled(data>>4&0b1 == 0b1)
+	case location == 0x4017:
+		apu.FrameCounter.Write(data, apu.Cycle%2 == 1)
+	}
+}
+
+// ReadStatus performs a read of the status register $4015. Bit 5 is not driven by the APU and returns 0.
+func (apu *APU) ReadStatus() uint8 {
+	status := apu.PeekStatus()
+	// Reading this register clears the frame interrupt flag (but not the DMC interrupt flag)
+	apu.FrameCounter.Interrupt = false
+	return status
 }
 
+// PeekStatus returns the value of the status register $4015 without clearing the frame interrupt flag
+func (apu *APU) PeekStatus() uint8 {
+	// 7  bit  0
+	// ---- ----
+	// IF-D NT21
+	// || | ||||
+	// || | |||+- Pulse 1 length counter > 0
+	// || | ||+-- Pulse 2 length counter > 0
+	// || | |+--- Triangle length counter > 0
+	// || | +---- Noise length counter > 0
+	// || +------ DMC active (bytes remaining > 0)
+	// |+-------- Frame interrupt
+	// +--------- DMC interrupt
+	var status uint8
+	if apu.Pulse1.LengthCounter.Active() {
+		status |= 0b0000_0001
+	}
+	if apu.Pulse2.LengthCounter.Active() {
+		status |= 0b0000_0010
+	}
+	if apu.Triangle.LengthCounter.Active() {
+		status |= 0b0000_0100
+	}
+	if apu.Noise.LengthCounter.Active() {
+		status |= 0b0000_1000
+	}
+	if apu.DMC.BytesRemaining > 0 {
+		status |= 0b0001_0000
+	}
+	if apu.FrameCounter.Interrupt {
+		status |= 0b0100_0000
+	}
+	if apu.DMC.Interrupt {
+		status |= 0b1000_0000
+	}
+	return status
+}
+
+// SampleReady returns true if an audio sample can be read with GetAudioSample
+func (apu *APU) SampleReady() bool {
+	return apu.audioBuffer.SamplesAvailable() > 0
+}
+
+// GetAudioSample returns the next audio sample as signed 16 bit sample. If no new sample is available, the last sample
+// is repeated.
 func (apu *APU) GetAudioSample() int32 {
-	return 0
+	var sample [1]int16
+	if apu.audioBuffer.ReadSamples(sample[:]) == 0 {
+		return apu.audioSample
+	}
+	out := float64(sample[0])
+	for i := range apu.filters {
+		out = apu.filters[i].Step(out)
+	}
+	if out > math.MaxInt16 {
+		out = math.MaxInt16
+	} else if out < math.MinInt16 {
+		out = math.MinInt16
+	}
+	apu.audioSample = int32(out)
+	return apu.audioSample
+}
+
+// Serialize saves or loads the state of all channels, the frame counter and the output filters
+func (apu *APU) Serialize(s *savestate.Serializer) {
+	s.Uint64(&apu.Cycle)
+	apu.Pulse1.Serialize(s)
+	apu.Pulse2.Serialize(s)
+	apu.Triangle.Serialize(s)
+	apu.Noise.Serialize(s)
+	apu.DMC.Serialize(s)
+	apu.FrameCounter.Serialize(s)
+	for i := range apu.filters {
+		apu.filters[i].Serialize(s)
+	}
+	s.Int32(&apu.audioSample)
+	if s.Loading() {
+		// Samples that have not been read yet are dropped. The current output level is added again on the next clock.
+		apu.audioBuffer.Clear()
+		apu.audioLevel = 0
+	}
 }
@@ -0,0 +1,46 @@
+package apu
+
+import "testing"
+
+func TestStatus(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		writes [][2]uint16
+		status uint8
+	}{
+		{"silent", nil, 0b0000_0000},
+		{"pulse 1", [][2]uint16{{0x4015, 0x1F}, {0x4003, 0x08}}, 0b0000_0001},
+		{"pulse 2", [][2]uint16{{0x4015, 0x1F}, {0x4007, 0x08}}, 0b0000_0010},
+		{"triangle", [][2]uint16{{0x4015, 0x1F}, {0x400B, 0x08}}, 0b0000_0100},
+		{"noise", [][2]uint16{{0x4015, 0x1F}, {0x400F, 0x08}}, 0b0000_1000},
+		{"dmc", [][2]uint16{{0x4013, 0x01}, {0x4015, 0x10}}, 0b0001_0000},
+		{"all", [][2]uint16{
+			{0x4013, 0x01}, {0x4015, 0x1F}, {0x4003, 0x08}, {0x4007, 0x08}, {0x400B, 0x08}, {0x400F, 0x08},
+		}, 0b0001_1111},
+		// The length counters can only be loaded while the channel is enabled
+		{"disabled", [][2]uint16{{0x4003, 0x08}, {0x4007, 0x08}, {0x400B, 0x08}, {0x400F, 0x08}}, 0b0000_0000},
+		{"disabled after load", [][2]uint16{{0x4015, 0x1F}, {0x4003, 0x08}, {0x400F, 0x08}, {0x4015, 0x08}},
+			0b0000_1000},
+	} {
+		apu := New()
+		for _, write := range test.writes {
+			apu.CPUWrite(write[0], uint8(write[1]))
+		}
+		if status := apu.ReadStatus(); status != test.status {
+			t.Errorf("%s: expected the status %08b, got %08b", test.name, test.status, status)
+		}
+	}
+
+	// Both interrupt flags
+	apu := New()
+	apu.FrameCounter.Interrupt = true
+	apu.DMC.Interrupt = true
+	if status := apu.PeekStatus(); status != 0b1100_0000 {
+		t.Errorf("expected the status %08b, got %08b", 0b1100_0000, status)
+	}
+	// Reading clears the frame interrupt, but not the DMC interrupt
+	apu.ReadStatus()
+	if status := apu.PeekStatus(); status != 0b1000_0000 {
+		t.Errorf("expected the status %08b, got %08b", 0b1000_0000, status)
+	}
+}
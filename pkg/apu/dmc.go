@@ -0,0 +1,202 @@
+package apu
+
+import "github.com/exp625/gones/internal/savestate"
+
+// dmcRateTable contains the NTSC timer periods of the delta modulation channel in CPU cycles
+var dmcRateTable = [16]uint16{
+	428, 380, 340, 320, 286, 254, 226, 214, 190, 160, 142, 128, 106, 84, 72, 54,
+}
+
+// DMC is the delta modulation channel of the APU. It outputs 1-bit delta-encoded samples, read directly from the CPU
+// memory, or can be used to output 7-bit PCM data by writing to the output level directly.
+//
+// From NES DEV WIKI https://wiki.nesdev.org/w/index.php?title=APU_DMC
+type DMC struct {
+	IRQEnabled bool
+	Loop       bool
+	Interrupt  bool
+
+	Timer       uint16
+	TimerPeriod uint16
+
+	// Memory reader
+	SampleAddress  uint16
+	SampleLength   uint16
+	CurrentAddress uint16
+	BytesRemaining uint16
+	SampleBuffer   uint8
+	BufferEmpty    bool
+
+	// Output unit
+	ShiftRegister uint8
+	BitsRemaining uint8
+	Silence       bool
+	OutputLevel   uint8
+}
+
+// NewDMC creates a delta modulation channel with an empty sample buffer
+func NewDMC() DMC {
+	return DMC{
+		TimerPeriod:   dmcRateTable[0],
+		BufferEmpty:   true,
+		BitsRemaining: 8,
+		Silence:       true,
+	}
+}
+
+// Write handles a write to one of the registers of the channel mapped to $4010-$4013
+func (d *DMC) Write(register uint16, data uint8) {
+	switch register {
+	case 0:
+		// $4010
+		// 7  bit  0
+		// ---- ----
+		// IL-- RRRR
+		// ||   ||||
+		// ||   ++++- Rate index
+		// |+-------- Loop flag
+		// +--------- IRQ enabled flag. If clear, the interrupt flag is cleared.
+		d.IRQEnabled = data>>7 == 1
+		d.Loop = data>>6&0b1 == 1
+		d.TimerPeriod = dmcRateTable[data&0b1111]
+		if !d.IRQEnabled {
+			d.Interrupt = false
+		}
+	case 1:
+		// $4011
+		// 7  bit  0
+		// ---- ----
+		// -DDD DDDD
+		//  ||| ||||
+		//  +++-++++- Load counter
+		d.OutputLevel = data & 0b0111_1111
+	case 2:
+		// $4012
+		// 7  bit  0
+		// ---- ----
+		// AAAA AAAA
+		// |||| ||||
+		// ++++-++++- Sample address = %11AAAAAA.AA000000 = $C000 + (A * 64)
+		d.SampleAddress = 0xC000 | uint16(data)<<6
+	case 3:
+		// $4013
+		// 7  bit  0
+		// ---- ----
+		// LLLL LLLL
+		// |||| ||||
+		// ++++-++++- Sample length = %LLLL.LLLL0001 = (L * 16) + 1 bytes
+		d.SampleLength = uint16(data)<<4 | 1
+	}
+}
+
+// SetEnabled handles the DMC bit of a write to $4015
+func (d *DMC) SetEnabled(enabled bool) {
+	// Writing to this register clears the DMC interrupt flag.
+	d.Interrupt = false
+	if !enabled {
+		// If the DMC bit is clear, the DMC bytes remaining will be set to 0 and the DMC will silence when it
+		// empties.
+		d.BytesRemaining = 0
+	} else if d.BytesRemaining == 0 {
+		// If the DMC bit is set, the DMC sample will be restarted only if its bytes remaining is 0.
+		d.restart()
+	}
+}
+
+// restart starts the playback of the sample from the beginning
+func (d *DMC) restart() {
+	d.CurrentAddress = d.SampleAddress
+	d.BytesRemaining = d.SampleLength
+}
+
+// NeedsSample returns true if the memory reader has to fetch the next sample byte
+func (d *DMC) NeedsSample() bool {
+	return d.BufferEmpty && d.BytesRemaining > 0
+}
+
+// FillSampleBuffer is called with the byte the memory reader fetched from CurrentAddress
+func (d *DMC) FillSampleBuffer(data uint8) {
+	d.SampleBuffer = data
+	d.BufferEmpty = false
+	// The address is incremented; if it exceeds $FFFF, it is wrapped around to $8000.
+	if d.CurrentAddress == 0xFFFF {
+		d.CurrentAddress = 0x8000
+	} else {
+		d.CurrentAddress++
+	}
+	// The bytes remaining counter is decremented; if it becomes zero and the loop flag is set, the sample is
+	// restarted; otherwise, if the bytes remaining counter becomes zero and the IRQ enabled flag is set, the
+	// interrupt flag is set.
+	d.BytesRemaining--
+	if d.BytesRemaining == 0 {
+		if d.Loop {
+			d.restart()
+		} else if d.IRQEnabled {
+			d.Interrupt = true
+		}
+	}
+}
+
+// ClockTimer is called on every CPU cycle
+func (d *DMC) ClockTimer() {
+	if d.Timer > 0 {
+		d.Timer--
+		return
+	}
+	d.Timer = d.TimerPeriod - 1
+
+	// If the silence flag is clear, the output level changes based on bit 0 of the shift register. If the bit is 1,
+	// add 2; otherwise, subtract 2. But if adding or subtracting 2 would cause the output level to leave the 0-127
+	// range, leave the output level unchanged.
+	if !d.Silence {
+		if d.ShiftRegister&0b1 == 1 {
+			if d.OutputLevel <= 125 {
+				d.OutputLevel += 2
+			}
+		} else if d.OutputLevel >= 2 {
+			d.OutputLevel -= 2
+		}
+	}
+	d.ShiftRegister >>= 1
+
+	// When the bits-remaining counter reaches zero, a new output cycle is started
+	d.BitsRemaining--
+	if d.BitsRemaining == 0 {
+		d.BitsRemaining = 8
+		if d.BufferEmpty {
+			d.Silence = true
+		} else {
+			d.Silence = false
+			d.ShiftRegister = d.SampleBuffer
+			d.BufferEmpty = true
+		}
+	}
+}
+
+// Output returns the current output level of the channel in the range 0-127
+func (d *DMC) Output() uint8 {
+	return d.OutputLevel
+}
+
+// Reset silences the channel and clears all registers
+func (d *DMC) Reset() {
+	*d = NewDMC()
+}
+
+func (d *DMC) Serialize(s *savestate.Serializer) {
+	s.Bool(&d.IRQEnabled)
+	s.Bool(&d.Loop)
+	s.Bool(&d.Interrupt)
+	s.Uint16(&d.Timer)
+	s.Uint16(&d.TimerPeriod)
+	s.Uint16(&d.SampleAddress)
+	s.Uint16(&d.SampleLength)
+	s.Uint16(&d.CurrentAddress)
+	s.Uint16(&d.BytesRemaining)
+	s.Uint8(&d.SampleBuffer)
+	s.Bool(&d.BufferEmpty)
+	s.Uint8(&d.ShiftRegister)
+	s.Uint8(&d.BitsRemaining)
+	s.Bool(&d.Silence)
+	s.Uint8(&d.OutputLevel)
+}
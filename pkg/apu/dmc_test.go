@@ -0,0 +1,141 @@
+package apu
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/exp625/gones/pkg/bus"
+)
+
+// testBus maps the sample data of the DMC to the CPU memory and records the stalls and IRQs of the APU
+type testBus struct {
+	bus.Bus
+	memory map[uint16]uint8
+	reads  []uint16
+	stalls int
+	irq    bool
+}
+
+func (b *testBus) CPURead(location uint16) uint8 {
+	b.reads = append(b.reads, location)
+	return b.memory[location]
+}
+
+func (b *testBus) Stall(cycles int) {
+	b.stalls += cycles
+}
+
+func (b *testBus) IRQ() {
+	b.irq = true
+}
+
+// playSample starts the playback of a sample with the given DMC register values and clocks the APU until all bytes
+// have been fetched or the number of cycles is reached
+func playSample(flags uint8, address uint8, length uint8, cycles int) (*APU, *testBus) {
+	b := &testBus{memory: map[uint16]uint8{}}
+	apu := New()
+	apu.Bus = b
+	// No frame interrupts
+	apu.CPUWrite(0x4017, 0x40)
+	apu.CPUWrite(0x4010, flags)
+	apu.CPUWrite(0x4012, address)
+	apu.CPUWrite(0x4013, length)
+	apu.CPUWrite(0x4015, 0x10)
+	for i := 0; i < cycles; i++ {
+		apu.Clock()
+	}
+	return apu, b
+}
+
+func TestDMCFetch(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		address uint8
+		length  uint8
+		reads   []uint16
+	}{
+		{"one byte", 0x00, 0x00, []uint16{0xC000}},
+		{"address", 0x12, 0x00, []uint16{0xC480}},
+		{"length", 0x00, 0x01, []uint16{
+			0xC000, 0xC001, 0xC002, 0xC003, 0xC004, 0xC005, 0xC006, 0xC007, 0xC008, 0xC009, 0xC00A, 0xC00B,
+			0xC00C, 0xC00D, 0xC00E, 0xC00F, 0xC010,
+		}},
+		// The address wraps around to $8000
+		{"wrap", 0xFF, 0x01, []uint16{
+			0xFFC0, 0xFFC1, 0xFFC2, 0xFFC3, 0xFFC4, 0xFFC5, 0xFFC6, 0xFFC7, 0xFFC8, 0xFFC9, 0xFFCA, 0xFFCB,
+			0xFFCC, 0xFFCD, 0xFFCE, 0xFFCF, 0xFFD0,
+		}},
+	} {
+		// Fastest rate, 8 bits of 54 cycles per byte
+		apu, b := playSample(0x0F, test.address, test.length, 20*8*54)
+		if !reflect.DeepEqual(b.reads, test.reads) {
+			t.Errorf("%s: expected the reads %04X, got %04X", test.name, test.reads, b.reads)
+		}
+		// The CPU is stalled for 4 cycles per fetch
+		if b.stalls != 4*len(test.reads) {
+			t.Errorf("%s: expected %d stall cycles, got %d", test.name, 4*len(test.reads), b.stalls)
+		}
+		if apu.PeekStatus()&0b0001_0000 != 0 {
+			t.Errorf("%s: expected the sample to be finished", test.name)
+		}
+	}
+
+	// Wrap around of the current address from $FFFF to $8000
+	d := NewDMC()
+	d.CurrentAddress = 0xFFFF
+	d.BytesRemaining = 2
+	d.FillSampleBuffer(0)
+	if d.CurrentAddress != 0x8000 {
+		t.Errorf("expected the address to wrap around to $8000, got $%04X", d.CurrentAddress)
+	}
+}
+
+func TestDMCLoop(t *testing.T) {
+	apu, b := playSample(0b0100_1111, 0x00, 0x00, 5*8*54)
+	if len(b.reads) < 4 {
+		t.Fatalf("expected the sample to be repeated, got the reads %04X", b.reads)
+	}
+	for i, location := range b.reads {
+		if location != 0xC000 {
+			t.Errorf("read %d: expected the address $C000, got $%04X", i, location)
+		}
+	}
+	if apu.PeekStatus() != 0b0001_0000 {
+		t.Errorf("expected the sample to be active without interrupt, got %08b", apu.PeekStatus())
+	}
+}
+
+func TestDMCInterrupt(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		flags     uint8
+		interrupt bool
+	}{
+		{"IRQ enabled", 0b1000_1111, true},
+		{"IRQ disabled", 0b0000_1111, false},
+		// Looping samples never finish
+		{"loop", 0b1100_1111, false},
+	} {
+		apu, b := playSample(test.flags, 0x00, 0x01, 20*8*54)
+		if apu.DMC.Interrupt != test.interrupt || b.irq != test.interrupt {
+			t.Errorf("%s: expected the interrupt %t, got flag %t and IRQ %t",
+				test.name, test.interrupt, apu.DMC.Interrupt, b.irq)
+		}
+	}
+
+	// The interrupt flag is cleared by disabling the IRQ and by writes to $4015, but not by reads
+	apu, _ := playSample(0b1000_1111, 0x00, 0x00, 8*54)
+	apu.ReadStatus()
+	if !apu.DMC.Interrupt {
+		t.Error("expected the DMC interrupt flag to be kept on reads of $4015")
+	}
+	apu.CPUWrite(0x4015, 0x00)
+	if apu.DMC.Interrupt {
+		t.Error("expected the DMC interrupt flag to be cleared by writes to $4015")
+	}
+	apu, _ = playSample(0b1000_1111, 0x00, 0x00, 8*54)
+	apu.CPUWrite(0x4010, 0x0F)
+	if apu.DMC.Interrupt {
+		t.Error("expected the DMC interrupt flag to be cleared by disabling the IRQ")
+	}
+}
@@ -0,0 +1,71 @@
+package apu
+
+import "github.com/exp625/gones/internal/savestate"
+
+// Envelope generates a decreasing saw envelope (like a decay) with optional looping, or a constant volume that can be
+// used for more complex volume control. Used by the pulse and the noise channel.
+type Envelope struct {
+	Start          bool
+	Loop           bool
+	ConstantVolume bool
+	// Volume is used as constant volume or as the reload value of the divider
+	Volume  uint8
+	Divider uint8
+	Decay   uint8
+}
+
+// Write sets the envelope parameters from bits 0-5 of $4000, $4004 or $400C
+func (e *Envelope) Write(data uint8) {
+	// 7  bit  0
+	// ---- ----
+	// xxLC VVVV
+	//   || ||||
+	//   || ++++- Volume / envelope divider period
+	//   |+------ Constant volume flag (1: constant volume; 0: envelope)
+	//   +------- Loop flag (shared with the length counter halt flag)
+	e.Loop = data>>5&0b1 == 1
+	e.ConstantVolume = data>>4&0b1 == 1
+	e.Volume = data & 0b1111
+}
+
+// Clock is called on every quarter frame
+func (e *Envelope) Clock() {
+	if e.Start {
+		// If the start flag is set, the start flag is cleared, the decay level counter is loaded with 15,
+		// and the divider's period is immediately reloaded.
+		e.Start = false
+		e.Decay = 15
+		e.Divider = e.Volume
+		return
+	}
+	if e.Divider > 0 {
+		e.Divider--
+		return
+	}
+	// When the divider is clocked while at 0, it is loaded with V and clocks the decay level counter. Then if the
+	// decay level counter is non-zero, it is decremented, otherwise if the loop flag is set, the decay level counter
+	// is loaded with 15.
+	e.Divider = e.Volume
+	if e.Decay > 0 {
+		e.Decay--
+	} else if e.Loop {
+		e.Decay = 15
+	}
+}
+
+// Output returns the current volume of the envelope
+func (e *Envelope) Output() uint8 {
+	if e.ConstantVolume {
+		return e.Volume
+	}
+	return e.Decay
+}
+
+func (e *Envelope) Serialize(s *savestate.Serializer) {
+	s.Bool(&e.Start)
+	s.Bool(&e.Loop)
+	s.Bool(&e.ConstantVolume)
+	s.Uint8(&e.Volume)
+	s.Uint8(&e.Divider)
+	s.Uint8(&e.Decay)
+}
@@ -0,0 +1,53 @@
+package apu
+
+import (
+	"math"
+
+	"github.com/exp625/gones/internal/savestate"
+)
+
+// filter is a first-order IIR filter as found in the analog output stage of the NES.
+//
+// From NES DEV WIKI https://wiki.nesdev.org/w/index.php?title=APU_Mixer
+//
+// The NES hardware follows the DACs with a surprisingly involved circuit that adds several low-pass and high-pass
+// filters:
+// - A first-order high-pass filter at 90 Hz
+// - Another first-order high-pass filter at 440 Hz
+// - A first-order low-pass filter at 14 kHz
+type filter struct {
+	highPass bool
+	alpha    float64
+	prevIn   float64
+	prevOut  float64
+}
+
+// newHighPassFilter creates a first-order high-pass filter with the given cutoff frequency
+func newHighPassFilter(sampleRate float64, cutoff float64) filter {
+	rc := 1 / (2 * math.Pi * cutoff)
+	dt := 1 / sampleRate
+	return filter{highPass: true, alpha: rc / (rc + dt)}
+}
+
+// newLowPassFilter creates a first-order low-pass filter with the given cutoff frequency
+func newLowPassFilter(sampleRate float64, cutoff float64) filter {
+	rc := 1 / (2 * math.Pi * cutoff)
+	dt := 1 / sampleRate
+	return filter{alpha: dt / (rc + dt)}
+}
+
+// Step filters the next sample
+func (f *filter) Step(in float64) float64 {
+	if f.highPass {
+		f.prevOut = f.alpha * (f.prevOut + in - f.prevIn)
+	} else {
+		f.prevOut = f.prevOut + f.alpha*(in-f.prevOut)
+	}
+	f.prevIn = in
+	return f.prevOut
+}
+
+func (f *filter) Serialize(s *savestate.Serializer) {
+	s.Float64(&f.prevIn)
+	s.Float64(&f.prevOut)
+}
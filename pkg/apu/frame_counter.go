@@ -0,0 +1,125 @@
+package apu
+
+import "github.com/exp625/gones/internal/savestate"
+
+// FrameCounter or frame sequencer generates the low frequency quarter and half frame clocks for the channels and an
+// optional 60 Hz interrupt.
+//
+// From NES DEV WIKI https://wiki.nesdev.org/w/index.php?title=APU_Frame_Counter
+//
+// The sequencer runs in one of two modes. The step positions are given in CPU cycles (NTSC):
+//
+// Mode 0: 4-Step Sequence              Mode 1: 5-Step Sequence
+// Cycle   Quarter Half  IRQ            Cycle   Quarter Half
+// 7457    x                            7457    x
+// 14913   x       x                    14913   x       x
+// 22371   x                            22371   x
+// 29828                 x              29829
+// 29829   x       x     x              37281   x       x
+// 29830                 x              37282   (reset)
+// (reset)
+type FrameCounter struct {
+	// FiveStepMode selects the 5-step sequence
+	FiveStepMode bool
+	IRQInhibit   bool
+	Interrupt    bool
+	Cycle        uint16
+
+	// A write to $4017 takes effect 3 or 4 CPU cycles after the write
+	WriteDelay      uint8
+	PendingFiveStep bool
+}
+
+// Write handles a write to $4017. oddCycle is true if the write happens in the second half of an APU cycle.
+func (f *FrameCounter) Write(data uint8, oddCycle bool) {
+	// 7  bit  0
+	// ---- ----
+	// MI-- ----
+	// ||
+	// |+-------- Interrupt inhibit flag. If set, the frame interrupt flag is cleared, otherwise it is unaffected.
+	// +--------- Sequencer mode: 0 selects 4-step sequence, 1 selects 5-step sequence
+	f.PendingFiveStep = data>>7 == 1
+	f.IRQInhibit = data>>6&0b1 == 1
+	if f.IRQInhibit {
+		f.Interrupt = false
+	}
+	// After 3 or 4 CPU clock cycles, the timer is reset. If the write occurs during an APU cycle, the effects occur
+	// 3 CPU cycles after the $4017 write cycle, and if the write occurs between APU cycles, the effects occurs 4 CPU
+	// cycles after the write cycle.
+	if oddCycle {
+		f.WriteDelay = 4
+	} else {
+		f.WriteDelay = 3
+	}
+}
+
+// Clock is called on every CPU cycle and reports whether a quarter frame or half frame clock has to be issued.
+func (f *FrameCounter) Clock() (quarter bool, half bool) {
+	if f.WriteDelay > 0 {
+		f.WriteDelay--
+		if f.WriteDelay == 0 {
+			f.Cycle = 0
+			f.FiveStepMode = f.PendingFiveStep
+			// Writing to $4017 with bit 7 set will immediately generate a clock for both the quarter frame and the
+			// half frame units.
+			if f.FiveStepMode {
+				return true, true
+			}
+			return false, false
+		}
+	}
+
+	f.Cycle++
+	if f.FiveStepMode {
+		switch f.Cycle {
+		case 7457, 22371:
+			quarter = true
+		case 14913, 37281:
+			quarter, half = true, true
+		case 37282:
+			f.Cycle = 0
+		}
+		return quarter, half
+	}
+
+	switch f.Cycle {
+	case 7457, 22371:
+		quarter = true
+	case 14913:
+		quarter, half = true, true
+	case 29828:
+		f.setInterrupt()
+	case 29829:
+		quarter, half = true, true
+		f.setInterrupt()
+	case 29830:
+		f.setInterrupt()
+		f.Cycle = 0
+	}
+	return quarter, half
+}
+
+// setInterrupt sets the frame interrupt flag, if interrupts are not inhibited
+func (f *FrameCounter) setInterrupt() {
+	if !f.IRQInhibit {
+		f.Interrupt = true
+	}
+}
+
+// Reset resets the sequencer. The mode and the interrupt inhibit flag survive a reset, as if $4017 was rewritten
+// with its last value.
+func (f *FrameCounter) Reset() {
+	f.Interrupt = false
+	f.Cycle = 0
+	f.WriteDelay = 0
+	f.FiveStepMode = f.PendingFiveStep
+}
+
+func (f *FrameCounter) Serialize(s *savestate.Serializer) {
+	s.Bool(&f.FiveStepMode)
+	s.Bool(&f.IRQInhibit)
+	s.Bool(&f.Interrupt)
+	s.Uint16(&f.Cycle)
+	s.Uint8(&f.WriteDelay)
+	s.Bool(&f.PendingFiveStep)
+}
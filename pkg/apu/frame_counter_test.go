@@ -0,0 +1,140 @@
+package apu
+
+import (
+	"reflect"
+	"testing"
+)
+
+// frameClocks runs the frame counter for the number of CPU cycles and returns the cycles of the quarter and half frame
+// clocks and the first cycle with the interrupt flag set, -1 if it was not set
+func frameClocks(f *FrameCounter, cycles int) (quarters []int, halves []int, interrupt int) {
+	interrupt = -1
+	for cycle := 1; cycle <= cycles; cycle++ {
+		quarter, half := f.Clock()
+		if quarter {
+			quarters = append(quarters, cycle)
+		}
+		if half {
+			halves = append(halves, cycle)
+		}
+		if f.Interrupt && interrupt == -1 {
+			interrupt = cycle
+		}
+	}
+	return quarters, halves, interrupt
+}
+
+func TestFrameCounterSequence(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		counter FrameCounter
+		// One sequence and the first quarter frame of the next one
+		cycles    int
+		quarters  []int
+		halves    []int
+		interrupt int
+	}{
+		{
+			name:      "4-step",
+			cycles:    29830 + 7457,
+			quarters:  []int{7457, 14913, 22371, 29829, 29830 + 7457},
+			halves:    []int{14913, 29829},
+			interrupt: 29828,
+		},
+		{
+			name:      "4-step inhibited",
+			counter:   FrameCounter{IRQInhibit: true},
+			cycles:    29830 + 7457,
+			quarters:  []int{7457, 14913, 22371, 29829, 29830 + 7457},
+			halves:    []int{14913, 29829},
+			interrupt: -1,
+		},
+		{
+			name:      "5-step",
+			counter:   FrameCounter{FiveStepMode: true},
+			cycles:    37282 + 7457,
+			quarters:  []int{7457, 14913, 22371, 37281, 37282 + 7457},
+			halves:    []int{14913, 37281},
+			interrupt: -1,
+		},
+	} {
+		f := test.counter
+		quarters, halves, interrupt := frameClocks(&f, test.cycles)
+		if !reflect.DeepEqual(quarters, test.quarters) {
+			t.Errorf("%s: expected quarter frames at %v, got %v", test.name, test.quarters, quarters)
+		}
+		if !reflect.DeepEqual(halves, test.halves) {
+			t.Errorf("%s: expected half frames at %v, got %v", test.name, test.halves, halves)
+		}
+		if interrupt != test.interrupt {
+			t.Errorf("%s: expected the interrupt at %d, got %d", test.name, test.interrupt, interrupt)
+		}
+	}
+}
+
+func TestFrameCounterWrite(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		data     uint8
+		oddCycle bool
+		// Cycle of the reset after the write and whether it clocks the quarter and half frame units
+		reset int
+		clock bool
+	}{
+		{"4-step", 0x00, false, 3, false},
+		{"4-step between APU cycles", 0x00, true, 4, false},
+		{"5-step", 0x80, false, 3, true},
+		{"5-step between APU cycles", 0x80, true, 4, true},
+	} {
+		f := FrameCounter{Cycle: 1000}
+		f.Write(test.data, test.oddCycle)
+		for cycle := 1; cycle <= test.reset; cycle++ {
+			quarter, half := f.Clock()
+			if cycle < test.reset && f.Cycle != uint16(1000+cycle) {
+				t.Errorf("%s: expected no reset in cycle %d", test.name, cycle)
+			}
+			if cycle == test.reset && (f.Cycle != 0 || quarter != test.clock || half != test.clock) {
+				t.Errorf("%s: expected the reset in cycle %d, got cycle %d, quarter %t, half %t",
+					test.name, cycle, f.Cycle, quarter, half)
+			}
+		}
+	}
+}
+
+func TestFrameInterrupt(t *testing.T) {
+	apu := New()
+	apu.Bus = &testBus{}
+	for i := 0; i < 29829; i++ {
+		apu.Clock()
+	}
+	if !apu.Bus.(*testBus).irq {
+		t.Error("expected an IRQ")
+	}
+	if status := apu.PeekStatus(); status&0b0100_0000 == 0 {
+		t.Errorf("expected the frame interrupt flag, got %08b", status)
+	}
+	// Peeking does not clear the flag, reading does
+	if status := apu.ReadStatus(); status&0b0100_0000 == 0 {
+		t.Errorf("expected the frame interrupt flag, got %08b", status)
+	}
+	if status := apu.ReadStatus(); status&0b0100_0000 != 0 {
+		t.Errorf("expected the frame interrupt flag to be cleared by the read, got %08b", status)
+	}
+
+	// Setting the interrupt inhibit flag clears the flag
+	apu.Clock()
+	if !apu.FrameCounter.Interrupt {
+		t.Fatal("expected the frame interrupt flag to be set again in the last cycle of the sequence")
+	}
+	apu.CPUWrite(0x4017, 0x40)
+	if apu.FrameCounter.Interrupt {
+		t.Error("expected the frame interrupt flag to be cleared by the inhibit flag")
+	}
+	apu.Bus.(*testBus).irq = false
+	for i := 0; i < 2*29830; i++ {
+		apu.Clock()
+	}
+	if apu.Bus.(*testBus).irq {
+		t.Error("expected no IRQ while inhibited")
+	}
+}
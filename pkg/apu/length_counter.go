@@ -0,0 +1,52 @@
+package apu
+
+import "github.com/exp625/gones/internal/savestate"
+
+// lengthTable maps the 5 bit length counter load index written to $4003, $4007, $400B and $400F to the number of
+// half frames the channel will keep playing.
+var lengthTable = [32]uint8{
+	10, 254, 20, 2, 40, 4, 80, 6, 160, 8, 60, 10, 14, 12, 26, 14,
+	12, 16, 24, 18, 48, 20, 96, 22, 192, 24, 72, 26, 16, 28, 32, 30,
+}
+
+// LengthCounter provides automatic duration control for the waveform channels. Once loaded with a value, it can
+// optionally count down (when the length counter halt flag is clear). Once it reaches zero, the corresponding
+// channel is silenced.
+type LengthCounter struct {
+	Counter uint8
+	Halt    bool
+	Enabled bool
+}
+
+// Load reloads the counter from the length table, if the channel is enabled
+func (l *LengthCounter) Load(index uint8) {
+	if l.Enabled {
+		l.Counter = lengthTable[index&0b1_1111]
+	}
+}
+
+// SetEnabled enables or disables the counter. When disabled, the counter is forced to 0 and can not be reloaded.
+func (l *LengthCounter) SetEnabled(enabled bool) {
+	l.Enabled = enabled
+	if !enabled {
+		l.Counter = 0
+	}
+}
+
+// Clock is called on every half frame. If the counter is not halted and not 0, it will count down.
+func (l *LengthCounter) Clock() {
+	if !l.Halt && l.Counter > 0 {
+		l.Counter--
+	}
+}
+
+// Active returns true if the counter has not reached 0 yet
+func (l *LengthCounter) Active() bool {
+	return l.Counter > 0
+}
+
+func (l *LengthCounter) Serialize(s *savestate.Serializer) {
+	s.Uint8(&l.Counter)
+	s.Bool(&l.Halt)
+	s.Bool(&l.Enabled)
+}
@@ -0,0 +1,55 @@
+package apu
+
+import "testing"
+
+func TestLengthTable(t *testing.T) {
+	// The values of the length table for the load indices written to bits 3-7 of $4003
+	expected := [32]uint8{
+		0x0A, 0xFE, 0x14, 0x02, 0x28, 0x04, 0x50, 0x06, 0xA0, 0x08, 0x3C, 0x0A, 0x0E, 0x0C, 0x1A, 0x0E,
+		0x0C, 0x10, 0x18, 0x12, 0x30, 0x14, 0x60, 0x16, 0xC0, 0x18, 0x48, 0x1A, 0x10, 0x1C, 0x20, 0x1E,
+	}
+	for index, length := range expected {
+		apu := New()
+		apu.CPUWrite(0x4015, 0b0001)
+		apu.CPUWrite(0x4003, uint8(index)<<3)
+		if apu.Pulse1.LengthCounter.Counter != length {
+			t.Errorf("index %d: expected length %d, got %d", index, length, apu.Pulse1.LengthCounter.Counter)
+		}
+	}
+}
+
+func TestLengthCounter(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		enabled bool
+		halt    bool
+		clocks  int
+		counter uint8
+	}{
+		{"count down", true, false, 3, 7},
+		{"stop at 0", true, false, 20, 0},
+		{"halt", true, true, 3, 10},
+		// A disabled counter can not be loaded
+		{"disabled", false, false, 0, 0},
+	} {
+		var l LengthCounter
+		l.SetEnabled(test.enabled)
+		l.Halt = test.halt
+		l.Load(0)
+		for i := 0; i < test.clocks; i++ {
+			l.Clock()
+		}
+		if l.Counter != test.counter {
+			t.Errorf("%s: expected counter %d, got %d", test.name, test.counter, l.Counter)
+		}
+	}
+
+	// Disabling the channel clears the counter
+	var l LengthCounter
+	l.SetEnabled(true)
+	l.Load(1)
+	l.SetEnabled(false)
+	if l.Active() {
+		t.Error("expected the counter to be cleared when the channel is disabled")
+	}
+}
@@ -0,0 +1,32 @@
+package apu
+
+// The channel outputs are combined by the non-linear mixer of the NES. Instead of the formulas, the lookup tables
+// from the NES DEV WIKI are used https://wiki.nesdev.org/w/index.php?title=APU_Mixer
+//
+// pulse_table [n] = 95.52 / (8128.0 / n + 100)
+// tnd_table [n] = 163.67 / (24329.0 / n + 100)
+//
+// output = pulse_table [pulse1 + pulse2] + tnd_table [3 * triangle + 2 * noise + dmc]
+var (
+	pulseTable [31]float64
+	tndTable   [203]float64
+)
+
+func init() {
+	for n := 1; n < len(pulseTable); n++ {
+		pulseTable[n] = 95.52 / (8128.0/float64(n) + 100)
+	}
+	for n := 1; n < len(tndTable); n++ {
+		tndTable[n] = 163.67 / (24329.0/float64(n) + 100)
+	}
+}
+
+// mix returns the output of the mixer in the range 0.0 - 1.0
+func (apu *APU) mix() float64 {
+	pulse := pulseTable[apu.Pulse1.Output()+apu.Pulse2.Output()]
+	tnd := tndTable[3*uint16(apu.Triangle.Output())+2*uint16(apu.Noise.Output())+uint16(apu.DMC.Output())]
+	if apu.Expansion != nil {
+		return pulse + tnd + apu.Expansion.AudioOutput()
+	}
+	return pulse + tnd
+}
@@ -0,0 +1,109 @@
+package apu
+
+import "github.com/exp625/gones/internal/savestate"
+
+// noisePeriodTable contains the NTSC timer periods of the noise channel in APU cycles
+var noisePeriodTable = [16]uint16{
+	2, 4, 8, 16, 32, 48, 64, 80, 101, 127, 190, 254, 381, 508, 1017, 2034,
+}
+
+// Noise is the noise channel of the APU. It generates pseudo-random 1-bit noise using a 15 bit linear feedback shift
+// register at 16 different frequencies.
+//
+// From NES DEV WIKI https://wiki.nesdev.org/w/index.php?title=APU_Noise
+type Noise struct {
+	// Mode flag selects the short (93 or 31 steps) or the long (32767 steps) sequence
+	Mode          bool
+	ShiftRegister uint16
+
+	Timer       uint16
+	TimerPeriod uint16
+
+	Envelope      Envelope
+	LengthCounter LengthCounter
+}
+
+// NewNoise creates a noise channel. On power-up, the shift register is loaded with the value 1.
+func NewNoise() Noise {
+	return Noise{ShiftRegister: 1, TimerPeriod: noisePeriodTable[0]}
+}
+
+// Write handles a write to one of the registers of the channel mapped to $400C-$400F
+func (n *Noise) Write(register uint16, data uint8) {
+	switch register {
+	case 0:
+		// $400C
+		// 7  bit  0
+		// ---- ----
+		// --LC VVVV
+		//   || ||||
+		//   || ++++- Volume / envelope divider period
+		//   |+------ Constant volume flag
+		//   +------- Length counter halt / envelope loop flag
+		n.LengthCounter.Halt = data>>5&0b1 == 1
+		n.Envelope.Write(data)
+	case 1:
+		// $400D is unused
+	case 2:
+		// $400E
+		// 7  bit  0
+		// ---- ----
+		// M--- PPPP
+		// |    ||||
+		// |    ++++- Timer period index
+		// +--------- Mode flag
+		n.Mode = data>>7 == 1
+		n.TimerPeriod = noisePeriodTable[data&0b1111]
+	case 3:
+		// $400F
+		// 7  bit  0
+		// ---- ----
+		// LLLL L---
+		// |||| |
+		// ++++-+---- Length counter load
+		// Side effects: The envelope is restarted
+		n.LengthCounter.Load(data >> 3)
+		n.Envelope.Start = true
+	}
+}
+
+// ClockTimer is called on every APU cycle (every second CPU cycle)
+func (n *Noise) ClockTimer() {
+	if n.Timer > 0 {
+		n.Timer--
+		return
+	}
+	n.Timer = n.TimerPeriod - 1
+	// When the timer clocks the shift register, the feedback is calculated as the exclusive-OR of bit 0 and one other
+	// bit: bit 6 if mode flag is set, otherwise bit 1. The shift register is shifted right by one bit and bit 14 is
+	// set to the feedback.
+	other := n.ShiftRegister >> 1
+	if n.Mode {
+		other = n.ShiftRegister >> 6
+	}
+	feedback := (n.ShiftRegister ^ other) & 0b1
+	n.ShiftRegister = n.ShiftRegister>>1 | feedback<<14
+}
+
+// Output returns the current volume of the channel in the range 0-15
+func (n *Noise) Output() uint8 {
+	// The channel is silenced while bit 0 of the shift register is set or the length counter is 0
+	if n.ShiftRegister&0b1 == 1 || !n.LengthCounter.Active() {
+		return 0
+	}
+	return n.Envelope.Output()
+}
+
+// Reset silences the channel and clears all registers
+func (n *Noise) Reset() {
+	*n = NewNoise()
+}
+
+func (n *Noise) Serialize(s *savestate.Serializer) {
+	s.Bool(&n.Mode)
+	s.Uint16(&n.ShiftRegister)
+	s.Uint16(&n.Timer)
+	s.Uint16(&n.TimerPeriod)
+	n.Envelope.Serialize(s)
+	n.LengthCounter.Serialize(s)
+}
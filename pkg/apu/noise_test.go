@@ -0,0 +1,56 @@
+package apu
+
+import "testing"
+
+func TestNoisePeriod(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		mode   uint8
+		length int
+	}{
+		// The shift register repeats after 32767 steps, or after 93 steps in mode 1
+		{"mode 0", 0x00, 32767},
+		{"mode 1", 0x80, 93},
+	} {
+		n := NewNoise()
+		// Timer period 2
+		n.Write(2, test.mode)
+		start := n.ShiftRegister
+		length := 0
+		for {
+			// The timer reaches 0 every other clock
+			n.ClockTimer()
+			n.ClockTimer()
+			length++
+			if n.ShiftRegister == start || length > 40000 {
+				break
+			}
+		}
+		if length != test.length {
+			t.Errorf("%s: expected a period of %d steps, got %d", test.name, test.length, length)
+		}
+	}
+}
+
+func TestNoiseOutput(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		enabled  bool
+		register uint16
+		output   uint8
+	}{
+		{"bit 0 clear", true, 0b10, 9},
+		{"bit 0 set", true, 0b11, 0},
+		{"length counter 0", false, 0b10, 0},
+	} {
+		n := NewNoise()
+		n.LengthCounter.SetEnabled(test.enabled)
+		// Constant volume 9
+		n.Write(0, 0b0001_1001)
+		n.Write(3, 0x08)
+		n.ShiftRegister = test.register
+		if output := n.Output(); output != test.output {
+			t.Errorf("%s: expected %d, got %d", test.name, test.output, output)
+		}
+	}
+}
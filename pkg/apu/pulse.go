@@ -0,0 +1,178 @@
+package apu
+
+import "github.com/exp625/gones/internal/savestate"
+
+// dutyTable contains the 8 step waveforms of the four duty cycles. The sequencer reads the table from the left to the
+// right, but counts down, so the steps are output in the order 0, 7, 6, ..., 1.
+var dutyTable = [4][8]uint8{
+	{0, 1, 0, 0, 0, 0, 0, 0}, // 12.5 %
+	{0, 1, 1, 0, 0, 0, 0, 0}, // 25 %
+	{0, 1, 1, 1, 1, 0, 0, 0}, // 50 %
+	{1, 0, 0, 1, 1, 1, 1, 1}, // 25 % negated
+}
+
+// Pulse is one of the two square wave channels of the APU. Its output is gated by the sweep unit, the sequencer and
+// the length counter, the volume is controlled by the envelope.
+//
+// From NES DEV WIKI https://wiki.nesdev.org/w/index.php?title=APU_Pulse
+type Pulse struct {
+	// Pulse 1 adds the ones' complement (-c - 1) in the sweep unit, pulse 2 adds the two's complement (-c)
+	OnesComplement bool
+	// NoSweep is set for pulse channels without a sweep unit, like the pulses of the MMC5. They are never muted.
+	NoSweep bool
+
+	Duty     uint8
+	DutyStep uint8
+
+	Timer       uint16
+	TimerPeriod uint16
+
+	Envelope      Envelope
+	LengthCounter LengthCounter
+
+	SweepEnabled bool
+	SweepPeriod  uint8
+	SweepNegate  bool
+	SweepShift   uint8
+	SweepReload  bool
+	SweepDivider uint8
+}
+
+// Write handles a write to one of the four registers of the channel.
+// Pulse 1 is mapped to $4000-$4003, pulse 2 to $4004-$4007.
+func (p *Pulse) Write(register uint16, data uint8) {
+	switch register {
+	case 0:
+		// $4000 / $4004
+		// 7  bit  0
+		// ---- ----
+		// DDLC VVVV
+		// |||| ||||
+		// |||| ++++- Volume / envelope divider period
+		// |||+------ Constant volume flag
+		// ||+------- Length counter halt / envelope loop flag
+		// ++-------- Duty cycle
+		p.Duty = data >> 6
+		p.LengthCounter.Halt = data>>5&0b1 == 1
+		p.Envelope.Write(data)
+	case 1:
+		// $4001 / $4005
+		// 7  bit  0
+		// ---- ----
+		// EPPP NSSS
+		// |||| ||||
+		// |||| |+++- Shift count (number of bits)
+		// |||| +---- Negate flag
+		// |+++------ Divider period is P + 1 half-frames
+		// +--------- Enabled flag
+		// Side effects: Sets the reload flag
+		p.SweepEnabled = data>>7 == 1
+		p.SweepPeriod = data >> 4 & 0b111
+		p.SweepNegate = data>>3&0b1 == 1
+		p.SweepShift = data & 0b111
+		p.SweepReload = true
+	case 2:
+		// $4002 / $4006
+		// 7  bit  0
+		// ---- ----
+		// TTTT TTTT
+		// |||| ||||
+		// ++++-++++- Timer low 8 bits
+		p.TimerPeriod = p.TimerPeriod&0x0700 | uint16(data)
+	case 3:
+		// $4003 / $4007
+		// 7  bit  0
+		// ---- ----
+		// LLLL LTTT
+		// |||| ||||
+		// |||| |+++- Timer high 3 bits
+		// ++++-+---- Length counter load
+		// Side effects: The sequencer is immediately restarted at the first value of the current sequence.
+		// The envelope is also restarted. The period divider is not reset.
+		p.TimerPeriod = p.TimerPeriod&0x00FF | uint16(data&0b111)<<8
+		p.LengthCounter.Load(data >> 3)
+		p.DutyStep = 0
+		p.Envelope.Start = true
+	}
+}
+
+// ClockTimer is called on every APU cycle (every second CPU cycle)
+func (p *Pulse) ClockTimer() {
+	if p.Timer == 0 {
+		p.Timer = p.TimerPeriod
+		p.DutyStep = (p.DutyStep - 1) & 0b111
+	} else {
+		p.Timer--
+	}
+}
+
+// ClockSweep is called on every half frame
+func (p *Pulse) ClockSweep() {
+	// If the divider's counter is zero, the sweep is enabled, the shift count is nonzero and the sweep unit is not
+	// muting the channel, the pulse's period is set to the target period.
+	if p.SweepDivider == 0 && p.SweepEnabled && p.SweepShift > 0 && !p.muted() {
+		p.TimerPeriod = p.targetPeriod()
+	}
+	// If the divider's counter is zero or the reload flag is true: The divider counter is set to P and the reload
+	// flag is cleared. Otherwise, the divider counter is decremented.
+	if p.SweepDivider == 0 || p.SweepReload {
+		p.SweepDivider = p.SweepPeriod
+		p.SweepReload = false
+	} else {
+		p.SweepDivider--
+	}
+}
+
+// targetPeriod continuously calculates the period the sweep unit would set the timer to
+func (p *Pulse) targetPeriod() uint16 {
+	change := p.TimerPeriod >> p.SweepShift
+	if !p.SweepNegate {
+		return p.TimerPeriod + change
+	}
+	if p.OnesComplement {
+		change++
+	}
+	if change > p.TimerPeriod {
+		return 0
+	}
+	return p.TimerPeriod - change
+}
+
+// muted returns true if the sweep unit is muting the channel. This happens independent of the enabled flag if the
+// current period is less than 8 or the target period would overflow the 11 bit timer.
+func (p *Pulse) muted() bool {
+	if p.NoSweep {
+		return false
+	}
+	return p.TimerPeriod < 8 || p.targetPeriod() > 0x7FF
+}
+
+// Output returns the current volume of the channel in the range 0-15
+func (p *Pulse) Output() uint8 {
+	if !p.LengthCounter.Active() || p.muted() || dutyTable[p.Duty][p.DutyStep] == 0 {
+		return 0
+	}
+	return p.Envelope.Output()
+}
+
+// Reset silences the channel and clears all registers
+func (p *Pulse) Reset() {
+	*p = Pulse{OnesComplement: p.OnesComplement, NoSweep: p.NoSweep}
+}
+
+func (p *Pulse) Serialize(s *savestate.Serializer) {
+	s.Bool(&p.OnesComplement)
+	s.Bool(&p.NoSweep)
+	s.Uint8(&p.Duty)
+	s.Uint8(&p.DutyStep)
+	s.Uint16(&p.Timer)
+	s.Uint16(&p.TimerPeriod)
+	p.Envelope.Serialize(s)
+	p.LengthCounter.Serialize(s)
+	s.Bool(&p.SweepEnabled)
+	s.Uint8(&p.SweepPeriod)
+	s.Bool(&p.SweepNegate)
+	s.Uint8(&p.SweepShift)
+	s.Bool(&p.SweepReload)
+	s.Uint8(&p.SweepDivider)
+}
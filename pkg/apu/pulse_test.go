@@ -0,0 +1,118 @@
+package apu
+
+import "testing"
+
+func TestPulseSweepNegate(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		pulse    Pulse
+		period   uint16
+		shift    uint8
+		expected uint16
+	}{
+		// Pulse 1 subtracts the ones' complement, one more than pulse 2
+		{"pulse 1", New().Pulse1, 0x100, 1, 0x100 - 0x80 - 1},
+		{"pulse 2", New().Pulse2, 0x100, 1, 0x100 - 0x80},
+		{"pulse 1 shift 3", New().Pulse1, 0x123, 3, 0x123 - 0x24 - 1},
+		{"pulse 2 shift 3", New().Pulse2, 0x123, 3, 0x123 - 0x24},
+		// A change larger than the period results in a period of 0
+		{"pulse 1 shift 0", New().Pulse1, 0x100, 0, 0},
+		{"pulse 2 shift 0", New().Pulse2, 0x100, 0, 0},
+	} {
+		p := test.pulse
+		p.TimerPeriod = test.period
+		// Enabled, divider period 0, negate
+		p.Write(1, 0b1000_1000|test.shift)
+		if target := p.targetPeriod(); target != test.expected {
+			t.Errorf("%s: expected the target period $%03X, got $%03X", test.name, test.expected, target)
+		}
+	}
+}
+
+func TestPulseSweep(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		sweep    uint8
+		period   uint16
+		expected uint16
+	}{
+		{"increase", 0b1000_0001, 0x100, 0x180},
+		{"decrease", 0b1000_1001, 0x100, 0x07F},
+		{"disabled", 0b0000_0001, 0x100, 0x100},
+		{"shift 0", 0b1000_0000, 0x100, 0x100},
+		// The period is not changed while the channel is muted
+		{"overflow", 0b1000_0001, 0x600, 0x600},
+		{"period below 8", 0b1000_1001, 0x007, 0x007},
+	} {
+		p := New().Pulse1
+		p.TimerPeriod = test.period
+		p.Write(1, test.sweep)
+		p.ClockSweep()
+		if p.TimerPeriod != test.expected {
+			t.Errorf("%s: expected the period $%03X, got $%03X", test.name, test.expected, p.TimerPeriod)
+		}
+	}
+}
+
+func TestPulseSweepDivider(t *testing.T) {
+	p := New().Pulse2
+	p.TimerPeriod = 0x100
+	// Divider period 2 + 1 half frames, shift 4
+	p.Write(1, 0b1010_0100)
+	var changes []int
+	for i := 0; i < 9; i++ {
+		period := p.TimerPeriod
+		p.ClockSweep()
+		if p.TimerPeriod != period {
+			changes = append(changes, i)
+		}
+	}
+	// The divider starts at 0, afterwards it counts down from 2
+	if len(changes) != 3 || changes[0] != 0 || changes[1] != 3 || changes[2] != 6 {
+		t.Errorf("expected period changes on half frames 0, 3 and 6, got %v", changes)
+	}
+}
+
+func TestPulseMute(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		period uint16
+		sweep  uint8
+		muted  bool
+	}{
+		{"period 7", 7, 0, true},
+		{"period 8", 8, 0, false},
+		// The target period is computed even if the sweep is disabled
+		{"target $800", 0x400, 0b0000_0000, true},
+		{"target $7FE", 0x3FF, 0b0000_0000, false},
+		{"target $7FF", 0x555, 0b0000_0001, false},
+		{"negated target", 0x400, 0b0000_1000, false},
+	} {
+		p := New().Pulse1
+		p.LengthCounter.SetEnabled(true)
+		// Duty 50 %, constant volume 15
+		p.Write(0, 0b1001_1111)
+		p.Write(3, 0b1000_0000)
+		p.TimerPeriod = test.period
+		p.Write(1, test.sweep)
+		if p.muted() != test.muted {
+			t.Errorf("%s: expected muted to be %t", test.name, test.muted)
+		}
+		high := false
+		for i := 0; i < 8; i++ {
+			p.DutyStep = uint8(i)
+			if p.Output() == 15 {
+				high = true
+			}
+		}
+		if high == test.muted {
+			t.Errorf("%s: expected output %t", test.name, !test.muted)
+		}
+	}
+
+	// Pulses without sweep unit are never muted
+	p := Pulse{NoSweep: true, TimerPeriod: 2}
+	if p.muted() {
+		t.Error("expected no muting without sweep unit")
+	}
+}
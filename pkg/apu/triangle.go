@@ -0,0 +1,117 @@
+package apu
+
+import "github.com/exp625/gones/internal/savestate"
+
+// triangleTable contains the 32 step sequence of the triangle channel
+var triangleTable = [32]uint8{
+	15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1, 0,
+	0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+}
+
+// Triangle is the triangle wave channel of the APU. It has no volume control, but a linear counter in addition to the
+// length counter to control the duration of a note.
+//
+// From NES DEV WIKI https://wiki.nesdev.org/w/index.php?title=APU_Triangle
+type Triangle struct {
+	Step uint8
+
+	Timer       uint16
+	TimerPeriod uint16
+
+	LengthCounter LengthCounter
+
+	// The control flag is shared with the length counter halt flag
+	Control             bool
+	LinearCounter       uint8
+	LinearCounterReload uint8
+	LinearReloadFlag    bool
+}
+
+// Write handles a write to one of the registers of the channel mapped to $4008-$400B
+func (t *Triangle) Write(register uint16, data uint8) {
+	switch register {
+	case 0:
+		// $4008
+		// 7  bit  0
+		// ---- ----
+		// CRRR RRRR
+		// |||| ||||
+		// |+++-++++- Linear counter reload value
+		// +--------- Control flag (this bit is also the length counter halt flag)
+		t.Control = data>>7 == 1
+		t.LengthCounter.Halt = t.Control
+		t.LinearCounterReload = data & 0b0111_1111
+	case 1:
+		// $4009 is unused
+	case 2:
+		// $400A
+		// 7  bit  0
+		// ---- ----
+		// TTTT TTTT
+		// |||| ||||
+		// ++++-++++- Timer low 8 bits
+		t.TimerPeriod = t.TimerPeriod&0x0700 | uint16(data)
+	case 3:
+		// $400B
+		// 7  bit  0
+		// ---- ----
+		// LLLL LTTT
+		// |||| ||||
+		// |||| |+++- Timer high 3 bits
+		// ++++-+---- Length counter load
+		// Side effects: Sets the linear counter reload flag
+		t.TimerPeriod = t.TimerPeriod&0x00FF | uint16(data&0b111)<<8
+		t.LengthCounter.Load(data >> 3)
+		t.LinearReloadFlag = true
+	}
+}
+
+// ClockTimer is called on every CPU cycle
+func (t *Triangle) ClockTimer() {
+	if t.Timer > 0 {
+		t.Timer--
+		return
+	}
+	t.Timer = t.TimerPeriod
+	// The sequencer is clocked by the timer as long as both the linear counter and the length counter are nonzero.
+	if t.LinearCounter > 0 && t.LengthCounter.Active() {
+		t.Step = (t.Step + 1) & 0b1_1111
+	}
+}
+
+// ClockLinearCounter is called on every quarter frame
+func (t *Triangle) ClockLinearCounter() {
+	// If the linear counter reload flag is set, the linear counter is reloaded with the counter reload value,
+	// otherwise if the linear counter is non-zero, it is decremented.
+	if t.LinearReloadFlag {
+		t.LinearCounter = t.LinearCounterReload
+	} else if t.LinearCounter > 0 {
+		t.LinearCounter--
+	}
+	// If the control flag is clear, the linear counter reload flag is cleared.
+	if !t.Control {
+		t.LinearReloadFlag = false
+	}
+}
+
+// Output returns the current value of the sequencer in the range 0-15.
+// Silencing the channel only halts the sequencer, so the output stays at the last value.
+func (t *Triangle) Output() uint8 {
+	return triangleTable[t.Step]
+}
+
+// Reset silences the channel and clears all registers
+func (t *Triangle) Reset() {
+	*t = Triangle{}
+}
+
+func (t *Triangle) Serialize(s *savestate.Serializer) {
+	s.Uint8(&t.Step)
+	s.Uint16(&t.Timer)
+	s.Uint16(&t.TimerPeriod)
+	t.LengthCounter.Serialize(s)
+	s.Bool(&t.Control)
+	s.Uint8(&t.LinearCounter)
+	s.Uint8(&t.LinearCounterReload)
+	s.Bool(&t.LinearReloadFlag)
+}
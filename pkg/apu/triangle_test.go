@@ -0,0 +1,67 @@
+package apu
+
+import "testing"
+
+func TestTriangleSequence(t *testing.T) {
+	var tri Triangle
+	tri.LengthCounter.SetEnabled(true)
+	// Linear counter 127, timer period 2
+	tri.Write(0, 0x7F)
+	tri.Write(2, 0x02)
+	tri.Write(3, 0x08)
+	tri.ClockLinearCounter()
+	var outputs []uint8
+	for i := 0; i < 32*3; i++ {
+		tri.ClockTimer()
+		if i%3 == 0 {
+			outputs = append(outputs, tri.Output())
+		}
+	}
+	for i, output := range outputs {
+		if output != triangleTable[(i+1)%32] {
+			t.Fatalf("step %d: expected %d, got %d", i, triangleTable[(i+1)%32], output)
+		}
+	}
+}
+
+func TestTriangleSilence(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		enabled bool
+		linear  uint8
+		clocks  int
+		silent  bool
+	}{
+		{"playing", true, 0x10, 1, false},
+		{"linear counter 0", true, 0x00, 1, true},
+		// The linear counter is reloaded on the first quarter frame and counts down afterwards
+		{"linear counter expired", true, 0x03, 4, true},
+		{"linear counter running", true, 0x03, 3, false},
+		{"length counter 0", false, 0x10, 1, true},
+	} {
+		var tri Triangle
+		tri.LengthCounter.SetEnabled(test.enabled)
+		tri.Write(0, test.linear)
+		tri.Write(3, 0x08)
+		for i := 0; i < test.clocks; i++ {
+			tri.ClockLinearCounter()
+		}
+		// The sequencer holds its step while silent, the output does not drop to 0
+		step := tri.Step
+		tri.ClockTimer()
+		if silent := tri.Step == step; silent != test.silent {
+			t.Errorf("%s: expected silent to be %t", test.name, test.silent)
+		}
+	}
+
+	// With the control flag set, the linear counter is reloaded on every quarter frame
+	var tri Triangle
+	tri.Write(0, 0x82)
+	tri.Write(3, 0x00)
+	for i := 0; i < 5; i++ {
+		tri.ClockLinearCounter()
+	}
+	if tri.LinearCounter != 2 {
+		t.Errorf("expected the linear counter to be reloaded, got %d", tri.LinearCounter)
+	}
+}
@@ -11,7 +11,24 @@ type Bus interface {
 	PPUWrite(location uint16, data uint8)
 	PPUWriteRam(location uint16, data uint8)
 	PPUWritePalette(location uint16, data uint8)
+	// PPUFetch performs a memory access of the PPU while rendering. Unlike PPURead, the kind of the fetch is known.
+	PPUFetch(location uint16, fetch Fetch) uint8
 	DMA(page uint8)
+	Stall(cycles int)
 	NMI()
 	IRQ()
 }
+
+// Fetch is the kind of memory access the PPU performs while rendering
+type Fetch uint8
+
+const (
+	// FetchNametable reads the tile number of the next background tile
+	FetchNametable Fetch = iota
+	// FetchAttribute reads the attribute byte of the next background tile
+	FetchAttribute
+	// FetchBackground reads the pattern of the next background tile
+	FetchBackground
+	// FetchSprite reads the pattern of a sprite on the next scanline
+	FetchSprite
+)
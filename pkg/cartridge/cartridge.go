@@ -2,89 +2,103 @@ package cartridge
 
 import (
 	"crypto/md5"
+	"fmt"
+	"github.com/exp625/gones/internal/savestate"
 	"github.com/exp625/gones/pkg/bus"
 	"log"
 )
 
 type Cartridge struct {
 	Mapper
-	Bus        bus.Bus
+	Bus    bus.Bus
+	Header *Header
+	// Size of the PRG ROM in 16 KB units and of the CHR ROM in 8 KB units, derived from the header
 	PrgRomSize uint8
 	PrgRom     []uint8
 	ChrRomSize uint8
+	// CHR ROM or CHR RAM, if ChrRam is set
 	ChrRom     []uint8
 	ChrRam     bool
 	MirrorBit  bool
 	Identifier [16]byte
 }
 
-// Load loads a Cartridge from an iNES file.
+// Load loads a Cartridge from an iNES or NES 2.0 file.
 //
 // An iNES file consists of the following sections, in order:
 //
-// Header (16 bytes)
+// Header (16 bytes), see ParseHeader
 // Trainer, if present (0 or 512 bytes)
 // PRG ROM data (16384 * x bytes)
 // CHR ROM data, if present (8192 * y bytes)
 // PlayChoice INST-ROM, if present (0 or 8192 bytes)
 // PlayChoice PROM, if present (16 bytes Data, 16 bytes CounterOut) (this is often missing, see PC10 ROM-Images for details)
 //
-// The format of the header is as follows:
+// NES 2.0 files can contain ROM sizes that are not a multiple of the bank size and miscellaneous ROMs after the CHR ROM.
 //
-// 0-3: Constant $4E $45 $53 $1A ("NES" followed by MS-DOS end-of-file)
-// 4: Size of PRG ROM in 16 KB units
-// 5: Size of CHR ROM in 8 KB units (Value 0 means the board uses CHR RAM)
-// 6: Flags 6 - Mapper, mirroring, battery, trainer
-// 7: Flags 7 - Mapper, VS/Playchoice, NES 2.0
-// 8: Flags 8 - PRG-RAM size (rarely used extension)
-// 9: Flags 9 - TV system (rarely used extension)
-// 10: Flags 10 - TV system, PRG-RAM presence (unofficial, rarely used extension)
-// 11-15: Unused padding (should be filled with zero, but some rippers put their name across bytes 7-15)
-func Load(rom []byte, bus bus.Bus) *Cartridge {
-	prgRomSize := rom[4]
-	chrRomSize := rom[5]
-	chrRam := false
-	if chrRomSize == 0 {
-		chrRomSize = 1
-		chrRam = true
+// Load returns the errors of ParseHeader, a TruncatedError if the file ends before the announced ROM data and an
+// UnsupportedMapperError if the mapper is not emulated.
+func Load(rom []byte, bus bus.Bus) (*Cartridge, error) {
+	header, err := ParseHeader(rom)
+	if err != nil {
+		return nil, err
 	}
-	mapperNumberLo := rom[6] >> 4
-	mapperNumberHi := rom[7] >> 4
-	mapperNumber := mapperNumberHi<<4 | mapperNumberLo
 
-	trainerPresent := (rom[6]&0b0000_0100)>>2 == 1
-	mirrorBit := rom[6]&0b0000_0001 == 1
-
-	prgRom := make([]uint8, int(prgRomSize)*0x4000)
-	chrRom := make([]uint8, int(chrRomSize)*0x2000)
+	if header.PrgRomSize == 0 {
+		return nil, fmt.Errorf("%w: no PRG ROM", ErrInvalidHeader)
+	}
 
-	ptr := 0x10
-	if trainerPresent {
+	ptr := HeaderSize
+	if header.Trainer {
+		if len(rom) < ptr+0x200 {
+			return nil, &TruncatedError{Section: "trainer", Expected: 0x200, Got: len(rom) - ptr}
+		}
 		log.Println("Trainer present!")
 		ptr += 0x200
 	}
-	for i := 0; i < int(prgRomSize)*0x4000; i++ {
-		prgRom[i] = rom[ptr]
-		ptr++
+	if len(rom) < ptr+header.PrgRomSize {
+		return nil, &TruncatedError{Section: "PRG ROM", Expected: header.PrgRomSize, Got: len(rom) - ptr}
 	}
-	if !chrRam {
-		for i := 0; i < int(chrRomSize)*0x2000; i++ {
-			chrRom[i] = rom[ptr]
-			ptr++
-		}
+	if len(rom) < ptr+header.PrgRomSize+header.ChrRomSize {
+		return nil, &TruncatedError{Section: "CHR ROM", Expected: header.ChrRomSize, Got: len(rom) - ptr - header.PrgRomSize}
+	}
+
+	// The mappers expect whole banks
+	prgRomSize := (header.PrgRomSize + 0x3FFF) / 0x4000
+	prgRom := make([]uint8, prgRomSize*0x4000)
+	copy(prgRom, rom[ptr:ptr+header.PrgRomSize])
+	ptr += header.PrgRomSize
+
+	chrRam := header.ChrRomSize == 0
+	var chrRom []uint8
+	if chrRam {
+		chrRom = make([]uint8, header.ChrRamTotal())
+	} else {
+		chrRom = make([]uint8, header.ChrRomSize)
+		copy(chrRom, rom[ptr:ptr+header.ChrRomSize])
+	}
+	if len(chrRom) < 0x2000 {
+		// The pattern tables are always mapped, even if the cartridge has no CHR memory
+		chrRom = append(chrRom, make([]uint8, 0x2000-len(chrRom))...)
+	}
+	chrRomSize := (len(chrRom) + 0x1FFF) / 0x2000
+	if prgRomSize > 0xFF || chrRomSize > 0xFF {
+		return nil, fmt.Errorf("%w: ROM larger than 4 MB", ErrInvalidHeader)
 	}
 
 	c := &Cartridge{
 		Bus:        bus,
-		PrgRomSize: prgRomSize,
+		Header:     header,
+		PrgRomSize: uint8(prgRomSize),
 		PrgRom:     prgRom,
-		ChrRomSize: chrRomSize,
+		ChrRomSize: uint8(chrRomSize),
 		ChrRom:     chrRom,
 		ChrRam:     chrRam,
-		MirrorBit:  mirrorBit,
+		MirrorBit:  header.VerticalMirroring,
 		Identifier: md5.Sum(rom),
 	}
 
+	mapperNumber := header.Mapper
 	switch mapperNumber {
 	case 0:
 		c.Mapper = NewMapper000(c)
@@ -98,16 +112,108 @@ func Load(rom []byte, bus bus.Bus) *Cartridge {
 	case 3:
 		c.Mapper = NewMapper003(c)
 		log.Println("Created Cartridge with Mapper 003")
-	case 4:
-		c.Mapper = NewMapper004(c)
-		log.Println("Created Cartridge with Mapper 004")
+	case 4, 118, 119:
+		m := NewMapper004(c)
+		c.Mapper = m
+		log.Printf("Created Cartridge with Mapper %03d (%s)", mapperNumber, m.board())
+	case 5:
+		c.Mapper = NewMapper005(c)
+		log.Println("Created Cartridge with Mapper 005")
 	case 7:
 		c.Mapper = NewMapper007(c)
 		log.Println("Created Cartridge with Mapper 007")
+	case 9:
+		c.Mapper = NewMapper009(c)
+		log.Println("Created Cartridge with Mapper 009")
+	case 10:
+		c.Mapper = NewMapper010(c)
+		log.Println("Created Cartridge with Mapper 010")
+	case 11:
+		c.Mapper = NewMapper011(c)
+		log.Println("Created Cartridge with Mapper 011")
+	case 16, 153, 159:
+		c.Mapper = NewMapper016(c)
+		log.Printf("Created Cartridge with Mapper %03d", mapperNumber)
+	case 19:
+		c.Mapper = NewMapper019(c)
+		log.Println("Created Cartridge with Mapper 019")
+	case 21, 22, 23, 25:
+		m := NewMapper021(c)
+		c.Mapper = m
+		log.Printf("Created Cartridge with Mapper %03d (%s)", mapperNumber, m.board.name)
+	case 24, 26:
+		c.Mapper = NewMapper024(c)
+		log.Printf("Created Cartridge with Mapper %03d", mapperNumber)
+	case 34:
+		c.Mapper = NewMapper034(c)
+		log.Println("Created Cartridge with Mapper 034")
+	case 66:
+		c.Mapper = NewMapper066(c)
+		log.Println("Created Cartridge with Mapper 066")
+	case 69:
+		c.Mapper = NewMapper069(c)
+		log.Println("Created Cartridge with Mapper 069")
+	case 71:
+		c.Mapper = NewMapper071(c)
+		log.Println("Created Cartridge with Mapper 071")
+	case 79:
+		c.Mapper = NewMapper079(c)
+		log.Println("Created Cartridge with Mapper 079")
+	case 85:
+		c.Mapper = NewMapper085(c)
+		log.Println("Created Cartridge with Mapper 085")
+	case 180:
+		c.Mapper = NewMapper180(c)
+		log.Println("Created Cartridge with Mapper 180")
+	case 206:
+		c.Mapper = NewMapper206(c)
+		log.Println("Created Cartridge with Mapper 206")
+	case 232:
+		c.Mapper = NewMapper232(c)
+		log.Println("Created Cartridge with Mapper 232")
 	default:
-		log.Printf("Unsupported ROM File with Mapper %00d", mapperNumber)
-		return nil
+		return nil, &UnsupportedMapperError{Mapper: mapperNumber, Submapper: header.Submapper}
 	}
 
-	return c
+	return c, nil
+}
+
+// Serialize saves or loads the CHR RAM and the state of the mapper
+func (c *Cartridge) Serialize(s *savestate.Serializer) {
+	if c.ChrRam {
+		s.Bytes(c.ChrRom)
+	}
+	c.Mapper.Serialize(s)
+}
+
+// readRam reads from a RAM on the cartridge. Smaller RAMs are mirrored. Without RAM, the data bus is not driven and
+// the high byte of the address is returned, because it was the last value on the bus for most instructions.
+func readRam(ram []uint8, index int, location uint16) uint8 {
+	if len(ram) == 0 {
+		return uint8(location >> 8)
+	}
+	return ram[index%len(ram)]
+}
+
+// writeRam writes to a RAM on the cartridge, see readRam
+func writeRam(ram []uint8, index int, data uint8) {
+	if len(ram) == 0 {
+		return
+	}
+	ram[index%len(ram)] = data
+}
+
+// busConflict returns the value that is written to a register of a board with bus conflicts. The PRG ROM drives the
+// data bus at the same time as the CPU and the register sees the AND of both values.
+func busConflict(m Mapper, location uint16, data uint8) uint8 {
+	return data & m.CPURead(location)
+}
+
+// loadRam loads a save into a battery backed RAM
+func loadRam(ram []uint8, data []uint8) error {
+	if len(data) != len(ram) {
+		return &SaveSizeError{Expected: len(ram), Got: len(data)}
+	}
+	copy(ram, data)
+	return nil
 }
@@ -0,0 +1,66 @@
+package cartridge
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLoadErrors(t *testing.T) {
+	// iNES, 2 * 16 KB PRG ROM, 1 * 8 KB CHR ROM
+	rom := append(header(2, 1), make([]byte, 0x8000+0x2000)...)
+	if _, err := Load(rom, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(append([]byte("PK\x03\x04"), rom[4:]...), nil); !errors.Is(err, ErrBadMagic) {
+		t.Errorf("expected ErrBadMagic, got %v", err)
+	}
+
+	var truncated *TruncatedError
+	if _, err := Load(rom[:0x1000], nil); !errors.As(err, &truncated) || truncated.Section != "PRG ROM" {
+		t.Errorf("expected truncated PRG ROM, got %v", err)
+	}
+	if _, err := Load(rom[:len(rom)-1], nil); !errors.As(err, &truncated) || truncated.Section != "CHR ROM" {
+		t.Errorf("expected truncated CHR ROM, got %v", err)
+	} else if truncated.Expected != 0x2000 || truncated.Got != 0x1FFF {
+		t.Errorf("unexpected sizes in %v", err)
+	}
+	trainer := append([]byte{}, rom...)
+	trainer[6] |= 0b100
+	if _, err := Load(trainer[:0x100], nil); !errors.As(err, &truncated) || truncated.Section != "trainer" {
+		t.Errorf("expected truncated trainer, got %v", err)
+	}
+
+	// NES 2.0, mapper 0x123, submapper 4
+	unsupported := append([]byte{}, rom...)
+	unsupported[6] = 0x30
+	unsupported[7] = 0x28
+	unsupported[8] = 0x41
+	var mapper *UnsupportedMapperError
+	if _, err := Load(unsupported, nil); !errors.As(err, &mapper) || mapper.Mapper != 0x123 || mapper.Submapper != 4 {
+		t.Errorf("expected unsupported mapper, got %v", err)
+	} else if err.Error() != "unsupported mapper 291, submapper 4" {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+}
+
+func TestLoadSave(t *testing.T) {
+	// MMC1 with battery
+	rom := append(header(2, 1, 0x12), make([]byte, 0x8000+0x2000)...)
+	c, err := Load(rom, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	save := c.Save()
+	save[0x10] = 0x42
+	if err := c.Load(save); err != nil {
+		t.Fatal(err)
+	}
+	if c.CPURead(0x6010) != 0x42 {
+		t.Error("save has not been loaded")
+	}
+	var size *SaveSizeError
+	if err := c.Load(save[:0x2000]); !errors.As(err, &size) || size.Expected != len(save) || size.Got != 0x2000 {
+		t.Errorf("expected save size error, got %v", err)
+	}
+}
@@ -1,9 +1,9 @@
 package cartridge
 
 import (
-	"github.com/exp625/gones/internal/textutil"
+	"io"
 )
 
 type Debugger interface {
-	DebugDisplay(t *textutil.Text)
+	DebugDisplay(w io.Writer)
 }
@@ -0,0 +1,79 @@
+package cartridge_test
+
+import (
+	"testing"
+)
+
+// The PRG ROM of the test ROMs is filled with NOP ($EA) except for the first byte of every bank, so writes to $8001
+// conflict with $EA on boards with bus conflicts
+
+func TestDiscreteMappers(t *testing.T) {
+	type read struct {
+		location uint16
+		// 8 KB PRG bank or 1 KB CHR bank at the location
+		bank uint8
+	}
+	for _, test := range []struct {
+		name      string
+		mapper    uint16
+		submapper uint8
+		chrBanks  int
+		writes    [][2]uint16
+		prg       []read
+		chr       []read
+	}{
+		{"Color Dreams", 11, 0, 128, [][2]uint16{{0x8001, 0xFF}}, []read{{0x8000, 8}}, []read{{0x0400, 14*8 + 1}}},
+		{"GxROM", 66, 0, 32, [][2]uint16{{0x8001, 0x33}}, []read{{0x8000, 8}}, []read{{0x0400, 2*8 + 1}}},
+		{"BNROM", 34, 2, 8, [][2]uint16{{0x8001, 0x03}}, []read{{0x8000, 8}}, []read{{0x1000, 4}}},
+		{"BNROM without submapper", 34, 0, 8, [][2]uint16{{0x8001, 0x03}}, []read{{0x8000, 8}}, nil},
+		{"NINA-001", 34, 1, 64, [][2]uint16{{0x7FFD, 1}, {0x7FFE, 3}, {0x7FFF, 5}},
+			[]read{{0x8000, 4}, {0x7FFE, 3}}, []read{{0x0000, 12}, {0x1400, 21}}},
+		{"NINA-001 without submapper", 34, 0, 64, [][2]uint16{{0x7FFD, 1}, {0x7FFE, 3}},
+			[]read{{0x8000, 4}}, []read{{0x0000, 12}}},
+		{"BF909x", 71, 0, 8, [][2]uint16{{0xC000, 3}}, []read{{0x8000, 6}, {0xA000, 7}, {0xC000, 30}}, nil},
+		{"NINA-03/06", 79, 0, 64, [][2]uint16{{0x5F00, 0b1101}, {0x4000, 0}},
+			[]read{{0x8000, 4}}, []read{{0x0000, 40}}},
+		{"Crazy Climber", 180, 0, 8, [][2]uint16{{0x8001, 0x07}}, []read{{0x8000, 0}, {0xC000, 4}}, nil},
+		{"Namco 108", 206, 0, 64, [][2]uint16{{0x8000, 6}, {0x8001, 3}, {0x9FFE, 7}, {0x9FFF, 4}, {0x8000, 0},
+			{0x8001, 5}, {0x8000, 2}, {0x8001, 9}},
+			[]read{{0x8000, 3}, {0xA000, 4}, {0xC000, 30}, {0xE000, 31}}, []read{{0x0000, 4}, {0x0400, 5}, {0x1000, 9}}},
+		{"Quattro", 232, 0, 8, [][2]uint16{{0x8000, 0b1_0000}, {0xC000, 1}}, []read{{0x8000, 18}, {0xC000, 22}}, nil},
+		{"Aladdin Deck Enhancer", 232, 1, 8, [][2]uint16{{0x8000, 0b1_0000}, {0xC000, 1}},
+			[]read{{0x8000, 10}, {0xC000, 14}}, nil},
+	} {
+		c, _ := newTestCartridge(t, test.mapper, test.submapper, 32, test.chrBanks)
+		for _, write := range test.writes {
+			c.CPUWrite(write[0], uint8(write[1]))
+		}
+		for _, r := range test.prg {
+			if data := c.CPURead(r.location); data != r.bank {
+				t.Errorf("%s: expected %d at $%04X, got %d", test.name, r.bank, r.location, data)
+			}
+		}
+		for _, r := range test.chr {
+			if data := c.PPURead(r.location); data != r.bank {
+				t.Errorf("%s: expected CHR bank %d at $%04X, got %d", test.name, r.bank, r.location, data)
+			}
+		}
+	}
+}
+
+func TestMapper071Mirroring(t *testing.T) {
+	// Fire Hawk
+	c, _ := newTestCartridge(t, 71, 1, 8, 8)
+	c.CPUWrite(0x8000, 0x10)
+	if location := c.PPUMap(0x2000); location != 0x2400 {
+		t.Errorf("expected one-screen mirroring of the upper bank, got $%04X", location)
+	}
+
+	// Without a submapper, the mirroring of the header is used until the register is written
+	c, _ = newTestCartridge(t, 71, 0, 8, 8)
+	c.CPUWrite(0x8000, 0x10)
+	if location := c.PPUMap(0x2400); location != 0x2000 {
+		t.Errorf("expected horizontal mirroring, got $%04X", location)
+	}
+	c.CPUWrite(0x9000, 0x10)
+	if location := c.PPUMap(0x2000); location != 0x2400 {
+		t.Errorf("expected one-screen mirroring of the upper bank, got $%04X", location)
+	}
+}
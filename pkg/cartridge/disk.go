@@ -0,0 +1,162 @@
+package cartridge
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"github.com/exp625/gones/pkg/bus"
+	"log"
+)
+
+// From NES DEV WIKI https://wiki.nesdev.org/w/index.php?title=FDS_disk_format and
+// https://wiki.nesdev.org/w/index.php?title=FDS_file_format
+
+// DiskSideSize is the size of a disk side in an FDS file
+const DiskSideSize = 65500
+
+// BIOSSize is the size of the BIOS ROM of the Famicom Disk System
+const BIOSSize = 0x2000
+
+// diskMagic is the constant at the start of the optional fwNES header: "FDS" followed by MS-DOS end-of-file
+var diskMagic = []byte("FDS\x1a")
+
+// diskVerification is the start of the disk info block of every side
+var diskVerification = []byte("\x01*NINTENDO-HVC*")
+
+// Gaps between the blocks on the disk in bytes. The FDS file omits the gaps and the CRCs of the blocks.
+const (
+	// Gap at the start of the disk side, 28300 bits
+	diskLeadIn = 28300 / 8
+	// Gap after every block, 976 bits
+	diskBlockGap = 976 / 8
+)
+
+// IsDisk returns true if the file is an FDS disk image, with or without the fwNES header
+func IsDisk(data []byte) bool {
+	return bytes.HasPrefix(data, diskMagic) || bytes.HasPrefix(data, diskVerification)
+}
+
+// LoadDisk loads the RAM adapter of the Famicom Disk System with the 8 KB BIOS and inserts side A of the disk image.
+//
+// An FDS file consists of the following sections, in order:
+//
+// fwNES header, if present (16 bytes): "FDS" followed by MS-DOS end-of-file, number of sides, zero padding
+// Disk sides (65500 * x bytes)
+//
+// LoadDisk returns an error wrapping ErrInvalidDisk or ErrInvalidBIOS if one of the files can not be used.
+func LoadDisk(image []byte, bios []byte, bus bus.Bus) (*Cartridge, error) {
+	if len(bios) != BIOSSize {
+		return nil, fmt.Errorf("%w: expected %d bytes, got %d", ErrInvalidBIOS, BIOSSize, len(bios))
+	}
+	data := image
+	if bytes.HasPrefix(data, diskMagic) {
+		if len(data) < HeaderSize {
+			return nil, &TruncatedError{Section: "header", Expected: HeaderSize, Got: len(data)}
+		}
+		data = data[HeaderSize:]
+	}
+	if len(data) == 0 || len(data)%DiskSideSize != 0 {
+		return nil, fmt.Errorf("%w: size %d is not a multiple of %d bytes", ErrInvalidDisk, len(data), DiskSideSize)
+	}
+	sides := make([][]uint8, len(data)/DiskSideSize)
+	for i := range sides {
+		sides[i] = append([]uint8{}, data[i*DiskSideSize:(i+1)*DiskSideSize]...)
+		if !bytes.HasPrefix(sides[i], diskVerification) {
+			return nil, fmt.Errorf("%w: side %d has no disk info block", ErrInvalidDisk, i)
+		}
+	}
+
+	c := &Cartridge{
+		Bus: bus,
+		// Mapper 20 is reserved for the Famicom Disk System
+		Header: &Header{
+			Format:     FormatINES,
+			Mapper:     20,
+			PrgRamSize: 0x8000,
+			ChrRamSize: 0x2000,
+		},
+		PrgRom:     append([]uint8{}, bios...),
+		ChrRomSize: 1,
+		ChrRom:     make([]uint8, 0x2000),
+		ChrRam:     true,
+		Identifier: md5.Sum(image),
+	}
+	c.Mapper = NewMapper020(c, sides)
+	log.Printf("Created Cartridge with Mapper 020 (Famicom Disk System, %d sides)", len(sides))
+	return c, nil
+}
+
+// diskTrack returns the disk side as it is read by the drive: with the gaps, the start mark before every block and a
+// CRC after every block. The CRCs are not checked by the RAM adapter and are filled with a constant.
+func diskTrack(side []uint8) []uint8 {
+	track := make([]uint8, diskLeadIn, diskLeadIn+DiskSideSize)
+	fileSize := 0
+	for ptr := 0; ptr < len(side); {
+		length := diskBlockLength(side[ptr], fileSize)
+		if length == 0 || ptr+length > len(side) {
+			// The rest of the side is unused
+			break
+		}
+		if side[ptr] == 3 {
+			fileSize = int(side[ptr+13]) | int(side[ptr+14])<<8
+		}
+		track = append(track, 0x80)
+		track = append(track, side[ptr:ptr+length]...)
+		track = append(track, 0x4D, 0x62)
+		track = append(track, make([]uint8, diskBlockGap)...)
+		ptr += length
+	}
+	if len(track) < diskLeadIn+DiskSideSize {
+		// Free space for new files
+		track = append(track, make([]uint8, diskLeadIn+DiskSideSize-len(track))...)
+	}
+	return track
+}
+
+// diskSide reverses diskTrack: the gaps, start marks and CRCs are removed. Blocks that do not fit into the side are
+// dropped.
+func diskSide(track []uint8) []uint8 {
+	side := make([]uint8, 0, DiskSideSize)
+	fileSize := 0
+	for ptr := 0; ptr < len(track); ptr++ {
+		if track[ptr] != 0x80 {
+			// Gap
+			continue
+		}
+		ptr++
+		if ptr >= len(track) {
+			break
+		}
+		length := diskBlockLength(track[ptr], fileSize)
+		if length == 0 || ptr+length > len(track) || len(side)+length > DiskSideSize {
+			break
+		}
+		if track[ptr] == 3 {
+			fileSize = int(track[ptr+13]) | int(track[ptr+14])<<8
+		}
+		side = append(side, track[ptr:ptr+length]...)
+		// Skip the block and the CRC, the loop skips the last byte
+		ptr += length + 1
+	}
+	return append(side, make([]uint8, DiskSideSize-len(side))...)
+}
+
+// diskBlockLength returns the length of a block including the block code, 0 if the block code is not valid. The size
+// of a file data block is given by the file header block before it.
+func diskBlockLength(code uint8, fileSize int) int {
+	switch code {
+	case 1:
+		// Disk info block
+		return 56
+	case 2:
+		// File amount block
+		return 2
+	case 3:
+		// File header block
+		return 16
+	case 4:
+		// File data block
+		return 1 + fileSize
+	}
+	return 0
+}
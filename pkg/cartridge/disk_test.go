@@ -0,0 +1,33 @@
+package cartridge
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDiskTrack(t *testing.T) {
+	side := make([]uint8, 0, DiskSideSize)
+	info := make([]uint8, 56)
+	copy(info, diskVerification)
+	side = append(side, info...)
+	side = append(side, 0x02, 2)
+	for i, size := range []int{0x100, 3} {
+		side = append(side, 0x03, uint8(i), uint8(i), 'F', 'I', 'L', 'E', ' ', ' ', ' ', uint8('0'+i), 0, 0, uint8(size), uint8(size>>8), 0)
+		side = append(side, 0x04)
+		side = append(side, bytes.Repeat([]uint8{0x80}, size)...)
+	}
+	length := len(side)
+	side = append(side, make([]uint8, DiskSideSize-length)...)
+
+	track := diskTrack(side)
+	if len(track) != diskLeadIn+DiskSideSize {
+		t.Errorf("expected %d bytes, got %d", diskLeadIn+DiskSideSize, len(track))
+	}
+	// Lead-in, start mark, disk info block, CRC and gap
+	if track[diskLeadIn] != 0x80 || track[diskLeadIn+1] != 0x01 || track[diskLeadIn+57+2+diskBlockGap] != 0x80 {
+		t.Error("unexpected layout of the track")
+	}
+	if !bytes.Equal(diskSide(track), side) {
+		t.Error("expected the side to be restored from the track")
+	}
+}
@@ -0,0 +1,209 @@
+package cartridge
+
+import (
+	"github.com/exp625/gones/internal/savestate"
+)
+
+// From NES DEV WIKI https://wiki.nesdev.org/w/index.php?title=Bandai_FCG_board
+
+// eeprom is a serial EEPROM on the I²C bus, the 24C02 with 256 bytes or the X24C01 with 128 bytes. The CPU is the
+// master and drives the clock (SCL) and the data line (SDA) through a register of the mapper. The data line is read
+// back through the mapper while the EEPROM drives it.
+//
+// A transfer starts when SDA falls while SCL is high and stops when SDA rises while SCL is high. Between them, bytes
+// are transferred with 8 data bits and an acknowledge bit. The receiver samples the bits on the rising edge of SCL and
+// the sender changes SDA while SCL is low. The receiver acknowledges a byte by pulling SDA low.
+//
+// 24C02:  Start, 1010xxxR, word address, data... (write) or Start, 1010xxx1, data... (read from the current address)
+// X24C01: Start, 7-bit word address and R, data... The X24C01 sends all bits with the least significant bit first.
+type eeprom struct {
+	data []uint8
+	// X24C01 protocol instead of the 24C02 protocol
+	x24c01 bool
+
+	// Lines driven by the CPU
+	scl, sda bool
+	// SDA driven by the EEPROM, false pulls the line low
+	output bool
+
+	state   uint8
+	address uint8
+	// Received bits and the number of bits of the current byte. Bit 8 is the acknowledge bit.
+	shift uint8
+	bit   uint8
+}
+
+// States of the EEPROM
+const (
+	// Waiting for a start condition
+	eepromIdle = iota
+	// Receiving the device address (24C02 only)
+	eepromDevice
+	// Receiving the word address
+	eepromAddress
+	// Receiving data
+	eepromWrite
+	// Sending data
+	eepromRead
+)
+
+// newEEPROM creates a 24C02 with 256 bytes or a X24C01 with 128 bytes
+func newEEPROM(size int) *eeprom {
+	return &eeprom{
+		data:   make([]uint8, size),
+		x24c01: size == 128,
+		scl:    true,
+		sda:    true,
+		output: true,
+	}
+}
+
+// write sets the lines driven by the CPU
+func (e *eeprom) write(scl bool, sda bool) {
+	switch {
+	case scl && e.scl && sda != e.sda:
+		if !sda {
+			// Start condition
+			e.state = eepromDevice
+			if e.x24c01 {
+				e.state = eepromAddress
+			}
+			e.bit = 0
+		} else {
+			e.state = eepromIdle
+		}
+		e.output = true
+	case scl && !e.scl:
+		e.rise(sda)
+	case !scl && e.scl:
+		e.fall()
+	}
+	e.scl, e.sda = scl, sda
+}
+
+// read returns the level of SDA. The line is pulled low if the CPU or the EEPROM drives it low.
+func (e *eeprom) read() bool {
+	return e.sda && e.output
+}
+
+// rise handles the rising edge of SCL, the receiver samples SDA
+func (e *eeprom) rise(sda bool) {
+	switch {
+	case e.state == eepromIdle:
+	case e.bit < 8:
+		if e.state != eepromRead {
+			e.shift <<= 1
+			if sda {
+				e.shift |= 0b1
+			}
+		}
+		e.bit++
+	case e.bit == 8:
+		// The EEPROM releases SDA for the acknowledge of the CPU after a sent byte. Otherwise it is the acknowledge
+		// of the EEPROM for the byte that started the read.
+		if e.state == eepromRead && e.output {
+			if sda {
+				// No acknowledge, the CPU ends the read
+				e.state = eepromIdle
+			} else {
+				e.address = uint8((int(e.address) + 1) % len(e.data))
+			}
+		}
+		e.bit++
+	}
+}
+
+// fall handles the falling edge of SCL, the sender changes SDA
+func (e *eeprom) fall() {
+	switch {
+	case e.state == eepromIdle:
+		e.output = true
+	case e.bit < 8:
+		if e.state == eepromRead {
+			e.output = e.dataBit()
+		}
+	case e.bit == 8:
+		if e.state == eepromRead {
+			// Release SDA for the acknowledge of the CPU
+			e.output = true
+		} else {
+			e.output = !e.receive()
+		}
+	case e.bit == 9:
+		e.bit = 0
+		e.output = true
+		if e.state == eepromRead {
+			e.output = e.dataBit()
+		}
+	}
+}
+
+// dataBit returns the next bit of the current byte
+func (e *eeprom) dataBit() bool {
+	data := e.data[e.address]
+	if e.x24c01 {
+		return data>>e.bit&0b1 == 1
+	}
+	return data>>(7-e.bit)&0b1 == 1
+}
+
+// receive handles a received byte and returns whether it is acknowledged
+func (e *eeprom) receive() bool {
+	data := e.shift
+	if e.x24c01 {
+		data = reverseBits(data)
+	}
+	switch e.state {
+	case eepromDevice:
+		if data>>4 != 0b1010 {
+			// Not addressed
+			e.state = eepromIdle
+			return false
+		}
+		e.state = eepromAddress
+		if data&0b1 == 1 {
+			e.state = eepromRead
+		}
+	case eepromAddress:
+		if !e.x24c01 {
+			e.address = data
+			e.state = eepromWrite
+			break
+		}
+		// 7-bit word address followed by R
+		e.address = data & 0x7F
+		e.state = eepromWrite
+		if data>>7 == 1 {
+			e.state = eepromRead
+		}
+	case eepromWrite:
+		e.data[e.address] = data
+		// The address wraps around in a page of 8 (24C02) or 4 bytes (X24C01)
+		page := uint8(0b111)
+		if e.x24c01 {
+			page = 0b11
+		}
+		e.address = e.address&^page | (e.address+1)&page
+	}
+	return true
+}
+
+// reverseBits returns the byte with the order of the bits reversed
+func reverseBits(data uint8) uint8 {
+	var reversed uint8
+	for i := 0; i < 8; i++ {
+		reversed = reversed<<1 | data>>i&0b1
+	}
+	return reversed
+}
+
+func (e *eeprom) serialize(s *savestate.Serializer) {
+	s.Bytes(e.data)
+	s.Bool(&e.scl)
+	s.Bool(&e.sda)
+	s.Bool(&e.output)
+	s.Uint8(&e.state)
+	s.Uint8(&e.address)
+	s.Uint8(&e.shift)
+	s.Uint8(&e.bit)
+}
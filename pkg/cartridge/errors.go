@@ -0,0 +1,52 @@
+package cartridge
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrBadMagic is returned by Load if the file does not start with "NES" followed by MS-DOS end-of-file
+	ErrBadMagic = errors.New("not an iNES file")
+	// ErrInvalidHeader is returned by Load if the header contains impossible values
+	ErrInvalidHeader = errors.New("invalid iNES header")
+	// ErrInvalidDisk is returned by LoadDisk if the file is not a valid FDS disk image
+	ErrInvalidDisk = errors.New("invalid FDS disk image")
+	// ErrInvalidBIOS is returned by LoadDisk if the BIOS of the Famicom Disk System does not have 8 KB
+	ErrInvalidBIOS = errors.New("invalid FDS BIOS")
+)
+
+// TruncatedError is returned by Load if the file ends before a section announced by the header
+type TruncatedError struct {
+	// Section is "header", "trainer", "PRG ROM" or "CHR ROM"
+	Section  string
+	Expected int
+	Got      int
+}
+
+func (e *TruncatedError) Error() string {
+	return fmt.Sprintf("truncated ROM file: %s needs %d bytes, only %d bytes left", e.Section, e.Expected, e.Got)
+}
+
+// UnsupportedMapperError is returned by Load if the mapper of the cartridge is not emulated
+type UnsupportedMapperError struct {
+	Mapper    uint16
+	Submapper uint8
+}
+
+func (e *UnsupportedMapperError) Error() string {
+	if e.Submapper != 0 {
+		return fmt.Sprintf("unsupported mapper %d, submapper %d", e.Mapper, e.Submapper)
+	}
+	return fmt.Sprintf("unsupported mapper %d", e.Mapper)
+}
+
+// SaveSizeError is returned by Mapper.Load if the save does not fit the battery backed RAM of the cartridge
+type SaveSizeError struct {
+	Expected int
+	Got      int
+}
+
+func (e *SaveSizeError) Error() string {
+	return fmt.Sprintf("save has %d bytes, but the cartridge has %d bytes of RAM", e.Got, e.Expected)
+}
@@ -0,0 +1,288 @@
+package cartridge
+
+import (
+	"github.com/exp625/gones/internal/savestate"
+	"github.com/exp625/gones/pkg/apu"
+)
+
+// From NES DEV WIKI https://wiki.nesdev.org/w/index.php?title=FDS_audio
+
+// The sound channel of the RAM adapter plays a wavetable of 64 6-bit samples. The pitch of the wave can be modulated
+// by a second unit that steps through a table of 64 3-bit deltas. Both units have a volume envelope.
+
+// fdsModDeltas are the changes of the modulation counter for the entries of the modulation table. Entry 4 resets the
+// counter to 0.
+var fdsModDeltas = [8]int32{0, 1, 2, 4, 0, -4, -2, -1}
+
+// fdsMasterVolumes are the multipliers of the master volume ($4089): 2/2, 2/3, 2/4 and 2/5
+var fdsMasterVolumes = [4]uint32{36, 24, 17, 14}
+
+// fdsEnvelope is the volume envelope of the wave ($4080) or the gain of the modulation ($4084) and the frequency of
+// the unit
+type fdsEnvelope struct {
+	// 7  bit  0
+	// ---- ----
+	// MDSS SSSS
+	// |||| ||||
+	// ||++-++++- Speed of the envelope, the gain if the envelope is disabled
+	// |+-------- Direction (0: decrease; 1: increase)
+	// +--------- Disable the envelope
+	control uint8
+	gain    uint8
+	timer   uint32
+
+	// 12-bit frequency
+	frequency uint16
+}
+
+// write writes the envelope register
+func (e *fdsEnvelope) write(data uint8, masterSpeed uint8) {
+	e.control = data
+	if e.control&0b1000_0000 != 0 {
+		e.gain = data & 0b11_1111
+	}
+	e.resetTimer(masterSpeed)
+}
+
+// resetTimer restarts the time until the next step of the envelope
+func (e *fdsEnvelope) resetTimer(masterSpeed uint8) {
+	e.timer = 8 * (uint32(e.control&0b11_1111) + 1) * uint32(masterSpeed)
+}
+
+// clock clocks the envelope for one CPU cycle. The gain changes by one step until it reaches 0 or 32.
+func (e *fdsEnvelope) clock(masterSpeed uint8) {
+	if e.control&0b1000_0000 != 0 || masterSpeed == 0 {
+		return
+	}
+	if e.timer > 0 {
+		e.timer--
+	}
+	if e.timer != 0 {
+		return
+	}
+	e.resetTimer(masterSpeed)
+	if e.control&0b0100_0000 != 0 {
+		if e.gain < 32 {
+			e.gain++
+		}
+	} else if e.gain > 0 {
+		e.gain--
+	}
+}
+
+func (e *fdsEnvelope) serialize(s *savestate.Serializer) {
+	s.Uint8(&e.control)
+	s.Uint8(&e.gain)
+	s.Uint32(&e.timer)
+	s.Uint16(&e.frequency)
+}
+
+type fdsAudio struct {
+	wave [64]uint8
+	// Write enable of the wavetable and master volume ($4089)
+	waveWrite    bool
+	masterVolume uint8
+	// Halt of the wave and of the envelopes ($4083)
+	haltWave      bool
+	haltEnvelopes bool
+	// Multiplier of the envelope timers ($408A)
+	masterSpeed uint8
+
+	volume fdsEnvelope
+	mod    fdsEnvelope
+
+	// The wave position is advanced whenever the accumulator overflows 16 bits
+	waveAccumulator uint32
+	wavePosition    uint8
+
+	modTable    [64]uint8
+	modPosition uint8
+	// Halt of the modulation, allows writing the modulation table ($4087)
+	haltMod        bool
+	modAccumulator uint32
+	// 7-bit signed modulation counter ($4085)
+	modCounter int32
+
+	// Current output level, 0-63
+	level uint8
+}
+
+func (a *fdsAudio) read(location uint16) (uint8, bool) {
+	switch {
+	case 0x4040 <= location && location <= 0x407F:
+		return a.wave[location&0x3F] | 0x40, true
+	case location == 0x4090:
+		return a.volume.gain | 0x40, true
+	case location == 0x4092:
+		return a.mod.gain | 0x40, true
+	}
+	return 0, false
+}
+
+func (a *fdsAudio) write(location uint16, data uint8) bool {
+	switch {
+	case 0x4040 <= location && location <= 0x407F:
+		if a.waveWrite {
+			a.wave[location&0x3F] = data & 0b11_1111
+		}
+	case location == 0x4080:
+		a.volume.write(data, a.masterSpeed)
+	case location == 0x4082:
+		a.volume.frequency = a.volume.frequency&0x0F00 | uint16(data)
+	case location == 0x4083:
+		// 7  bit  0
+		// ---- ----
+		// HEFF FFFF
+		// |||| ||||
+		// ||++-++++- Bits 8-11 of the wave frequency
+		// |+-------- Halt the envelopes
+		// +--------- Halt the wave and reset its position
+		a.volume.frequency = a.volume.frequency&0x00FF | uint16(data&0x0F)<<8
+		a.haltEnvelopes = data&0b0100_0000 != 0
+		a.haltWave = data&0b1000_0000 != 0
+		if a.haltWave {
+			a.waveAccumulator = 0
+			a.wavePosition = 0
+		}
+		if a.haltEnvelopes {
+			a.volume.resetTimer(a.masterSpeed)
+			a.mod.resetTimer(a.masterSpeed)
+		}
+	case location == 0x4084:
+		a.mod.write(data, a.masterSpeed)
+	case location == 0x4085:
+		a.setModCounter(int32(data & 0x7F))
+	case location == 0x4086:
+		a.mod.frequency = a.mod.frequency&0x0F00 | uint16(data)
+	case location == 0x4087:
+		a.mod.frequency = a.mod.frequency&0x00FF | uint16(data&0x0F)<<8
+		a.haltMod = data&0b1000_0000 != 0
+		if a.haltMod {
+			a.modAccumulator = 0
+		}
+	case location == 0x4088:
+		// Every entry is written twice, the table can only be written while the modulation is halted
+		if a.haltMod {
+			a.modTable[a.modPosition] = data & 0b111
+			a.modTable[(a.modPosition+1)&0x3F] = data & 0b111
+			a.modPosition = (a.modPosition + 2) & 0x3F
+		}
+	case location == 0x4089:
+		a.waveWrite = data&0b1000_0000 != 0
+		a.masterVolume = data & 0b11
+	case location == 0x408A:
+		a.masterSpeed = data
+	default:
+		return false
+	}
+	return true
+}
+
+// setModCounter sets the modulation counter, which wraps around in the range -64 to 63
+func (a *fdsAudio) setModCounter(counter int32) {
+	a.modCounter = (counter+64)&0x7F - 64
+}
+
+// clock clocks the channel for one CPU cycle
+func (a *fdsAudio) clock() {
+	if !a.haltWave && !a.haltEnvelopes {
+		a.volume.clock(a.masterSpeed)
+		a.mod.clock(a.masterSpeed)
+	}
+
+	if !a.haltMod && a.mod.frequency != 0 {
+		a.modAccumulator += uint32(a.mod.frequency)
+		if a.modAccumulator >= 0x10000 {
+			a.modAccumulator -= 0x10000
+			entry := a.modTable[a.modPosition]
+			if entry == 4 {
+				a.setModCounter(0)
+			} else {
+				a.setModCounter(a.modCounter + fdsModDeltas[entry])
+			}
+			a.modPosition = (a.modPosition + 1) & 0x3F
+		}
+	}
+
+	if !a.waveWrite {
+		level := uint32(a.volume.gain)
+		if level > 32 {
+			level = 32
+		}
+		a.level = uint8(uint32(a.wave[a.wavePosition]) * level * fdsMasterVolumes[a.masterVolume] / 1152)
+	}
+
+	// The output is held while the wavetable is written. The modulation follows the current counter, gain and
+	// frequency, also while the modulation unit is halted.
+	if !a.haltWave && !a.waveWrite {
+		if pitch := int32(a.volume.frequency) + a.modPitch(); pitch > 0 {
+			a.waveAccumulator += uint32(pitch)
+			if a.waveAccumulator >= 0x10000 {
+				a.waveAccumulator -= 0x10000
+				a.wavePosition = (a.wavePosition + 1) & 0x3F
+			}
+		}
+	}
+}
+
+// modPitch returns the change of the wave frequency by the modulation counter and gain, with the rounding of the
+// hardware
+func (a *fdsAudio) modPitch() int32 {
+	// Multiply the counter by the gain and drop 4 bits, rounding in a peculiar way
+	temp := a.modCounter * int32(a.mod.gain)
+	remainder := temp & 0x0F
+	temp >>= 4
+	if remainder > 0 && temp&0x80 == 0 {
+		if a.modCounter < 0 {
+			temp--
+		} else {
+			temp += 2
+		}
+	}
+	// Wrap around in the range -64 to 191
+	if temp >= 192 {
+		temp -= 256
+	} else if temp < -64 {
+		temp += 256
+	}
+	// Multiply by the frequency and round to the nearest while dropping 6 bits
+	temp *= int32(a.volume.frequency)
+	remainder = temp & 0x3F
+	temp >>= 6
+	if remainder >= 32 {
+		temp++
+	}
+	return temp
+}
+
+// output returns the output of the channel. At full volume, the channel is about 2.4 times louder than an APU pulse.
+func (a *fdsAudio) output() float64 {
+	return float64(a.level) * apu.PulseStep * 15 * 2.4 / 63
+}
+
+func (a *fdsAudio) reset() {
+	*a = fdsAudio{
+		masterSpeed: 0xE8,
+	}
+	a.volume.resetTimer(a.masterSpeed)
+	a.mod.resetTimer(a.masterSpeed)
+}
+
+func (a *fdsAudio) serialize(s *savestate.Serializer) {
+	s.Bytes(a.wave[:])
+	s.Bool(&a.waveWrite)
+	s.Uint8(&a.masterVolume)
+	s.Bool(&a.haltWave)
+	s.Bool(&a.haltEnvelopes)
+	s.Uint8(&a.masterSpeed)
+	a.volume.serialize(s)
+	a.mod.serialize(s)
+	s.Uint32(&a.waveAccumulator)
+	s.Uint8(&a.wavePosition)
+	s.Bytes(a.modTable[:])
+	s.Uint8(&a.modPosition)
+	s.Bool(&a.haltMod)
+	s.Uint32(&a.modAccumulator)
+	s.Int32(&a.modCounter)
+	s.Uint8(&a.level)
+}
@@ -0,0 +1,151 @@
+package cartridge
+
+import (
+	"bytes"
+	"testing"
+)
+
+// newTestFDSAudio returns the channel with a square wave at full volume
+func newTestFDSAudio() *fdsAudio {
+	a := &fdsAudio{}
+	a.reset()
+	a.write(0x4089, 0b1000_0000)
+	for i := uint16(0); i < 64; i++ {
+		if i < 32 {
+			a.write(0x4040+i, 63)
+		} else {
+			a.write(0x4040+i, 0)
+		}
+	}
+	a.write(0x4089, 0)
+	a.write(0x4080, 0b1000_0000|32)
+	return a
+}
+
+func TestFDSAudioFrequency(t *testing.T) {
+	a := newTestFDSAudio()
+	// The wave advances by one sample when the accumulator overflows 16 bits, one period lasts 64 * 65536 / 1024 cycles
+	a.write(0x4082, 0x00)
+	a.write(0x4083, 0x04)
+	periods := 0
+	last := a.level
+	for i := 0; i < 10*4096; i++ {
+		a.clock()
+		if last == 63 && a.level == 0 {
+			periods++
+		}
+		last = a.level
+	}
+	if periods != 10 {
+		t.Errorf("expected 10 periods, got %d", periods)
+	}
+
+	// Halting the wave resets its position
+	a.write(0x4083, 0b1000_0100)
+	a.clock()
+	if a.wavePosition != 0 || a.level != 63 {
+		t.Errorf("expected the first sample, got position %d", a.wavePosition)
+	}
+	if a.output() < 0.2 || a.output() > 0.3 {
+		t.Errorf("unexpected output %f at full volume", a.output())
+	}
+}
+
+func TestFDSAudioEnvelope(t *testing.T) {
+	a := newTestFDSAudio()
+	// Increase the gain every 8 * (speed + 1) * master speed cycles
+	a.write(0x4080, 0b1000_0000)
+	a.write(0x4080, 0b0100_0000)
+	for i := 0; i < 10*8*0xE8; i++ {
+		a.clock()
+	}
+	if data, _ := a.read(0x4090); data&0x3F != 10 {
+		t.Errorf("expected gain 10, got %d", data&0x3F)
+	}
+	// Halted envelopes keep the gain
+	a.write(0x4083, 0b0100_0000)
+	for i := 0; i < 10*8*0xE8; i++ {
+		a.clock()
+	}
+	if a.volume.gain != 10 {
+		t.Errorf("expected the halted gain of 10, got %d", a.volume.gain)
+	}
+}
+
+func TestFDSAudioModulation(t *testing.T) {
+	a := newTestFDSAudio()
+	a.write(0x4082, 0x00)
+	a.write(0x4083, 0x04)
+	// The table can only be written while the modulation is halted, every entry is written twice
+	a.write(0x4087, 0b1000_0000)
+	for i := 0; i < 32; i++ {
+		a.write(0x4088, 1)
+	}
+	if !bytes.Equal(a.modTable[:], bytes.Repeat([]uint8{1}, 64)) {
+		t.Fatalf("unexpected modulation table %v", a.modTable)
+	}
+	a.write(0x4084, 0b1000_0000|16)
+	a.write(0x4085, 0)
+	// One step every 32 cycles
+	a.write(0x4086, 0x00)
+	a.write(0x4087, 0x08)
+	for i := 0; i < 32; i++ {
+		a.clock()
+	}
+	// Counter 1 * gain 16 / 16 * frequency 1024 / 64
+	if a.modCounter != 1 || a.modPitch() != 16 {
+		t.Errorf("expected counter 1 and pitch change 16, got %d and %d", a.modCounter, a.modPitch())
+	}
+	// The counter wraps around in 7 bits
+	for i := 0; i < 63*32; i++ {
+		a.clock()
+	}
+	if a.modCounter != -64 {
+		t.Errorf("expected the counter to wrap to -64, got %d", a.modCounter)
+	}
+}
+
+func TestFDSAudioHaltedModulation(t *testing.T) {
+	// wavePeriod returns the number of cycles of the next 64 steps of the wave, give or take a cycle of the accumulator
+	wavePeriod := func(a *fdsAudio) int {
+		cycles := 0
+		for steps := 0; steps < 64; cycles++ {
+			position := a.wavePosition
+			a.clock()
+			if a.wavePosition != position {
+				steps++
+			}
+		}
+		return cycles
+	}
+	near := func(period int, expected int) bool {
+		return period >= expected-1 && period <= expected+1
+	}
+
+	a := newTestFDSAudio()
+	a.write(0x4082, 0x00)
+	a.write(0x4083, 0x04)
+	a.write(0x4087, 0b1000_0000)
+	a.write(0x4084, 0b1000_0000|16)
+	// Counter 32 * gain 16 / 16 * frequency 1024 / 64 raises the frequency by half
+	a.write(0x4085, 32)
+	if period := wavePeriod(a); !near(period, 2731) {
+		t.Errorf("expected a period of 2731 cycles, got %d", period)
+	}
+	// The counter, gain and frequency change the pitch of the wave while the modulation is halted
+	a.write(0x4085, 0)
+	if period := wavePeriod(a); !near(period, 4096) {
+		t.Errorf("expected a period of 4096 cycles after resetting the counter, got %d", period)
+	}
+	a.write(0x4085, 32)
+	a.write(0x4084, 0b1000_0000)
+	if period := wavePeriod(a); !near(period, 4096) {
+		t.Errorf("expected a period of 4096 cycles with gain 0, got %d", period)
+	}
+	a.write(0x4084, 0b1000_0000|16)
+	a.write(0x4082, 0x00)
+	a.write(0x4083, 0x02)
+	if period := wavePeriod(a); !near(period, 5461) {
+		t.Errorf("expected a period of 5461 cycles at half the frequency, got %d", period)
+	}
+}
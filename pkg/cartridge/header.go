@@ -0,0 +1,256 @@
+package cartridge
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// HeaderSize is the size of the iNES header in bytes
+const HeaderSize = 16
+
+// headerMagic is the constant at the start of every iNES file: "NES" followed by MS-DOS end-of-file
+var headerMagic = []byte("NES\x1a")
+
+// Format of the header
+type Format uint8
+
+const (
+	// FormatArchaicINES is an iNES header with garbage in bytes 7-15, e.g. the name of the ripper. Only the lower
+	// nibble of the mapper number is valid.
+	FormatArchaicINES Format = iota
+	// FormatINES is the original iNES header
+	FormatINES
+	// FormatNES20 is the NES 2.0 header
+	FormatNES20
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatArchaicINES:
+		return "archaic iNES"
+	case FormatINES:
+		return "iNES"
+	case FormatNES20:
+		return "NES 2.0"
+	}
+	return fmt.Sprintf("Format(%d)", uint8(f))
+}
+
+// ConsoleType is the type of console the ROM was made for
+type ConsoleType uint8
+
+const (
+	ConsoleNES ConsoleType = iota
+	ConsoleVsSystem
+	ConsolePlayChoice10
+	// ConsoleExtended means the console type is given by Header.ExtendedConsoleType
+	ConsoleExtended
+)
+
+// Timing is the CPU/PPU timing of the console the ROM was made for
+type Timing uint8
+
+const (
+	TimingNTSC Timing = iota
+	TimingPAL
+	// TimingMultiRegion means the ROM works on NTSC and PAL consoles
+	TimingMultiRegion
+	TimingDendy
+)
+
+// Header is the decoded header of an iNES or NES 2.0 file. All sizes are in bytes.
+type Header struct {
+	Format Format
+
+	Mapper    uint16
+	Submapper uint8
+
+	PrgRomSize int
+	ChrRomSize int
+	// Volatile RAM and battery backed RAM on the cartridge
+	PrgRamSize   int
+	PrgNvramSize int
+	ChrRamSize   int
+	ChrNvramSize int
+
+	// VerticalMirroring is set if the nametables are mirrored vertically (horizontal arrangement). It is ignored if
+	// the cartridge switches the mirroring.
+	VerticalMirroring bool
+	// FourScreen is set if the cartridge provides its own nametable RAM
+	FourScreen bool
+	Battery    bool
+	Trainer    bool
+
+	ConsoleType ConsoleType
+	Timing      Timing
+	// VsPPUType and VsHardwareType are only valid for ConsoleVsSystem
+	VsPPUType      uint8
+	VsHardwareType uint8
+	// ExtendedConsoleType is only valid for ConsoleExtended
+	ExtendedConsoleType uint8
+	// Number of miscellaneous ROMs after the CHR ROM
+	MiscROMs uint8
+	// DefaultExpansionDevice is the input device that should be connected, see the NES 2.0 documentation
+	DefaultExpansionDevice uint8
+}
+
+// ParseHeader decodes the header of an iNES or NES 2.0 file. Returns ErrBadMagic, ErrInvalidHeader or a
+// TruncatedError if the header is not valid.
+//
+// From NES DEV WIKI https://wiki.nesdev.org/w/index.php?title=NES_2.0
+//
+// 0-3: Constant $4E $45 $53 $1A ("NES" followed by MS-DOS end-of-file)
+// 4: PRG ROM size LSB
+// 5: CHR ROM size LSB
+// 6: Flags 6 - Mapper D3..D0, four-screen, trainer, battery, mirroring
+// 7: Flags 7 - Mapper D7..D4, NES 2.0 identifier, console type
+// 8: Mapper MSB/Submapper
+// 9: PRG/CHR ROM size MSB
+// 10: PRG RAM/NVRAM size
+// 11: CHR RAM/NVRAM size
+// 12: CPU/PPU timing
+// 13: Vs. System type or extended console type
+// 14: Miscellaneous ROMs
+// 15: Default expansion device
+//
+// For iNES headers, bytes 8-15 are mostly unused. Byte 8 holds the PRG RAM size in 8 KB units and bit 0 of byte 9
+// selects PAL.
+func ParseHeader(data []byte) (*Header, error) {
+	if len(data) >= len(headerMagic) && !bytes.Equal(data[:len(headerMagic)], headerMagic) {
+		return nil, ErrBadMagic
+	}
+	if len(data) < HeaderSize {
+		return nil, &TruncatedError{Section: "header", Expected: HeaderSize, Got: len(data)}
+	}
+	h := &Header{
+		VerticalMirroring: data[6]&0b0000_0001 != 0,
+		Battery:           data[6]&0b0000_0010 != 0,
+		Trainer:           data[6]&0b0000_0100 != 0,
+		FourScreen:        data[6]&0b0000_1000 != 0,
+	}
+
+	switch {
+	case data[7]&0b0000_1100 == 0b0000_1000:
+		h.Format = FormatNES20
+	case data[7]&0b0000_1100 == 0 && bytes.Equal(data[12:16], []byte{0, 0, 0, 0}):
+		h.Format = FormatINES
+	default:
+		h.Format = FormatArchaicINES
+	}
+
+	h.Mapper = uint16(data[6] >> 4)
+	if h.Format == FormatArchaicINES {
+		// Bytes 7-15 are garbage, assume the most common values
+		h.PrgRomSize = int(data[4]) * 0x4000
+		h.ChrRomSize = int(data[5]) * 0x2000
+		h.PrgRamSize = 0x2000
+		if h.ChrRomSize == 0 {
+			h.ChrRamSize = 0x2000
+		}
+		h.moveToBattery()
+		return h, nil
+	}
+
+	h.Mapper |= uint16(data[7] & 0xF0)
+	h.ConsoleType = ConsoleType(data[7] & 0b11)
+
+	if h.Format == FormatINES {
+		h.PrgRomSize = int(data[4]) * 0x4000
+		h.ChrRomSize = int(data[5]) * 0x2000
+		// A value of 0 infers 8 KB for compatibility
+		h.PrgRamSize = int(data[8]) * 0x2000
+		if h.PrgRamSize == 0 {
+			h.PrgRamSize = 0x2000
+		}
+		if h.ChrRomSize == 0 {
+			h.ChrRamSize = 0x2000
+		}
+		if data[9]&0b1 != 0 {
+			h.Timing = TimingPAL
+		}
+		if h.ConsoleType == ConsoleExtended {
+			// iNES only knows Vs. System and PlayChoice-10 as flags
+			h.ConsoleType = ConsolePlayChoice10
+		}
+		h.moveToBattery()
+		return h, nil
+	}
+
+	h.Mapper |= uint16(data[8]&0x0F) << 8
+	h.Submapper = data[8] >> 4
+	h.PrgRomSize = romSize(data[4], data[9]&0x0F, 0x4000)
+	h.ChrRomSize = romSize(data[5], data[9]>>4, 0x2000)
+	h.PrgRamSize = ramSize(data[10] & 0x0F)
+	h.PrgNvramSize = ramSize(data[10] >> 4)
+	h.ChrRamSize = ramSize(data[11] & 0x0F)
+	h.ChrNvramSize = ramSize(data[11] >> 4)
+	h.Timing = Timing(data[12] & 0b11)
+	switch h.ConsoleType {
+	case ConsoleVsSystem:
+		h.VsPPUType = data[13] & 0x0F
+		h.VsHardwareType = data[13] >> 4
+	case ConsoleExtended:
+		h.ExtendedConsoleType = data[13] & 0x0F
+	}
+	h.MiscROMs = data[14] & 0b11
+	h.DefaultExpansionDevice = data[15] & 0b0011_1111
+	if h.PrgRomSize < 0 || h.ChrRomSize < 0 {
+		return nil, fmt.Errorf("%w: ROM size too large", ErrInvalidHeader)
+	}
+	return h, nil
+}
+
+// romSize decodes the size of the PRG or CHR ROM of a NES 2.0 header
+func romSize(lsb uint8, msb uint8, unit int) int {
+	if msb != 0x0F {
+		return (int(msb)<<8 | int(lsb)) * unit
+	}
+	// Exponent-multiplier notation
+	// 7  bit  0
+	// ---- ----
+	// EEEE EEMM
+	// |||| ||++- Multiplier, actual value is MM*2+1 (1,3,5,7)
+	// ++++-++--- Exponent (2^E), 0-63
+	exponent := lsb >> 2
+	multiplier := int(lsb&0b11)*2 + 1
+	if exponent > 30 {
+		// Larger than any real ROM and larger than an int can hold
+		return -1
+	}
+	return multiplier << exponent
+}
+
+// ramSize decodes the size of a RAM of a NES 2.0 header. The size is 64 << shift bytes or 0 if the shift is 0.
+func ramSize(shift uint8) int {
+	if shift == 0 {
+		return 0
+	}
+	return 64 << shift
+}
+
+// moveToBattery moves the PRG RAM to the battery backed PRG NVRAM, because iNES headers only have a battery flag
+func (h *Header) moveToBattery() {
+	if h.Battery {
+		h.PrgNvramSize = h.PrgRamSize
+		h.PrgRamSize = 0
+	}
+}
+
+// PrgRamTotal returns the size of the volatile and battery backed PRG RAM
+func (h *Header) PrgRamTotal() int {
+	return h.PrgRamSize + h.PrgNvramSize
+}
+
+// ChrRamTotal returns the size of the volatile and battery backed CHR RAM
+func (h *Header) ChrRamTotal() int {
+	return h.ChrRamSize + h.ChrNvramSize
+}
+
+// FileSize returns the size of an iNES file with this header. Miscellaneous ROMs are not included.
+func (h *Header) FileSize() int {
+	size := HeaderSize + h.PrgRomSize + h.ChrRomSize
+	if h.Trainer {
+		size += 0x200
+	}
+	return size
+}
@@ -0,0 +1,115 @@
+package cartridge
+
+import (
+	"errors"
+	"testing"
+)
+
+func header(bytes ...uint8) []byte {
+	data := make([]byte, HeaderSize)
+	copy(data, "NES\x1a")
+	copy(data[4:], bytes)
+	return data
+}
+
+func TestParseHeaderINES(t *testing.T) {
+	h, err := ParseHeader(header(2, 0, 0x43, 0x10, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Header{
+		Format:            FormatINES,
+		Mapper:            0x14,
+		PrgRomSize:        0x8000,
+		PrgNvramSize:      0x2000,
+		ChrRamSize:        0x2000,
+		VerticalMirroring: true,
+		Battery:           true,
+		Timing:            TimingPAL,
+	}
+	if *h != expected {
+		t.Fatalf("unexpected header\n%+v\nexpected\n%+v", *h, expected)
+	}
+}
+
+func TestParseHeaderArchaicINES(t *testing.T) {
+	data := header(1, 1, 0x20, 'D', 'i', 's', 'k', 'D', 'u', 'd', 'e', '!')
+	h, err := ParseHeader(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Format != FormatArchaicINES || h.Mapper != 2 || h.PrgRomSize != 0x4000 || h.ChrRomSize != 0x2000 {
+		t.Fatalf("unexpected header %+v", *h)
+	}
+}
+
+func TestParseHeaderNES20(t *testing.T) {
+	h, err := ParseHeader(header(0x02, 0x01, 0x5A, 0x49, 0x31, 0x11, 0x97, 0x07, 0x01, 0x21, 0x01, 0x05))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Header{
+		Format:         FormatNES20,
+		Mapper:         0x145,
+		Submapper:      3,
+		PrgRomSize:     0x102 * 0x4000,
+		ChrRomSize:     0x101 * 0x2000,
+		PrgRamSize:     64 << 7,
+		PrgNvramSize:   64 << 9,
+		ChrRamSize:     64 << 7,
+		FourScreen:     true,
+		Battery:        true,
+		Timing:         TimingPAL,
+		ConsoleType:    ConsoleVsSystem,
+		VsPPUType:      1,
+		VsHardwareType: 2,
+		MiscROMs:       1,
+		// Expansion device 5 is the Vs. System zapper
+		DefaultExpansionDevice: 5,
+	}
+	if *h != expected {
+		t.Fatalf("unexpected header\n%+v\nexpected\n%+v", *h, expected)
+	}
+}
+
+func TestParseHeaderExponent(t *testing.T) {
+	// PRG ROM: 2^5 * 3 = 96 bytes, CHR ROM: 2^13 * 1 = 8 KB
+	h, err := ParseHeader(header(0b0001_0101, 0b0011_0100, 0, 0x08, 0, 0xFF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.PrgRomSize != 96 || h.ChrRomSize != 0x2000 {
+		t.Fatalf("unexpected sizes %d and %d", h.PrgRomSize, h.ChrRomSize)
+	}
+	if _, err := ParseHeader(header(0xFC, 0, 0, 0x08, 0, 0x0F)); !errors.Is(err, ErrInvalidHeader) {
+		t.Fatalf("expected ErrInvalidHeader, got %v", err)
+	}
+}
+
+func TestParseHeaderInvalid(t *testing.T) {
+	var truncated *TruncatedError
+	if _, err := ParseHeader([]byte("NES\x1a")); !errors.As(err, &truncated) || truncated.Section != "header" {
+		t.Errorf("expected truncated header, got %v", err)
+	}
+	if _, err := ParseHeader(make([]byte, 16)); !errors.Is(err, ErrBadMagic) {
+		t.Errorf("expected ErrBadMagic, got %v", err)
+	}
+}
+
+func TestLoadSizesFromHeader(t *testing.T) {
+	// NES 2.0, mapper 0, 16 KB PRG ROM, no CHR ROM, 2 KB PRG RAM, 16 KB CHR RAM
+	rom := append(header(1, 0, 0, 0x08, 0, 0, 0x05, 0x08), make([]byte, 0x4000)...)
+	c, err := Load(rom, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := c.Mapper.(*Mapper000)
+	if len(m.prgRam) != 0x800 || len(c.ChrRom) != 0x4000 || !c.ChrRam {
+		t.Fatalf("unexpected sizes: PRG RAM %d, CHR RAM %d", len(m.prgRam), len(c.ChrRom))
+	}
+	// The 2 KB PRG RAM is mirrored
+	m.CPUWrite(0x6001, 0x42)
+	if m.CPURead(0x6801) != 0x42 {
+		t.Error("PRG RAM is not mirrored")
+	}
+}
@@ -0,0 +1,45 @@
+//go:build go1.18
+// +build go1.18
+
+package cartridge
+
+import (
+	"testing"
+
+	"github.com/exp625/gones/internal/testrom"
+)
+
+// FuzzLoad feeds arbitrary files to the loader. Load must never panic, and the loaded cartridges must handle any
+// access without panicking.
+func FuzzLoad(f *testing.F) {
+	f.Add(testrom.ROM())
+	f.Add(append(header(2, 1, 0x12), make([]byte, 0x8000+0x2000)...))
+	f.Add(append(header(1, 0, 0x40, 0x08, 0, 0, 0x07, 0x07), make([]byte, 0x4000)...))
+	// MMC2 with less PRG ROM than its three fixed banks
+	f.Add(append(header(1, 1, 0x90), make([]byte, 0x4000+0x2000)...))
+	f.Add(header(0x15, 0x34, 0x10, 0x08, 0, 0xFF))
+	f.Add([]byte("NES\x1a"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		c, err := Load(data, nil)
+		if err != nil {
+			if c != nil {
+				t.Fatal("returned a cartridge and an error")
+			}
+			return
+		}
+		// Access the cartridge like the NES does
+		for location := 0x4020; location <= 0xFFFF; location += 0x3F {
+			if mapped := c.CPUMap(uint16(location)); mapped >= 0x4020 {
+				c.CPURead(mapped)
+			}
+		}
+		for location := 0; location <= 0x3EFF; location += 0x3F {
+			if mapped := c.PPUMap(uint16(location)); mapped <= 0x1FFF {
+				c.PPURead(mapped)
+			}
+		}
+		if err := c.Load(c.Save()); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
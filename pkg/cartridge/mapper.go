@@ -1,5 +1,13 @@
 package cartridge
 
+import (
+	"github.com/exp625/gones/internal/savestate"
+	"github.com/exp625/gones/pkg/bus"
+)
+
+// Mapper is the hardware of a cartridge that maps the memory of the cartridge into the address spaces of the CPU and
+// the PPU. CPUClock is called on every master clock. Mappers can implement the optional interfaces CPUCycler,
+// Renderer, NametableMapper, DiskDrive, Peeker and apu.ExpansionAudio for sound chips on the cartridge.
 type Mapper interface {
 	Debugger
 	CPUMap(location uint16) uint16
@@ -11,5 +19,48 @@ type Mapper interface {
 	Reset()
 	CPUClock()
 	Save() []uint8
-	Load([]uint8)
+	Load([]uint8) error
+	Serialize(s *savestate.Serializer)
+}
+
+// CPUCycler is implemented by mappers with counters that run at the CPU clock, e.g. IRQ timers. CPUCycle is called on
+// every CPU cycle, right before the CPU executes it.
+type CPUCycler interface {
+	CPUCycle()
+}
+
+// Renderer is implemented by mappers that take part in the rendering of the picture, e.g. by counting scanlines or by
+// replacing tiles depending on their position on the screen.
+type Renderer interface {
+	// PPUFetch is called for every memory access of the PPU while rendering, before the location is mapped. If true
+	// is returned, the data is used instead of the memory at the location.
+	PPUFetch(location uint16, fetch bus.Fetch) (uint8, bool)
+}
+
+// NametableMapper is implemented by mappers that can replace the nametable RAM of the console with memory on the
+// cartridge. The location is the unmapped PPU location $2000-$3EFF. If false is returned, the nametable RAM at the
+// location returned by PPUMap is accessed.
+type NametableMapper interface {
+	NametableRead(location uint16) (uint8, bool)
+	NametableWrite(location uint16, data uint8) bool
+}
+
+// Peeker is implemented by mappers with registers that change their state when read, e.g. by acknowledging an IRQ.
+// Peek returns the same data as CPURead without changing the state, so that the memory can be inspected by a debugger.
+type Peeker interface {
+	Peek(location uint16) uint8
+}
+
+// DiskDrive is implemented by mappers with a disk drive. The sides are numbered from 0, side 0 is side A of the first
+// disk.
+type DiskDrive interface {
+	// Sides returns the number of disk sides
+	Sides() int
+	// Side returns the inserted side and false if the disk is ejected
+	Side() (side int, inserted bool)
+	Eject()
+	// Insert inserts a side of the disk. An inserted disk is ejected first.
+	Insert(side int)
+	// Patch returns the changes to the disk sides as IPS patch. Save returns the same patch, but no error.
+	Patch() ([]uint8, error)
 }
@@ -3,18 +3,19 @@ package cartridge
 import (
 	"fmt"
 	"github.com/exp625/gones/internal/plz"
-	"github.com/exp625/gones/internal/textutil"
+	"github.com/exp625/gones/internal/savestate"
+	"io"
 )
 
 type Mapper000 struct {
 	cartridge *Cartridge
-	prgRam    [0x2000]uint8
+	prgRam    []uint8
 }
 
 func NewMapper000(c *Cartridge) *Mapper000 {
 	return &Mapper000{
 		cartridge: c,
-		prgRam:    [0x2000]uint8{},
+		prgRam:    make([]uint8, c.Header.PrgRamTotal()),
 	}
 }
 
@@ -41,7 +42,7 @@ func (m *Mapper000) CPUMap(location uint16) uint16 {
 func (m *Mapper000) CPURead(location uint16) uint8 {
 	if location >= 0x6000 && location <= 0x7FFF {
 		// Read to 0x6001 should result in array index 1
-		return m.prgRam[location-0x6000]
+		return readRam(m.prgRam, int(location-0x6000), location)
 	}
 	if location >= 0x8000 {
 		// If prgRomSize == 1, we need to mirror the last 16 KB
@@ -60,7 +61,7 @@ func (m *Mapper000) CPURead(location uint16) uint8 {
 func (m *Mapper000) CPUWrite(location uint16, data uint8) bool {
 	if location >= 0x6000 && location <= 0x7FFF {
 		// Write to 0x6001 should result in array index 1
-		m.prgRam[location-0x6000] = data
+		writeRam(m.prgRam, int(location-0x6000), data)
 		return true
 	}
 	// Beside RAM, this card does not write to anything
@@ -113,11 +114,8 @@ func (m *Mapper000) Reset() {
 func (m *Mapper000) CPUClock() {
 }
 
-func (m *Mapper000) Load(data []uint8) {
-	if len(data) != len(m.prgRam) {
-		panic("Error loading save")
-	}
-	copy(m.prgRam[:], data[:])
+func (m *Mapper000) Load(data []uint8) error {
+	return loadRam(m.prgRam, data)
 }
 
 func (m *Mapper000) Save() []uint8 {
@@ -126,7 +124,11 @@ func (m *Mapper000) Save() []uint8 {
 	return data
 }
 
-func (m *Mapper000) DebugDisplay(text *textutil.Text) {
+func (m *Mapper000) Serialize(s *savestate.Serializer) {
+	s.Bytes(m.prgRam[:])
+}
+
+func (m *Mapper000) DebugDisplay(text io.Writer) {
 	// If I understand the wiki correctly, the ram is only use by some weird type of nes.
 	// No other game has ram on the cartridge
 	plz.Just(fmt.Fprint(text, "Cartridge with Mapper 000\n"))
@@ -3,14 +3,15 @@ package cartridge
 import (
 	"fmt"
 	"github.com/exp625/gones/internal/plz"
+	"github.com/exp625/gones/internal/savestate"
 	"github.com/exp625/gones/internal/shift_register"
-	"github.com/exp625/gones/internal/textutil"
+	"io"
 )
 
 type Mapper001 struct {
 	cartridge      *Cartridge
 	shiftRegister  shift_register.ShiftRegister8
-	prgRam         [0x8000]uint8
+	prgRam         []uint8
 	control        uint8
 	chrBanks       [2]uint8
 	prgBanks       [1]uint8
@@ -22,6 +23,11 @@ type Mapper001 struct {
 func NewMapper001(c *Cartridge) *Mapper001 {
 	m := &Mapper001{
 		cartridge: c,
+		prgRam:    make([]uint8, c.Header.PrgRamTotal()),
+	}
+	if c.Header.Format != FormatNES20 {
+		// iNES can not describe the banked PRG RAM of SOROM and SXROM, provide the maximum of 32 KB
+		m.prgRam = make([]uint8, 0x8000)
 	}
 	// We use the initial 0b1000_0000 to check when the register was shifted 5 times
 	m.shiftRegister.Set(0b1000_0000)
@@ -39,7 +45,7 @@ func (m *Mapper001) CPUMap(location uint16) uint16 {
 func (m *Mapper001) CPURead(location uint16) uint8 {
 	if 0x6000 <= location && location <= 0x7FFF {
 		// CPU $6000-$7FFF: 8 KB PRG RAM bank, (optional)
-		return m.prgRam[uint64(location-0x6000)+0x2000*uint64(m.ramBanks[0])]
+		return readRam(m.prgRam, int(location-0x6000)+0x2000*int(m.ramBanks[0]), location)
 	}
 
 	if 0x8000 <= location && location <= 0xBFFF {
@@ -78,7 +84,7 @@ func (m *Mapper001) CPURead(location uint16) uint8 {
 func (m *Mapper001) CPUWrite(location uint16, data uint8) bool {
 	if location >= 0x6000 && location <= 0x7FFF {
 		// CPU $6000-$7FFF: 8 KB PRG RAM bank, (optional)
-		m.prgRam[uint64(location-0x6000)+0x2000*uint64(m.ramBanks[0])] = data
+		writeRam(m.prgRam, int(location-0x6000)+0x2000*int(m.ramBanks[0]), data)
 		return true
 	}
 	if location >= 0x8000 {
@@ -233,11 +239,8 @@ func (m *Mapper001) PPUWrite(location uint16, data uint8) bool {
 	return false
 }
 
-func (m *Mapper001) Load(data []uint8) {
-	if len(data) != len(m.prgRam) {
-		panic("Error loading save")
-	}
-	copy(m.prgRam[:], data[:])
+func (m *Mapper001) Load(data []uint8) error {
+	return loadRam(m.prgRam, data)
 }
 
 func (m *Mapper001) Save() []uint8 {
@@ -246,6 +249,17 @@ func (m *Mapper001) Save() []uint8 {
 	return data
 }
 
+func (m *Mapper001) Serialize(s *savestate.Serializer) {
+	m.shiftRegister.Serialize(s)
+	s.Bytes(m.prgRam[:])
+	s.Uint8(&m.control)
+	s.Bytes(m.chrBanks[:])
+	s.Bytes(m.prgBanks[:])
+	s.Uint8(&m.prgBanksDouble)
+	s.Bytes(m.ramBanks[:])
+	s.Bool(&m.ramEnable)
+}
+
 func (m *Mapper001) Reset() {
 	// We use the initial 0b1000_0000 to check when the register was shifted 5 times
 	m.shiftRegister.Set(0b1000_0000)
@@ -259,7 +273,7 @@ func (m *Mapper001) Reset() {
 func (m *Mapper001) CPUClock() {
 }
 
-func (m *Mapper001) DebugDisplay(text *textutil.Text) {
+func (m *Mapper001) DebugDisplay(text io.Writer) {
 	plz.Just(fmt.Fprint(text, "Cartridge with Mapper 001\n"))
 	plz.Just(fmt.Fprintf(text, "PRG ROM Size: %d * 16 KB\n", m.cartridge.PrgRomSize))
 	plz.Just(fmt.Fprintf(text, "PRG BANK    : %d \n", m.prgBanks[0]))
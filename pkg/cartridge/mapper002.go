@@ -3,7 +3,8 @@ package cartridge
 import (
 	"fmt"
 	"github.com/exp625/gones/internal/plz"
-	"github.com/exp625/gones/internal/textutil"
+	"github.com/exp625/gones/internal/savestate"
+	"io"
 )
 
 type Mapper002 struct {
@@ -106,20 +107,26 @@ func (m *Mapper002) PPUWrite(location uint16, data uint8) bool {
 	return false
 }
 
-func (m *Mapper002) Load(data []uint8) {
+func (m *Mapper002) Load(data []uint8) error {
+	// No battery backed RAM
+	return loadRam(nil, data)
 }
 
 func (m *Mapper002) Save() []uint8 {
 	return []uint8{}
 }
 
+func (m *Mapper002) Serialize(s *savestate.Serializer) {
+	s.Uint8(&m.bankSelect)
+}
+
 func (m *Mapper002) Reset() {
 }
 
 func (m *Mapper002) CPUClock() {
 }
 
-func (m *Mapper002) DebugDisplay(text *textutil.Text) {
+func (m *Mapper002) DebugDisplay(text io.Writer) {
 	plz.Just(fmt.Fprint(text, "Cartridge with Mapper 002\n"))
 	plz.Just(fmt.Fprintf(text, "PRG ROM Size: %d * 16 KB\n", m.cartridge.PrgRomSize))
 	plz.Just(fmt.Fprintf(text, "PRG BANK    : %d \n", m.bankSelect))
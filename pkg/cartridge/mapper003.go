@@ -3,7 +3,8 @@ package cartridge
 import (
 	"fmt"
 	"github.com/exp625/gones/internal/plz"
-	"github.com/exp625/gones/internal/textutil"
+	"github.com/exp625/gones/internal/savestate"
+	"io"
 )
 
 type Mapper003 struct {
@@ -86,13 +87,19 @@ func (m *Mapper003) PPUWrite(location uint16, data uint8) bool {
 	return false
 }
 
-func (m *Mapper003) Load(data []uint8) {
+func (m *Mapper003) Load(data []uint8) error {
+	// No battery backed RAM
+	return loadRam(nil, data)
 }
 
 func (m *Mapper003) Save() []uint8 {
 	return []uint8{}
 }
 
+func (m *Mapper003) Serialize(s *savestate.Serializer) {
+	s.Uint8(&m.bankSelect)
+}
+
 func (m *Mapper003) Reset() {
 	m.bankSelect = 0
 }
@@ -100,7 +107,7 @@ func (m *Mapper003) Reset() {
 func (m *Mapper003) CPUClock() {
 }
 
-func (m *Mapper003) DebugDisplay(text *textutil.Text) {
+func (m *Mapper003) DebugDisplay(text io.Writer) {
 	plz.Just(fmt.Fprint(text, "Cartridge with Mapper 003\n"))
 	plz.Just(fmt.Fprintf(text, "PRG ROM Size: %d * 16 KB\n", m.cartridge.PrgRomSize))
 	plz.Just(fmt.Fprintf(text, "PRG BANK    : %d \n", m.bankSelect))
@@ -3,15 +3,36 @@ package cartridge
 import (
 	"fmt"
 	"github.com/exp625/gones/internal/plz"
-	"github.com/exp625/gones/internal/textutil"
+	"github.com/exp625/gones/internal/savestate"
+	"io"
 )
 
+// From NES DEV WIKI https://wiki.nesdev.org/w/index.php?title=MMC3
+
+// The MMC3 is used by the mappers 4, 118 and 119. The mappers differ in the board:
+//
+// Mapper  Submapper  Board
+// 4       0          MMC3, new IRQ behaviour (MMC3B/MMC3C)
+// 4       1          MMC6 with 1 KB PRG RAM (StarTropics)
+// 4       4          MMC3A, old IRQ behaviour
+// 118     0          TxSROM, the nametables are selected by bit 7 of the CHR banks
+// 119     0          TQROM, 64 KB CHR ROM and 8 KB CHR RAM selected by bit 6 of the CHR banks
+
 type Mapper004 struct {
 	cartridge *Cartridge
+	// MMC6 with its internal PRG RAM and protection
+	mmc6 bool
+	// The MMC3A only triggers an IRQ if the counter changes to 0
+	oldIRQ bool
+	// TxSROM nametables
+	chrMirroring bool
 
 	// ProgramRam
 	// CPU $6000-$7FFF: 8 KB PRG RAM bank (optional)
-	programRam [0x2000]uint8
+	// CPU $7000-$7FFF: 1 KB PRG RAM, mirrored (MMC6)
+	programRam []uint8
+	// CHR RAM next to the CHR ROM (TQROM)
+	chrRam []uint8
 
 	// Bank selections R0 - R7
 	bankSelections [8]uint8
@@ -23,6 +44,7 @@ type Mapper004 struct {
 	irqLatch          uint8
 	irqReload         bool
 	irqEnabled        bool
+	irqPending        bool
 
 	// irqCounter
 	irqCounter uint8
@@ -32,13 +54,38 @@ type Mapper004 struct {
 	addressLatch   uint16
 }
 
+// NewMapper004 creates the MMC3 or MMC6 for the mappers 4, 118 and 119
 func NewMapper004(c *Cartridge) *Mapper004 {
-	return &Mapper004{
-		cartridge: c,
+	m := &Mapper004{
+		cartridge:    c,
+		mmc6:         c.Header.Mapper == 4 && c.Header.Submapper == 1,
+		oldIRQ:       c.Header.Mapper == 4 && c.Header.Submapper == 4,
+		chrMirroring: c.Header.Mapper == 118,
+		programRam:   make([]uint8, c.Header.PrgRamTotal()),
+	}
+	if m.mmc6 {
+		m.programRam = make([]uint8, 0x400)
+	}
+	if c.Header.Mapper == 119 {
+		m.chrRam = make([]uint8, 0x2000)
 	}
+	return m
 }
 
-// From NES DEV WIKI https://wiki.nesdev.org/w/index.php?title=MMC3
+// board returns the name of the board
+func (m *Mapper004) board() string {
+	switch {
+	case m.mmc6:
+		return "MMC6"
+	case m.oldIRQ:
+		return "MMC3A"
+	case m.chrMirroring:
+		return "TxSROM"
+	case m.chrRam != nil:
+		return "TQROM"
+	}
+	return "MMC3"
+}
 
 func (m *Mapper004) CPUMap(location uint16) uint16 {
 	return location
@@ -56,8 +103,10 @@ func (m *Mapper004) CPURead(location uint16) uint8 {
 	// (-2) : the second last bank
 	mapMode := m.bankSelect >> 6 & 0b1
 	switch {
+	case 0x6000 <= location && location <= 0x7FFF && m.mmc6:
+		return m.readMMC6Ram(location)
 	case 0x6000 <= location && location <= 0x7FFF && m.programRamProtect>>7 == 1:
-		return m.programRam[location-0x6000]
+		return readRam(m.programRam, int(location-0x6000), location)
 	case 0x8000 <= location && location <= 0x9FFF && mapMode == 0:
 		return m.cartridge.PrgRom[uint32(location-0x8000)+uint32(0x2000)*uint32(m.bankSelections[6])]
 	case 0xA000 <= location && location <= 0xBFFF && mapMode == 0:
@@ -82,8 +131,10 @@ func (m *Mapper004) CPURead(location uint16) uint8 {
 func (m *Mapper004) CPUWrite(location uint16, data uint8) bool {
 	// 268407
 	switch {
+	case 0x6000 <= location && location <= 0x7FFF && m.mmc6:
+		m.writeMMC6Ram(location, data)
 	case 0x6000 <= location && location <= 0x7FFF && m.programRamProtect>>7 == 1:
-		m.programRam[location-0x6000] = data
+		writeRam(m.programRam, int(location-0x6000), data)
 	case 0x8000 <= location && location <= 0x9FFF && location%2 == 0:
 		// Bank select ($8000-$9FFE, even)
 		// 7  bit  0
@@ -99,7 +150,7 @@ func (m *Mapper004) CPUWrite(location uint16, data uint8) bool {
 		// |||          101: R5: Select 1 KB CHR bank at PPU $1C00-$1FFF (or $0C00-$0FFF)
 		// |||          110: R6: Select 8 KB PRG ROM bank at $8000-$9FFF (or $C000-$DFFF)
 		// |||          111: R7: Select 8 KB PRG ROM bank at $A000-$BFFF
-		// ||+------- Nothing on the MMC3, see MMC6
+		// ||+------- Nothing on the MMC3, PRG RAM enable on the MMC6
 		// |+-------- PRG ROM bank mode (0: $8000-$9FFF swappable,
 		// |                                $C000-$DFFF fixed to second-last bank;
 		// |                             1: $C000-$DFFF swappable,
@@ -143,6 +194,20 @@ func (m *Mapper004) CPUWrite(location uint16, data uint8) bool {
 		// ||++------ Nothing on the MMC3, see MMC6
 		// |+-------- Write protection (0: allow writes; 1: deny writes)
 		// +--------- PRG RAM chip enable (0: disable; 1: enable)
+		//
+		// On the MMC6, the halves of the PRG RAM are protected separately. The register can only be written while
+		// the PRG RAM is enabled in the bank select register.
+		// 7  bit  0
+		// ---- ----
+		// HhLl xxxx
+		// ||||
+		// |||+------ Enable writes to $7000-$71FF
+		// ||+------- Enable reads from $7000-$71FF
+		// |+-------- Enable writes to $7200-$73FF
+		// +--------- Enable reads from $7200-$73FF
+		if m.mmc6 && m.bankSelect&0b0010_0000 == 0 {
+			break
+		}
 		m.programRamProtect = data
 	case 0xC000 <= location && location <= 0xDFFF && location%2 == 0:
 		// IRQ latch ($C000-$DFFE, even)
@@ -163,7 +228,9 @@ func (m *Mapper004) CPUWrite(location uint16, data uint8) bool {
 		// 7  bit  0
 		// ---- ----
 		// xxxx xxxx
+		// Disabling the IRQ acknowledges a pending IRQ
 		m.irqEnabled = false
+		m.irqPending = false
 	case 0xE000 <= location && location%2 == 1:
 		// IRQ enable ($E001-$FFFF, odd)
 		// 7  bit  0
@@ -180,6 +247,33 @@ func (m *Mapper004) CPUClock() {
 	if m.lineLowCounter != 3 && m.addressLatch>>12&0b1 == 0 {
 		m.lineLowCounter++
 	}
+	if m.irqPending {
+		m.cartridge.Bus.IRQ()
+	}
+}
+
+// readMMC6Ram reads from the 1 KB PRG RAM of the MMC6. If only one half is readable, the other half reads as 0.
+func (m *Mapper004) readMMC6Ram(location uint16) uint8 {
+	readLow := m.programRamProtect&0b0010_0000 != 0
+	readHigh := m.programRamProtect&0b1000_0000 != 0
+	if location < 0x7000 || m.bankSelect&0b0010_0000 == 0 || !readLow && !readHigh {
+		return uint8(location >> 8)
+	}
+	if high := location&0x200 != 0; high && !readHigh || !high && !readLow {
+		return 0
+	}
+	return m.programRam[location%0x400]
+}
+
+// writeMMC6Ram writes to the 1 KB PRG RAM of the MMC6, if the half is writable
+func (m *Mapper004) writeMMC6Ram(location uint16, data uint8) {
+	if location < 0x7000 || m.bankSelect&0b0010_0000 == 0 {
+		return
+	}
+	if location&0x200 != 0 && m.programRamProtect&0b0100_0000 != 0 ||
+		location&0x200 == 0 && m.programRamProtect&0b0001_0000 != 0 {
+		m.programRam[location%0x400] = data
+	}
 }
 
 func (m *Mapper004) PPUMap(location uint16) uint16 {
@@ -189,6 +283,7 @@ func (m *Mapper004) PPUMap(location uint16) uint16 {
 
 			// When the IRQ is clocked (filtered A12 0→1), the counter value is checked - if zero or the reload flag
 			// is true, it's reloaded with the IRQ latched value at $C000; otherwise, it decrements.
+			counter, reload := m.irqCounter, m.irqReload
 			if m.irqCounter == 0 || m.irqReload {
 				m.irqCounter = m.irqLatch
 				m.irqReload = false
@@ -199,8 +294,8 @@ func (m *Mapper004) PPUMap(location uint16) uint16 {
 			// Rising edge
 			// If the IRQ counter is zero and IRQs are enabled ($E001), an IRQ is triggered. The "alternate revision"
 			// checks the IRQ counter transition 1→0, whether from decrementing or reloading.
-			if m.irqCounter == 0 && m.irqEnabled {
-				m.cartridge.Bus.IRQ()
+			if m.irqCounter == 0 && m.irqEnabled && (!m.oldIRQ || counter != 0 || reload) {
+				m.irqPending = true
 			}
 		}
 		m.lineLowCounter = 0
@@ -210,7 +305,11 @@ func (m *Mapper004) PPUMap(location uint16) uint16 {
 		if location >= 0x3000 {
 			location -= 0x1000
 		}
-		if m.mirrorMode&0b1 == 1 {
+		if m.chrMirroring {
+			// Bit 7 of the CHR bank at $0000-$0FFF selects the CIRAM page of the nametable at $2000-$2FFF
+			page := uint16(m.chrBank((location-0x2000)%0x1000) >> 7)
+			location = 0x2000 + page*0x400 + location%0x400
+		} else if m.mirrorMode&0b1 == 1 {
 			// 1: horizontal mirroring
 			if location-0x2000 < 0x800 {
 				location = 0x2000 + location%0x400
@@ -226,7 +325,8 @@ func (m *Mapper004) PPUMap(location uint16) uint16 {
 	return location
 }
 
-func (m *Mapper004) PPURead(location uint16) uint8 {
+// chrBank returns the 1 KB CHR bank at the PPU location $0000-$1FFF
+func (m *Mapper004) chrBank(location uint16) uint8 {
 	// CHR Banks
 	// CHR map mode → $8000.D7 = 0 	$8000.D7 = 1
 	// PPU Bank 	  Value of MMC3 register
@@ -238,79 +338,49 @@ func (m *Mapper004) PPURead(location uint16) uint8 {
 	// $1400-$17FF 	  R3
 	// $1800-$1BFF 	  R4 			R1
 	// $1C00-$1FFF 	  R5
+	if m.bankSelect>>7&0b1 == 1 {
+		location ^= 0x1000
+	}
+	switch {
+	case location <= 0x07FF:
+		return m.bankSelections[0] + uint8(location>>10&0b1)
+	case location <= 0x0FFF:
+		return m.bankSelections[1] + uint8(location>>10&0b1)
+	}
+	return m.bankSelections[2+(location-0x1000)/0x400]
+}
 
-	// 7  bit  0
-	// ---- ----
-	// CPMx xRRR
-	// |||   |||
-	// |||   +++- Specify which bank register to update on next write to Bank Data register
-	// |||          000: R0: Select 2 KB CHR bank at PPU $0000-$07FF (or $1000-$17FF)
-	// |||          001: R1: Select 2 KB CHR bank at PPU $0800-$0FFF (or $1800-$1FFF)
-	// |||          010: R2: Select 1 KB CHR bank at PPU $1000-$13FF (or $0000-$03FF)
-	// |||          011: R3: Select 1 KB CHR bank at PPU $1400-$17FF (or $0400-$07FF)
-	// |||          100: R4: Select 1 KB CHR bank at PPU $1800-$1BFF (or $0800-$0BFF)
-	// |||          101: R5: Select 1 KB CHR bank at PPU $1C00-$1FFF (or $0C00-$0FFF)
-	// |||          110: R6: Select 8 KB PRG ROM bank at $8000-$9FFF (or $C000-$DFFF)
-	// |||          111: R7: Select 8 KB PRG ROM bank at $A000-$BFFF
-	// ||+------- Nothing on the MMC3, see MMC6
-	// |+-------- PRG ROM bank mode (0: $8000-$9FFF swappable,
-	// |                                $C000-$DFFF fixed to second-last bank;
-	// |                             1: $C000-$DFFF swappable,
-	// |                                $8000-$9FFF fixed to second-last bank)
-	// +--------- CHR A12 inversion (0: two 2 KB banks at $0000-$0FFF,
-	//                                  four 1 KB banks at $1000-$1FFF;
-	//                               1: two 2 KB banks at $1000-$1FFF,
-	//                                  four 1 KB banks at $0000-$0FFF)
+// chrMemory returns the CHR memory and the index into it for the PPU location $0000-$1FFF and whether it is writable
+func (m *Mapper004) chrMemory(location uint16) ([]uint8, int, bool) {
+	bank := m.chrBank(location)
+	if m.chrRam != nil && bank&0b0100_0000 != 0 {
+		// TQROM: Bit 6 selects the CHR RAM
+		return m.chrRam, int(bank&0b111)*0x400 + int(location%0x400), true
+	}
+	return m.cartridge.ChrRom, (int(bank)*0x400 + int(location%0x400)) % len(m.cartridge.ChrRom), m.cartridge.ChrRam
+}
 
-	mapMode := m.bankSelect >> 7 & 0b1
-	switch { //
-	case location <= 0x03FF && mapMode == 0:
-		return m.cartridge.ChrRom[uint32(location)+uint32(0x0400)*uint32(m.bankSelections[0])]
-	case 0x0400 <= location && location <= 0x07FF && mapMode == 0:
-		return m.cartridge.ChrRom[uint32(location-0x0400)+uint32(0x0400)*uint32(m.bankSelections[0]+1)]
-	case 0x0800 <= location && location <= 0x0BFF && mapMode == 0:
-		return m.cartridge.ChrRom[uint32(location-0x0800)+uint32(0x0400)*uint32(m.bankSelections[1])]
-	case 0x0C00 <= location && location <= 0x0FFF && mapMode == 0:
-		return m.cartridge.ChrRom[uint32(location-0x0C00)+uint32(0x0400)*uint32(m.bankSelections[1]+1)]
-	case 0x1000 <= location && location <= 0x13FF && mapMode == 0:
-		return m.cartridge.ChrRom[uint32(location-0x1000)+uint32(0x0400)*uint32(m.bankSelections[2])]
-	case 0x1400 <= location && location <= 0x17FF && mapMode == 0:
-		return m.cartridge.ChrRom[uint32(location-0x1400)+uint32(0x0400)*uint32(m.bankSelections[3])]
-	case 0x1800 <= location && location <= 0x1BFF && mapMode == 0:
-		return m.cartridge.ChrRom[uint32(location-0x1800)+uint32(0x0400)*uint32(m.bankSelections[4])]
-	case 0x1C00 <= location && location <= 0x1FFF && mapMode == 0:
-		return m.cartridge.ChrRom[uint32(location-0x1C00)+uint32(0x0400)*uint32(m.bankSelections[5])]
-	case location <= 0x03FF && mapMode == 1:
-		return m.cartridge.ChrRom[uint32(location)+uint32(0x0400)*uint32(m.bankSelections[2])]
-	case 0x0400 <= location && location <= 0x07FF && mapMode == 1:
-		return m.cartridge.ChrRom[uint32(location-0x400)+uint32(0x0400)*uint32(m.bankSelections[3])]
-	case 0x0800 <= location && location <= 0x0BFF && mapMode == 1:
-		return m.cartridge.ChrRom[uint32(location-0x0800)+uint32(0x0400)*uint32(m.bankSelections[4])]
-	case 0x0C00 <= location && location <= 0x0FFF && mapMode == 1:
-		return m.cartridge.ChrRom[uint32(location-0x0C00)+uint32(0x0400)*uint32(m.bankSelections[5])]
-	case 0x1000 <= location && location <= 0x13FF && mapMode == 1:
-		return m.cartridge.ChrRom[uint32(location-0x1000)+uint32(0x0400)*uint32(m.bankSelections[0])]
-	case 0x1400 <= location && location <= 0x17FF && mapMode == 1:
-		return m.cartridge.ChrRom[uint32(location-0x1400)+uint32(0x0400)*uint32(m.bankSelections[0]+1)]
-	case 0x1800 <= location && location <= 0x1BFF && mapMode == 1:
-		return m.cartridge.ChrRom[uint32(location-0x1800)+uint32(0x0400)*uint32(m.bankSelections[1])]
-	case 0x1C00 <= location && location <= 0x1FFF && mapMode == 1:
-		return m.cartridge.ChrRom[uint32(location-0x1C00)+uint32(0x0400)*uint32(m.bankSelections[1]+1)]
+func (m *Mapper004) PPURead(location uint16) uint8 {
+	if location <= 0x1FFF {
+		memory, index, _ := m.chrMemory(location)
+		return memory[index]
 	}
 	// Mapper was not responsible for the location
 	return 0
 }
 
 func (m *Mapper004) PPUWrite(location uint16, data uint8) bool {
-	// No support for CHR Ram
+	if location <= 0x1FFF {
+		if memory, index, ram := m.chrMemory(location); ram {
+			memory[index] = data
+		}
+		return true
+	}
 	return false
 }
 
-func (m *Mapper004) Load(data []uint8) {
-	if len(data) != len(m.programRam) {
-		panic("Error loading save")
-	}
-	copy(m.programRam[:], data[:])
+func (m *Mapper004) Load(data []uint8) error {
+	return loadRam(m.programRam, data)
 }
 
 func (m *Mapper004) Save() []uint8 {
@@ -319,17 +389,34 @@ func (m *Mapper004) Save() []uint8 {
 	return data
 }
 
+func (m *Mapper004) Serialize(s *savestate.Serializer) {
+	s.Bytes(m.programRam[:])
+	s.Bytes(m.bankSelections[:])
+	s.Uint8(&m.bankSelect)
+	s.Uint8(&m.mirrorMode)
+	s.Uint8(&m.programRamProtect)
+	s.Uint8(&m.irqLatch)
+	s.Bool(&m.irqReload)
+	s.Bool(&m.irqEnabled)
+	s.Bool(&m.irqPending)
+	s.Uint8(&m.irqCounter)
+	s.Uint8(&m.lineLowCounter)
+	s.Uint16(&m.addressLatch)
+	s.Bytes(m.chrRam)
+}
+
 func (m *Mapper004) Reset() {
 	m.bankSelections = [8]uint8{}
 	m.bankSelect = 0
 	m.irqEnabled = false
+	m.irqPending = false
 	m.irqCounter = 0
 	m.irqLatch = 0
 	m.irqReload = false
 }
 
-func (m *Mapper004) DebugDisplay(text *textutil.Text) {
-	plz.Just(fmt.Fprint(text, "Cartridge with Mapper 004\n"))
+func (m *Mapper004) DebugDisplay(text io.Writer) {
+	plz.Just(fmt.Fprintf(text, "Cartridge with Mapper %03d (%s)\n", m.cartridge.Header.Mapper, m.board()))
 	plz.Just(fmt.Fprintf(text, "PRG ROM Size: %d * 16 KB\n", m.cartridge.PrgRomSize))
 	plz.Just(fmt.Fprintf(text, "PRG BANK    : %d \n", m.bankSelect))
 	plz.Just(fmt.Fprintf(text, "CHR ROM Size: %d * 8 KB\n", m.cartridge.ChrRomSize))
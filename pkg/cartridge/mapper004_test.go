@@ -0,0 +1,136 @@
+package cartridge_test
+
+import (
+	"testing"
+
+	"github.com/exp625/gones/pkg/cartridge"
+)
+
+// mmc3Bank writes a bank register of the MMC3
+func mmc3Bank(c *cartridge.Cartridge, mode uint8, register uint8, data uint8) {
+	c.CPUWrite(0x8000, mode|register)
+	c.CPUWrite(0x8001, data)
+}
+
+// clockA12 toggles PPU A12 to clock the IRQ counter of the MMC3 and returns whether an IRQ is pending
+func clockA12(c *cartridge.Cartridge, b *testBus) bool {
+	c.PPUMap(0x0000)
+	for i := 0; i < 3; i++ {
+		c.CPUClock()
+	}
+	c.PPUMap(0x1000)
+	b.irq = false
+	c.CPUClock()
+	return b.irq
+}
+
+func TestMapper004IRQRevisions(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		submapper uint8
+		// IRQs on the first and the second clock with a latch of 0
+		irqs [2]bool
+	}{
+		{"MMC3", 0, [2]bool{true, true}},
+		{"MMC3A", 4, [2]bool{true, false}},
+	} {
+		c, b := newTestCartridge(t, 4, test.submapper, 16, 8)
+		c.CPUWrite(0xC000, 0)
+		c.CPUWrite(0xC001, 0)
+		c.CPUWrite(0xE001, 0)
+		for i, expected := range test.irqs {
+			if irq := clockA12(c, b); irq != expected {
+				t.Errorf("%s: expected IRQ %t on clock %d, got %t", test.name, expected, i+1, irq)
+			}
+			// Acknowledge
+			c.CPUWrite(0xE000, 0)
+			c.CPUWrite(0xE001, 0)
+		}
+	}
+
+	// Both revisions trigger the IRQ when the counter is decremented to 0
+	for _, submapper := range []uint8{0, 4} {
+		c, b := newTestCartridge(t, 4, submapper, 16, 8)
+		c.CPUWrite(0xC000, 2)
+		c.CPUWrite(0xC001, 0)
+		c.CPUWrite(0xE001, 0)
+		for i, expected := range []bool{false, false, true} {
+			if irq := clockA12(c, b); irq != expected {
+				t.Errorf("submapper %d: expected IRQ %t on clock %d, got %t", submapper, expected, i+1, irq)
+			}
+		}
+	}
+}
+
+func TestMapper118Mirroring(t *testing.T) {
+	c, _ := newTestCartridge(t, 118, 0, 16, 128)
+	mmc3Bank(c, 0, 0, 0x80)
+	mmc3Bank(c, 0, 1, 0x00)
+	for _, test := range []struct {
+		location uint16
+		mapped   uint16
+	}{{0x2000, 0x2400}, {0x2410, 0x2410}, {0x2810, 0x2010}, {0x2C10, 0x2010}} {
+		if location := c.PPUMap(test.location); location != test.mapped {
+			t.Errorf("expected $%04X mapped to $%04X, got $%04X", test.location, test.mapped, location)
+		}
+	}
+	if data := c.PPURead(0x0400); data != 0x01 {
+		t.Errorf("expected CHR bank 1 without bit 7, got $%02X", data)
+	}
+
+	// With CHR A12 inversion, the 1 KB banks R2-R5 select the nametables
+	mmc3Bank(c, 0x80, 4, 0x80)
+	if location := c.PPUMap(0x2810); location != 0x2410 {
+		t.Errorf("expected $2810 mapped to $2410 by R4, got $%04X", location)
+	}
+}
+
+func TestMapper119CHRRam(t *testing.T) {
+	c, _ := newTestCartridge(t, 119, 0, 16, 64)
+	mmc3Bank(c, 0, 2, 0x41)
+	mmc3Bank(c, 0, 3, 0x01)
+	mmc3Bank(c, 0, 4, 0x41)
+	c.PPUWrite(0x1010, 0x55)
+	if data := c.PPURead(0x1810); data != 0x55 {
+		t.Errorf("expected the write to CHR RAM bank 1, got $%02X", data)
+	}
+	c.PPUWrite(0x1410, 0x55)
+	if data := c.PPURead(0x1410); data != 0x01 {
+		t.Errorf("expected CHR ROM bank 1, got $%02X", data)
+	}
+}
+
+func TestMMC6Ram(t *testing.T) {
+	c, _ := newTestCartridge(t, 4, 1, 16, 8)
+	c.CPUWrite(0xA001, 0xF0)
+	if data := c.CPURead(0x7000); data != 0x70 {
+		t.Errorf("expected open bus with the PRG RAM disabled, got $%02X", data)
+	}
+
+	c.CPUWrite(0x8000, 0b0010_0000)
+	c.CPUWrite(0xA001, 0xF0)
+	c.CPUWrite(0x7000, 0x01)
+	c.CPUWrite(0x7200, 0x02)
+	for _, test := range []struct {
+		location uint16
+		data     uint8
+	}{{0x7000, 0x01}, {0x7200, 0x02}, {0x7400, 0x01}, {0x7E00, 0x02}, {0x6000, 0x60}} {
+		if data := c.CPURead(test.location); data != test.data {
+			t.Errorf("expected $%02X at $%04X, got $%02X", test.data, test.location, data)
+		}
+	}
+
+	// Only the lower half is readable and writable, the upper half reads as 0
+	c.CPUWrite(0xA001, 0b0011_0000)
+	c.CPUWrite(0x7200, 0x03)
+	if data := c.CPURead(0x7200); data != 0 {
+		t.Errorf("expected 0 from the unreadable half, got $%02X", data)
+	}
+	c.CPUWrite(0xA001, 0b1011_0000)
+	if data := c.CPURead(0x7200); data != 0x02 {
+		t.Errorf("expected the write to the protected half to be ignored, got $%02X", data)
+	}
+	if saved := c.Save(); len(saved) != 0x400 {
+		t.Errorf("expected 1 KB of PRG RAM, got %d bytes", len(saved))
+	}
+}
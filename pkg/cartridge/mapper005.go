@@ -0,0 +1,688 @@
+package cartridge
+
+import (
+	"fmt"
+	"github.com/exp625/gones/internal/plz"
+	"github.com/exp625/gones/internal/savestate"
+	"github.com/exp625/gones/pkg/apu"
+	"github.com/exp625/gones/pkg/bus"
+	"io"
+)
+
+// From NES DEV WIKI https://wiki.nesdev.org/w/index.php?title=MMC5
+
+// ExRAM modes of register $5104
+const (
+	exRamNametable = iota
+	exRamAttributes
+	exRamReadWrite
+	exRamReadOnly
+)
+
+// The MMC5 clocks the envelopes and length counters of its pulses at a fixed rate of about 240 Hz
+const mmc5FrameCycles = 7457
+
+// Number of master clocks without PPU fetches after which the MMC5 assumes the PPU has stopped rendering (3 CPU cycles)
+const mmc5IdleClocks = 9
+
+type Mapper005 struct {
+	cartridge *Cartridge
+
+	// CPU $6000-$FFFF: up to 128 KB of PRG RAM, banked in 8 KB units
+	prgRam []uint8
+	// 1 KB of internal extended RAM at CPU $5C00-$5FFF
+	exRam [0x400]uint8
+
+	// registers
+	prgMode       uint8
+	chrMode       uint8
+	prgRamProtect [2]uint8
+	exRamMode     uint8
+	nametables    uint8
+	fillTile      uint8
+	fillAttribute uint8
+	// $5113-$5117
+	prgBanks [5]uint8
+	// $5120-$512B, including the upper bits of $5130
+	chrBanks [12]uint16
+	chrUpper uint8
+	// True if a register of the background set $5128-$512B was written last
+	chrSetB      bool
+	splitControl uint8
+	splitScroll  uint8
+	splitBank    uint8
+	irqCompare   uint8
+	irqEnabled   bool
+	irqPending   bool
+	multiplicand uint8
+	multiplier   uint8
+
+	// PPU observation
+	largeSprites  bool
+	inFrame       bool
+	scanline      uint8
+	idleClocks    uint8
+	lastNametable uint16
+	repeatedReads uint8
+	// Position of the tile the PPU is fetching, 0-33. Tile 0 and 1 are fetched at the end of the previous scanline.
+	tile         uint8
+	spriteFetch  bool
+	inSplit      bool
+	splitY       uint8
+	exAttributes uint8
+
+	// Audio
+	pulse1       apu.Pulse
+	pulse2       apu.Pulse
+	pcm          uint8
+	pcmReadMode  bool
+	pcmIRQEnable bool
+	pcmIRQ       bool
+	audioCycle   uint16
+}
+
+func NewMapper005(c *Cartridge) *Mapper005 {
+	m := &Mapper005{
+		cartridge: c,
+		prgRam:    make([]uint8, c.Header.PrgRamTotal()),
+		pulse1:    apu.Pulse{NoSweep: true},
+		pulse2:    apu.Pulse{NoSweep: true},
+	}
+	if c.Header.Format != FormatNES20 {
+		// iNES can not describe the PRG RAM of the different boards, provide 64 KB like the largest board
+		m.prgRam = make([]uint8, 0x10000)
+	}
+	m.Reset()
+	return m
+}
+
+func (m *Mapper005) CPUMap(location uint16) uint16 {
+	return location
+}
+
+func (m *Mapper005) CPURead(location uint16) uint8 {
+	switch {
+	case location == 0x5010:
+		// PCM IRQ status, reading acknowledges the IRQ
+		// 7  bit  0
+		// ---- ----
+		// Ixxx xxxM
+		// |       |
+		// |       +- PCM mode
+		// +--------- IRQ
+		data := m.pcmMode()
+		if m.pcmIRQ {
+			data |= 0b1000_0000
+		}
+		m.pcmIRQ = false
+		return data
+	case location == 0x5015:
+		// Status of the length counters of the pulses
+		var data uint8
+		if m.pulse1.LengthCounter.Active() {
+			data |= 0b01
+		}
+		if m.pulse2.LengthCounter.Active() {
+			data |= 0b10
+		}
+		return data
+	case location == 0x5204:
+		// Scanline IRQ status, reading acknowledges the IRQ
+		// 7  bit  0
+		// ---- ----
+		// SVxx xxxx
+		// ||
+		// |+-------- "In Frame" flag
+		// +--------- Scanline IRQ Pending flag
+		var data uint8
+		if m.irqPending {
+			data |= 0b1000_0000
+		}
+		if m.inFrame {
+			data |= 0b0100_0000
+		}
+		m.irqPending = false
+		return data
+	case location == 0x5205:
+		// Low byte of the unsigned 8x8 multiplication
+		return uint8(uint16(m.multiplicand) * uint16(m.multiplier))
+	case location == 0x5206:
+		// High byte of the unsigned 8x8 multiplication
+		return uint8(uint16(m.multiplicand) * uint16(m.multiplier) >> 8)
+	case 0x5C00 <= location && location <= 0x5FFF:
+		if m.exRamMode == exRamReadWrite || m.exRamMode == exRamReadOnly {
+			return m.exRam[location-0x5C00]
+		}
+		// Not readable in the nametable modes, the data bus is not driven
+		return uint8(location >> 8)
+	case 0x6000 <= location && location <= 0x7FFF:
+		return readRam(m.prgRam, m.ramIndex(location), location)
+	case 0x8000 <= location:
+		rom, index := m.prgBank(location)
+		if location == 0xFFFA || location == 0xFFFB {
+			// The NMI vector is read at the start of the vertical blank
+			m.inFrame = false
+		}
+		var data uint8
+		if rom {
+			data = m.cartridge.PrgRom[index%len(m.cartridge.PrgRom)]
+		} else {
+			data = readRam(m.prgRam, index, location)
+		}
+		if m.pcmReadMode && location <= 0xBFFF {
+			// In read mode, the PCM channel plays the data the CPU reads from $8000-$BFFF. A value of 0 triggers an IRQ.
+			if data == 0 {
+				m.pcmIRQ = true
+			} else {
+				m.pcm = data
+			}
+		}
+		return data
+	}
+	// Open bus
+	return uint8(location >> 8)
+}
+
+// Peek returns the data of a CPU read without acknowledging the IRQs and without the effects of reads of the NMI
+// vector and of the PCM read mode
+func (m *Mapper005) Peek(location uint16) uint8 {
+	pcmIRQ, pcm, irqPending, inFrame := m.pcmIRQ, m.pcm, m.irqPending, m.inFrame
+	data := m.CPURead(location)
+	m.pcmIRQ, m.pcm, m.irqPending, m.inFrame = pcmIRQ, pcm, irqPending, inFrame
+	return data
+}
+
+func (m *Mapper005) CPUWrite(location uint16, data uint8) bool {
+	switch {
+	case 0x2000 <= location && location <= 0x3FFF:
+		// The MMC5 watches the sprite size in the PPU control register
+		if location&0b111 == 0 {
+			m.largeSprites = data&0b0010_0000 != 0
+		}
+		return false
+	case 0x5000 <= location && location <= 0x5003:
+		// Pulse 1, like the APU pulses without sweep unit
+		if location != 0x5001 {
+			m.pulse1.Write(location-0x5000, data)
+		}
+	case 0x5004 <= location && location <= 0x5007:
+		// Pulse 2
+		if location != 0x5005 {
+			m.pulse2.Write(location-0x5004, data)
+		}
+	case location == 0x5010:
+		// PCM mode and IRQ
+		// 7  bit  0
+		// ---- ----
+		// Ixxx xxxM
+		// |       |
+		// |       +- Mode select (0 = write mode. 1 = read mode.)
+		// +--------- PCM IRQ enable (1 = enabled.)
+		m.pcmReadMode = data&0b1 == 1
+		m.pcmIRQEnable = data>>7 == 1
+	case location == 0x5011:
+		// Raw PCM, writes of 0 are ignored
+		if !m.pcmReadMode && data != 0 {
+			m.pcm = data
+		}
+	case location == 0x5015:
+		// Enable the length counters of the pulses
+		m.pulse1.LengthCounter.SetEnabled(data&0b01 != 0)
+		m.pulse2.LengthCounter.SetEnabled(data&0b10 != 0)
+	case location == 0x5100:
+		// PRG mode
+		// 0: one 32 KB bank, 1: two 16 KB banks, 2: one 16 KB and two 8 KB banks, 3: four 8 KB banks
+		m.prgMode = data & 0b11
+	case location == 0x5101:
+		// CHR mode
+		// 0: 8 KB pages, 1: 4 KB pages, 2: 2 KB pages, 3: 1 KB pages
+		m.chrMode = data & 0b11
+	case location == 0x5102 || location == 0x5103:
+		// PRG RAM protect, writes are allowed if $5102 is %10 and $5103 is %01
+		m.prgRamProtect[location-0x5102] = data & 0b11
+	case location == 0x5104:
+		// Extended RAM mode
+		// 0: extra nametable, 1: extended attributes, 2: CPU read/write, 3: CPU read only
+		m.exRamMode = data & 0b11
+	case location == 0x5105:
+		// Nametable mapping
+		// 7  bit  0
+		// ---- ----
+		// DDCC BBAA
+		// |||| ||||
+		// |||| ||++- Select nametable at PPU $2000-$23FF
+		// |||| ++--- Select nametable at PPU $2400-$27FF
+		// ||++------ Select nametable at PPU $2800-$2BFF
+		// ++-------- Select nametable at PPU $2C00-$2FFF
+		// 0: CIRAM page 0, 1: CIRAM page 1, 2: extended RAM, 3: fill mode
+		m.nametables = data
+	case location == 0x5106:
+		// Fill mode tile
+		m.fillTile = data
+	case location == 0x5107:
+		// Fill mode attribute
+		m.fillAttribute = data & 0b11
+	case 0x5113 <= location && location <= 0x5117:
+		// PRG bank registers
+		// 7  bit  0
+		// ---- ----
+		// RAAA AaAA
+		// |||| ||||
+		// |||| |+++- PRG RAM bank (for RAM at $6000 and RAM in the other windows)
+		// |+++-++++- PRG ROM bank number in 8 KB units
+		// +--------- RAM/ROM toggle (0: RAM; 1: ROM) ($5114-$5116 only)
+		m.prgBanks[location-0x5113] = data
+	case 0x5120 <= location && location <= 0x512B:
+		// CHR bank registers, $5120-$5127 for the sprites and $5128-$512B for the background in 8x16 sprite mode
+		m.chrBanks[location-0x5120] = uint16(m.chrUpper)<<8 | uint16(data)
+		m.chrSetB = location >= 0x5128
+	case location == 0x5130:
+		// Upper CHR bank bits, applied when a CHR bank register is written
+		m.chrUpper = data & 0b11
+	case location == 0x5200:
+		// Vertical split mode
+		// 7  bit  0
+		// ---- ----
+		// ESxW WWWW
+		// || | ||||
+		// || +-++++- Specify vertical split threshold tile count
+		// |+-------- Specify vertical split region screen side (0:left; 1:right)
+		// +--------- Enable vertical split mode
+		m.splitControl = data
+	case location == 0x5201:
+		// Vertical split scroll
+		m.splitScroll = data
+	case location == 0x5202:
+		// Vertical split CHR page, 4 KB
+		m.splitBank = data
+	case location == 0x5203:
+		// IRQ scanline compare value
+		m.irqCompare = data
+	case location == 0x5204:
+		// Scanline IRQ enable
+		m.irqEnabled = data>>7 == 1
+	case location == 0x5205:
+		m.multiplicand = data
+	case location == 0x5206:
+		m.multiplier = data
+	case 0x5C00 <= location && location <= 0x5FFF:
+		switch m.exRamMode {
+		case exRamNametable, exRamAttributes:
+			// Writes only work while the PPU is rendering, otherwise 0 is written
+			if m.inFrame {
+				m.exRam[location-0x5C00] = data
+			} else {
+				m.exRam[location-0x5C00] = 0
+			}
+		case exRamReadWrite:
+			m.exRam[location-0x5C00] = data
+		}
+	case 0x6000 <= location && location <= 0x7FFF:
+		if m.prgRamWritable() {
+			writeRam(m.prgRam, m.ramIndex(location), data)
+		}
+	case 0x8000 <= location && location <= 0xDFFF:
+		if rom, index := m.prgBank(location); !rom && m.prgRamWritable() {
+			writeRam(m.prgRam, index, data)
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// prgBank returns whether ROM or RAM is mapped to the CPU location $8000-$FFFF and the index into the PRG ROM or RAM
+func (m *Mapper005) prgBank(location uint16) (bool, int) {
+	// PRG mode  $8000-$9FFF  $A000-$BFFF  $C000-$DFFF  $E000-$FFFF
+	// 0         $5117 (32 KB)
+	// 1         $5115 (16 KB)             $5117 (16 KB)
+	// 2         $5115 (16 KB)             $5116        $5117
+	// 3         $5114        $5115        $5116        $5117
+	var register int
+	size := 0x2000
+	switch {
+	case m.prgMode == 0:
+		register, size = 4, 0x8000
+	case m.prgMode <= 2 && location < 0xC000:
+		register, size = 2, 0x4000
+	case m.prgMode == 1:
+		register, size = 4, 0x4000
+	default:
+		register = 1 + int(location-0x8000)/0x2000
+	}
+	bank := m.prgBanks[register]
+	// $5117 always selects ROM
+	rom := register == 4 || bank&0b1000_0000 != 0
+	if !rom {
+		bank &= 0b111
+	}
+	// The lower bits of the bank number are ignored for larger banks
+	return rom, int(bank&0x7F)*0x2000&^(size-1) | int(location)&(size-1)
+}
+
+// ramIndex returns the index into the PRG RAM for the location $6000-$7FFF
+func (m *Mapper005) ramIndex(location uint16) int {
+	return int(m.prgBanks[0]&0b111)*0x2000 | int(location)&0x1FFF
+}
+
+func (m *Mapper005) prgRamWritable() bool {
+	return m.prgRamProtect[0] == 0b10 && m.prgRamProtect[1] == 0b01
+}
+
+func (m *Mapper005) pcmMode() uint8 {
+	if m.pcmReadMode {
+		return 1
+	}
+	return 0
+}
+
+func (m *Mapper005) CPUClock() {
+	// The MMC5 assumes that the PPU stopped rendering, if it does not fetch anything for 3 CPU cycles
+	if m.idleClocks < mmc5IdleClocks {
+		m.idleClocks++
+		if m.idleClocks == mmc5IdleClocks {
+			m.inFrame = false
+			m.lastNametable = 0
+		}
+	}
+	if m.irqPending && m.irqEnabled || m.pcmIRQ && m.pcmIRQEnable {
+		m.cartridge.Bus.IRQ()
+	}
+}
+
+// PPUFetch watches the fetches of the PPU to count the scanlines and to replace the tiles of the split screen and the
+// attributes in extended attribute mode
+func (m *Mapper005) PPUFetch(location uint16, fetch bus.Fetch) (uint8, bool) {
+	m.idleClocks = 0
+	switch fetch {
+	case bus.FetchNametable:
+		m.fetchNametable(location)
+		if m.inSplit {
+			return m.exRam[uint16(m.splitY/8)*32+uint16(m.tile%32)], true
+		}
+		if m.exRamMode == exRamAttributes {
+			m.exAttributes = m.exRam[location&0x3FF]
+		}
+	case bus.FetchAttribute:
+		if m.inSplit {
+			attribute := m.exRam[0x3C0+uint16(m.splitY/32)*8+uint16(m.tile%32/4)]
+			shift := (m.splitY/8)&0b10<<1 | m.tile&0b10
+			return (attribute >> shift & 0b11) * 0b0101_0101, true
+		}
+		if m.exRamMode == exRamAttributes {
+			return (m.exAttributes >> 6) * 0b0101_0101, true
+		}
+	case bus.FetchBackground:
+		if m.inSplit {
+			// The split region has its own fine Y scroll
+			index := int(m.splitBank)*0x1000 | int(location)&0x0FF8 | int(m.splitY&0b111)
+			return m.cartridge.ChrRom[index%len(m.cartridge.ChrRom)], true
+		}
+		if m.exRamMode == exRamAttributes {
+			// Every tile selects its own 4 KB bank
+			index := (int(m.chrUpper)<<6|int(m.exAttributes&0x3F))*0x1000 | int(location)&0x0FFF
+			return m.cartridge.ChrRom[index%len(m.cartridge.ChrRom)], true
+		}
+		return m.cartridge.ChrRom[m.chrIndex(location, m.largeSprites)], true
+	case bus.FetchSprite:
+		m.spriteFetch = true
+		if location > 0x1FFF {
+			// Sprites out of range can produce addresses outside the pattern tables
+			return 0, false
+		}
+		return m.cartridge.ChrRom[m.chrIndex(location, false)], true
+	}
+	return 0, false
+}
+
+// fetchNametable keeps track of the tile position and detects the start of a scanline. The PPU fetches the same
+// nametable byte three times in a row only at the start of a scanline.
+func (m *Mapper005) fetchNametable(location uint16) {
+	switch {
+	case m.spriteFetch:
+		// The first tile after the sprites is the first tile of the next scanline
+		m.tile = 0
+		m.spriteFetch = false
+		m.repeatedReads = 0
+	case location == m.lastNametable:
+		m.repeatedReads++
+		if m.repeatedReads == 2 {
+			m.scanlineStart()
+		}
+	default:
+		m.tile++
+		m.repeatedReads = 0
+	}
+	m.lastNametable = location
+
+	// Scanline of the fetched tile, tile 0 and 1 belong to the next scanline
+	line := 0
+	if m.inFrame {
+		line = int(m.scanline)
+		if m.tile < 2 {
+			line++
+		}
+	}
+	m.splitY = uint8((int(m.splitScroll) + line) % 240)
+
+	threshold := m.splitControl & 0b1_1111
+	right := m.splitControl&0b0100_0000 != 0
+	m.inSplit = m.splitControl&0b1000_0000 != 0 && m.exRamMode <= exRamAttributes && m.tile < 32 &&
+		(m.tile < threshold) != right
+}
+
+// scanlineStart increases the scanline counter and sets the IRQ if the compare value is reached
+func (m *Mapper005) scanlineStart() {
+	// The third fetch is the fetch of the third tile
+	m.tile = 2
+	if !m.inFrame {
+		m.inFrame = true
+		m.scanline = 0
+		m.irqPending = false
+		return
+	}
+	m.scanline++
+	if m.scanline == m.irqCompare {
+		m.irqPending = true
+	}
+}
+
+// chrIndex returns the index into the CHR memory. Set B is used for the background in 8x16 sprite mode, set A for the
+// sprites and for everything in 8x8 sprite mode.
+func (m *Mapper005) chrIndex(location uint16, setB bool) int {
+	// CHR mode  Set A                                 Set B
+	// 0         $5127 (8 KB)                          $512B (8 KB)
+	// 1         $5123, $5127 (4 KB)                   $512B, $512B (4 KB)
+	// 2         $5121, $5123, $5125, $5127 (2 KB)     $5129, $512B, $5129, $512B (2 KB)
+	// 3         $5120 - $5127 (1 KB)                  $5128 - $512B, $5128 - $512B (1 KB)
+	var register uint16
+	size := 0x2000 >> m.chrMode
+	switch {
+	case m.chrMode == 0 && setB, m.chrMode == 1 && setB:
+		register = 11
+	case m.chrMode == 2 && setB:
+		register = 9 + location>>11&0b1*2
+	case m.chrMode == 3 && setB:
+		register = 8 + location>>10&0b11
+	default:
+		// Set A uses the last register of every page
+		register = (location/uint16(size)+1)*uint16(size/0x400) - 1
+	}
+	index := int(m.chrBanks[register])*size | int(location)&(size-1)
+	return index % len(m.cartridge.ChrRom)
+}
+
+func (m *Mapper005) PPUMap(location uint16) uint16 {
+	if 0x2000 <= location && location <= 0x3EFF {
+		// Only the CIRAM pages are mapped here, see NametableRead
+		quadrant := (location - 0x2000) / 0x400 % 4
+		page := uint16(m.nametables>>(quadrant*2)) & 0b1
+		location = 0x2000 + page*0x400 + location%0x400
+	}
+	return location
+}
+
+// NametableRead returns the data of nametables in extended RAM or in fill mode
+func (m *Mapper005) NametableRead(location uint16) (uint8, bool) {
+	switch m.nametables >> ((location - 0x2000) / 0x400 % 4 * 2) & 0b11 {
+	case 2:
+		if m.exRamMode <= exRamAttributes {
+			return m.exRam[location%0x400], true
+		}
+		return 0, true
+	case 3:
+		if location%0x400 >= 0x3C0 {
+			return m.fillAttribute * 0b0101_0101, true
+		}
+		return m.fillTile, true
+	}
+	return 0, false
+}
+
+// NametableWrite writes to nametables in extended RAM. Writes to fill mode nametables are ignored.
+func (m *Mapper005) NametableWrite(location uint16, data uint8) bool {
+	switch m.nametables >> ((location - 0x2000) / 0x400 % 4 * 2) & 0b11 {
+	case 2:
+		if m.exRamMode <= exRamAttributes {
+			m.exRam[location%0x400] = data
+		}
+		return true
+	case 3:
+		return true
+	}
+	return false
+}
+
+func (m *Mapper005) PPURead(location uint16) uint8 {
+	// Outside of the rendering, the last written set is used
+	return m.cartridge.ChrRom[m.chrIndex(location, m.largeSprites && m.chrSetB)]
+}
+
+func (m *Mapper005) PPUWrite(location uint16, data uint8) bool {
+	if location <= 0x1FFF {
+		if m.cartridge.ChrRam {
+			m.cartridge.ChrRom[m.chrIndex(location, m.largeSprites && m.chrSetB)] = data
+		}
+		return true
+	}
+	return false
+}
+
+// ClockAudio clocks the pulses and the frame sequencer of the MMC5
+func (m *Mapper005) ClockAudio() {
+	m.audioCycle++
+	if m.audioCycle%2 == 0 {
+		m.pulse1.ClockTimer()
+		m.pulse2.ClockTimer()
+	}
+	if m.audioCycle >= mmc5FrameCycles {
+		m.audioCycle = 0
+		m.pulse1.Envelope.Clock()
+		m.pulse2.Envelope.Clock()
+		m.pulse1.LengthCounter.Clock()
+		m.pulse2.LengthCounter.Clock()
+	}
+}
+
+// AudioOutput mixes the pulses and the PCM channel. The pulses are as loud as the APU pulses.
+func (m *Mapper005) AudioOutput() float64 {
+	return apu.PulseStep*float64(m.pulse1.Output()+m.pulse2.Output()) + 0.00168*float64(m.pcm)
+}
+
+func (m *Mapper005) Load(data []uint8) error {
+	return loadRam(m.prgRam, data)
+}
+
+func (m *Mapper005) Save() []uint8 {
+	data := make([]uint8, len(m.prgRam))
+	data = m.prgRam[:]
+	return data
+}
+
+func (m *Mapper005) Serialize(s *savestate.Serializer) {
+	s.Bytes(m.prgRam[:])
+	s.Bytes(m.exRam[:])
+	s.Uint8(&m.prgMode)
+	s.Uint8(&m.chrMode)
+	s.Bytes(m.prgRamProtect[:])
+	s.Uint8(&m.exRamMode)
+	s.Uint8(&m.nametables)
+	s.Uint8(&m.fillTile)
+	s.Uint8(&m.fillAttribute)
+	s.Bytes(m.prgBanks[:])
+	for i := range m.chrBanks {
+		s.Uint16(&m.chrBanks[i])
+	}
+	s.Uint8(&m.chrUpper)
+	s.Bool(&m.chrSetB)
+	s.Uint8(&m.splitControl)
+	s.Uint8(&m.splitScroll)
+	s.Uint8(&m.splitBank)
+	s.Uint8(&m.irqCompare)
+	s.Bool(&m.irqEnabled)
+	s.Bool(&m.irqPending)
+	s.Uint8(&m.multiplicand)
+	s.Uint8(&m.multiplier)
+
+	s.Bool(&m.largeSprites)
+	s.Bool(&m.inFrame)
+	s.Uint8(&m.scanline)
+	s.Uint8(&m.idleClocks)
+	s.Uint16(&m.lastNametable)
+	s.Uint8(&m.repeatedReads)
+	s.Uint8(&m.tile)
+	s.Bool(&m.spriteFetch)
+	s.Bool(&m.inSplit)
+	s.Uint8(&m.splitY)
+	s.Uint8(&m.exAttributes)
+
+	m.pulse1.Serialize(s)
+	m.pulse2.Serialize(s)
+	s.Uint8(&m.pcm)
+	s.Bool(&m.pcmReadMode)
+	s.Bool(&m.pcmIRQEnable)
+	s.Bool(&m.pcmIRQ)
+	s.Uint16(&m.audioCycle)
+}
+
+func (m *Mapper005) Reset() {
+	// The last bank is mapped to $8000-$FFFF at power-on
+	m.prgMode = 3
+	m.prgBanks = [5]uint8{0, 0xFF, 0xFF, 0xFF, 0xFF}
+	m.chrMode = 0
+	m.chrBanks = [12]uint16{}
+	m.chrUpper = 0
+	m.chrSetB = false
+	m.prgRamProtect = [2]uint8{}
+	m.exRamMode = 0
+	m.nametables = 0
+	m.splitControl = 0
+	m.irqEnabled = false
+	m.irqPending = false
+	m.inFrame = false
+	m.multiplicand = 0xFF
+	m.multiplier = 0xFF
+	m.pulse1.Reset()
+	m.pulse2.Reset()
+	m.pcm = 0
+	m.pcmReadMode = false
+	m.pcmIRQEnable = false
+	m.pcmIRQ = false
+	m.audioCycle = 0
+}
+
+func (m *Mapper005) DebugDisplay(text io.Writer) {
+	plz.Just(fmt.Fprint(text, "Cartridge with Mapper 005\n"))
+	plz.Just(fmt.Fprintf(text, "PRG ROM Size: %d * 16 KB\n", m.cartridge.PrgRomSize))
+	plz.Just(fmt.Fprintf(text, "CHR ROM Size: %d * 8 KB\n", m.cartridge.ChrRomSize))
+	plz.Just(fmt.Fprintf(text, "PRG Mode: %d \n", m.prgMode))
+	plz.Just(fmt.Fprintf(text, "PRG Banks: %02X %02X %02X %02X %02X\n", m.prgBanks[0], m.prgBanks[1], m.prgBanks[2], m.prgBanks[3], m.prgBanks[4]))
+	plz.Just(fmt.Fprintf(text, "CHR Mode: %d \n", m.chrMode))
+	plz.Just(fmt.Fprintf(text, "CHR Banks A: %v\n", m.chrBanks[:8]))
+	plz.Just(fmt.Fprintf(text, "CHR Banks B: %v\n", m.chrBanks[8:]))
+	plz.Just(fmt.Fprintf(text, "ExRAM Mode: %d \n", m.exRamMode))
+	plz.Just(fmt.Fprintf(text, "Nametables: %08b\n", m.nametables))
+	plz.Just(fmt.Fprintf(text, "Split: %08b\n", m.splitControl))
+	plz.Just(fmt.Fprintf(text, "Scanline: %d, IRQ at %d\n", m.scanline, m.irqCompare))
+	plz.Just(fmt.Fprintf(text, "IRQ: %t\n", m.irqEnabled))
+}
@@ -0,0 +1,234 @@
+package cartridge_test
+
+import (
+	"testing"
+
+	"github.com/exp625/gones/pkg/bus"
+	"github.com/exp625/gones/pkg/cartridge"
+)
+
+func TestMapper005PRGBanks(t *testing.T) {
+	n := newTestNES(t, 5, 16, 8)
+	if data := n.CPURead(0x8000); data != 15 {
+		t.Errorf("expected the last bank at power-on, got %d", data)
+	}
+
+	n.CPUWrite(0x5100, 3)
+	for i, location := range []uint16{0x8000, 0xA000, 0xC000} {
+		n.CPUWrite(0x5114+uint16(i), 0x80|uint8(i+4))
+		if data := n.CPURead(location); data != uint8(i+4) {
+			t.Errorf("mode 3: expected bank %d at %04X, got %d", i+4, location, data)
+		}
+	}
+	n.CPUWrite(0x5100, 1)
+	n.CPUWrite(0x5115, 0x80|5)
+	if data := n.CPURead(0x8000); data != 4 {
+		t.Errorf("mode 1: expected the low bit to be ignored, got bank %d", data)
+	}
+	if data := n.CPURead(0xA000); data != 5 {
+		t.Errorf("mode 1: expected bank 5 at A000, got %d", data)
+	}
+
+	// PRG RAM in a ROM window, only writable after unlocking
+	n.CPUWrite(0x5100, 3)
+	n.CPUWrite(0x5114, 0x02)
+	n.CPUWrite(0x8010, 0x42)
+	if data := n.CPURead(0x8010); data == 0x42 {
+		t.Error("PRG RAM is writable without unlocking")
+	}
+	n.CPUWrite(0x5102, 0b10)
+	n.CPUWrite(0x5103, 0b01)
+	n.CPUWrite(0x8010, 0x42)
+	n.CPUWrite(0x5113, 0x02)
+	if data := n.CPURead(0x6010); data != 0x42 {
+		t.Errorf("expected PRG RAM bank 2 at $6000, got %02X", data)
+	}
+}
+
+func TestMapper005CHRBanks(t *testing.T) {
+	n := newTestNES(t, 5, 4, 256)
+	n.CPUWrite(0x5101, 3)
+	n.CPUWrite(0x5130, 0)
+	for i := uint16(0); i < 12; i++ {
+		n.CPUWrite(0x5120+i, uint8(0x10+i))
+	}
+	// 8x8 sprites use set A for everything
+	if data := n.PPUFetch(0x0400, bus.FetchBackground); data != 0x11 {
+		t.Errorf("expected bank $11 for the background, got %02X", data)
+	}
+	// 8x16 sprites use set B for the background
+	n.CPUWrite(0x2000, 0x20)
+	if data := n.PPUFetch(0x1400, bus.FetchBackground); data != 0x19 {
+		t.Errorf("expected bank $19 for the background, got %02X", data)
+	}
+	if data := n.PPUFetch(0x1400, bus.FetchSprite); data != 0x15 {
+		t.Errorf("expected bank $15 for the sprites, got %02X", data)
+	}
+	// Outside of rendering, the last written set is used
+	if data := n.PPURead(0x0000); data != 0x18 {
+		t.Errorf("expected bank $18 for $2007 reads, got %02X", data)
+	}
+
+	n.CPUWrite(0x5101, 2)
+	if data := n.PPUFetch(0x0C00, bus.FetchBackground); data != 0x37 {
+		t.Errorf("expected 2 KB bank $1B for the background, got %02X", data)
+	}
+	if data := n.PPUFetch(0x0C00, bus.FetchSprite); data != 0x27 {
+		t.Errorf("expected 2 KB bank $13 for the sprites, got %02X", data)
+	}
+}
+
+func TestMapper005Multiplier(t *testing.T) {
+	n := newTestNES(t, 5, 4, 8)
+	n.CPUWrite(0x5205, 200)
+	n.CPUWrite(0x5206, 123)
+	product := uint16(n.CPURead(0x5206))<<8 | uint16(n.CPURead(0x5205))
+	if product != 200*123 {
+		t.Errorf("expected %d, got %d", 200*123, product)
+	}
+}
+
+func TestMapper005Nametables(t *testing.T) {
+	n := newTestNES(t, 5, 4, 8)
+	n.CPUWrite(0x5104, 2)
+	n.CPUWrite(0x5C05, 0x42)
+	n.CPUWrite(0x5104, 0)
+	// CIRAM page 1, CIRAM page 0, ExRAM, fill mode
+	n.CPUWrite(0x5105, 0b11_10_00_01)
+	n.CPUWrite(0x5106, 0x33)
+	n.CPUWrite(0x5107, 0x02)
+
+	n.PPUWrite(0x2000, 0x11)
+	n.PPUWrite(0x2400, 0x22)
+	if data := n.PPURead(0x2400); data != 0x22 {
+		t.Errorf("expected CIRAM page 0 at $2400, got %02X", data)
+	}
+	if data := n.PPURead(0x2000); data != 0x11 || n.VRAM.Read(0x400) != 0x11 {
+		t.Errorf("expected CIRAM page 1 at $2000, got %02X", data)
+	}
+	if data := n.PPURead(0x2805); data != 0x42 {
+		t.Errorf("expected ExRAM at $2800, got %02X", data)
+	}
+	if data := n.PPURead(0x2C10); data != 0x33 {
+		t.Errorf("expected the fill tile, got %02X", data)
+	}
+	if data := n.PPURead(0x2FC0); data != 0xAA {
+		t.Errorf("expected the fill attribute, got %02X", data)
+	}
+}
+
+func TestMapper005ExtendedAttributes(t *testing.T) {
+	n := newTestNES(t, 5, 4, 64)
+	n.CPUWrite(0x5104, 2)
+	// Palette 3 and the 4 KB CHR bank 2 for the tile at $2005
+	n.CPUWrite(0x5C05, 0b11_000010)
+	n.CPUWrite(0x5104, 1)
+
+	n.PPUFetch(0x2005, bus.FetchNametable)
+	if data := n.PPUFetch(0x23C1, bus.FetchAttribute); data != 0xFF {
+		t.Errorf("expected palette 3, got %02X", data)
+	}
+	if data := n.PPUFetch(0x0410, bus.FetchBackground); data != 9 {
+		t.Errorf("expected 1 KB CHR bank 9, got %d", data)
+	}
+}
+
+func TestMapper005Split(t *testing.T) {
+	n := newTestNES(t, 5, 4, 64)
+	n.CPUWrite(0x5104, 2)
+	n.CPUWrite(0x5C00+2*32, 0x42)
+	n.CPUWrite(0x5C00+0x3C0, 0b0011_0000)
+	n.CPUWrite(0x5104, 0)
+	// The first two tiles on the left side, scrolled down by two rows, use CHR bank 3
+	n.CPUWrite(0x5200, 0b1000_0010)
+	n.CPUWrite(0x5201, 19)
+	n.CPUWrite(0x5202, 3)
+	n.PPUWrite(0x2000, 0x11)
+	n.PPUWrite(0x2001, 0x22)
+	n.PPUWrite(0x2002, 0x33)
+
+	// The first tile after the sprites is the first tile of the scanline
+	n.PPUFetch(0x1000, bus.FetchSprite)
+	if data := n.PPUFetch(0x2000, bus.FetchNametable); data != 0x42 {
+		t.Errorf("expected the split tile, got %02X", data)
+	}
+	if data := n.PPUFetch(0x23C0, bus.FetchAttribute); data != 0xFF {
+		t.Errorf("expected the split attribute, got %02X", data)
+	}
+	if data := n.PPUFetch(0x0000, bus.FetchBackground); data != 12 {
+		t.Errorf("expected 1 KB CHR bank 12, got %d", data)
+	}
+	n.PPUFetch(0x2001, bus.FetchNametable)
+	if data := n.PPUFetch(0x2002, bus.FetchNametable); data != 0x33 {
+		t.Errorf("expected the nametable outside of the split, got %02X", data)
+	}
+}
+
+func TestMapper005ScanlineIRQ(t *testing.T) {
+	n := newTestNES(t, 5, 4, 8)
+	// Disable the frame IRQ of the APU
+	n.CPUWrite(0x4017, 0x40)
+	n.CPUWrite(0x5203, 100)
+	n.CPUWrite(0x5204, 0x80)
+	// Enable rendering during the vertical blank
+	runUntil(t, n, 1, func() bool { return n.PPU.ScanLine == 241 })
+	n.CPUWrite(0x2001, 0x18)
+
+	runUntil(t, n, 2, func() bool { return n.CPU.RequestIRQ })
+	if n.PPU.ScanLine != 100 || n.PPU.Dot > 8 {
+		t.Errorf("expected the IRQ at the start of scanline 100, got scanline %d, dot %d", n.PPU.ScanLine, n.PPU.Dot)
+	}
+	if status := n.Cartridge.Mapper.(cartridge.Peeker).Peek(0x5204); status != 0b1100_0000 {
+		t.Errorf("expected to peek IRQ pending and in frame, got %08b", status)
+	}
+	if status := n.CPURead(0x5204); status != 0b1100_0000 {
+		t.Errorf("expected IRQ pending and in frame, got %08b", status)
+	}
+	if status := n.CPURead(0x5204); status != 0b0100_0000 {
+		t.Errorf("expected the IRQ to be acknowledged, got %08b", status)
+	}
+
+	// The in frame flag is cleared after the last scanline
+	runUntil(t, n, 1, func() bool { return n.PPU.ScanLine == 241 })
+	if status := n.CPURead(0x5204); status != 0 {
+		t.Errorf("expected not in frame during the vertical blank, got %08b", status)
+	}
+	// No IRQ if rendering is disabled
+	n.CPUWrite(0x2001, 0)
+	runUntil(t, n, 2, func() bool { return n.PPU.ScanLine == 120 })
+	if status := n.CPURead(0x5204); status != 0 {
+		t.Errorf("expected no IRQ without rendering, got %08b", status)
+	}
+}
+
+func TestMapper005Audio(t *testing.T) {
+	n := newTestNES(t, 5, 4, 8)
+	if n.APU.Expansion == nil {
+		t.Fatal("expected expansion audio")
+	}
+	n.CPUWrite(0x5011, 0x80)
+	if output := n.APU.Expansion.AudioOutput(); output <= 0 {
+		t.Errorf("expected PCM output, got %f", output)
+	}
+	n.CPUWrite(0x5011, 0)
+	pcm := n.APU.Expansion.AudioOutput()
+
+	// Pulse 1 with constant volume 15 and a period of 100
+	n.CPUWrite(0x5015, 0b01)
+	n.CPUWrite(0x5000, 0b1011_1111)
+	n.CPUWrite(0x5002, 100)
+	n.CPUWrite(0x5003, 0b0000_1000)
+	high := false
+	for i := 0; i < 1000; i++ {
+		n.APU.Expansion.ClockAudio()
+		if n.APU.Expansion.AudioOutput() > pcm {
+			high = true
+		}
+	}
+	if !high {
+		t.Error("expected pulse output")
+	}
+	if status := n.CPURead(0x5015); status != 0b01 {
+		t.Errorf("expected the length counter of pulse 1 to be active, got %08b", status)
+	}
+}
@@ -3,7 +3,8 @@ package cartridge
 import (
 	"fmt"
 	"github.com/exp625/gones/internal/plz"
-	"github.com/exp625/gones/internal/textutil"
+	"github.com/exp625/gones/internal/savestate"
+	"io"
 )
 
 type Mapper007 struct {
@@ -89,13 +90,21 @@ func (m *Mapper007) PPUWrite(location uint16, data uint8) bool {
 	return false
 }
 
-func (m *Mapper007) Load(data []uint8) {
+func (m *Mapper007) Load(data []uint8) error {
+	// No battery backed RAM
+	return loadRam(nil, data)
 }
 
 func (m *Mapper007) Save() []uint8 {
 	return []uint8{}
 }
 
+func (m *Mapper007) Serialize(s *savestate.Serializer) {
+	s.Uint8(&m.romBankSelect)
+	s.Bytes(m.chrRam[:])
+	s.Uint8(&m.nameTablePage)
+}
+
 func (m *Mapper007) Reset() {
 	m.romBankSelect = 0
 	m.nameTablePage = 0
@@ -104,7 +113,7 @@ func (m *Mapper007) Reset() {
 func (m *Mapper007) CPUClock() {
 }
 
-func (m *Mapper007) DebugDisplay(text *textutil.Text) {
+func (m *Mapper007) DebugDisplay(text io.Writer) {
 	plz.Just(fmt.Fprint(text, "Cartridge with Mapper 007\n"))
 	plz.Just(fmt.Fprintf(text, "PRG ROM Size: %d * 16 KB\n", m.cartridge.PrgRomSize))
 	plz.Just(fmt.Fprintf(text, "PRG BANK    : %d \n", m.romBankSelect))
@@ -0,0 +1,239 @@
+package cartridge
+
+import (
+	"fmt"
+	"github.com/exp625/gones/internal/plz"
+	"github.com/exp625/gones/internal/savestate"
+	"github.com/exp625/gones/pkg/bus"
+	"io"
+)
+
+// From NES DEV WIKI https://wiki.nesdev.org/w/index.php?title=MMC2
+
+// Used by Punch-Out!!
+
+type Mapper009 struct {
+	cartridge *Cartridge
+
+	// CPU $6000-$7FFF: 8 KB PRG RAM bank (PlayChoice version only)
+	prgRam []uint8
+
+	prgBank    uint8
+	chr        latchChr
+	mirrorMode uint8
+}
+
+func NewMapper009(c *Cartridge) *Mapper009 {
+	m := &Mapper009{
+		cartridge: c,
+		prgRam:    make([]uint8, c.Header.PrgRamTotal()),
+		// Latch 0 only switches at the exact addresses $0FD8 and $0FE8
+		chr: latchChr{cartridge: c, exact: true},
+	}
+	m.Reset()
+	return m
+}
+
+func (m *Mapper009) CPUMap(location uint16) uint16 {
+	return location
+}
+
+// CPU $8000-$9FFF: 8 KB switchable PRG ROM bank
+// CPU $A000-$FFFF: Three 8 KB PRG ROM banks, fixed to the last three banks
+
+func (m *Mapper009) CPURead(location uint16) uint8 {
+	switch {
+	case 0x6000 <= location && location <= 0x7FFF:
+		return readRam(m.prgRam, int(location-0x6000), location)
+	case 0x8000 <= location && location <= 0x9FFF:
+		return m.cartridge.PrgRom[(int(m.prgBank)*0x2000+int(location-0x8000))%len(m.cartridge.PrgRom)]
+	case 0xA000 <= location:
+		// Smaller PRG ROMs are mirrored, the last three banks wrap around
+		banks := len(m.cartridge.PrgRom) / 0x2000
+		bank := (3*banks - 3 + int(location-0xA000)/0x2000) % banks
+		return m.cartridge.PrgRom[bank*0x2000+int(location&0x1FFF)]
+	}
+	// Mapper was not responsible for the location
+	return 0
+}
+
+func (m *Mapper009) CPUWrite(location uint16, data uint8) bool {
+	switch {
+	case 0x6000 <= location && location <= 0x7FFF:
+		writeRam(m.prgRam, int(location-0x6000), data)
+	case 0xA000 <= location && location <= 0xAFFF:
+		// PRG ROM bank select ($A000-$AFFF)
+		// 7  bit  0
+		// ---- ----
+		// xxxx PPPP
+		//      ||||
+		//      ++++- Select 8 KB PRG ROM bank for CPU $8000-$9FFF
+		m.prgBank = data & 0x0F
+	case 0xB000 <= location && location <= 0xEFFF:
+		m.chr.write(location, data)
+	case 0xF000 <= location:
+		// Mirroring ($F000-$FFFF)
+		// 7  bit  0
+		// ---- ----
+		// xxxx xxxM
+		//         |
+		//         +- Select nametable mirroring (0: vertical; 1: horizontal)
+		m.mirrorMode = data & 0b1
+	default:
+		return false
+	}
+	return true
+}
+
+func (m *Mapper009) PPUMap(location uint16) uint16 {
+	if 0x2000 <= location && location <= 0x3EFF {
+		if location >= 0x3000 {
+			location -= 0x1000
+		}
+		if m.mirrorMode == 1 {
+			// 1: horizontal mirroring
+			if location-0x2000 < 0x800 {
+				location = 0x2000 + location%0x400
+			} else {
+				location = 0x2400 + location%0x400
+			}
+		} else {
+			// 0: vertical mirroring
+			location = 0x2000 + location%0x800
+		}
+	}
+	return location
+}
+
+func (m *Mapper009) PPURead(location uint16) uint8 {
+	if location <= 0x1FFF {
+		return m.chr.read(location)
+	}
+	return 0
+}
+
+func (m *Mapper009) PPUFetch(location uint16, fetch bus.Fetch) (uint8, bool) {
+	return m.chr.fetch(location, fetch)
+}
+
+func (m *Mapper009) PPUWrite(location uint16, data uint8) bool {
+	if location <= 0x1FFF {
+		if m.cartridge.ChrRam {
+			m.cartridge.ChrRom[m.chr.index(location)] = data
+		}
+		return true
+	}
+	return false
+}
+
+func (m *Mapper009) Load(data []uint8) error {
+	return loadRam(m.prgRam, data)
+}
+
+func (m *Mapper009) Save() []uint8 {
+	return m.prgRam
+}
+
+func (m *Mapper009) Serialize(s *savestate.Serializer) {
+	s.Bytes(m.prgRam)
+	s.Uint8(&m.prgBank)
+	m.chr.serialize(s)
+	s.Uint8(&m.mirrorMode)
+}
+
+func (m *Mapper009) Reset() {
+	m.prgBank = 0
+	m.chr.reset()
+	m.mirrorMode = 0
+}
+
+func (m *Mapper009) CPUClock() {
+}
+
+func (m *Mapper009) DebugDisplay(text io.Writer) {
+	plz.Just(fmt.Fprint(text, "Cartridge with Mapper 009\n"))
+	plz.Just(fmt.Fprintf(text, "PRG ROM Size: %d * 16 KB\n", m.cartridge.PrgRomSize))
+	plz.Just(fmt.Fprintf(text, "PRG BANK    : %d \n", m.prgBank))
+	plz.Just(fmt.Fprintf(text, "CHR ROM Size: %d * 8 KB\n", m.cartridge.ChrRomSize))
+	m.chr.debugDisplay(text)
+	plz.Just(fmt.Fprintf(text, "Mirror Mode : %d \n", m.mirrorMode))
+}
+
+// latchChr is the CHR banking of the MMC2 and MMC4. Both pattern tables have two 4 KB banks, one for latch value $FD
+// and one for latch value $FE. The latch of a pattern table switches after the PPU fetched tile $FD or $FE from it, so
+// the graphics can change in the middle of a scanline without the help of the CPU.
+type latchChr struct {
+	cartridge *Cartridge
+	// 4 KB banks of the pattern tables for latch value $FD and $FE
+	banks [2][2]uint8
+	// Latch values of the pattern tables, $FD or $FE
+	latches [2]uint8
+	// The MMC2 only switches latch 0 at $0FD8 and $0FE8, the MMC4 like latch 1 at $0FD8-$0FDF and $0FE8-$0FEF
+	exact bool
+}
+
+// write handles the CHR bank registers
+func (c *latchChr) write(location uint16, data uint8) {
+	// CHR ROM bank select ($B000-$EFFF)
+	// 7  bit  0
+	// ---- ----
+	// xxxC CCCC
+	//    | ||||
+	//    +-++++- Select 4 KB CHR ROM bank
+	// $B000: PPU $0000-$0FFF for latch 0 = $FD
+	// $C000: PPU $0000-$0FFF for latch 0 = $FE
+	// $D000: PPU $1000-$1FFF for latch 1 = $FD
+	// $E000: PPU $1000-$1FFF for latch 1 = $FE
+	register := (location - 0xB000) >> 12
+	c.banks[register>>1][register&0b1] = data & 0b1_1111
+}
+
+// index returns the index into the CHR memory for the PPU location $0000-$1FFF
+func (c *latchChr) index(location uint16) int {
+	table := location >> 12
+	bank := c.banks[table][(c.latches[table]-0xFD)&0b1]
+	return (int(bank)*0x1000 + int(location&0x0FFF)) % len(c.cartridge.ChrRom)
+}
+
+// read reads the CHR memory without switching the latches
+func (c *latchChr) read(location uint16) uint8 {
+	return c.cartridge.ChrRom[c.index(location)]
+}
+
+// fetch handles the pattern fetches of the PPU while rendering. The latch switches after the fetch of tile $FD or $FE.
+// Other reads, like those of the debugger, do not switch the latches.
+func (c *latchChr) fetch(location uint16, fetch bus.Fetch) (uint8, bool) {
+	if location > 0x1FFF || (fetch != bus.FetchBackground && fetch != bus.FetchSprite) {
+		return 0, false
+	}
+	data := c.read(location)
+	table := location >> 12
+	tile := location & 0x0FF8
+	if table == 0 && c.exact && location&0b111 != 0 {
+		return data, true
+	}
+	switch tile {
+	case 0x0FD8:
+		c.latches[table] = 0xFD
+	case 0x0FE8:
+		c.latches[table] = 0xFE
+	}
+	return data, true
+}
+
+func (c *latchChr) reset() {
+	c.banks = [2][2]uint8{}
+	c.latches = [2]uint8{0xFE, 0xFE}
+}
+
+func (c *latchChr) serialize(s *savestate.Serializer) {
+	for i := range c.banks {
+		s.Bytes(c.banks[i][:])
+	}
+	s.Bytes(c.latches[:])
+}
+
+func (c *latchChr) debugDisplay(text io.Writer) {
+	plz.Just(fmt.Fprintf(text, "CHR $0000   : $FD: %d, $FE: %d, Latch: $%02X\n", c.banks[0][0], c.banks[0][1], c.latches[0]))
+	plz.Just(fmt.Fprintf(text, "CHR $1000   : $FD: %d, $FE: %d, Latch: $%02X\n", c.banks[1][0], c.banks[1][1], c.latches[1]))
+}
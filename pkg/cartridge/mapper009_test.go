@@ -0,0 +1,125 @@
+package cartridge_test
+
+import (
+	"testing"
+
+	"github.com/exp625/gones/pkg/bus"
+	"github.com/exp625/gones/pkg/nes"
+)
+
+// bankColumn returns the column of the opaque pixel in the row of a tile. Every 1 KB CHR bank of the test ROM is
+// filled with its bank number, only the rows of the 4 KB banks 1 and 2 with a single bit set are used.
+func bankColumn(n *nes.NES, x int, y int) int {
+	for column := 0; column < 8; column++ {
+		if n.PPU.ActiveIndices[y*256+x+column]&0x3F == 0x30 {
+			return column
+		}
+	}
+	return -1
+}
+
+func TestMapper009LatchFrame(t *testing.T) {
+	n := newTestNES(t, 9, 16, 128)
+	// The 4 KB banks 1 and 2 hold the bytes 4 and 8 for tile 0, drawn as an opaque pixel in column 5 or 4
+	n.CPUWrite(0xB000, 1)
+	n.CPUWrite(0xC000, 2)
+	n.PPUWrite(0x3F00, 0x0F)
+	n.PPUWrite(0x3F03, 0x30)
+	// Switch to latch $FE at the first row of tile row 10 and back to $FD at tile row 20
+	n.PPUWrite(0x2000+10*32, 0xFE)
+	n.PPUWrite(0x2000+20*32, 0xFD)
+	n.CPUWrite(0x2001, 0b0000_1010)
+
+	runUntil(t, n, 3, func() bool { return n.PPU.FrameCount == 2 })
+	for y := 0; y < 240; y++ {
+		expected := 5
+		if 80 <= y && y < 160 {
+			expected = 4
+		}
+		if column := bankColumn(n, 8, y); column != expected {
+			t.Fatalf("scanline %d: expected the opaque pixel in column %d, got %d", y, expected, column)
+		}
+	}
+	// The tile that switches the latch is still fetched from the old bank
+	if column := bankColumn(n, 0, 80); column == 4 {
+		t.Error("expected the switch after the fetch of tile $FE")
+	}
+}
+
+func TestMapper009LatchAddresses(t *testing.T) {
+	for _, test := range []struct {
+		mapper uint8
+		// Whether fetches of other rows of tile $FD switch latch 0
+		anyRow bool
+	}{{9, false}, {10, true}} {
+		n := newTestNES(t, test.mapper, 16, 128)
+		for i, bank := range []uint8{1, 2, 3, 4} {
+			n.CPUWrite(0xB000+uint16(i)*0x1000, bank)
+		}
+
+		// Both latches start with $FE
+		if data := n.PPURead(0x0000); data != 8 {
+			t.Errorf("mapper %d: expected bank 2 at power-on, got 1 KB bank %d", test.mapper, data)
+		}
+		// Reads outside of rendering, e.g. by the debugger, do not switch the latches
+		n.PPURead(0x0FD8)
+		if data := n.PPURead(0x0000); data != 8 {
+			t.Errorf("mapper %d: expected no switch after reading $0FD8, got 1 KB bank %d", test.mapper, data)
+		}
+		n.PPUFetch(0x0FDB, bus.FetchBackground)
+		if data := n.PPURead(0x0000); (data == 4) != test.anyRow {
+			t.Errorf("mapper %d: unexpected 1 KB bank %d after fetching $0FDB", test.mapper, data)
+		}
+		n.PPUFetch(0x0FD8, bus.FetchBackground)
+		if data := n.PPURead(0x0000); data != 4 {
+			t.Errorf("mapper %d: expected bank 1 after fetching $0FD8, got 1 KB bank %d", test.mapper, data)
+		}
+		// Latch 1 switches on any row of the tile, also by sprite fetches
+		n.PPUFetch(0x1FDE, bus.FetchSprite)
+		if data := n.PPURead(0x1000); data != 12 {
+			t.Errorf("mapper %d: expected bank 3 after fetching $1FDE, got 1 KB bank %d", test.mapper, data)
+		}
+		n.PPUFetch(0x1FE8, bus.FetchBackground)
+		if data := n.PPURead(0x1000); data != 16 {
+			t.Errorf("mapper %d: expected bank 4 after fetching $1FE8, got 1 KB bank %d", test.mapper, data)
+		}
+	}
+}
+
+func TestMapper009PRG(t *testing.T) {
+	for _, test := range []struct {
+		prgBanks int
+		fixed    [3]uint8
+	}{
+		{16, [3]uint8{13, 14, 15}},
+		// Smaller PRG ROMs are mirrored
+		{2, [3]uint8{1, 0, 1}},
+	} {
+		n := newTestNES(t, 9, test.prgBanks, 128)
+		n.CPUWrite(0xA000, 1)
+		if data := n.CPURead(0x8000); data != 1 {
+			t.Errorf("%d KB: expected bank 1, got bank %d", test.prgBanks*8, data)
+		}
+		for i, bank := range test.fixed {
+			if data := n.CPURead(0xA000 + uint16(i)*0x2000); data != bank {
+				t.Errorf("%d KB: expected bank %d at $%04X, got bank %d", test.prgBanks*8, bank,
+					0xA000+i*0x2000, data)
+			}
+		}
+	}
+}
+
+func TestMapper010PRG(t *testing.T) {
+	n := newTestNES(t, 10, 16, 128)
+	n.CPUWrite(0xA000, 3)
+	if data := n.CPURead(0x8000); data != 6 {
+		t.Errorf("expected 16 KB bank 3, got 8 KB bank %d", data)
+	}
+	if data := n.CPURead(0xC000); data != 14 {
+		t.Errorf("expected the last 16 KB bank, got 8 KB bank %d", data)
+	}
+	n.CPUWrite(0x6123, 0x42)
+	if data := n.CPURead(0x6123); data != 0x42 {
+		t.Errorf("expected PRG RAM, got %02X", data)
+	}
+}
@@ -0,0 +1,156 @@
+package cartridge
+
+import (
+	"fmt"
+	"github.com/exp625/gones/internal/plz"
+	"github.com/exp625/gones/internal/savestate"
+	"github.com/exp625/gones/pkg/bus"
+	"io"
+)
+
+// From NES DEV WIKI https://wiki.nesdev.org/w/index.php?title=MMC4
+
+// Used by Fire Emblem and Famicom Wars. Like the MMC2, but with 16 KB PRG ROM banks and battery backed PRG RAM.
+
+type Mapper010 struct {
+	cartridge *Cartridge
+
+	// CPU $6000-$7FFF: 8 KB PRG RAM bank
+	prgRam []uint8
+
+	prgBank    uint8
+	chr        latchChr
+	mirrorMode uint8
+}
+
+func NewMapper010(c *Cartridge) *Mapper010 {
+	m := &Mapper010{
+		cartridge: c,
+		prgRam:    make([]uint8, c.Header.PrgRamTotal()),
+		chr:       latchChr{cartridge: c},
+	}
+	m.Reset()
+	return m
+}
+
+func (m *Mapper010) CPUMap(location uint16) uint16 {
+	return location
+}
+
+// CPU $8000-$BFFF: 16 KB switchable PRG ROM bank
+// CPU $C000-$FFFF: 16 KB PRG ROM bank, fixed to the last bank
+
+func (m *Mapper010) CPURead(location uint16) uint8 {
+	switch {
+	case 0x6000 <= location && location <= 0x7FFF:
+		return readRam(m.prgRam, int(location-0x6000), location)
+	case 0x8000 <= location && location <= 0xBFFF:
+		return m.cartridge.PrgRom[(int(m.prgBank)*0x4000+int(location-0x8000))%len(m.cartridge.PrgRom)]
+	case 0xC000 <= location:
+		return m.cartridge.PrgRom[len(m.cartridge.PrgRom)-0x4000+int(location-0xC000)]
+	}
+	// Mapper was not responsible for the location
+	return 0
+}
+
+func (m *Mapper010) CPUWrite(location uint16, data uint8) bool {
+	switch {
+	case 0x6000 <= location && location <= 0x7FFF:
+		writeRam(m.prgRam, int(location-0x6000), data)
+	case 0xA000 <= location && location <= 0xAFFF:
+		// PRG ROM bank select ($A000-$AFFF)
+		// 7  bit  0
+		// ---- ----
+		// xxxx PPPP
+		//      ||||
+		//      ++++- Select 16 KB PRG ROM bank for CPU $8000-$BFFF
+		m.prgBank = data & 0x0F
+	case 0xB000 <= location && location <= 0xEFFF:
+		m.chr.write(location, data)
+	case 0xF000 <= location:
+		// Mirroring ($F000-$FFFF)
+		// 7  bit  0
+		// ---- ----
+		// xxxx xxxM
+		//         |
+		//         +- Select nametable mirroring (0: vertical; 1: horizontal)
+		m.mirrorMode = data & 0b1
+	default:
+		return false
+	}
+	return true
+}
+
+func (m *Mapper010) PPUMap(location uint16) uint16 {
+	if 0x2000 <= location && location <= 0x3EFF {
+		if location >= 0x3000 {
+			location -= 0x1000
+		}
+		if m.mirrorMode == 1 {
+			// 1: horizontal mirroring
+			if location-0x2000 < 0x800 {
+				location = 0x2000 + location%0x400
+			} else {
+				location = 0x2400 + location%0x400
+			}
+		} else {
+			// 0: vertical mirroring
+			location = 0x2000 + location%0x800
+		}
+	}
+	return location
+}
+
+func (m *Mapper010) PPURead(location uint16) uint8 {
+	if location <= 0x1FFF {
+		return m.chr.read(location)
+	}
+	return 0
+}
+
+func (m *Mapper010) PPUFetch(location uint16, fetch bus.Fetch) (uint8, bool) {
+	return m.chr.fetch(location, fetch)
+}
+
+func (m *Mapper010) PPUWrite(location uint16, data uint8) bool {
+	if location <= 0x1FFF {
+		if m.cartridge.ChrRam {
+			m.cartridge.ChrRom[m.chr.index(location)] = data
+		}
+		return true
+	}
+	return false
+}
+
+func (m *Mapper010) Load(data []uint8) error {
+	return loadRam(m.prgRam, data)
+}
+
+func (m *Mapper010) Save() []uint8 {
+	return m.prgRam
+}
+
+func (m *Mapper010) Serialize(s *savestate.Serializer) {
+	s.Bytes(m.prgRam)
+	s.Uint8(&m.prgBank)
+	m.chr.serialize(s)
+	s.Uint8(&m.mirrorMode)
+}
+
+func (m *Mapper010) Reset() {
+	m.prgBank = 0
+	m.chr.reset()
+	m.mirrorMode = 0
+}
+
+func (m *Mapper010) CPUClock() {
+}
+
+func (m *Mapper010) DebugDisplay(text io.Writer) {
+	plz.Just(fmt.Fprint(text, "Cartridge with Mapper 010\n"))
+	plz.Just(fmt.Fprintf(text, "PRG ROM Size: %d * 16 KB\n", m.cartridge.PrgRomSize))
+	plz.Just(fmt.Fprintf(text, "PRG BANK    : %d \n", m.prgBank))
+	plz.Just(fmt.Fprintf(text, "CHR ROM Size: %d * 8 KB\n", m.cartridge.ChrRomSize))
+	m.chr.debugDisplay(text)
+	plz.Just(fmt.Fprintf(text, "Mirror Mode : %d \n", m.mirrorMode))
+}
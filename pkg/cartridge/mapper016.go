@@ -0,0 +1,290 @@
+package cartridge
+
+import (
+	"fmt"
+	"github.com/exp625/gones/internal/plz"
+	"github.com/exp625/gones/internal/savestate"
+	"io"
+)
+
+// From NES DEV WIKI https://wiki.nesdev.org/w/index.php?title=Bandai_FCG_board
+
+// The Bandai FCG-1, FCG-2 and LZ93D50 are used by the mappers 16, 153 and 159. The boards differ in the location of
+// the registers, the IRQ counter and the memory for the saves:
+//
+// Mapper  Submapper  Chip        Registers    Save
+// 16      4          FCG-1/2     $6000-$7FFF  None
+// 16      5          LZ93D50     $8000-$FFFF  24C02 EEPROM, or X24C01 EEPROM with 128 bytes of PRG NVRAM
+// 16      0          Both        Both         24C02 EEPROM, or X24C01 EEPROM with 128 bytes of PRG NVRAM
+// 153     0          LZ93D50     $8000-$FFFF  8 KB PRG RAM, CHR bank bit 0 selects the 256 KB PRG ROM half
+// 159     0          LZ93D50     $8000-$FFFF  X24C01 EEPROM
+//
+// Used by the Dragon Ball and SD Gundam games.
+
+type Mapper016 struct {
+	cartridge *Cartridge
+	// Registers at $6000-$7FFF (FCG-1/2) and at $8000-$FFFF (LZ93D50)
+	fcg, lz93d50 bool
+
+	// CPU $6000-$7FFF: 8 KB PRG RAM bank (mapper 153)
+	prgRam []uint8
+	// Serial EEPROM, read at $6000-$7FFF (optional)
+	eeprom *eeprom
+
+	chrBanks [8]uint8
+	prgBank  uint8
+	// 256 KB PRG ROM half (mapper 153)
+	outerBank  uint8
+	mirrorMode uint8
+	// EEPROM lines and PRG RAM enable ($800D)
+	control uint8
+
+	irqEnabled bool
+	irqPending bool
+	irqCounter uint16
+	irqLatch   uint16
+}
+
+// NewMapper016 creates the FCG board for the mappers 16, 153 and 159
+func NewMapper016(c *Cartridge) *Mapper016 {
+	m := &Mapper016{
+		cartridge: c,
+		fcg:       c.Header.Mapper == 16 && c.Header.Submapper != 5,
+		lz93d50:   c.Header.Mapper != 16 || c.Header.Submapper != 4,
+	}
+	switch {
+	case c.Header.Mapper == 153:
+		m.prgRam = make([]uint8, c.Header.PrgRamTotal())
+	case c.Header.Mapper == 159:
+		m.eeprom = newEEPROM(128)
+	case c.Header.Submapper == 4:
+	case c.Header.PrgNvramSize == 128:
+		m.eeprom = newEEPROM(128)
+	case c.Header.Submapper != 5 || c.Header.PrgNvramSize != 0:
+		m.eeprom = newEEPROM(256)
+	}
+	return m
+}
+
+func (m *Mapper016) CPUMap(location uint16) uint16 {
+	return location
+}
+
+// CPU $6000-$7FFF: EEPROM data (bit 4) or 8 KB PRG RAM bank (mapper 153)
+// CPU $8000-$BFFF: 16 KB switchable PRG ROM bank
+// CPU $C000-$FFFF: 16 KB PRG ROM bank, fixed to the last bank
+
+func (m *Mapper016) CPURead(location uint16) uint8 {
+	switch {
+	case 0x6000 <= location && location <= 0x7FFF:
+		if m.prgRam != nil {
+			if m.control&0b0010_0000 == 0 {
+				return uint8(location >> 8)
+			}
+			return readRam(m.prgRam, int(location-0x6000), location)
+		}
+		data := uint8(location>>8) &^ 0b1_0000
+		if m.eeprom != nil && m.eeprom.read() {
+			data |= 0b1_0000
+		}
+		return data
+	case 0x8000 <= location && location <= 0xBFFF:
+		bank := int(m.outerBank)<<4 | int(m.prgBank)
+		return m.cartridge.PrgRom[(bank*0x4000+int(location-0x8000))%len(m.cartridge.PrgRom)]
+	case 0xC000 <= location:
+		bank := int(m.outerBank)<<4 | 0x0F
+		return m.cartridge.PrgRom[(bank*0x4000+int(location-0xC000))%len(m.cartridge.PrgRom)]
+	}
+	// Mapper was not responsible for the location
+	return 0
+}
+
+func (m *Mapper016) CPUWrite(location uint16, data uint8) bool {
+	switch {
+	case 0x6000 <= location && location <= 0x7FFF && m.prgRam != nil:
+		if m.control&0b0010_0000 != 0 {
+			writeRam(m.prgRam, int(location-0x6000), data)
+		}
+	case 0x6000 <= location && location <= 0x7FFF && m.fcg, 0x8000 <= location && m.lz93d50:
+		m.writeRegister(location&0x0F, data)
+	case location < 0x6000:
+		return false
+	}
+	return true
+}
+
+// writeRegister writes to the register $x0-$xF
+func (m *Mapper016) writeRegister(register uint16, data uint8) {
+	switch {
+	case register <= 0x7:
+		// 1 KB CHR banks, bit 0 selects the 256 KB PRG ROM half on mapper 153
+		m.chrBanks[register] = data
+		if m.cartridge.Header.Mapper == 153 {
+			m.outerBank = data & 0b1
+		}
+	case register == 0x8:
+		// 7  bit  0
+		// ---- ----
+		// xxxx PPPP
+		//      ||||
+		//      ++++- Select 16 KB PRG ROM bank for CPU $8000-$BFFF
+		m.prgBank = data & 0x0F
+	case register == 0x9:
+		// 7  bit  0
+		// ---- ----
+		// xxxx xxMM
+		//        ||
+		//        ++- Mirroring (0: vertical; 1: horizontal; 2: one-screen, lower bank; 3: one-screen, upper bank)
+		m.mirrorMode = data & 0b11
+	case register == 0xA:
+		// 7  bit  0
+		// ---- ----
+		// xxxx xxxE
+		//         |
+		//         +- IRQ enable, the write acknowledges the IRQ and the LZ93D50 copies the latch to the counter
+		m.irqEnabled = data&0b1 == 1
+		m.irqPending = false
+		if m.lz93d50 {
+			m.irqCounter = m.irqLatch
+		}
+	case register == 0xB, register == 0xC:
+		// Low and high byte of the IRQ latch (LZ93D50) or of the IRQ counter (FCG-1/2)
+		shift := (register - 0xB) * 8
+		if m.lz93d50 {
+			m.irqLatch = m.irqLatch&^(0xFF<<shift) | uint16(data)<<shift
+		} else {
+			m.irqCounter = m.irqCounter&^(0xFF<<shift) | uint16(data)<<shift
+		}
+	case register == 0xD:
+		// 7  bit  0
+		// ---- ----
+		// RDCx xxxx
+		// |||
+		// ||+------- EEPROM SCL, PRG RAM enable on mapper 153
+		// |+-------- EEPROM SDA
+		// +--------- Release SDA to read the EEPROM
+		m.control = data
+		if m.eeprom != nil {
+			m.eeprom.write(data&0b0010_0000 != 0, data&0b1100_0000 != 0)
+		}
+	}
+}
+
+func (m *Mapper016) CPUClock() {
+}
+
+func (m *Mapper016) CPUCycle() {
+	// The counter is checked before it is decremented
+	if m.irqEnabled {
+		if m.irqCounter == 0 {
+			m.irqPending = true
+		}
+		m.irqCounter--
+	}
+	if m.irqPending {
+		m.cartridge.Bus.IRQ()
+	}
+}
+
+func (m *Mapper016) PPUMap(location uint16) uint16 {
+	if 0x2000 <= location && location <= 0x3EFF {
+		var page uint16
+		switch m.mirrorMode {
+		case 0:
+			// Vertical mirroring
+			page = location >> 10 & 0b1
+		case 1:
+			// Horizontal mirroring
+			page = location >> 11 & 0b1
+		case 2:
+			page = 0
+		case 3:
+			page = 1
+		}
+		location = 0x2000 + page*0x400 + location%0x400
+	}
+	return location
+}
+
+// chrIndex returns the index into the CHR memory for the PPU location $0000-$1FFF. The CHR RAM of mapper 153 is not
+// banked.
+func (m *Mapper016) chrIndex(location uint16) int {
+	if m.cartridge.ChrRam {
+		return int(location) % len(m.cartridge.ChrRom)
+	}
+	return (int(m.chrBanks[location/0x400])*0x400 + int(location%0x400)) % len(m.cartridge.ChrRom)
+}
+
+func (m *Mapper016) PPURead(location uint16) uint8 {
+	if location <= 0x1FFF {
+		return m.cartridge.ChrRom[m.chrIndex(location)]
+	}
+	return 0
+}
+
+func (m *Mapper016) PPUWrite(location uint16, data uint8) bool {
+	if location <= 0x1FFF {
+		if m.cartridge.ChrRam {
+			m.cartridge.ChrRom[m.chrIndex(location)] = data
+		}
+		return true
+	}
+	return false
+}
+
+// Load loads the contents of the EEPROM or of the PRG RAM
+func (m *Mapper016) Load(data []uint8) error {
+	if m.eeprom != nil {
+		return loadRam(m.eeprom.data, data)
+	}
+	return loadRam(m.prgRam, data)
+}
+
+// Save returns the contents of the EEPROM or of the PRG RAM
+func (m *Mapper016) Save() []uint8 {
+	if m.eeprom != nil {
+		return m.eeprom.data
+	}
+	return m.prgRam
+}
+
+func (m *Mapper016) Serialize(s *savestate.Serializer) {
+	s.Bytes(m.prgRam)
+	if m.eeprom != nil {
+		m.eeprom.serialize(s)
+	}
+	s.Bytes(m.chrBanks[:])
+	s.Uint8(&m.prgBank)
+	s.Uint8(&m.outerBank)
+	s.Uint8(&m.mirrorMode)
+	s.Uint8(&m.control)
+	s.Bool(&m.irqEnabled)
+	s.Bool(&m.irqPending)
+	s.Uint16(&m.irqCounter)
+	s.Uint16(&m.irqLatch)
+}
+
+func (m *Mapper016) Reset() {
+	m.chrBanks = [8]uint8{}
+	m.prgBank = 0
+	m.outerBank = 0
+	m.mirrorMode = 0
+	m.control = 0
+	m.irqEnabled = false
+	m.irqPending = false
+	m.irqCounter = 0
+	m.irqLatch = 0
+}
+
+func (m *Mapper016) DebugDisplay(text io.Writer) {
+	plz.Just(fmt.Fprintf(text, "Cartridge with Mapper %03d\n", m.cartridge.Header.Mapper))
+	plz.Just(fmt.Fprintf(text, "PRG ROM Size: %d * 16 KB\n", m.cartridge.PrgRomSize))
+	plz.Just(fmt.Fprintf(text, "PRG Bank    : %d, Outer: %d\n", m.prgBank, m.outerBank))
+	plz.Just(fmt.Fprintf(text, "CHR ROM Size: %d * 8 KB\n", m.cartridge.ChrRomSize))
+	plz.Just(fmt.Fprintf(text, "CHR Banks   : %v\n", m.chrBanks))
+	plz.Just(fmt.Fprintf(text, "Mirror Mode : %d \n", m.mirrorMode))
+	plz.Just(fmt.Fprintf(text, "IRQ Counter : %04X, Latch: %04X, Enabled: %t\n", m.irqCounter, m.irqLatch, m.irqEnabled))
+	if m.eeprom != nil {
+		plz.Just(fmt.Fprintf(text, "EEPROM      : %d bytes, Address: %02X\n", len(m.eeprom.data), m.eeprom.address))
+	}
+}
@@ -0,0 +1,246 @@
+package cartridge_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/exp625/gones/pkg/cartridge"
+)
+
+// i2c is the CPU as master on the I²C bus of the EEPROM of the LZ93D50
+type i2c struct {
+	c *cartridge.Cartridge
+	// Bit order of the X24C01
+	lsbFirst bool
+}
+
+// lines sets SCL and SDA through $800D. A high SDA is released, so the EEPROM can drive it.
+func (b i2c) lines(scl bool, sda bool) {
+	var data uint8
+	if scl {
+		data |= 0b0010_0000
+	}
+	if sda {
+		data |= 0b1000_0000
+	}
+	b.c.CPUWrite(0x800D, data)
+}
+
+// sda reads SDA from $6000
+func (b i2c) sda() bool {
+	return b.c.CPURead(0x6000)&0b1_0000 != 0
+}
+
+func (b i2c) start() {
+	b.lines(false, true)
+	b.lines(true, true)
+	b.lines(true, false)
+}
+
+func (b i2c) stop() {
+	b.lines(false, false)
+	b.lines(true, false)
+	b.lines(true, true)
+}
+
+// clock sends a bit and returns the level of SDA while SCL is high
+func (b i2c) clock(bit bool) bool {
+	b.lines(false, bit)
+	b.lines(true, bit)
+	return b.sda()
+}
+
+// send sends a byte and returns whether it was acknowledged
+func (b i2c) send(data uint8) bool {
+	for i := 0; i < 8; i++ {
+		if b.lsbFirst {
+			b.clock(data>>i&0b1 == 1)
+		} else {
+			b.clock(data>>(7-i)&0b1 == 1)
+		}
+	}
+	return !b.clock(true)
+}
+
+// receive receives a byte and acknowledges it, if more bytes are read
+func (b i2c) receive(more bool) uint8 {
+	var data uint8
+	for i := 0; i < 8; i++ {
+		if !b.clock(true) {
+			continue
+		}
+		if b.lsbFirst {
+			data |= 1 << i
+		} else {
+			data |= 1 << (7 - i)
+		}
+	}
+	b.clock(!more)
+	return data
+}
+
+func TestMapper016EEPROM(t *testing.T) {
+	c, _ := newTestCartridge(t, 16, 5, 16, 8)
+	if len(c.Save()) != 0 {
+		t.Errorf("expected no EEPROM without PRG NVRAM, got %d bytes", len(c.Save()))
+	}
+
+	// 24C02 with 256 bytes
+	c, _ = newTestCartridge(t, 16, 0, 16, 8)
+	b := i2c{c: c}
+	b.start()
+	if !b.send(0xA0) || !b.send(0x0E) {
+		t.Fatal("expected the 24C02 to acknowledge the device and word address")
+	}
+	for _, data := range []uint8{0x11, 0x22, 0x33} {
+		if !b.send(data) {
+			t.Fatalf("expected the 24C02 to acknowledge $%02X", data)
+		}
+	}
+	b.stop()
+	save := c.Save()
+	if len(save) != 256 {
+		t.Fatalf("expected 256 bytes, got %d", len(save))
+	}
+	// Writes wrap around in the page of 8 bytes
+	if !bytes.Equal(save[0x08:0x10], []uint8{0x33, 0, 0, 0, 0, 0, 0x11, 0x22}) {
+		t.Errorf("unexpected contents % X", save[0x08:0x10])
+	}
+
+	// Random read with a dummy write of the word address
+	b.start()
+	b.send(0xA0)
+	b.send(0x0F)
+	b.start()
+	b.send(0xA1)
+	if data := []uint8{b.receive(true), b.receive(false)}; !bytes.Equal(data, []uint8{0x22, 0x00}) {
+		t.Errorf("expected 22 00, got % X", data)
+	}
+	b.stop()
+
+	// Other devices are not acknowledged
+	b.start()
+	if b.send(0x50) {
+		t.Error("expected no acknowledge for another device")
+	}
+	b.stop()
+
+	// The contents are loaded from the save
+	c, _ = newTestCartridge(t, 16, 0, 16, 8)
+	if err := c.Load(save); err != nil {
+		t.Fatal(err)
+	}
+	b = i2c{c: c}
+	b.start()
+	b.send(0xA0)
+	b.send(0x08)
+	b.start()
+	b.send(0xA1)
+	if data := b.receive(false); data != 0x33 {
+		t.Errorf("expected $33 from the loaded save, got $%02X", data)
+	}
+	b.stop()
+}
+
+func TestMapper159EEPROM(t *testing.T) {
+	// X24C01 with 128 bytes, the word address and R are sent with the least significant bit first
+	c, _ := newTestCartridge(t, 159, 0, 16, 8)
+	b := i2c{c: c, lsbFirst: true}
+	b.start()
+	if !b.send(0x05) || !b.send(0x81) || !b.send(0x42) {
+		t.Fatal("expected the X24C01 to acknowledge the word address and the data")
+	}
+	b.stop()
+	save := c.Save()
+	if len(save) != 128 || save[5] != 0x81 || save[6] != 0x42 {
+		t.Fatalf("unexpected contents % X", save)
+	}
+
+	b.start()
+	b.send(0x80 | 0x05)
+	if data := []uint8{b.receive(true), b.receive(false)}; !bytes.Equal(data, []uint8{0x81, 0x42}) {
+		t.Errorf("expected 81 42, got % X", data)
+	}
+	b.stop()
+}
+
+func TestMapper016Banks(t *testing.T) {
+	for _, test := range []struct {
+		submapper uint8
+		register  uint16
+	}{{4, 0x6000}, {5, 0x8000}, {0, 0x6000}, {0, 0x8000}} {
+		c, _ := newTestCartridge(t, 16, test.submapper, 32, 256)
+		c.CPUWrite(test.register|0x8, 3)
+		for i := uint16(0); i < 8; i++ {
+			c.CPUWrite(test.register|i, uint8(0x30+i))
+		}
+		c.CPUWrite(test.register|0x9, 1)
+		if data := c.CPURead(0x8000); data != 6 {
+			t.Errorf("submapper %d: expected PRG bank 6 at $8000, got %d", test.submapper, data)
+		}
+		if data := c.CPURead(0xC000); data != 30 {
+			t.Errorf("submapper %d: expected PRG bank 30 at $C000, got %d", test.submapper, data)
+		}
+		for i := uint16(0); i < 8; i++ {
+			if data := c.PPURead(i * 0x400); data != uint8(0x30+i) {
+				t.Errorf("submapper %d: expected CHR bank $%02X at slot %d, got $%02X", test.submapper, 0x30+i, i, data)
+			}
+		}
+		if location := c.PPUMap(0x2800); location != 0x2400 {
+			t.Errorf("submapper %d: expected horizontal mirroring, got $%04X", test.submapper, location)
+		}
+	}
+
+	// The registers of the FCG-1/2 are not mirrored at $8000 and the other way around
+	c, _ := newTestCartridge(t, 16, 4, 32, 8)
+	c.CPUWrite(0x8008, 3)
+	if data := c.CPURead(0x8000); data != 0 {
+		t.Errorf("expected no register at $8008 on the FCG-1/2, got PRG bank %d", data)
+	}
+}
+
+func TestMapper016IRQ(t *testing.T) {
+	// The LZ93D50 copies the latch to the counter, the FCG-1/2 writes the counter directly
+	for _, test := range []struct {
+		submapper uint8
+		register  uint16
+	}{{5, 0x8000}, {4, 0x6000}} {
+		c, b := newTestCartridge(t, 16, test.submapper, 16, 8)
+		c.CPUWrite(test.register|0xB, 10)
+		c.CPUWrite(test.register|0xC, 0)
+		c.CPUWrite(test.register|0xA, 1)
+		if cycle := clockCPU(c, b, 100); cycle != 11 {
+			t.Errorf("submapper %d: expected the IRQ after 11 cycles, got %d", test.submapper, cycle)
+		}
+		// Writing the control register acknowledges the IRQ
+		c.CPUWrite(test.register|0xA, 0)
+		if cycle := clockCPU(c, b, 100); cycle != -1 {
+			t.Errorf("submapper %d: expected no IRQ after the acknowledgement, got one after %d cycles", test.submapper, cycle)
+		}
+	}
+}
+
+func TestMapper153(t *testing.T) {
+	c, _ := newTestCartridge(t, 153, 0, 64, 0)
+	c.CPUWrite(0x8000, 1)
+	c.CPUWrite(0x8008, 2)
+	if data := c.CPURead(0x8000); data != 36 {
+		t.Errorf("expected PRG bank 36 in the upper half, got %d", data)
+	}
+	if data := c.CPURead(0xC000); data != 62 {
+		t.Errorf("expected the last PRG bank of the upper half, got %d", data)
+	}
+
+	c.CPUWrite(0x6000, 0x42)
+	if data := c.CPURead(0x6000); data != 0x60 {
+		t.Errorf("expected open bus for disabled PRG RAM, got $%02X", data)
+	}
+	c.CPUWrite(0x800D, 0b0010_0000)
+	c.CPUWrite(0x6000, 0x42)
+	if data := c.CPURead(0x6000); data != 0x42 {
+		t.Errorf("expected PRG RAM, got $%02X", data)
+	}
+	if save := c.Save(); len(save) != 0x2000 || save[0] != 0x42 {
+		t.Errorf("expected the PRG RAM as save")
+	}
+}
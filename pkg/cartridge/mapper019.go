@@ -0,0 +1,379 @@
+package cartridge
+
+import (
+	"fmt"
+	"github.com/exp625/gones/internal/plz"
+	"github.com/exp625/gones/internal/savestate"
+	"github.com/exp625/gones/pkg/apu"
+	"io"
+)
+
+// From NES DEV WIKI https://wiki.nesdev.org/w/index.php?title=INES_Mapper_019
+
+// The Namco 163 with its wavetable sound channels. The nametables can be mapped to CHR ROM.
+// Used by Megami Tensei II, King of Kings and Rolling Thunder.
+
+type Mapper019 struct {
+	cartridge *Cartridge
+
+	// CPU $6000-$7FFF: 8 KB PRG RAM (optional)
+	prgRam []uint8
+	// Write protection of the PRG RAM ($F800)
+	writeProtect uint8
+
+	prgBanks [3]uint8
+	// CHR banks at $0000-$1FFF ($8000-$B800) and nametables ($C000-$D800). Values of $E0 and above select the CIRAM.
+	chrBanks [12]uint8
+	// CIRAM disable for the pattern tables and sound disable ($E000 and $E800)
+	sound      bool
+	ciramLow   bool
+	ciramHigh  bool
+	irqCounter uint16
+	irqEnabled bool
+	irqPending bool
+
+	// Sound RAM with the waveforms and the channel registers at $40-$7F
+	soundRam     [128]uint8
+	soundAddress uint8
+	// Current channel and the CPU cycles since the last channel update
+	channel     uint8
+	audioCycles uint8
+	// Output of the current channel
+	sample int
+}
+
+func NewMapper019(c *Cartridge) *Mapper019 {
+	return &Mapper019{
+		cartridge: c,
+		prgRam:    make([]uint8, c.Header.PrgRamTotal()),
+	}
+}
+
+func (m *Mapper019) CPUMap(location uint16) uint16 {
+	return location
+}
+
+// CPU $4800-$4FFF: Sound RAM data port
+// CPU $5000-$5FFF: IRQ counter
+// CPU $6000-$7FFF: 8 KB PRG RAM bank
+// CPU $8000-$9FFF: 8 KB switchable PRG ROM bank
+// CPU $A000-$BFFF: 8 KB switchable PRG ROM bank
+// CPU $C000-$DFFF: 8 KB switchable PRG ROM bank
+// CPU $E000-$FFFF: 8 KB PRG ROM bank, fixed to the last bank
+
+func (m *Mapper019) CPURead(location uint16) uint8 {
+	switch {
+	case 0x4800 <= location && location <= 0x4FFF:
+		data := m.soundRam[m.soundAddress&0x7F]
+		m.incrementSoundAddress()
+		return data
+	case 0x5000 <= location && location <= 0x57FF:
+		return uint8(m.irqCounter)
+	case 0x5800 <= location && location <= 0x5FFF:
+		data := uint8(m.irqCounter >> 8)
+		if m.irqEnabled {
+			data |= 0b1000_0000
+		}
+		return data
+	case 0x6000 <= location && location <= 0x7FFF:
+		return readRam(m.prgRam, int(location-0x6000), location)
+	case 0x8000 <= location && location <= 0xDFFF:
+		bank := int(m.prgBanks[(location-0x8000)/0x2000])
+		return m.cartridge.PrgRom[(bank*0x2000+int(location&0x1FFF))%len(m.cartridge.PrgRom)]
+	case 0xE000 <= location:
+		return m.cartridge.PrgRom[len(m.cartridge.PrgRom)-0x2000+int(location&0x1FFF)]
+	}
+	// Mapper was not responsible for the location
+	return 0
+}
+
+// Peek returns the data of a CPU read without incrementing the address of the sound RAM
+func (m *Mapper019) Peek(location uint16) uint8 {
+	soundAddress := m.soundAddress
+	data := m.CPURead(location)
+	m.soundAddress = soundAddress
+	return data
+}
+
+func (m *Mapper019) CPUWrite(location uint16, data uint8) bool {
+	switch {
+	case location < 0x4800:
+		return false
+	case location <= 0x4FFF:
+		m.soundRam[m.soundAddress&0x7F] = data
+		m.incrementSoundAddress()
+	case location <= 0x57FF:
+		// Writing the counter acknowledges the IRQ
+		m.irqCounter = m.irqCounter&0x7F00 | uint16(data)
+		m.irqPending = false
+	case location <= 0x5FFF:
+		// 7  bit  0
+		// ---- ----
+		// EIII IIII
+		// |||| ||||
+		// |+++-++++- High 7 bits of the IRQ counter
+		// +--------- IRQ enable
+		m.irqCounter = m.irqCounter&0x00FF | uint16(data&0x7F)<<8
+		m.irqEnabled = data>>7 == 1
+		m.irqPending = false
+	case location <= 0x7FFF:
+		// The RAM is writable if the upper nibble is 0100 and the bit of the 2 KB window is clear
+		if m.writeProtect>>4 == 0b0100 && m.writeProtect>>((location-0x6000)/0x800)&0b1 == 0 {
+			writeRam(m.prgRam, int(location-0x6000), data)
+		}
+	case location <= 0xDFFF:
+		// CHR and nametable banks
+		m.chrBanks[(location-0x8000)/0x800] = data
+	case location <= 0xE7FF:
+		// 7  bit  0
+		// ---- ----
+		// .SPP PPPP
+		//  ||| ||||
+		//  |++-++++- 8 KB PRG bank at $8000
+		//  +-------- Sound disable
+		m.prgBanks[0] = data & 0b11_1111
+		m.sound = data&0b0100_0000 == 0
+	case location <= 0xEFFF:
+		// 7  bit  0
+		// ---- ----
+		// HLPP PPPP
+		// |||| ||||
+		// ||++-++++- 8 KB PRG bank at $A000
+		// |+-------- Disable CIRAM in the pattern table at $0000
+		// +--------- Disable CIRAM in the pattern table at $1000
+		m.prgBanks[1] = data & 0b11_1111
+		m.ciramLow = data&0b0100_0000 == 0
+		m.ciramHigh = data&0b1000_0000 == 0
+	case location <= 0xF7FF:
+		m.prgBanks[2] = data & 0b11_1111
+	default:
+		// The same register sets the write protection and the sound RAM address
+		// 7  bit  0
+		// ---- ----
+		// IAAA AAAA
+		// |||| ||||
+		// |+++-++++- Sound RAM address
+		// +--------- Auto increment
+		//
+		// 7  bit  0
+		// ---- ----
+		// KKKK DCBA
+		// |||| ||||
+		// |||| |||+- Write protect $6000-$67FF
+		// |||| ||+-- Write protect $6800-$6FFF
+		// |||| |+--- Write protect $7000-$77FF
+		// |||| +---- Write protect $7800-$7FFF
+		// ++++------ Must be 0100 to enable writes
+		m.writeProtect = data
+		m.soundAddress = data
+	}
+	return true
+}
+
+func (m *Mapper019) incrementSoundAddress() {
+	if m.soundAddress>>7 == 1 {
+		m.soundAddress = 0x80 | (m.soundAddress+1)&0x7F
+	}
+}
+
+func (m *Mapper019) CPUClock() {
+}
+
+func (m *Mapper019) CPUCycle() {
+	// The 15-bit counter counts up every CPU cycle and stops at $7FFF
+	if m.irqEnabled && m.irqCounter < 0x7FFF {
+		m.irqCounter++
+		if m.irqCounter == 0x7FFF {
+			m.irqPending = true
+		}
+	}
+	if m.irqPending {
+		m.cartridge.Bus.IRQ()
+	}
+}
+
+// ciramPage returns the CIRAM page selected by the bank register or false if the register selects CHR ROM
+func (m *Mapper019) ciramPage(register int) (uint16, bool) {
+	bank := m.chrBanks[register]
+	switch {
+	case bank < 0xE0:
+		return 0, false
+	case register < 4 && !m.ciramLow, 4 <= register && register < 8 && !m.ciramHigh:
+		return 0, false
+	}
+	return uint16(bank & 0b1), true
+}
+
+func (m *Mapper019) PPUMap(location uint16) uint16 {
+	if 0x2000 <= location && location <= 0x3EFF {
+		// Only the CIRAM pages are mapped here, see NametableRead
+		page, _ := m.ciramPage(int(8 + (location-0x2000)/0x400%4))
+		location = 0x2000 + page*0x400 + location%0x400
+	}
+	return location
+}
+
+// chrIndex returns the index into the CHR memory for the 1 KB bank register
+func (m *Mapper019) chrIndex(register int, location uint16) int {
+	return (int(m.chrBanks[register])*0x400 + int(location%0x400)) % len(m.cartridge.ChrRom)
+}
+
+// NametableRead returns the data of nametables in CHR ROM
+func (m *Mapper019) NametableRead(location uint16) (uint8, bool) {
+	register := int(8 + (location-0x2000)/0x400%4)
+	if _, ok := m.ciramPage(register); ok {
+		return 0, false
+	}
+	return m.cartridge.ChrRom[m.chrIndex(register, location)], true
+}
+
+// NametableWrite writes to nametables in CHR RAM. Writes to CHR ROM are ignored.
+func (m *Mapper019) NametableWrite(location uint16, data uint8) bool {
+	register := int(8 + (location-0x2000)/0x400%4)
+	if _, ok := m.ciramPage(register); ok {
+		return false
+	}
+	if m.cartridge.ChrRam {
+		m.cartridge.ChrRom[m.chrIndex(register, location)] = data
+	}
+	return true
+}
+
+func (m *Mapper019) PPURead(location uint16) uint8 {
+	if location <= 0x1FFF {
+		register := int(location / 0x400)
+		if page, ok := m.ciramPage(register); ok {
+			return m.cartridge.Bus.PPUReadRam(0x2000 + page*0x400 + location%0x400)
+		}
+		return m.cartridge.ChrRom[m.chrIndex(register, location)]
+	}
+	return 0
+}
+
+func (m *Mapper019) PPUWrite(location uint16, data uint8) bool {
+	if location <= 0x1FFF {
+		register := int(location / 0x400)
+		if page, ok := m.ciramPage(register); ok {
+			m.cartridge.Bus.PPUWriteRam(0x2000+page*0x400+location%0x400, data)
+		} else if m.cartridge.ChrRam {
+			m.cartridge.ChrRom[m.chrIndex(register, location)] = data
+		}
+		return true
+	}
+	return false
+}
+
+// ClockAudio updates one channel every 15 CPU cycles. The channels are updated one after another from channel 7
+// down to the lowest enabled channel and only the last updated channel is output.
+//
+// Channel registers at $40 + channel * 8
+// $x0: Frequency low
+// $x1: Phase low
+// $x2: Frequency middle
+// $x3: Phase middle
+// $x4: Length (bits 2-7, 256 - L samples) and frequency high (bits 0-1)
+// $x5: Phase high
+// $x6: Wave address in 4-bit samples
+// $x7: Volume (bits 0-3). Bits 4-6 of $7F are the number of enabled channels minus 1.
+func (m *Mapper019) ClockAudio() {
+	if !m.sound {
+		return
+	}
+	m.audioCycles++
+	if m.audioCycles < 15 {
+		return
+	}
+	m.audioCycles = 0
+
+	lowest := 7 - m.soundRam[0x7F]>>4&0b111
+	if m.channel < lowest {
+		m.channel = 7
+	}
+	registers := m.soundRam[0x40+int(m.channel)*8:]
+	frequency := uint32(registers[0]) | uint32(registers[2])<<8 | uint32(registers[4]&0b11)<<16
+	phase := uint32(registers[1]) | uint32(registers[3])<<8 | uint32(registers[5])<<16
+	length := uint32(256-int(registers[4]&0b1111_1100)) << 16
+	phase = (phase + frequency) % length
+	registers[1] = uint8(phase)
+	registers[3] = uint8(phase >> 8)
+	registers[5] = uint8(phase >> 16)
+
+	// Two 4-bit samples per byte, the low nibble first
+	address := (phase>>16 + uint32(registers[6])) & 0xFF
+	sample := m.soundRam[address>>1] >> (address & 0b1 * 4) & 0x0F
+	m.sample = (int(sample) - 8) * int(registers[7]&0x0F)
+
+	if m.channel == lowest {
+		m.channel = 7
+	} else {
+		m.channel--
+	}
+}
+
+// AudioOutput returns the output of the current channel. The samples at full volume span -120 to 105, so a channel at
+// full volume is about as loud as an APU pulse.
+func (m *Mapper019) AudioOutput() float64 {
+	if !m.sound {
+		return 0
+	}
+	return apu.PulseStep / 8 * float64(m.sample)
+}
+
+func (m *Mapper019) Load(data []uint8) error {
+	return loadRam(m.prgRam, data)
+}
+
+func (m *Mapper019) Save() []uint8 {
+	return m.prgRam
+}
+
+func (m *Mapper019) Serialize(s *savestate.Serializer) {
+	s.Bytes(m.prgRam)
+	s.Uint8(&m.writeProtect)
+	s.Bytes(m.prgBanks[:])
+	s.Bytes(m.chrBanks[:])
+	s.Bool(&m.sound)
+	s.Bool(&m.ciramLow)
+	s.Bool(&m.ciramHigh)
+	s.Uint16(&m.irqCounter)
+	s.Bool(&m.irqEnabled)
+	s.Bool(&m.irqPending)
+	s.Bytes(m.soundRam[:])
+	s.Uint8(&m.soundAddress)
+	s.Uint8(&m.channel)
+	s.Uint8(&m.audioCycles)
+	s.Int(&m.sample)
+}
+
+func (m *Mapper019) Reset() {
+	m.writeProtect = 0
+	m.prgBanks = [3]uint8{}
+	m.chrBanks = [12]uint8{}
+	// Until the game sets the nametables, use the mirroring of the header
+	if m.cartridge.MirrorBit {
+		copy(m.chrBanks[8:], []uint8{0xE0, 0xE1, 0xE0, 0xE1})
+	} else {
+		copy(m.chrBanks[8:], []uint8{0xE0, 0xE0, 0xE1, 0xE1})
+	}
+	m.sound = true
+	m.ciramLow = true
+	m.ciramHigh = true
+	m.irqCounter = 0
+	m.irqEnabled = false
+	m.irqPending = false
+	m.soundAddress = 0
+	m.channel = 7
+	m.audioCycles = 0
+	m.sample = 0
+}
+
+func (m *Mapper019) DebugDisplay(text io.Writer) {
+	plz.Just(fmt.Fprintf(text, "Cartridge with Mapper 019\n"))
+	plz.Just(fmt.Fprintf(text, "PRG ROM Size: %d * 16 KB\n", m.cartridge.PrgRomSize))
+	plz.Just(fmt.Fprintf(text, "PRG Banks   : %v\n", m.prgBanks))
+	plz.Just(fmt.Fprintf(text, "CHR ROM Size: %d * 8 KB\n", m.cartridge.ChrRomSize))
+	plz.Just(fmt.Fprintf(text, "CHR Banks   : %v\n", m.chrBanks[:8]))
+	plz.Just(fmt.Fprintf(text, "Nametables  : %v\n", m.chrBanks[8:]))
+	plz.Just(fmt.Fprintf(text, "IRQ Counter : %04X, Enabled: %t, Pending: %t\n", m.irqCounter, m.irqEnabled, m.irqPending))
+	plz.Just(fmt.Fprintf(text, "Channels    : %d\n", m.soundRam[0x7F]>>4&0b111+1))
+}
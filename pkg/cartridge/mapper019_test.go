@@ -0,0 +1,151 @@
+package cartridge_test
+
+import (
+	"testing"
+
+	"github.com/exp625/gones/pkg/apu"
+	"github.com/exp625/gones/pkg/cartridge"
+)
+
+func TestMapper019Banks(t *testing.T) {
+	c, b := newTestCartridge(t, 19, 0, 32, 256)
+	c.CPUWrite(0xE000, 3)
+	c.CPUWrite(0xE800, 4)
+	c.CPUWrite(0xF000, 5)
+	for _, test := range []struct {
+		location uint16
+		bank     uint8
+	}{{0x8000, 3}, {0xA000, 4}, {0xC000, 5}, {0xE000, 31}} {
+		if data := c.CPURead(test.location); data != test.bank {
+			t.Errorf("expected PRG bank %d at $%04X, got %d", test.bank, test.location, data)
+		}
+	}
+
+	for i := uint16(0); i < 8; i++ {
+		c.CPUWrite(0x8000+i*0x800, uint8(0x30+i))
+	}
+	for i := uint16(0); i < 8; i++ {
+		if data := c.PPURead(i * 0x400); data != uint8(0x30+i) {
+			t.Errorf("expected CHR bank $%02X at slot %d, got $%02X", 0x30+i, i, data)
+		}
+	}
+
+	// Banks of $E0 and above select the CIRAM, unless it is disabled for the pattern table
+	b.PPUWriteRam(0x2410, 0x42)
+	c.CPUWrite(0x8000, 0xE1)
+	c.CPUWrite(0xA000, 0xE1)
+	if data := c.PPURead(0x0010); data != 0x42 {
+		t.Errorf("expected CIRAM page 1 in the pattern table, got $%02X", data)
+	}
+	c.PPUWrite(0x0011, 0x43)
+	if data := b.PPUReadRam(0x2411); data != 0x43 {
+		t.Errorf("expected a write to CIRAM page 1, got $%02X", data)
+	}
+	c.CPUWrite(0xE800, 0b1000_0000)
+	if data := c.PPURead(0x0010); data != 0x42 {
+		t.Errorf("expected CIRAM at $0000 with the upper CIRAM disabled, got $%02X", data)
+	}
+	if data := c.PPURead(0x1010); data != 0xE1 {
+		t.Errorf("expected CHR bank $E1 at $1000, got $%02X", data)
+	}
+	c.CPUWrite(0xE800, 0b0100_0000)
+	if data := c.PPURead(0x0010); data != 0xE1 {
+		t.Errorf("expected CHR bank $E1 at $0000, got $%02X", data)
+	}
+}
+
+func TestMapper019Nametables(t *testing.T) {
+	c, _ := newTestCartridge(t, 19, 0, 16, 256)
+	nametables := c.Mapper.(cartridge.NametableMapper)
+	c.CPUWrite(0xC000, 0xE1)
+	c.CPUWrite(0xC800, 0xE0)
+	c.CPUWrite(0xD000, 0x25)
+	c.CPUWrite(0xD800, 0xE1)
+	for _, test := range []struct {
+		location uint16
+		mapped   uint16
+	}{{0x2010, 0x2410}, {0x2410, 0x2010}, {0x2C10, 0x2410}} {
+		if location := c.PPUMap(test.location); location != test.mapped {
+			t.Errorf("expected $%04X mapped to $%04X, got $%04X", test.location, test.mapped, location)
+		}
+		if _, ok := nametables.NametableRead(test.location); ok {
+			t.Errorf("expected CIRAM at $%04X", test.location)
+		}
+	}
+	if data, ok := nametables.NametableRead(0x2810); !ok || data != 0x25 {
+		t.Errorf("expected CHR bank $25 as nametable, got $%02X", data)
+	}
+	if !nametables.NametableWrite(0x2810, 0) {
+		t.Error("expected writes to CHR ROM nametables to be handled by the mapper")
+	}
+}
+
+func TestMapper019IRQ(t *testing.T) {
+	c, b := newTestCartridge(t, 19, 0, 16, 8)
+	c.CPUWrite(0x5000, 0xF0)
+	c.CPUWrite(0x5800, 0x7F)
+	if cycle := clockCPU(c, b, 100); cycle != -1 {
+		t.Errorf("expected no IRQ with the counter disabled, got one after %d cycles", cycle)
+	}
+	c.CPUWrite(0x5800, 0xFF)
+	if cycle := clockCPU(c, b, 100); cycle != 15 {
+		t.Errorf("expected the IRQ when the counter reaches $7FFF after 15 cycles, got %d", cycle)
+	}
+	if counter := c.CPURead(0x5000); counter != 0xFF {
+		t.Errorf("expected the counter to stop at $7FFF, got $%02X", counter)
+	}
+	// Writing the counter acknowledges the IRQ
+	c.CPUWrite(0x5000, 0x00)
+	if cycle := clockCPU(c, b, 100); cycle != -1 {
+		t.Errorf("expected no IRQ after the acknowledgement, got one after %d cycles", cycle)
+	}
+}
+
+func TestMapper019Audio(t *testing.T) {
+	c, _ := newTestCartridge(t, 19, 0, 16, 8)
+	audio := c.Mapper.(apu.ExpansionAudio)
+
+	// Sound RAM with auto increment
+	c.CPUWrite(0xF800, 0x80)
+	for _, data := range []uint8{0xF0, 0x00} {
+		c.CPUWrite(0x4800, data)
+	}
+	c.CPUWrite(0xF800, 0x80)
+	// Peeking does not increment the address
+	peeker := c.Mapper.(cartridge.Peeker)
+	if data := peeker.Peek(0x4800); data != 0xF0 || peeker.Peek(0x4800) != 0xF0 {
+		t.Errorf("expected to peek $F0 twice, got $%02X", data)
+	}
+	if data := c.CPURead(0x4800); data != 0xF0 {
+		t.Errorf("expected $F0 in the sound RAM, got $%02X", data)
+	}
+	if data := c.CPURead(0x4800); data != 0x00 {
+		t.Errorf("expected $00 in the sound RAM, got $%02X", data)
+	}
+
+	// Channel 7 plays the 4 samples 0, F, 0, 0 and advances by one sample per update, starting with the second
+	c.CPUWrite(0xF800, 0x80|0x78)
+	for _, data := range []uint8{0x00, 0x00, 0x00, 0x00, 0xFD, 0x00, 0x00, 0x0F} {
+		c.CPUWrite(0x4800, data)
+	}
+	var outputs []float64
+	for i := 0; i < 4*15; i++ {
+		audio.ClockAudio()
+		if i%15 == 14 {
+			outputs = append(outputs, audio.AudioOutput())
+		}
+	}
+	step := apu.PulseStep / 8 * 15
+	expected := []float64{7 * step, -8 * step, -8 * step, -8 * step}
+	for i := range expected {
+		if outputs[i] < expected[i]-1e-9 || outputs[i] > expected[i]+1e-9 {
+			t.Fatalf("expected %v, got %v", expected, outputs)
+		}
+	}
+
+	// Sound disable
+	c.CPUWrite(0xE000, 0b0100_0000)
+	if output := audio.AudioOutput(); output != 0 {
+		t.Errorf("expected no output with the sound disabled, got %f", output)
+	}
+}
@@ -0,0 +1,569 @@
+package cartridge
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/exp625/gones/internal/ips"
+	"github.com/exp625/gones/internal/plz"
+	"github.com/exp625/gones/internal/savestate"
+	"io"
+	"log"
+)
+
+// From NES DEV WIKI https://wiki.nesdev.org/w/index.php?title=Family_Computer_Disk_System
+
+// The RAM adapter of the Famicom Disk System with its disk drive, timer IRQ and wavetable sound channel. The BIOS is
+// mapped at $E000 and loads the files from the disk into the PRG RAM and the CHR RAM.
+
+// diskInsertDelay is the time in CPU cycles a disk stays ejected when another side is inserted, about one second. The
+// BIOS only notices the new side if the drive has been empty for a while.
+const diskInsertDelay = 1789773
+
+// Delays of the disk drive in CPU cycles
+const (
+	// Time from the start of the motor until the head reaches the start of the disk
+	diskSpinUp = 50000
+	// Time to transfer one byte at 96.4 kbit/s
+	diskByteTime = 150
+)
+
+type Mapper020 struct {
+	cartridge *Cartridge
+
+	// CPU $6000-$DFFF: 32 KB PRG RAM
+	prgRam []uint8
+
+	// Sides of the disk image and the tracks of the disk sides, see diskTrack
+	sides  [][]uint8
+	tracks [][]uint8
+	// Inserted side. A new side is inserted after the delay.
+	side        int
+	inserted    bool
+	nextSide    int
+	insertDelay int
+
+	// Timer IRQ ($4020-$4022)
+	irqReload  uint16
+	irqCounter uint16
+	irqRepeat  bool
+	irqEnabled bool
+	irqPending bool
+
+	// Disk and sound registers enable ($4023)
+	diskEnabled  bool
+	soundEnabled bool
+	// 7  bit  0
+	// ---- ----
+	// IS1C MRTD
+	// |||| ||||
+	// |||| |||+- Drive motor (0: stop; 1: start)
+	// |||| ||+-- Reset the transfer, the head stays at the start of the disk
+	// |||| |+--- Transfer mode (0: write; 1: read)
+	// |||| +---- Mirroring (0: vertical; 1: horizontal)
+	// |||+------ Transfer the CRC
+	// ||+------- Always 1
+	// |+-------- Start the transfer of the next block after the gap
+	// +--------- Enable the disk IRQ when a byte has been transferred
+	control uint8
+	// Output of the expansion port ($4026)
+	external uint8
+
+	// Position of the head on the track and the time until the next byte
+	position int
+	delay    int
+	// The head is at the start of the disk after the motor has been started
+	endOfHead bool
+	scanning  bool
+	// The start mark of a block has been read
+	gapEnded bool
+	// Data register for reads ($4031) and writes ($4024)
+	readData  uint8
+	writeData uint8
+	// Byte transfer flag and disk IRQ
+	transferred bool
+	diskIRQ     bool
+	crc         uint16
+	lastCRC     bool
+
+	audio fdsAudio
+}
+
+// NewMapper020 creates the RAM adapter with the sides of the disk image, side A is inserted
+func NewMapper020(c *Cartridge, sides [][]uint8) *Mapper020 {
+	m := &Mapper020{
+		cartridge: c,
+		prgRam:    make([]uint8, 0x8000),
+		sides:     sides,
+		tracks:    make([][]uint8, len(sides)),
+		inserted:  true,
+		endOfHead: true,
+	}
+	for i, side := range sides {
+		m.tracks[i] = diskTrack(side)
+	}
+	m.audio.reset()
+	return m
+}
+
+// Sides returns the number of disk sides
+func (m *Mapper020) Sides() int {
+	return len(m.sides)
+}
+
+// Side returns the inserted side. If no disk is inserted, the last inserted side is returned.
+func (m *Mapper020) Side() (int, bool) {
+	return m.side, m.inserted
+}
+
+// Eject removes the disk from the drive
+func (m *Mapper020) Eject() {
+	m.inserted = false
+	m.insertDelay = 0
+}
+
+// Insert inserts a side of the disk. If a disk is inserted, it is ejected first and the new side is inserted after a
+// delay.
+func (m *Mapper020) Insert(side int) {
+	if side < 0 || side >= len(m.sides) {
+		return
+	}
+	if !m.inserted && m.insertDelay == 0 {
+		m.side = side
+		m.inserted = true
+		return
+	}
+	m.Eject()
+	m.nextSide = side
+	m.insertDelay = diskInsertDelay
+}
+
+func (m *Mapper020) CPUMap(location uint16) uint16 {
+	return location
+}
+
+// CPU $4030-$4033: Disk status registers
+// CPU $4040-$4092: Sound registers
+// CPU $6000-$DFFF: 32 KB PRG RAM
+// CPU $E000-$FFFF: 8 KB BIOS ROM
+
+func (m *Mapper020) CPURead(location uint16) uint8 {
+	switch {
+	case 0x4030 <= location && location <= 0x4033:
+		if !m.diskEnabled {
+			return uint8(location >> 8)
+		}
+		return m.readDisk(location)
+	case 0x4040 <= location && location <= 0x4097:
+		data, ok := m.audio.read(location)
+		if !ok {
+			return uint8(location >> 8)
+		}
+		return data
+	case 0x6000 <= location && location <= 0xDFFF:
+		return m.prgRam[location-0x6000]
+	case 0xE000 <= location:
+		return m.cartridge.PrgRom[location-0xE000]
+	}
+	// Mapper was not responsible for the location
+	return 0
+}
+
+// Peek returns the data of a CPU read without acknowledging the IRQs and the byte transfer flag
+func (m *Mapper020) Peek(location uint16) uint8 {
+	irqPending, transferred, diskIRQ := m.irqPending, m.transferred, m.diskIRQ
+	data := m.CPURead(location)
+	m.irqPending, m.transferred, m.diskIRQ = irqPending, transferred, diskIRQ
+	return data
+}
+
+func (m *Mapper020) readDisk(location uint16) uint8 {
+	switch location {
+	case 0x4030:
+		// 7  bit  0
+		// ---- ----
+		// IExB xxTD
+		// |||| ||||
+		// |||| |||+- Timer IRQ
+		// |||| ||+-- Byte transfer flag
+		// |||+------ CRC error
+		// ||+------- Open bus
+		// |+-------- End of the disk reached
+		// +--------- Disk data register access
+		// Reading acknowledges both IRQs
+		data := uint8(location>>8) & 0b0010_0000
+		if m.irqPending {
+			data |= 0b1
+		}
+		if m.transferred {
+			data |= 0b10
+		}
+		if m.inserted && m.position >= len(m.tracks[m.side]) {
+			data |= 0b0100_0000
+		}
+		m.irqPending = false
+		m.transferred = false
+		m.diskIRQ = false
+		return data
+	case 0x4031:
+		m.transferred = false
+		m.diskIRQ = false
+		return m.readData
+	case 0x4032:
+		// 7  bit  0
+		// ---- ----
+		// xxxx xPRS
+		//       |||
+		//       ||+- Disk missing
+		//       |+-- Disk not ready
+		//       +--- Disk write protected
+		data := uint8(location>>8) & 0b1111_1000
+		if !m.inserted {
+			data |= 0b101
+		}
+		if !m.inserted || !m.scanning {
+			data |= 0b10
+		}
+		return data
+	default:
+		// Expansion port input, bit 7 is the battery which is always good
+		return 0b1000_0000 | m.external&0b0111_1111
+	}
+}
+
+func (m *Mapper020) CPUWrite(location uint16, data uint8) bool {
+	switch {
+	case 0x4020 <= location && location <= 0x4026:
+		if location == 0x4023 || m.diskEnabled {
+			m.writeDisk(location, data)
+		}
+		return true
+	case 0x4040 <= location && location <= 0x408A:
+		if m.soundEnabled {
+			m.audio.write(location, data)
+		}
+		return true
+	case 0x6000 <= location && location <= 0xDFFF:
+		m.prgRam[location-0x6000] = data
+		return true
+	}
+	return false
+}
+
+func (m *Mapper020) writeDisk(location uint16, data uint8) {
+	switch location {
+	case 0x4020:
+		m.irqReload = m.irqReload&0xFF00 | uint16(data)
+	case 0x4021:
+		m.irqReload = m.irqReload&0x00FF | uint16(data)<<8
+	case 0x4022:
+		// 7  bit  0
+		// ---- ----
+		// xxxx xxER
+		//        ||
+		//        |+- Repeat the timer IRQ
+		//        +-- Enable the timer IRQ, reloads the counter
+		m.irqRepeat = data&0b1 != 0
+		m.irqEnabled = data&0b10 != 0
+		if m.irqEnabled {
+			m.irqCounter = m.irqReload
+		} else {
+			m.irqPending = false
+		}
+	case 0x4023:
+		// 7  bit  0
+		// ---- ----
+		// xxxx xxSD
+		//        ||
+		//        |+- Enable the disk registers
+		//        +-- Enable the sound registers
+		m.diskEnabled = data&0b1 != 0
+		m.soundEnabled = data&0b10 != 0
+		if !m.diskEnabled {
+			m.irqEnabled = false
+			m.irqPending = false
+			m.diskIRQ = false
+		}
+	case 0x4024:
+		m.writeData = data
+		m.transferred = false
+		m.diskIRQ = false
+	case 0x4025:
+		m.control = data
+		m.diskIRQ = false
+	case 0x4026:
+		m.external = data
+	}
+}
+
+func (m *Mapper020) CPUClock() {
+}
+
+func (m *Mapper020) CPUCycle() {
+	m.clockTimer()
+	m.clockDrive()
+	if m.irqPending || m.diskIRQ {
+		m.cartridge.Bus.IRQ()
+	}
+}
+
+// clockTimer clocks the timer IRQ counter for one CPU cycle
+func (m *Mapper020) clockTimer() {
+	if !m.irqEnabled {
+		return
+	}
+	if m.irqCounter != 0 {
+		m.irqCounter--
+		return
+	}
+	m.irqPending = true
+	m.irqCounter = m.irqReload
+	if !m.irqRepeat {
+		m.irqEnabled = false
+	}
+}
+
+// clockDrive moves the disk under the head for one CPU cycle. A byte is transferred every 150 cycles.
+func (m *Mapper020) clockDrive() {
+	if m.insertDelay > 0 {
+		m.insertDelay--
+		if m.insertDelay == 0 {
+			m.side = m.nextSide
+			m.inserted = true
+		}
+	}
+	if !m.inserted || m.control&0b1 == 0 {
+		// The motor is stopped, the head returns to the start of the disk
+		m.endOfHead = true
+		m.scanning = false
+		return
+	}
+	if m.control&0b10 != 0 && !m.scanning {
+		return
+	}
+	if m.endOfHead {
+		m.delay = diskSpinUp
+		m.endOfHead = false
+		m.position = 0
+		m.gapEnded = false
+		return
+	}
+	if m.delay > 0 {
+		m.delay--
+		return
+	}
+
+	m.scanning = true
+	track := m.tracks[m.side]
+	irq := m.control&0b1000_0000 != 0
+	ready := m.control&0b0100_0000 != 0
+	crcControl := m.control&0b1_0000 != 0
+	if m.control&0b100 != 0 {
+		// Read
+		data := track[m.position]
+		if !m.lastCRC {
+			m.updateCRC(data)
+		}
+		if !ready {
+			m.gapEnded = false
+			m.crc = 0
+		} else if data != 0 && !m.gapEnded {
+			// The start mark of the block is not transferred
+			m.gapEnded = true
+			irq = false
+		}
+		if m.gapEnded {
+			m.transferred = true
+			m.readData = data
+			if irq {
+				m.diskIRQ = true
+			}
+		}
+	} else {
+		// Write
+		var data uint8
+		if !crcControl {
+			m.transferred = true
+			data = m.writeData
+			if irq {
+				m.diskIRQ = true
+			}
+		}
+		if !ready {
+			data = 0
+		}
+		if !crcControl {
+			m.updateCRC(data)
+		} else {
+			if !m.lastCRC {
+				// Finish the CRC
+				m.updateCRC(0)
+				m.updateCRC(0)
+			}
+			data = uint8(m.crc)
+			m.crc >>= 8
+		}
+		// The byte is written two bytes behind the head
+		if m.position >= 2 {
+			track[m.position-2] = data
+		}
+		m.gapEnded = false
+	}
+	m.lastCRC = crcControl
+
+	m.position++
+	if m.position >= len(track) {
+		// End of the disk, the motor stops
+		m.control &^= 0b1
+	} else {
+		m.delay = diskByteTime
+	}
+}
+
+// updateCRC adds a byte to the CRC-16/KERMIT of the current block
+func (m *Mapper020) updateCRC(data uint8) {
+	for i := 0; i < 8; i++ {
+		carry := m.crc&0b1 != uint16(data>>i&0b1)
+		m.crc >>= 1
+		if carry {
+			m.crc ^= 0x8408
+		}
+	}
+}
+
+func (m *Mapper020) PPUMap(location uint16) uint16 {
+	if 0x2000 <= location && location <= 0x3EFF {
+		if m.control&0b1000 != 0 {
+			// Horizontal mirroring
+			location = 0x2000 + (location>>11&0b1)*0x400 + location%0x400
+		} else {
+			// Vertical mirroring
+			location = 0x2000 + (location>>10&0b1)*0x400 + location%0x400
+		}
+	}
+	return location
+}
+
+func (m *Mapper020) PPURead(location uint16) uint8 {
+	if location <= 0x1FFF {
+		return m.cartridge.ChrRom[location]
+	}
+	return 0
+}
+
+func (m *Mapper020) PPUWrite(location uint16, data uint8) bool {
+	if location <= 0x1FFF {
+		m.cartridge.ChrRom[location] = data
+		return true
+	}
+	return false
+}
+
+// ClockAudio clocks the sound channel
+func (m *Mapper020) ClockAudio() {
+	m.audio.clock()
+}
+
+func (m *Mapper020) AudioOutput() float64 {
+	return m.audio.output()
+}
+
+// Load applies a patch created by Save to the disk sides of the image
+func (m *Mapper020) Load(data []uint8) error {
+	patched, err := ips.Apply(bytes.Join(m.sides, nil), data)
+	if err != nil {
+		return err
+	}
+	if len(patched) != len(m.sides)*DiskSideSize {
+		return &SaveSizeError{Expected: len(m.sides) * DiskSideSize, Got: len(patched)}
+	}
+	for i := range m.tracks {
+		m.tracks[i] = diskTrack(patched[i*DiskSideSize : (i+1)*DiskSideSize])
+	}
+	return nil
+}
+
+// Patch returns the changes to the disk as IPS patch against the sides of the loaded disk image
+func (m *Mapper020) Patch() ([]uint8, error) {
+	sides := make([][]uint8, len(m.tracks))
+	for i, track := range m.tracks {
+		sides[i] = diskSide(track)
+	}
+	return ips.Diff(bytes.Join(m.sides, nil), bytes.Join(sides, nil))
+}
+
+// Save returns the changes to the disk sides of the image as IPS patch. The disk image itself is never modified.
+func (m *Mapper020) Save() []uint8 {
+	patch, err := m.Patch()
+	if err != nil {
+		log.Println("could not create the disk patch: ", err.Error())
+		return nil
+	}
+	return patch
+}
+
+func (m *Mapper020) Serialize(s *savestate.Serializer) {
+	s.Bytes(m.prgRam)
+	for _, track := range m.tracks {
+		s.Bytes(track)
+	}
+	s.Int(&m.side)
+	s.Bool(&m.inserted)
+	s.Int(&m.nextSide)
+	s.Int(&m.insertDelay)
+	s.Uint16(&m.irqReload)
+	s.Uint16(&m.irqCounter)
+	s.Bool(&m.irqRepeat)
+	s.Bool(&m.irqEnabled)
+	s.Bool(&m.irqPending)
+	s.Bool(&m.diskEnabled)
+	s.Bool(&m.soundEnabled)
+	s.Uint8(&m.control)
+	s.Uint8(&m.external)
+	s.Int(&m.position)
+	s.Int(&m.delay)
+	s.Bool(&m.endOfHead)
+	s.Bool(&m.scanning)
+	s.Bool(&m.gapEnded)
+	s.Uint8(&m.readData)
+	s.Uint8(&m.writeData)
+	s.Bool(&m.transferred)
+	s.Bool(&m.diskIRQ)
+	s.Uint16(&m.crc)
+	s.Bool(&m.lastCRC)
+	m.audio.serialize(s)
+}
+
+// Reset resets the RAM adapter. The disk stays in the drive.
+func (m *Mapper020) Reset() {
+	m.irqReload = 0
+	m.irqCounter = 0
+	m.irqRepeat = false
+	m.irqEnabled = false
+	m.irqPending = false
+	m.diskEnabled = false
+	m.soundEnabled = false
+	m.control = 0
+	m.external = 0
+	m.endOfHead = true
+	m.scanning = false
+	m.gapEnded = false
+	m.transferred = false
+	m.diskIRQ = false
+	m.audio.reset()
+}
+
+func (m *Mapper020) DebugDisplay(text io.Writer) {
+	plz.Just(fmt.Fprintf(text, "Cartridge with Mapper %03d (Famicom Disk System)\n", m.cartridge.Header.Mapper))
+	if m.inserted {
+		plz.Just(fmt.Fprintf(text, "Disk        : Side %d of %d, Position %d\n", m.side+1, len(m.sides), m.position))
+	} else {
+		plz.Just(fmt.Fprintf(text, "Disk        : Ejected\n"))
+	}
+	plz.Just(fmt.Fprintf(text, "Control     : %08b\n", m.control))
+	plz.Just(fmt.Fprintf(text, "IRQ         : Counter %5d, Reload %5d, Enabled %t, Repeat %t\n",
+		m.irqCounter, m.irqReload, m.irqEnabled, m.irqRepeat))
+	plz.Just(fmt.Fprintf(text, "Wave        : Frequency %4d, Gain %2d, Position %2d\n",
+		m.audio.volume.frequency, m.audio.volume.gain, m.audio.wavePosition))
+	plz.Just(fmt.Fprintf(text, "Modulation  : Frequency %4d, Gain %2d, Counter %3d\n",
+		m.audio.mod.frequency, m.audio.mod.gain, m.audio.modCounter))
+}
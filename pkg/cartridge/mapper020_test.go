@@ -0,0 +1,269 @@
+package cartridge_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/exp625/gones/internal/ips"
+	"github.com/exp625/gones/pkg/cartridge"
+	"github.com/exp625/gones/pkg/nes"
+)
+
+// testDiskFile is the data of the only file on every side of the test disk
+var testDiskFile = []uint8{0xDE, 0xAD, 0xBE, 0xEF}
+
+// testDisk returns an FDS file with a fwNES header and the sides. Every side has one file.
+func testDisk(sides int) []byte {
+	image := append([]byte("FDS\x1a"), uint8(sides))
+	image = append(image, make([]byte, cartridge.HeaderSize-len(image))...)
+	for i := 0; i < sides; i++ {
+		side := make([]byte, 0, cartridge.DiskSideSize)
+		// Disk info block with the side number
+		info := make([]byte, 56)
+		copy(info, "\x01*NINTENDO-HVC*")
+		info[0x16] = uint8(i)
+		side = append(side, info...)
+		// File amount block
+		side = append(side, 0x02, 1)
+		// File header block: number, ID, name, address, size and type
+		side = append(side, 0x03, 0, 0)
+		side = append(side, "TESTFILE"...)
+		side = append(side, 0x00, 0x60, uint8(len(testDiskFile)), 0, 0)
+		// File data block
+		side = append(side, 0x04)
+		side = append(side, testDiskFile...)
+		image = append(image, side...)
+		image = append(image, make([]byte, cartridge.DiskSideSize-len(side))...)
+	}
+	return image
+}
+
+// newTestDisk loads the RAM adapter with the test disk and a BIOS filled with $EA. The disk registers are enabled.
+func newTestDisk(t *testing.T, sides int) (*cartridge.Cartridge, *testBus) {
+	t.Helper()
+	b := &testBus{NES: nes.New(1.0/5369318.0, 1.0/44100)}
+	c, err := cartridge.LoadDisk(testDisk(sides), bytes.Repeat([]byte{0xEA}, cartridge.BIOSSize), b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Reset()
+	c.CPUWrite(0x4023, 0b11)
+	return c, b
+}
+
+// startDisk starts the motor and waits until the head reaches the start of the disk
+func startDisk(t *testing.T, c *cartridge.Cartridge, b *testBus, control uint8) {
+	t.Helper()
+	c.CPUWrite(0x4025, control|0b0010_0001)
+	for i := 0; c.CPURead(0x4032)&0b10 != 0; i++ {
+		if i > 100000 {
+			t.Fatal("disk did not become ready")
+		}
+		clockCPU(c, b, 1)
+	}
+}
+
+// transfer waits for the next disk IRQ
+func transfer(t *testing.T, c *cartridge.Cartridge, b *testBus) {
+	t.Helper()
+	if clockCPU(c, b, 1000000) == -1 {
+		t.Fatal("expected a disk IRQ")
+	}
+}
+
+func TestLoadDisk(t *testing.T) {
+	image := testDisk(2)
+	bios := make([]byte, cartridge.BIOSSize)
+	if !cartridge.IsDisk(image) || !cartridge.IsDisk(image[cartridge.HeaderSize:]) {
+		t.Error("expected the image to be detected with and without header")
+	}
+	if cartridge.IsDisk(testROM(make([]byte, cartridge.HeaderSize), 2, 8)) {
+		t.Error("expected an iNES file not to be detected as disk image")
+	}
+
+	for _, image := range [][]byte{image, image[cartridge.HeaderSize:]} {
+		c, err := cartridge.LoadDisk(image, bios, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		drive, ok := c.Mapper.(cartridge.DiskDrive)
+		if !ok {
+			t.Fatal("expected a disk drive")
+		}
+		if side, inserted := drive.Side(); drive.Sides() != 2 || side != 0 || !inserted {
+			t.Errorf("expected side 0 of 2 inserted, got side %d of %d", side, drive.Sides())
+		}
+	}
+
+	if _, err := cartridge.LoadDisk(image, bios[:0x1000], nil); !errors.Is(err, cartridge.ErrInvalidBIOS) {
+		t.Errorf("expected ErrInvalidBIOS, got %v", err)
+	}
+	if _, err := cartridge.LoadDisk(image[:len(image)-1], bios, nil); !errors.Is(err, cartridge.ErrInvalidDisk) {
+		t.Errorf("expected ErrInvalidDisk, got %v", err)
+	}
+}
+
+func TestMapper020Memory(t *testing.T) {
+	c, _ := newTestDisk(t, 1)
+	if data := c.CPURead(0xFFFC); data != 0xEA {
+		t.Errorf("expected the BIOS at $E000, got $%02X", data)
+	}
+	c.CPUWrite(0x6000, 0x12)
+	c.CPUWrite(0xDFFF, 0x34)
+	if c.CPURead(0x6000) != 0x12 || c.CPURead(0xDFFF) != 0x34 {
+		t.Error("expected 32 KB PRG RAM at $6000-$DFFF")
+	}
+	c.PPUWrite(0x1FFF, 0x56)
+	if data := c.PPURead(0x1FFF); data != 0x56 {
+		t.Errorf("expected CHR RAM, got $%02X", data)
+	}
+
+	if location := c.PPUMap(0x2400); location != 0x2400 {
+		t.Errorf("expected vertical mirroring, got $%04X", location)
+	}
+	c.CPUWrite(0x4025, 0b0010_1000)
+	if location := c.PPUMap(0x2400); location != 0x2000 {
+		t.Errorf("expected horizontal mirroring, got $%04X", location)
+	}
+}
+
+func TestMapper020TimerIRQ(t *testing.T) {
+	c, b := newTestDisk(t, 1)
+	c.CPUWrite(0x4020, 10)
+	c.CPUWrite(0x4021, 0)
+	c.CPUWrite(0x4022, 0b10)
+	if cycle := clockCPU(c, b, 100); cycle != 11 {
+		t.Errorf("expected the IRQ after 11 cycles, got %d", cycle)
+	}
+	if data := c.Mapper.(cartridge.Peeker).Peek(0x4030); data&0b1 == 0 {
+		t.Errorf("expected to peek the timer IRQ flag, got %08b", data)
+	}
+	if data := c.CPURead(0x4030); data&0b1 == 0 {
+		t.Errorf("expected the timer IRQ flag, got %08b", data)
+	}
+	if cycle := clockCPU(c, b, 100); cycle != -1 {
+		t.Errorf("expected no IRQ without repeat, got one after %d cycles", cycle)
+	}
+
+	c.CPUWrite(0x4022, 0b11)
+	clockCPU(c, b, 11)
+	c.CPURead(0x4030)
+	if cycle := clockCPU(c, b, 100); cycle != 11 {
+		t.Errorf("expected the repeated IRQ after 11 cycles, got %d", cycle)
+	}
+
+	// Disabling the disk registers stops the timer
+	c.CPUWrite(0x4023, 0)
+	if cycle := clockCPU(c, b, 100); cycle != -1 {
+		t.Errorf("expected no IRQ with disabled disk registers, got one after %d cycles", cycle)
+	}
+}
+
+func TestMapper020Read(t *testing.T) {
+	c, b := newTestDisk(t, 1)
+	startDisk(t, c, b, 0b0000_0100)
+	c.CPUWrite(0x4025, 0b1110_0101)
+	// The start mark of the block is skipped
+	for _, expected := range []uint8("\x01*NINTENDO-HVC*") {
+		transfer(t, c, b)
+		if data := c.CPURead(0x4031); data != expected {
+			t.Fatalf("expected $%02X, got $%02X", expected, data)
+		}
+	}
+}
+
+func TestMapper020Write(t *testing.T) {
+	c, b := newTestDisk(t, 1)
+	startDisk(t, c, b, 0b0000_0100)
+	c.CPUWrite(0x4025, 0b1110_0101)
+	// Read up to the block code of the file data block
+	for previous := uint8(0); ; {
+		transfer(t, c, b)
+		data := c.CPURead(0x4031)
+		if data == 0x04 && previous == 0x80 {
+			break
+		}
+		previous = data
+	}
+
+	// Rewrite the block, the bytes are written two bytes behind the head
+	c.CPUWrite(0x4024, 0x80)
+	c.CPUWrite(0x4025, 0b1110_0001)
+	for _, data := range []uint8{0x04, 0x11, 0x22, 0x33, 0x44} {
+		transfer(t, c, b)
+		c.CPUWrite(0x4024, data)
+	}
+	transfer(t, c, b)
+	// CRC
+	c.CPUWrite(0x4025, 0b1111_0001)
+	clockCPU(c, b, 1000)
+	c.CPUWrite(0x4025, 0)
+
+	patch, err := c.Mapper.(cartridge.DiskDrive).Patch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(c.Save(), patch) {
+		t.Error("expected Save to return the patch")
+	}
+	original := testDisk(1)[cartridge.HeaderSize:]
+	patched, err := ips.Apply(original, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(patched[56+2+16+1:][:4], []uint8{0x11, 0x22, 0x33, 0x44}) {
+		t.Errorf("expected the rewritten file data, got % X", patched[56+2+16+1:][:4])
+	}
+	if bytes.Equal(patched, original) {
+		t.Fatal("expected changes")
+	}
+	if !bytes.Equal(patched[:56+2+16+1], original[:56+2+16+1]) || !bytes.Equal(patched[56+2+16+5:], original[56+2+16+5:]) {
+		t.Error("expected no other changes")
+	}
+
+	// The patch is applied to the disk image
+	c, _ = newTestDisk(t, 1)
+	if err := c.Load(patch); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(c.Save(), patch) {
+		t.Error("expected the loaded patch to be saved again")
+	}
+}
+
+func TestMapper020Eject(t *testing.T) {
+	c, b := newTestDisk(t, 2)
+	drive := c.Mapper.(cartridge.DiskDrive)
+	if data := c.CPURead(0x4032); data&0b101 != 0 {
+		t.Errorf("expected an inserted writable disk, got %08b", data)
+	}
+	drive.Eject()
+	if data := c.CPURead(0x4032); data&0b111 != 0b111 {
+		t.Errorf("expected no disk, got %08b", data)
+	}
+	drive.Insert(0)
+	if side, inserted := drive.Side(); side != 0 || !inserted {
+		t.Errorf("expected side 0 to be inserted immediately, got %d", side)
+	}
+
+	// Switching the side ejects the disk for a while
+	drive.Insert(1)
+	if _, inserted := drive.Side(); inserted {
+		t.Error("expected the disk to be ejected")
+	}
+	clockCPU(c, b, 1789773)
+	if side, inserted := drive.Side(); side != 1 || !inserted {
+		t.Errorf("expected side 1 to be inserted after the delay, got side %d", side)
+	}
+
+	// The disk info block of side B is read
+	startDisk(t, c, b, 0b0000_0100)
+	c.CPUWrite(0x4025, 0b1110_0101)
+	for i := 0; i <= 0x16; i++ {
+		transfer(t, c, b)
+		if data := c.CPURead(0x4031); i == 0x16 && data != 1 {
+			t.Errorf("expected side number 1, got %d", data)
+		}
+	}
+}
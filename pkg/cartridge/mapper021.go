@@ -0,0 +1,333 @@
+package cartridge
+
+import (
+	"fmt"
+	"github.com/exp625/gones/internal/plz"
+	"github.com/exp625/gones/internal/savestate"
+	"io"
+)
+
+// From NES DEV WIKI https://wiki.nesdev.org/w/index.php?title=VRC2_and_VRC4
+
+// The Konami VRC2 and VRC4 are used by the mappers 21, 22, 23 and 25. The mappers differ in the CPU address lines
+// that are connected to the register select pins of the chip. The NES 2.0 submapper selects the board, otherwise the
+// address lines of all boards of the mapper are combined. Games only use the addresses of their own board.
+//
+// Mapper  Submapper  Board  A0  A1
+// 21      1          VRC4a  A1  A2
+// 21      2          VRC4c  A6  A7
+// 22      0          VRC2a  A1  A0
+// 23      1          VRC4f  A0  A1
+// 23      2          VRC4e  A2  A3
+// 23      3          VRC2b  A0  A1
+// 25      1          VRC4b  A1  A0
+// 25      2          VRC4d  A3  A2
+// 25      3          VRC2c  A1  A0
+
+// vrcBoard describes the wiring of a VRC2 or VRC4 board
+type vrcBoard struct {
+	name string
+	// CPU address lines connected to the register select pins A0 and A1
+	a0, a1 uint16
+	vrc2   bool
+	// The VRC2a ignores the lowest bit of the CHR bank numbers
+	chrShift uint8
+}
+
+var (
+	vrc4a = vrcBoard{name: "VRC4a", a0: 1 << 1, a1: 1 << 2}
+	vrc4c = vrcBoard{name: "VRC4c", a0: 1 << 6, a1: 1 << 7}
+	vrc2a = vrcBoard{name: "VRC2a", a0: 1 << 1, a1: 1 << 0, vrc2: true, chrShift: 1}
+	vrc4f = vrcBoard{name: "VRC4f", a0: 1 << 0, a1: 1 << 1}
+	vrc4e = vrcBoard{name: "VRC4e", a0: 1 << 2, a1: 1 << 3}
+	vrc2b = vrcBoard{name: "VRC2b", a0: 1 << 0, a1: 1 << 1, vrc2: true}
+	vrc4b = vrcBoard{name: "VRC4b", a0: 1 << 1, a1: 1 << 0}
+	vrc4d = vrcBoard{name: "VRC4d", a0: 1 << 3, a1: 1 << 2}
+	vrc2c = vrcBoard{name: "VRC2c", a0: 1 << 1, a1: 1 << 0, vrc2: true}
+)
+
+// combineBoards returns a VRC4 board that responds to the address lines of both boards
+func combineBoards(a vrcBoard, b vrcBoard) vrcBoard {
+	return vrcBoard{name: a.name + "/" + b.name, a0: a.a0 | b.a0, a1: a.a1 | b.a1}
+}
+
+// findVrcBoard returns the board of the mapper and submapper
+func findVrcBoard(mapper uint16, submapper uint8) vrcBoard {
+	switch mapper {
+	case 21:
+		switch submapper {
+		case 1:
+			return vrc4a
+		case 2:
+			return vrc4c
+		}
+		return combineBoards(vrc4a, vrc4c)
+	case 22:
+		return vrc2a
+	case 23:
+		switch submapper {
+		case 1:
+			return vrc4f
+		case 2:
+			return vrc4e
+		case 3:
+			return vrc2b
+		}
+		// The VRC2b is wired like the VRC4f and the VRC4 is a superset of the VRC2
+		return combineBoards(vrc4f, vrc4e)
+	default:
+		switch submapper {
+		case 1:
+			return vrc4b
+		case 2:
+			return vrc4d
+		case 3:
+			return vrc2c
+		}
+		return combineBoards(vrc4b, vrc4d)
+	}
+}
+
+type Mapper021 struct {
+	cartridge *Cartridge
+	board     vrcBoard
+
+	// CPU $6000-$7FFF: 8 KB PRG RAM bank (optional)
+	prgRam []uint8
+	// Without PRG RAM, the VRC2 has a one bit latch at $6000-$6FFF
+	latch uint8
+
+	prgBanks [2]uint8
+	// Swaps the PRG ROM banks at $8000 and $C000 (VRC4 only)
+	prgSwap    bool
+	chrBanks   [8]uint16
+	mirrorMode uint8
+	irq        vrcIRQ
+}
+
+// NewMapper021 creates the VRC2 or VRC4 for the mappers 21, 22, 23 and 25
+func NewMapper021(c *Cartridge) *Mapper021 {
+	return &Mapper021{
+		cartridge: c,
+		board:     findVrcBoard(c.Header.Mapper, c.Header.Submapper),
+		prgRam:    make([]uint8, c.Header.PrgRamTotal()),
+	}
+}
+
+func (m *Mapper021) CPUMap(location uint16) uint16 {
+	return location
+}
+
+// register returns the register of the CPU location as $x000-$x003
+func (m *Mapper021) register(location uint16) uint16 {
+	register := location & 0xF000
+	if location&m.board.a0 != 0 {
+		register |= 0b01
+	}
+	if location&m.board.a1 != 0 {
+		register |= 0b10
+	}
+	return register
+}
+
+// CPU $6000-$7FFF: 8 KB PRG RAM bank or the latch of the VRC2
+// CPU $8000-$9FFF: 8 KB switchable PRG ROM bank, or fixed to the second-last bank if swapped
+// CPU $A000-$BFFF: 8 KB switchable PRG ROM bank
+// CPU $C000-$DFFF: 8 KB PRG ROM bank, fixed to the second-last bank, or switchable if swapped
+// CPU $E000-$FFFF: 8 KB PRG ROM bank, fixed to the last bank
+
+func (m *Mapper021) CPURead(location uint16) uint8 {
+	banks := len(m.cartridge.PrgRom) / 0x2000
+	switch {
+	case 0x6000 <= location && location <= 0x6FFF && m.board.vrc2 && len(m.prgRam) == 0:
+		return uint8(location>>8)&0b1111_1110 | m.latch
+	case 0x6000 <= location && location <= 0x7FFF:
+		return readRam(m.prgRam, int(location-0x6000), location)
+	case 0x8000 <= location && location <= 0x9FFF && m.prgSwap, 0xC000 <= location && location <= 0xDFFF && !m.prgSwap:
+		return m.cartridge.PrgRom[(banks-2)*0x2000+int(location&0x1FFF)]
+	case 0x8000 <= location && location <= 0x9FFF, 0xC000 <= location && location <= 0xDFFF:
+		return m.cartridge.PrgRom[(int(m.prgBanks[0])%banks)*0x2000+int(location&0x1FFF)]
+	case 0xA000 <= location && location <= 0xBFFF:
+		return m.cartridge.PrgRom[(int(m.prgBanks[1])%banks)*0x2000+int(location&0x1FFF)]
+	case 0xE000 <= location:
+		return m.cartridge.PrgRom[(banks-1)*0x2000+int(location&0x1FFF)]
+	}
+	// Mapper was not responsible for the location
+	return 0
+}
+
+func (m *Mapper021) CPUWrite(location uint16, data uint8) bool {
+	if 0x6000 <= location && location <= 0x7FFF {
+		if m.board.vrc2 && len(m.prgRam) == 0 {
+			m.latch = data & 0b1
+		} else {
+			writeRam(m.prgRam, int(location-0x6000), data)
+		}
+		return true
+	}
+	if location < 0x8000 {
+		return false
+	}
+
+	register := m.register(location)
+	switch {
+	case 0x8000 <= register && register <= 0x8003:
+		// PRG select 0 ($8000-$8003)
+		// 7  bit  0
+		// ---------
+		// ...P PPPP
+		//    | ||||
+		//    +-++++- Select 8 KB PRG bank at $8000 or $C000
+		m.prgBanks[0] = data & 0b1_1111
+	case 0x9000 <= register && register <= 0x9003 && m.board.vrc2:
+		// Mirroring control ($9000-$9003), VRC2
+		// 7  bit  0
+		// ---------
+		// .... ...M
+		//         |
+		//         +- Mirroring (0: vertical; 1: horizontal)
+		m.mirrorMode = data & 0b1
+	case 0x9000 <= register && register <= 0x9001:
+		// Mirroring control ($9000), VRC4
+		// 7  bit  0
+		// ---------
+		// .... ..MM
+		//        ||
+		//        ++- Mirroring (0: vertical; 1: horizontal; 2: one-screen, lower bank; 3: one-screen, upper bank)
+		m.mirrorMode = data & 0b11
+	case 0x9002 <= register && register <= 0x9003:
+		// PRG swap mode ($9002), VRC4
+		// 7  bit  0
+		// ---------
+		// .... ..MW
+		//        ||
+		//        |+- WRAM control, ignored because most games do not enable the PRG RAM
+		//        +-- Swap mode (0: $8000 swappable, $C000 fixed; 1: $C000 swappable, $8000 fixed)
+		m.prgSwap = data&0b10 != 0
+	case 0xA000 <= register && register <= 0xA003:
+		// PRG select 1 ($A000-$A003)
+		m.prgBanks[1] = data & 0b1_1111
+	case 0xB000 <= register && register <= 0xEFFF:
+		// CHR select ($B000-$E003)
+		// $B000: low 4 bits of the 1 KB CHR bank at PPU $0000, $B001: high bits
+		// $B002: low 4 bits of the 1 KB CHR bank at PPU $0400, $B003: high bits
+		// ...
+		// $E002: low 4 bits of the 1 KB CHR bank at PPU $1C00, $E003: high bits
+		// The VRC4 has 5 high bits, the VRC2 only 4
+		bank := (register-0xB000)>>12*2 + register&0b10>>1
+		if register&0b1 == 0 {
+			m.chrBanks[bank] = m.chrBanks[bank]&0x1F0 | uint16(data&0x0F)
+		} else if m.board.vrc2 {
+			m.chrBanks[bank] = m.chrBanks[bank]&0x0F | uint16(data&0x0F)<<4
+		} else {
+			m.chrBanks[bank] = m.chrBanks[bank]&0x0F | uint16(data&0x1F)<<4
+		}
+	case register >= 0xF000 && m.board.vrc2:
+		// The VRC2 has no IRQ
+	case register == 0xF000:
+		// IRQ latch, low 4 bits
+		m.irq.latch = m.irq.latch&0xF0 | data&0x0F
+	case register == 0xF001:
+		// IRQ latch, high 4 bits
+		m.irq.latch = m.irq.latch&0x0F | data<<4
+	case register == 0xF002:
+		m.irq.writeControl(data)
+	case register == 0xF003:
+		m.irq.acknowledge()
+	}
+	return true
+}
+
+func (m *Mapper021) CPUClock() {
+}
+
+func (m *Mapper021) CPUCycle() {
+	m.irq.clock()
+	if m.irq.pending {
+		m.cartridge.Bus.IRQ()
+	}
+}
+
+func (m *Mapper021) PPUMap(location uint16) uint16 {
+	if 0x2000 <= location && location <= 0x3EFF {
+		var page uint16
+		switch m.mirrorMode {
+		case 0:
+			// Vertical mirroring
+			page = location >> 10 & 0b1
+		case 1:
+			// Horizontal mirroring
+			page = location >> 11 & 0b1
+		case 2:
+			page = 0
+		case 3:
+			page = 1
+		}
+		location = 0x2000 + page*0x400 + location%0x400
+	}
+	return location
+}
+
+// chrIndex returns the index into the CHR memory for the PPU location $0000-$1FFF
+func (m *Mapper021) chrIndex(location uint16) int {
+	bank := int(m.chrBanks[location/0x400] >> m.board.chrShift)
+	return (bank*0x400 + int(location%0x400)) % len(m.cartridge.ChrRom)
+}
+
+func (m *Mapper021) PPURead(location uint16) uint8 {
+	if location <= 0x1FFF {
+		return m.cartridge.ChrRom[m.chrIndex(location)]
+	}
+	return 0
+}
+
+func (m *Mapper021) PPUWrite(location uint16, data uint8) bool {
+	if location <= 0x1FFF {
+		if m.cartridge.ChrRam {
+			m.cartridge.ChrRom[m.chrIndex(location)] = data
+		}
+		return true
+	}
+	return false
+}
+
+func (m *Mapper021) Load(data []uint8) error {
+	return loadRam(m.prgRam, data)
+}
+
+func (m *Mapper021) Save() []uint8 {
+	return m.prgRam
+}
+
+func (m *Mapper021) Serialize(s *savestate.Serializer) {
+	s.Bytes(m.prgRam)
+	s.Uint8(&m.latch)
+	s.Bytes(m.prgBanks[:])
+	s.Bool(&m.prgSwap)
+	for i := range m.chrBanks {
+		s.Uint16(&m.chrBanks[i])
+	}
+	s.Uint8(&m.mirrorMode)
+	m.irq.serialize(s)
+}
+
+func (m *Mapper021) Reset() {
+	m.latch = 0
+	m.prgBanks = [2]uint8{}
+	m.prgSwap = false
+	m.chrBanks = [8]uint16{}
+	m.mirrorMode = 0
+	m.irq.reset()
+}
+
+func (m *Mapper021) DebugDisplay(text io.Writer) {
+	plz.Just(fmt.Fprintf(text, "Cartridge with Mapper %03d (%s)\n", m.cartridge.Header.Mapper, m.board.name))
+	plz.Just(fmt.Fprintf(text, "PRG ROM Size: %d * 16 KB\n", m.cartridge.PrgRomSize))
+	plz.Just(fmt.Fprintf(text, "PRG Banks   : %v, Swapped: %t\n", m.prgBanks, m.prgSwap))
+	plz.Just(fmt.Fprintf(text, "CHR ROM Size: %d * 8 KB\n", m.cartridge.ChrRomSize))
+	plz.Just(fmt.Fprintf(text, "CHR Banks   : %v\n", m.chrBanks))
+	plz.Just(fmt.Fprintf(text, "Mirror Mode : %d \n", m.mirrorMode))
+	if !m.board.vrc2 {
+		m.irq.debugDisplay(text)
+	}
+}
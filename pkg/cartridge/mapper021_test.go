@@ -0,0 +1,112 @@
+package cartridge_test
+
+import (
+	"testing"
+)
+
+func TestMapper021Wiring(t *testing.T) {
+	for _, test := range []struct {
+		mapper    uint16
+		submapper uint8
+		// CPU address lines of the register select pins A0 and A1
+		a0, a1 uint16
+	}{
+		{21, 1, 1 << 1, 1 << 2},
+		{21, 2, 1 << 6, 1 << 7},
+		{21, 0, 1 << 1, 1 << 2},
+		{21, 0, 1 << 6, 1 << 7},
+		{22, 0, 1 << 1, 1 << 0},
+		{23, 1, 1 << 0, 1 << 1},
+		{23, 2, 1 << 2, 1 << 3},
+		{23, 3, 1 << 0, 1 << 1},
+		{23, 0, 1 << 0, 1 << 1},
+		{23, 0, 1 << 2, 1 << 3},
+		{25, 1, 1 << 1, 1 << 0},
+		{25, 2, 1 << 3, 1 << 2},
+		{25, 3, 1 << 1, 1 << 0},
+		{25, 0, 1 << 1, 1 << 0},
+		{25, 0, 1 << 3, 1 << 2},
+	} {
+		c, _ := newTestCartridge(t, test.mapper, test.submapper, 32, 512)
+		c.CPUWrite(0xA000, 5)
+		if data := c.CPURead(0xA000); data != 5 {
+			t.Errorf("mapper %d.%d: expected PRG bank 5, got %d", test.mapper, test.submapper, data)
+		}
+		// CHR bank 1 is selected by $C000 register 2 and 3
+		c.CPUWrite(0xC000|test.a1, 0x04)
+		c.CPUWrite(0xC000|test.a1|test.a0, 0x01)
+		expected := uint8(0x14)
+		if test.mapper == 22 {
+			// The VRC2a ignores the lowest bit
+			expected = 0x0A
+		}
+		if data := c.PPURead(0x0C00); data != expected {
+			t.Errorf("mapper %d.%d: expected CHR bank $%02X, got $%02X", test.mapper, test.submapper, expected, data)
+		}
+	}
+}
+
+func TestMapper021PRGSwap(t *testing.T) {
+	c, _ := newTestCartridge(t, 21, 1, 32, 8)
+	c.CPUWrite(0x8000, 3)
+	for _, test := range []struct {
+		swap     uint8
+		location uint16
+		bank     uint8
+	}{{0, 0x8000, 3}, {0, 0xC000, 30}, {2, 0x8000, 30}, {2, 0xC000, 3}, {2, 0xE000, 31}} {
+		c.CPUWrite(0x9004, test.swap)
+		if data := c.CPURead(test.location); data != test.bank {
+			t.Errorf("swap mode %d: expected bank %d at $%04X, got %d", test.swap, test.bank, test.location, data)
+		}
+	}
+}
+
+func TestMapper021Mirroring(t *testing.T) {
+	c, _ := newTestCartridge(t, 23, 1, 16, 8)
+	for _, test := range []struct {
+		mode  uint8
+		pages [4]uint16
+	}{
+		{0, [4]uint16{0x2000, 0x2400, 0x2000, 0x2400}},
+		{1, [4]uint16{0x2000, 0x2000, 0x2400, 0x2400}},
+		{2, [4]uint16{0x2000, 0x2000, 0x2000, 0x2000}},
+		{3, [4]uint16{0x2400, 0x2400, 0x2400, 0x2400}},
+	} {
+		c.CPUWrite(0x9000, test.mode)
+		for i, page := range test.pages {
+			if location := c.PPUMap(0x2000 + uint16(i)*0x400 + 0x10); location != page+0x10 {
+				t.Errorf("mode %d: expected nametable %d at $%04X, got $%04X", test.mode, i, page, location)
+			}
+		}
+	}
+}
+
+func TestMapper021IRQ(t *testing.T) {
+	c, b := newTestCartridge(t, 25, 1, 16, 8)
+	// Cycle mode, the counter overflows after 10 CPU cycles
+	c.CPUWrite(0xF000, 0x06)
+	c.CPUWrite(0xF002, 0x0F)
+	c.CPUWrite(0xF001, 0b111)
+	if cycle := clockCPU(c, b, 100); cycle != 10 {
+		t.Errorf("cycle mode: expected the IRQ after 10 cycles, got %d", cycle)
+	}
+	// The IRQ stays asserted until it is acknowledged
+	if cycle := clockCPU(c, b, 1); cycle != 1 {
+		t.Error("expected the IRQ to stay asserted")
+	}
+	// The counter was reloaded with the latch and keeps counting, because the enable after acknowledgement flag is set
+	c.CPUWrite(0xF003, 0)
+	if cycle := clockCPU(c, b, 100); cycle != 9 {
+		t.Errorf("expected the second IRQ 10 cycles after the first, got %d", cycle)
+	}
+
+	// Scanline mode, the counter overflows after 10 scanlines of 113.667 CPU cycles
+	c.CPUWrite(0xF001, 0b010)
+	if cycle := clockCPU(c, b, 2000); cycle != 1137 {
+		t.Errorf("scanline mode: expected the IRQ after 1137 cycles, got %d", cycle)
+	}
+	c.CPUWrite(0xF003, 0)
+	if cycle := clockCPU(c, b, 2000); cycle != -1 {
+		t.Errorf("expected the IRQ to be disabled after the acknowledgement, got one after %d cycles", cycle)
+	}
+}
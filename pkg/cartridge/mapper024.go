@@ -0,0 +1,418 @@
+package cartridge
+
+import (
+	"fmt"
+	"github.com/exp625/gones/internal/plz"
+	"github.com/exp625/gones/internal/savestate"
+	"github.com/exp625/gones/pkg/apu"
+	"io"
+)
+
+// From NES DEV WIKI https://wiki.nesdev.org/w/index.php?title=VRC6
+
+// The Konami VRC6 is used by the mappers 24 (VRC6a) and 26 (VRC6b). The VRC6b swaps the address lines A0 and A1.
+// Used by Akumajou Densetsu, Madara and Esper Dream 2.
+
+type Mapper024 struct {
+	cartridge *Cartridge
+	// Swapped address lines A0 and A1 (mapper 26)
+	swapped bool
+
+	// CPU $6000-$7FFF: 8 KB PRG RAM bank, enabled by $B003
+	prgRam []uint8
+
+	prgBanks [2]uint8
+	chrBanks [8]uint8
+	// PPU banking mode, mirroring and PRG RAM enable ($B003)
+	bankingMode uint8
+	irq         vrcIRQ
+
+	pulses   [2]vrc6Pulse
+	sawtooth vrc6Sawtooth
+	// Frequency control ($9003)
+	frequencyControl uint8
+}
+
+// NewMapper024 creates the VRC6 for the mappers 24 and 26
+func NewMapper024(c *Cartridge) *Mapper024 {
+	return &Mapper024{
+		cartridge: c,
+		swapped:   c.Header.Mapper == 26,
+		prgRam:    make([]uint8, c.Header.PrgRamTotal()),
+	}
+}
+
+func (m *Mapper024) CPUMap(location uint16) uint16 {
+	return location
+}
+
+// register returns the register of the CPU location as $x000-$x003
+func (m *Mapper024) register(location uint16) uint16 {
+	if m.swapped {
+		return location&0xF000 | location&0b01<<1 | location&0b10>>1
+	}
+	return location & 0xF003
+}
+
+// CPU $6000-$7FFF: 8 KB PRG RAM bank
+// CPU $8000-$BFFF: 16 KB switchable PRG ROM bank
+// CPU $C000-$DFFF: 8 KB switchable PRG ROM bank
+// CPU $E000-$FFFF: 8 KB PRG ROM bank, fixed to the last bank
+
+func (m *Mapper024) CPURead(location uint16) uint8 {
+	switch {
+	case 0x6000 <= location && location <= 0x7FFF && m.bankingMode>>7 == 1:
+		return readRam(m.prgRam, int(location-0x6000), location)
+	case 0x8000 <= location && location <= 0xBFFF:
+		return m.cartridge.PrgRom[(int(m.prgBanks[0])*0x4000+int(location&0x3FFF))%len(m.cartridge.PrgRom)]
+	case 0xC000 <= location && location <= 0xDFFF:
+		return m.cartridge.PrgRom[(int(m.prgBanks[1])*0x2000+int(location&0x1FFF))%len(m.cartridge.PrgRom)]
+	case 0xE000 <= location:
+		return m.cartridge.PrgRom[len(m.cartridge.PrgRom)-0x2000+int(location&0x1FFF)]
+	}
+	// Mapper was not responsible for the location
+	return 0
+}
+
+func (m *Mapper024) CPUWrite(location uint16, data uint8) bool {
+	if 0x6000 <= location && location <= 0x7FFF {
+		if m.bankingMode>>7 == 1 {
+			writeRam(m.prgRam, int(location-0x6000), data)
+		}
+		return true
+	}
+	if location < 0x8000 {
+		return false
+	}
+
+	register := m.register(location)
+	switch {
+	case 0x8000 <= register && register <= 0x8003:
+		// 16 KB PRG bank at $8000
+		m.prgBanks[0] = data & 0x0F
+	case 0x9000 <= register && register <= 0x9002:
+		m.pulses[0].write(register&0b11, data)
+	case register == 0x9003:
+		// Frequency control
+		// 7  bit  0
+		// ---- ----
+		// .... .ABH
+		//       |||
+		//       ||+- Halt all oscillators
+		//       |+-- 16x frequency (period shifted right by 4 bits)
+		//       +--- 256x frequency (period shifted right by 8 bits), overrides the 16x frequency
+		m.frequencyControl = data & 0b111
+	case 0xA000 <= register && register <= 0xA002:
+		m.pulses[1].write(register&0b11, data)
+	case 0xB000 <= register && register <= 0xB002:
+		m.sawtooth.write(register&0b11, data)
+	case register == 0xB003:
+		// PPU banking mode and mirroring
+		// 7  bit  0
+		// ---- ----
+		// W.PN MMDD
+		// | || ||||
+		// | || ||++- PPU banking mode
+		// | || ++--- Mirroring (0: vertical; 1: horizontal; 2: one-screen, lower bank; 3: one-screen, upper bank)
+		// | |+------ Nametables from CHR ROM, not used by any game and not emulated
+		// | +------- CHR A10 is 1 in the 2 KB banks (0: CHR A10 from PPU A10)
+		// +--------- PRG RAM enable
+		m.bankingMode = data
+	case 0xC000 <= register && register <= 0xC003:
+		// 8 KB PRG bank at $C000
+		m.prgBanks[1] = data & 0x1F
+	case 0xD000 <= register && register <= 0xE003:
+		// 1 KB CHR registers R0-R3 ($D000-$D003) and R4-R7 ($E000-$E003)
+		m.chrBanks[(register-0xD000)>>12*4+register&0b11] = data
+	case register == 0xF000:
+		m.irq.latch = data
+	case register == 0xF001:
+		m.irq.writeControl(data)
+	case register == 0xF002:
+		m.irq.acknowledge()
+	}
+	return true
+}
+
+func (m *Mapper024) CPUClock() {
+}
+
+func (m *Mapper024) CPUCycle() {
+	m.irq.clock()
+	if m.irq.pending {
+		m.cartridge.Bus.IRQ()
+	}
+}
+
+func (m *Mapper024) PPUMap(location uint16) uint16 {
+	if 0x2000 <= location && location <= 0x3EFF {
+		var page uint16
+		switch m.bankingMode >> 2 & 0b11 {
+		case 0:
+			// Vertical mirroring
+			page = location >> 10 & 0b1
+		case 1:
+			// Horizontal mirroring
+			page = location >> 11 & 0b1
+		case 2:
+			page = 0
+		case 3:
+			page = 1
+		}
+		location = 0x2000 + page*0x400 + location%0x400
+	}
+	return location
+}
+
+// chrIndex returns the index into the CHR memory for the PPU location $0000-$1FFF
+func (m *Mapper024) chrIndex(location uint16) int {
+	// PPU banking mode  $0000  $0400  $0800  $0C00  $1000  $1400  $1800  $1C00
+	// 0                 R0     R1     R2     R3     R4     R5     R6     R7
+	// 1                 R0 (2 KB)     R1 (2 KB)     R2 (2 KB)     R3 (2 KB)
+	// 2, 3              R0     R1     R2     R3     R4 (2 KB)     R5 (2 KB)
+	slot := location / 0x400
+	var bank uint8
+	large := true
+	switch {
+	case m.bankingMode&0b11 == 0:
+		bank = m.chrBanks[slot]
+		large = false
+	case m.bankingMode&0b11 == 1:
+		bank = m.chrBanks[slot/2]
+	case slot < 4:
+		bank = m.chrBanks[slot]
+		large = false
+	default:
+		bank = m.chrBanks[slot/2+2]
+	}
+	if large {
+		// 2 KB banks take CHR A10 from the PPU or force it to 1
+		if m.bankingMode&0b0010_0000 != 0 {
+			bank |= 0b1
+		} else {
+			bank = bank&0b1111_1110 | uint8(slot&0b1)
+		}
+	}
+	return (int(bank)*0x400 + int(location%0x400)) % len(m.cartridge.ChrRom)
+}
+
+func (m *Mapper024) PPURead(location uint16) uint8 {
+	if location <= 0x1FFF {
+		return m.cartridge.ChrRom[m.chrIndex(location)]
+	}
+	return 0
+}
+
+func (m *Mapper024) PPUWrite(location uint16, data uint8) bool {
+	if location <= 0x1FFF {
+		if m.cartridge.ChrRam {
+			m.cartridge.ChrRom[m.chrIndex(location)] = data
+		}
+		return true
+	}
+	return false
+}
+
+// ClockAudio clocks the pulse and sawtooth channels
+func (m *Mapper024) ClockAudio() {
+	if m.frequencyControl&0b1 == 1 {
+		return
+	}
+	shift := uint8(0)
+	if m.frequencyControl&0b100 != 0 {
+		shift = 8
+	} else if m.frequencyControl&0b010 != 0 {
+		shift = 4
+	}
+	m.pulses[0].clock(shift)
+	m.pulses[1].clock(shift)
+	m.sawtooth.clock(shift)
+}
+
+// AudioOutput mixes the channels linearly. A pulse at full volume is as loud as an APU pulse.
+func (m *Mapper024) AudioOutput() float64 {
+	return apu.PulseStep * float64(m.pulses[0].output()+m.pulses[1].output()+m.sawtooth.output())
+}
+
+func (m *Mapper024) Load(data []uint8) error {
+	return loadRam(m.prgRam, data)
+}
+
+func (m *Mapper024) Save() []uint8 {
+	return m.prgRam
+}
+
+func (m *Mapper024) Serialize(s *savestate.Serializer) {
+	s.Bytes(m.prgRam)
+	s.Bytes(m.prgBanks[:])
+	s.Bytes(m.chrBanks[:])
+	s.Uint8(&m.bankingMode)
+	m.irq.serialize(s)
+	m.pulses[0].serialize(s)
+	m.pulses[1].serialize(s)
+	m.sawtooth.serialize(s)
+	s.Uint8(&m.frequencyControl)
+}
+
+func (m *Mapper024) Reset() {
+	m.prgBanks = [2]uint8{}
+	m.chrBanks = [8]uint8{}
+	m.bankingMode = 0
+	m.irq.reset()
+	m.pulses = [2]vrc6Pulse{}
+	m.sawtooth = vrc6Sawtooth{}
+	m.frequencyControl = 0
+}
+
+func (m *Mapper024) DebugDisplay(text io.Writer) {
+	plz.Just(fmt.Fprintf(text, "Cartridge with Mapper %03d\n", m.cartridge.Header.Mapper))
+	plz.Just(fmt.Fprintf(text, "PRG ROM Size: %d * 16 KB\n", m.cartridge.PrgRomSize))
+	plz.Just(fmt.Fprintf(text, "PRG Banks   : %v\n", m.prgBanks))
+	plz.Just(fmt.Fprintf(text, "CHR ROM Size: %d * 8 KB\n", m.cartridge.ChrRomSize))
+	plz.Just(fmt.Fprintf(text, "CHR Banks   : %v\n", m.chrBanks))
+	plz.Just(fmt.Fprintf(text, "Banking Mode: %08b\n", m.bankingMode))
+	m.irq.debugDisplay(text)
+}
+
+// vrc6Pulse is a pulse channel of the VRC6. Unlike the pulses of the APU, it has 16 steps, eight duty cycles and
+// no envelope, sweep or length counter.
+type vrc6Pulse struct {
+	// Duty cycle, the output is high for duty+1 of 16 steps
+	duty   uint8
+	volume uint8
+	// Ignore the duty cycle and output the volume constantly
+	constant bool
+	enabled  bool
+	period   uint16
+	timer    uint16
+	step     uint8
+}
+
+func (p *vrc6Pulse) write(register uint16, data uint8) {
+	switch register {
+	case 0:
+		// 7  bit  0
+		// ---- ----
+		// MDDD VVVV
+		// |||| ||||
+		// |||| ++++- Volume
+		// |+++------ Duty cycle
+		// +--------- Mode (1: ignore the duty cycle)
+		p.constant = data>>7 == 1
+		p.duty = data >> 4 & 0b111
+		p.volume = data & 0x0F
+	case 1:
+		// Period low
+		p.period = p.period&0x0F00 | uint16(data)
+	case 2:
+		// 7  bit  0
+		// ---- ----
+		// E... PPPP
+		// |    ||||
+		// |    ++++- Period high
+		// +--------- Enable, the duty cycle is reset if the channel is disabled
+		p.period = p.period&0x00FF | uint16(data&0x0F)<<8
+		p.enabled = data>>7 == 1
+		if !p.enabled {
+			p.step = 15
+		}
+	}
+}
+
+func (p *vrc6Pulse) clock(shift uint8) {
+	if !p.enabled {
+		return
+	}
+	if p.timer == 0 {
+		p.timer = p.period >> shift
+		p.step = (p.step - 1) & 0x0F
+	} else {
+		p.timer--
+	}
+}
+
+func (p *vrc6Pulse) output() uint8 {
+	if p.enabled && (p.constant || p.step <= p.duty) {
+		return p.volume
+	}
+	return 0
+}
+
+func (p *vrc6Pulse) serialize(s *savestate.Serializer) {
+	s.Uint8(&p.duty)
+	s.Uint8(&p.volume)
+	s.Bool(&p.constant)
+	s.Bool(&p.enabled)
+	s.Uint16(&p.period)
+	s.Uint16(&p.timer)
+	s.Uint8(&p.step)
+}
+
+// vrc6Sawtooth is the sawtooth channel of the VRC6. An accumulator adds the rate on every second of 14 steps and is
+// reset after the last step, its upper 5 bits are the output.
+type vrc6Sawtooth struct {
+	rate        uint8
+	enabled     bool
+	period      uint16
+	timer       uint16
+	step        uint8
+	accumulator uint8
+}
+
+func (w *vrc6Sawtooth) write(register uint16, data uint8) {
+	switch register {
+	case 0:
+		// 7  bit  0
+		// ---- ----
+		// ..AA AAAA
+		//   ++-++++- Accumulator rate
+		w.rate = data & 0b11_1111
+	case 1:
+		// Period low
+		w.period = w.period&0x0F00 | uint16(data)
+	case 2:
+		// 7  bit  0
+		// ---- ----
+		// E... PPPP
+		// |    ||||
+		// |    ++++- Period high
+		// +--------- Enable, the accumulator is reset if the channel is disabled
+		w.period = w.period&0x00FF | uint16(data&0x0F)<<8
+		w.enabled = data>>7 == 1
+		if !w.enabled {
+			w.step = 0
+			w.accumulator = 0
+		}
+	}
+}
+
+func (w *vrc6Sawtooth) clock(shift uint8) {
+	if !w.enabled {
+		return
+	}
+	if w.timer > 0 {
+		w.timer--
+		return
+	}
+	w.timer = w.period >> shift
+	w.step++
+	if w.step == 14 {
+		w.step = 0
+		w.accumulator = 0
+	} else if w.step%2 == 0 {
+		w.accumulator += w.rate
+	}
+}
+
+func (w *vrc6Sawtooth) output() uint8 {
+	return w.accumulator >> 3
+}
+
+func (w *vrc6Sawtooth) serialize(s *savestate.Serializer) {
+	s.Uint8(&w.rate)
+	s.Bool(&w.enabled)
+	s.Uint16(&w.period)
+	s.Uint16(&w.timer)
+	s.Uint8(&w.step)
+	s.Uint8(&w.accumulator)
+}
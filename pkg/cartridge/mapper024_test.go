@@ -0,0 +1,137 @@
+package cartridge_test
+
+import (
+	"testing"
+
+	"github.com/exp625/gones/pkg/apu"
+)
+
+func TestMapper024Banks(t *testing.T) {
+	for _, mapper := range []uint16{24, 26} {
+		c, _ := newTestCartridge(t, mapper, 0, 32, 256)
+		// Registers 1 and 2 are swapped on the VRC6b
+		register1, register2 := uint16(1), uint16(2)
+		if mapper == 26 {
+			register1, register2 = 2, 1
+		}
+
+		c.CPUWrite(0x8000, 3)
+		c.CPUWrite(0xC000+register2, 9)
+		for _, test := range []struct {
+			location uint16
+			bank     uint8
+		}{{0x8000, 6}, {0xA000, 7}, {0xC000, 9}, {0xE000, 31}} {
+			if data := c.CPURead(test.location); data != test.bank {
+				t.Errorf("mapper %d: expected PRG bank %d at $%04X, got %d", mapper, test.bank, test.location, data)
+			}
+		}
+
+		for i := uint16(0); i < 8; i++ {
+			register := i & 0b11
+			switch register {
+			case 1:
+				register = register1
+			case 2:
+				register = register2
+			}
+			c.CPUWrite(0xD000+i/4*0x1000+register, uint8(0x10+i))
+		}
+		for _, test := range []struct {
+			mode  uint8
+			banks [8]uint8
+		}{
+			{0x00, [8]uint8{0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17}},
+			{0x01, [8]uint8{0x10, 0x11, 0x10, 0x11, 0x12, 0x13, 0x12, 0x13}},
+			{0x21, [8]uint8{0x11, 0x11, 0x11, 0x11, 0x13, 0x13, 0x13, 0x13}},
+			{0x02, [8]uint8{0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x14, 0x15}},
+		} {
+			c.CPUWrite(0xB003, test.mode)
+			for slot, bank := range test.banks {
+				if data := c.PPURead(uint16(slot) * 0x400); data != bank {
+					t.Errorf("mapper %d, mode $%02X: expected CHR bank $%02X at slot %d, got $%02X", mapper, test.mode, bank, slot, data)
+				}
+			}
+		}
+	}
+}
+
+func TestMapper024PRGRAM(t *testing.T) {
+	c, _ := newTestCartridge(t, 24, 0, 16, 8)
+	c.CPUWrite(0x6000, 0x42)
+	if data := c.CPURead(0x6000); data == 0x42 {
+		t.Error("expected PRG RAM to be disabled")
+	}
+	c.CPUWrite(0xB003, 0x80)
+	c.CPUWrite(0x6000, 0x42)
+	if data := c.CPURead(0x6000); data != 0x42 {
+		t.Errorf("expected PRG RAM, got $%02X", data)
+	}
+}
+
+func TestMapper024IRQ(t *testing.T) {
+	c, b := newTestCartridge(t, 26, 0, 16, 8)
+	c.CPUWrite(0xF000, 0xFC)
+	// Register 1 of the VRC6b is at $F002
+	c.CPUWrite(0xF002, 0b110)
+	if cycle := clockCPU(c, b, 100); cycle != 4 {
+		t.Errorf("expected the IRQ after 4 cycles, got %d", cycle)
+	}
+	c.CPUWrite(0xF001, 0)
+	if cycle := clockCPU(c, b, 1000); cycle != -1 {
+		t.Errorf("expected no IRQ after the acknowledgement, got one after %d cycles", cycle)
+	}
+}
+
+func TestMapper024Audio(t *testing.T) {
+	c, _ := newTestCartridge(t, 24, 0, 16, 8)
+	audio, ok := c.Mapper.(apu.ExpansionAudio)
+	if !ok {
+		t.Fatal("expected expansion audio")
+	}
+	// Wave forms over 64 CPU cycles
+	wave := func() []float64 {
+		var samples []float64
+		for i := 0; i < 64; i++ {
+			audio.ClockAudio()
+			samples = append(samples, audio.AudioOutput())
+		}
+		return samples
+	}
+
+	// Pulse 1 with volume 8, duty cycle 4/16 and a period of 2 CPU cycles
+	c.CPUWrite(0x9000, 0b0011_1000)
+	c.CPUWrite(0x9001, 1)
+	c.CPUWrite(0x9002, 0x80)
+	high := 0
+	for _, sample := range wave() {
+		if sample > 0 {
+			high++
+		}
+	}
+	if high != 16 {
+		t.Errorf("expected the pulse to be high for 16 of 64 cycles, got %d", high)
+	}
+	c.CPUWrite(0x9002, 0)
+
+	// Sawtooth with rate 8 and a period of 1 CPU cycle, the accumulator reaches 48 before it is reset
+	c.CPUWrite(0xB000, 8)
+	c.CPUWrite(0xB002, 0x80)
+	max := 0.0
+	for _, sample := range wave() {
+		if sample > max {
+			max = sample
+		}
+	}
+	if expected := apu.PulseStep * 48 / 8; max < expected-1e-9 || max > expected+1e-9 {
+		t.Errorf("expected a maximum of %f, got %f", expected, max)
+	}
+
+	// Halted oscillators keep their output
+	c.CPUWrite(0x9003, 1)
+	before := audio.AudioOutput()
+	for _, sample := range wave() {
+		if sample != before {
+			t.Fatal("expected the output to stay constant while halted")
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package cartridge
+
+import (
+	"fmt"
+	"github.com/exp625/gones/internal/plz"
+	"github.com/exp625/gones/internal/savestate"
+	"io"
+)
+
+// From NES DEV WIKI https://wiki.nesdev.org/w/index.php?title=INES_Mapper_034
+
+// Mapper 34 is used by two different boards. The NINA-001 (submapper 1) has PRG RAM and registers at $7FFD-$7FFF,
+// the BNROM (submapper 2) has a single register at $8000-$FFFF. Without a submapper, boards with more than 8 KB of
+// CHR ROM are NINA-001.
+// Subject to bus conflicts: BNROM only
+
+type Mapper034 struct {
+	cartridge *Cartridge
+	nina      bool
+	prgRam    []uint8
+	prgBank   uint8
+	// CHR banks at $0000 and $1000 (NINA-001 only)
+	chrBanks [2]uint8
+}
+
+func NewMapper034(c *Cartridge) *Mapper034 {
+	nina := c.Header.Submapper == 1
+	if c.Header.Submapper == 0 {
+		nina = !c.ChrRam && len(c.ChrRom) > 0x2000
+	}
+	m := &Mapper034{
+		cartridge: c,
+		nina:      nina,
+	}
+	if nina {
+		m.prgRam = make([]uint8, c.Header.PrgRamTotal())
+	}
+	return m
+}
+
+func (m *Mapper034) CPUMap(location uint16) uint16 {
+	return location
+}
+
+// CPU $6000-$7FFF: 8 KB PRG RAM bank (NINA-001 only)
+// CPU $8000-$FFFF: 32 KB switchable PRG ROM bank
+
+func (m *Mapper034) CPURead(location uint16) uint8 {
+	switch {
+	case 0x6000 <= location && location <= 0x7FFF:
+		return readRam(m.prgRam, int(location-0x6000), location)
+	case 0x8000 <= location:
+		return m.cartridge.PrgRom[(int(m.prgBank)*0x8000+int(location-0x8000))%len(m.cartridge.PrgRom)]
+	}
+	// Mapper was not responsible for the location
+	return 0
+}
+
+func (m *Mapper034) CPUWrite(location uint16, data uint8) bool {
+	if m.nina {
+		if location < 0x6000 || location > 0x7FFF {
+			return location >= 0x8000
+		}
+		// The registers do not replace the RAM, writes go to both
+		writeRam(m.prgRam, int(location-0x6000), data)
+		switch location {
+		case 0x7FFD:
+			// 7  bit  0
+			// ---- ----
+			// xxxx xxxP
+			//         |
+			//         +- Select 32 KB PRG ROM bank for CPU $8000-$FFFF
+			m.prgBank = data & 0b1
+		case 0x7FFE:
+			// Select 4 KB CHR ROM bank for PPU $0000-$0FFF
+			m.chrBanks[0] = data & 0x0F
+		case 0x7FFF:
+			// Select 4 KB CHR ROM bank for PPU $1000-$1FFF
+			m.chrBanks[1] = data & 0x0F
+		}
+		return true
+	}
+	if location >= 0x8000 {
+		// 7  bit  0
+		// ---- ----
+		// PPPP PPPP
+		// |||| ||||
+		// ++++-++++- Select 32 KB PRG ROM bank for CPU $8000-$FFFF
+		m.prgBank = busConflict(m, location, data)
+		return true
+	}
+	return false
+}
+
+func (m *Mapper034) PPUMap(location uint16) uint16 {
+	if 0x2000 <= location && location <= 0x3EFF {
+		if 0x3000 <= location && location <= 0x3FFF {
+			location -= 0x1000
+		}
+		if m.cartridge.MirrorBit == false {
+			// 1: horizontal mirroring
+			if location-0x2000 < 0x800 {
+				location = 0x2000 + location%0x400
+
+			} else {
+				location = 0x2400 + location%0x400
+			}
+		} else {
+			// 1: vertical mirroring
+			location = 0x2000 + location%0x800
+		}
+	}
+	return location
+}
+
+// chrIndex returns the index into the CHR memory for the PPU location $0000-$1FFF
+func (m *Mapper034) chrIndex(location uint16) int {
+	if !m.nina {
+		return int(location)
+	}
+	return (int(m.chrBanks[location/0x1000])*0x1000 + int(location%0x1000)) % len(m.cartridge.ChrRom)
+}
+
+func (m *Mapper034) PPURead(location uint16) uint8 {
+	if location <= 0x1FFF {
+		return m.cartridge.ChrRom[m.chrIndex(location)]
+	}
+	return 0
+}
+
+func (m *Mapper034) PPUWrite(location uint16, data uint8) bool {
+	if location <= 0x1FFF {
+		if m.cartridge.ChrRam {
+			m.cartridge.ChrRom[m.chrIndex(location)] = data
+		}
+		return true
+	}
+	return false
+}
+
+func (m *Mapper034) Load(data []uint8) error {
+	return loadRam(m.prgRam, data)
+}
+
+func (m *Mapper034) Save() []uint8 {
+	return m.prgRam
+}
+
+func (m *Mapper034) Serialize(s *savestate.Serializer) {
+	s.Bytes(m.prgRam)
+	s.Uint8(&m.prgBank)
+	s.Bytes(m.chrBanks[:])
+}
+
+func (m *Mapper034) Reset() {
+	m.prgBank = 0
+	m.chrBanks = [2]uint8{}
+}
+
+func (m *Mapper034) CPUClock() {
+}
+
+func (m *Mapper034) DebugDisplay(text io.Writer) {
+	board := "BNROM"
+	if m.nina {
+		board = "NINA-001"
+	}
+	plz.Just(fmt.Fprintf(text, "Cartridge with Mapper 034 (%s)\n", board))
+	plz.Just(fmt.Fprintf(text, "PRG ROM Size: %d * 16 KB\n", m.cartridge.PrgRomSize))
+	plz.Just(fmt.Fprintf(text, "PRG BANK    : %d \n", m.prgBank))
+	plz.Just(fmt.Fprintf(text, "CHR ROM Size: %d * 8 KB\n", m.cartridge.ChrRomSize))
+	if m.nina {
+		plz.Just(fmt.Fprintf(text, "CHR BANKS   : %v \n", m.chrBanks))
+	}
+}
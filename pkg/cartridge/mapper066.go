@@ -0,0 +1,123 @@
+package cartridge
+
+import (
+	"fmt"
+	"github.com/exp625/gones/internal/plz"
+	"github.com/exp625/gones/internal/savestate"
+	"io"
+)
+
+// From NES DEV WIKI https://wiki.nesdev.org/w/index.php?title=GxROM
+
+// GNROM and MHROM. Used by Super Mario Bros. + Duck Hunt and Dragon Power.
+// Subject to bus conflicts: Yes
+
+type Mapper066 struct {
+	cartridge *Cartridge
+	prgBank   uint8
+	chrBank   uint8
+}
+
+func NewMapper066(c *Cartridge) *Mapper066 {
+	return &Mapper066{
+		cartridge: c,
+	}
+}
+
+func (m *Mapper066) CPUMap(location uint16) uint16 {
+	return location
+}
+
+// CPU $8000-$FFFF: 32 KB switchable PRG ROM bank
+
+func (m *Mapper066) CPURead(location uint16) uint8 {
+	if location >= 0x8000 {
+		return m.cartridge.PrgRom[(int(m.prgBank)*0x8000+int(location-0x8000))%len(m.cartridge.PrgRom)]
+	}
+	// Mapper was not responsible for the location
+	return 0
+}
+
+func (m *Mapper066) CPUWrite(location uint16, data uint8) bool {
+	if location >= 0x8000 {
+		// 7  bit  0
+		// ---- ----
+		// xxPP xxCC
+		//   ||   ||
+		//   ||   ++- Select 8 KB CHR ROM bank for PPU $0000-$1FFF
+		//   ++------ Select 32 KB PRG ROM bank for CPU $8000-$FFFF
+		data = busConflict(m, location, data)
+		m.prgBank = data >> 4 & 0b11
+		m.chrBank = data & 0b11
+		return true
+	}
+	return false
+}
+
+func (m *Mapper066) PPUMap(location uint16) uint16 {
+	if 0x2000 <= location && location <= 0x3EFF {
+		if 0x3000 <= location && location <= 0x3FFF {
+			location -= 0x1000
+		}
+		if m.cartridge.MirrorBit == false {
+			// 1: horizontal mirroring
+			if location-0x2000 < 0x800 {
+				location = 0x2000 + location%0x400
+
+			} else {
+				location = 0x2400 + location%0x400
+			}
+		} else {
+			// 1: vertical mirroring
+			location = 0x2000 + location%0x800
+		}
+	}
+	return location
+}
+
+func (m *Mapper066) PPURead(location uint16) uint8 {
+	if location <= 0x1FFF {
+		return m.cartridge.ChrRom[(int(m.chrBank)*0x2000+int(location))%len(m.cartridge.ChrRom)]
+	}
+	return 0
+}
+
+func (m *Mapper066) PPUWrite(location uint16, data uint8) bool {
+	if location <= 0x1FFF {
+		if m.cartridge.ChrRam {
+			m.cartridge.ChrRom[(int(m.chrBank)*0x2000+int(location))%len(m.cartridge.ChrRom)] = data
+		}
+		return true
+	}
+	return false
+}
+
+func (m *Mapper066) Load(data []uint8) error {
+	// No battery backed RAM
+	return loadRam(nil, data)
+}
+
+func (m *Mapper066) Save() []uint8 {
+	return []uint8{}
+}
+
+func (m *Mapper066) Serialize(s *savestate.Serializer) {
+	s.Uint8(&m.prgBank)
+	s.Uint8(&m.chrBank)
+}
+
+func (m *Mapper066) Reset() {
+	m.prgBank = 0
+	m.chrBank = 0
+}
+
+func (m *Mapper066) CPUClock() {
+}
+
+func (m *Mapper066) DebugDisplay(text io.Writer) {
+	plz.Just(fmt.Fprint(text, "Cartridge with Mapper 066\n"))
+	plz.Just(fmt.Fprintf(text, "PRG ROM Size: %d * 16 KB\n", m.cartridge.PrgRomSize))
+	plz.Just(fmt.Fprintf(text, "PRG BANK    : %d \n", m.prgBank))
+	plz.Just(fmt.Fprintf(text, "CHR ROM Size: %d * 8 KB\n", m.cartridge.ChrRomSize))
+	plz.Just(fmt.Fprintf(text, "CHR BANK    : %d \n", m.chrBank))
+}
@@ -0,0 +1,440 @@
+package cartridge
+
+import (
+	"fmt"
+	"github.com/exp625/gones/internal/plz"
+	"github.com/exp625/gones/internal/savestate"
+	"github.com/exp625/gones/pkg/apu"
+	"io"
+	"math"
+)
+
+// From NES DEV WIKI https://wiki.nesdev.org/w/index.php?title=Sunsoft_FME-7
+
+// The Sunsoft FME-7 and the Sunsoft 5A and 5B, which are the same chip with an added sound chip on the 5B.
+// Used by Batman: Return of the Joker, Hebereke and Gimmick!
+
+type Mapper069 struct {
+	cartridge *Cartridge
+
+	// PRG RAM, mapped to $6000-$7FFF by command 8
+	prgRam []uint8
+
+	command uint8
+	// Commands 0-7: 1 KB CHR banks
+	chrBanks [8]uint8
+	// Command 8: PRG bank at $6000 with RAM select and RAM enable, commands 9-B: PRG banks at $8000-$DFFF
+	prgBanks   [4]uint8
+	mirrorMode uint8
+
+	irqEnabled     bool
+	counterEnabled bool
+	irqPending     bool
+	counter        uint16
+
+	audio sunsoft5B
+}
+
+func NewMapper069(c *Cartridge) *Mapper069 {
+	m := &Mapper069{
+		cartridge: c,
+		prgRam:    make([]uint8, c.Header.PrgRamTotal()),
+	}
+	m.audio.reset()
+	return m
+}
+
+func (m *Mapper069) CPUMap(location uint16) uint16 {
+	return location
+}
+
+// CPU $6000-$7FFF: 8 KB PRG ROM or RAM bank
+// CPU $8000-$9FFF: 8 KB switchable PRG ROM bank
+// CPU $A000-$BFFF: 8 KB switchable PRG ROM bank
+// CPU $C000-$DFFF: 8 KB switchable PRG ROM bank
+// CPU $E000-$FFFF: 8 KB PRG ROM bank, fixed to the last bank
+
+func (m *Mapper069) CPURead(location uint16) uint8 {
+	switch {
+	case 0x6000 <= location && location <= 0x7FFF:
+		bank := int(m.prgBanks[0] & 0b11_1111)
+		switch m.prgBanks[0] >> 6 {
+		case 0b01:
+			// RAM selected, but disabled
+			return uint8(location >> 8)
+		case 0b11:
+			return readRam(m.prgRam, bank*0x2000+int(location&0x1FFF), location)
+		}
+		return m.cartridge.PrgRom[(bank*0x2000+int(location&0x1FFF))%len(m.cartridge.PrgRom)]
+	case 0x8000 <= location && location <= 0xDFFF:
+		bank := int(m.prgBanks[(location-0x6000)/0x2000] & 0b11_1111)
+		return m.cartridge.PrgRom[(bank*0x2000+int(location&0x1FFF))%len(m.cartridge.PrgRom)]
+	case 0xE000 <= location:
+		return m.cartridge.PrgRom[len(m.cartridge.PrgRom)-0x2000+int(location&0x1FFF)]
+	}
+	// Mapper was not responsible for the location
+	return 0
+}
+
+func (m *Mapper069) CPUWrite(location uint16, data uint8) bool {
+	switch {
+	case 0x6000 <= location && location <= 0x7FFF:
+		if m.prgBanks[0]>>6 == 0b11 {
+			writeRam(m.prgRam, int(m.prgBanks[0]&0b11_1111)*0x2000+int(location&0x1FFF), data)
+		}
+	case 0x8000 <= location && location <= 0x9FFF:
+		// Command register ($8000-$9FFF)
+		// 7  bit  0
+		// ---- ----
+		// .... CCCC
+		//      ||||
+		//      ++++- The command number to invoke when writing to the parameter register
+		m.command = data & 0x0F
+	case 0xA000 <= location && location <= 0xBFFF:
+		// Parameter register ($A000-$BFFF)
+		m.writeParameter(data)
+	case 0xC000 <= location && location <= 0xDFFF:
+		// Audio register select ($C000-$DFFF)
+		m.audio.selectRegister(data)
+	case 0xE000 <= location:
+		// Audio register write ($E000-$FFFF)
+		m.audio.write(data)
+	default:
+		return false
+	}
+	return true
+}
+
+// writeParameter executes the selected command
+func (m *Mapper069) writeParameter(data uint8) {
+	switch {
+	case m.command <= 0x7:
+		// CHR bank 0-7, 1 KB at PPU $0000-$1FFF
+		m.chrBanks[m.command] = data
+	case m.command == 0x8:
+		// PRG bank 0 ($6000-$7FFF)
+		// 7  bit  0
+		// ---- ----
+		// ERbB BBBB
+		// |||| ||||
+		// ||++-++++- The bank number to select at CPU $6000 - $7FFF
+		// |+------- RAM / ROM Select Bit (0: PRG ROM; 1: PRG RAM)
+		// +-------- RAM Enable Bit (6264 +CE line) (0: PRG RAM disabled; 1: PRG RAM enabled)
+		m.prgBanks[0] = data
+	case m.command <= 0xB:
+		// PRG bank 1-3 ($8000-$DFFF)
+		m.prgBanks[m.command-0x8] = data & 0b11_1111
+	case m.command == 0xC:
+		// Mirroring (0: vertical; 1: horizontal; 2: one-screen, lower bank; 3: one-screen, upper bank)
+		m.mirrorMode = data & 0b11
+	case m.command == 0xD:
+		// IRQ control, acknowledges the IRQ
+		// 7  bit  0
+		// ---- ----
+		// C... ...T
+		// |       |
+		// |       +- IRQ Enable (0: Do not generate IRQs; 1: Do generate IRQs)
+		// +--------- IRQ Counter Enable (0: Disable Counter Decrement; 1: Enable Counter Decrement)
+		m.irqEnabled = data&0b1 == 1
+		m.counterEnabled = data>>7 == 1
+		m.irqPending = false
+	case m.command == 0xE:
+		// IRQ counter low byte
+		m.counter = m.counter&0xFF00 | uint16(data)
+	case m.command == 0xF:
+		// IRQ counter high byte
+		m.counter = m.counter&0x00FF | uint16(data)<<8
+	}
+}
+
+func (m *Mapper069) CPUClock() {
+}
+
+func (m *Mapper069) CPUCycle() {
+	if m.counterEnabled {
+		// The IRQ is triggered when the counter wraps around from $0000 to $FFFF
+		m.counter--
+		if m.counter == 0xFFFF && m.irqEnabled {
+			m.irqPending = true
+		}
+	}
+	if m.irqPending {
+		m.cartridge.Bus.IRQ()
+	}
+}
+
+func (m *Mapper069) PPUMap(location uint16) uint16 {
+	if 0x2000 <= location && location <= 0x3EFF {
+		var page uint16
+		switch m.mirrorMode {
+		case 0:
+			// Vertical mirroring
+			page = location >> 10 & 0b1
+		case 1:
+			// Horizontal mirroring
+			page = location >> 11 & 0b1
+		case 2:
+			page = 0
+		case 3:
+			page = 1
+		}
+		location = 0x2000 + page*0x400 + location%0x400
+	}
+	return location
+}
+
+// chrIndex returns the index into the CHR memory for the PPU location $0000-$1FFF
+func (m *Mapper069) chrIndex(location uint16) int {
+	return (int(m.chrBanks[location/0x400])*0x400 + int(location%0x400)) % len(m.cartridge.ChrRom)
+}
+
+func (m *Mapper069) PPURead(location uint16) uint8 {
+	if location <= 0x1FFF {
+		return m.cartridge.ChrRom[m.chrIndex(location)]
+	}
+	return 0
+}
+
+func (m *Mapper069) PPUWrite(location uint16, data uint8) bool {
+	if location <= 0x1FFF {
+		if m.cartridge.ChrRam {
+			m.cartridge.ChrRom[m.chrIndex(location)] = data
+		}
+		return true
+	}
+	return false
+}
+
+// ClockAudio clocks the sound chip of the 5B
+func (m *Mapper069) ClockAudio() {
+	m.audio.clock()
+}
+
+// AudioOutput returns the output of the sound chip of the 5B. The 5A and FME-7 have none, but games for them never
+// write to the audio registers.
+func (m *Mapper069) AudioOutput() float64 {
+	return m.audio.output()
+}
+
+func (m *Mapper069) Load(data []uint8) error {
+	return loadRam(m.prgRam, data)
+}
+
+func (m *Mapper069) Save() []uint8 {
+	return m.prgRam
+}
+
+func (m *Mapper069) Serialize(s *savestate.Serializer) {
+	s.Bytes(m.prgRam)
+	s.Uint8(&m.command)
+	s.Bytes(m.chrBanks[:])
+	s.Bytes(m.prgBanks[:])
+	s.Uint8(&m.mirrorMode)
+	s.Bool(&m.irqEnabled)
+	s.Bool(&m.counterEnabled)
+	s.Bool(&m.irqPending)
+	s.Uint16(&m.counter)
+	m.audio.serialize(s)
+}
+
+func (m *Mapper069) Reset() {
+	m.command = 0
+	m.chrBanks = [8]uint8{}
+	m.prgBanks = [4]uint8{}
+	m.mirrorMode = 0
+	m.irqEnabled = false
+	m.counterEnabled = false
+	m.irqPending = false
+	m.counter = 0
+	m.audio.reset()
+}
+
+func (m *Mapper069) DebugDisplay(text io.Writer) {
+	plz.Just(fmt.Fprint(text, "Cartridge with Mapper 069\n"))
+	plz.Just(fmt.Fprintf(text, "PRG ROM Size: %d * 16 KB\n", m.cartridge.PrgRomSize))
+	plz.Just(fmt.Fprintf(text, "PRG Banks   : %v\n", m.prgBanks))
+	plz.Just(fmt.Fprintf(text, "CHR ROM Size: %d * 8 KB\n", m.cartridge.ChrRomSize))
+	plz.Just(fmt.Fprintf(text, "CHR Banks   : %v\n", m.chrBanks))
+	plz.Just(fmt.Fprintf(text, "Mirror Mode : %d \n", m.mirrorMode))
+	plz.Just(fmt.Fprintf(text, "IRQ: %t, Counter: %t, %d\n", m.irqEnabled, m.counterEnabled, m.counter))
+}
+
+// From NES DEV WIKI https://wiki.nesdev.org/w/index.php?title=Sunsoft_5B_audio
+
+// sunsoft5BLevels are the amplitudes of the 32 logarithmic output levels of the 5B. Every level is 1.5 dB louder than
+// the previous, level 0 is silent. A channel at the highest level is as loud as an APU pulse.
+var sunsoft5BLevels = func() [32]float64 {
+	var levels [32]float64
+	for i := 1; i < 32; i++ {
+		levels[i] = apu.PulseStep * 15 * math.Pow(10, -1.5*float64(31-i)/20)
+	}
+	return levels
+}()
+
+// sunsoft5B is the sound chip of the Sunsoft 5B, a variant of the Yamaha YM2149F, which is a clone of the General
+// Instrument AY-3-8910. It has three square channels, a noise generator and an envelope generator that the channels
+// share. The I/O ports of the chip are not connected.
+type sunsoft5B struct {
+	register  uint8
+	registers [16]uint8
+
+	// The tone and noise generators are clocked every 16 CPU cycles, the envelope every 8 CPU cycles
+	divider uint8
+
+	toneCounters [3]uint16
+	toneOutputs  [3]bool
+
+	noiseCounter uint8
+	// 17-bit linear feedback shift register
+	noise uint32
+
+	envelopeCounter uint16
+	envelopeStep    uint8
+	envelopeAttack  bool
+	envelopeHolding bool
+}
+
+// selectRegister selects the register for the next write
+func (a *sunsoft5B) selectRegister(data uint8) {
+	// 7  bit  0
+	// ---- ----
+	// .... RRRR
+	//      ||||
+	//      ++++- The register to write to at $E000
+	a.register = data & 0x0F
+}
+
+// write writes to the selected register
+//
+// $00, $02, $04: Low 8 bits of the tone period of channel A, B and C
+// $01, $03, $05: High 4 bits of the tone period of channel A, B and C
+// $06: Noise period (5 bits)
+// $07: Disable the tone of channel A, B and C (bits 0-2) and the noise of channel A, B and C (bits 3-5)
+// $08-$0A: Volume of channel A, B and C (bits 0-3) and envelope enable (bit 4)
+// $0B, $0C: Low and high byte of the envelope period
+// $0D: Envelope shape, restarts the envelope
+// $0E, $0F: I/O ports, not connected
+func (a *sunsoft5B) write(data uint8) {
+	a.registers[a.register] = data
+	if a.register == 0x0D {
+		// 7  bit  0
+		// ---- ----
+		// .... CAaH
+		//      ||||
+		//      |||+- Hold
+		//      ||+-- Alternate
+		//      |+--- Attack
+		//      +---- Continue
+		a.envelopeStep = 0
+		a.envelopeCounter = 0
+		a.envelopeAttack = data&0b0100 != 0
+		a.envelopeHolding = false
+	}
+}
+
+func (a *sunsoft5B) clock() {
+	a.divider = (a.divider + 1) % 16
+	if a.divider%8 == 0 {
+		a.clockEnvelope()
+	}
+	if a.divider != 0 {
+		return
+	}
+	for i := range a.toneCounters {
+		period := uint16(a.registers[i*2+1]&0x0F)<<8 | uint16(a.registers[i*2])
+		a.toneCounters[i]++
+		if a.toneCounters[i] >= period {
+			a.toneCounters[i] = 0
+			a.toneOutputs[i] = !a.toneOutputs[i]
+		}
+	}
+	a.noiseCounter++
+	if a.noiseCounter >= a.registers[0x06]&0b1_1111 {
+		a.noiseCounter = 0
+		feedback := (a.noise ^ a.noise>>3) & 0b1
+		a.noise = a.noise>>1 | feedback<<16
+	}
+}
+
+func (a *sunsoft5B) clockEnvelope() {
+	if a.envelopeHolding {
+		return
+	}
+	a.envelopeCounter++
+	if a.envelopeCounter < uint16(a.registers[0x0C])<<8|uint16(a.registers[0x0B]) {
+		return
+	}
+	a.envelopeCounter = 0
+	if a.envelopeStep < 31 {
+		a.envelopeStep++
+		return
+	}
+
+	// End of the ramp
+	shape := a.registers[0x0D]
+	switch {
+	case shape&0b1000 == 0:
+		// Without continue, the envelope stays silent
+		a.envelopeHolding = true
+		a.envelopeAttack = false
+	case shape&0b0001 != 0:
+		// Hold the last level, or the opposite level if alternating
+		a.envelopeHolding = true
+		if shape&0b0010 != 0 {
+			a.envelopeAttack = !a.envelopeAttack
+		}
+	default:
+		// Repeat the ramp, in the opposite direction if alternating
+		a.envelopeStep = 0
+		if shape&0b0010 != 0 {
+			a.envelopeAttack = !a.envelopeAttack
+		}
+	}
+}
+
+// envelopeLevel returns the level of the envelope
+func (a *sunsoft5B) envelopeLevel() uint8 {
+	if a.envelopeAttack {
+		return a.envelopeStep
+	}
+	return 31 - a.envelopeStep
+}
+
+func (a *sunsoft5B) output() float64 {
+	output := 0.0
+	for i := range a.toneOutputs {
+		toneDisabled := a.registers[0x07]>>i&0b1 == 1
+		noiseDisabled := a.registers[0x07]>>(i+3)&0b1 == 1
+		if !(a.toneOutputs[i] || toneDisabled) || !(a.noise&0b1 == 1 || noiseDisabled) {
+			continue
+		}
+		volume := a.registers[0x08+i]
+		if volume&0b1_0000 != 0 {
+			output += sunsoft5BLevels[a.envelopeLevel()]
+		} else if volume&0x0F != 0 {
+			// The 4-bit volume uses every second level
+			output += sunsoft5BLevels[volume&0x0F*2+1]
+		}
+	}
+	return output
+}
+
+func (a *sunsoft5B) reset() {
+	*a = sunsoft5B{noise: 1}
+}
+
+func (a *sunsoft5B) serialize(s *savestate.Serializer) {
+	s.Uint8(&a.register)
+	s.Bytes(a.registers[:])
+	s.Uint8(&a.divider)
+	for i := range a.toneCounters {
+		s.Uint16(&a.toneCounters[i])
+		s.Bool(&a.toneOutputs[i])
+	}
+	s.Uint8(&a.noiseCounter)
+	s.Uint32(&a.noise)
+	s.Uint16(&a.envelopeCounter)
+	s.Uint8(&a.envelopeStep)
+	s.Bool(&a.envelopeAttack)
+	s.Bool(&a.envelopeHolding)
+}
@@ -0,0 +1,141 @@
+package cartridge_test
+
+import (
+	"testing"
+
+	"github.com/exp625/gones/pkg/apu"
+	"github.com/exp625/gones/pkg/cartridge"
+)
+
+// fme7 invokes a command of the FME-7
+func fme7(c *cartridge.Cartridge, command uint8, parameter uint8) {
+	c.CPUWrite(0x8000, command)
+	c.CPUWrite(0xA000, parameter)
+}
+
+func TestMapper069Banks(t *testing.T) {
+	c, _ := newTestCartridge(t, 69, 0, 32, 64)
+	fme7(c, 0x9, 3)
+	fme7(c, 0xA, 4)
+	fme7(c, 0xB, 5)
+	fme7(c, 0x8, 6)
+	for _, test := range []struct {
+		location uint16
+		bank     uint8
+	}{{0x6000, 6}, {0x8000, 3}, {0xA000, 4}, {0xC000, 5}, {0xE000, 31}} {
+		if data := c.CPURead(test.location); data != test.bank {
+			t.Errorf("expected PRG bank %d at $%04X, got %d", test.bank, test.location, data)
+		}
+	}
+
+	// PRG RAM at $6000
+	fme7(c, 0x8, 0b0100_0000)
+	c.CPUWrite(0x6010, 0x42)
+	if data := c.CPURead(0x6010); data != 0x60 {
+		t.Errorf("expected open bus for disabled PRG RAM, got $%02X", data)
+	}
+	fme7(c, 0x8, 0b1100_0000)
+	c.CPUWrite(0x6010, 0x42)
+	if data := c.CPURead(0x6010); data != 0x42 {
+		t.Errorf("expected PRG RAM, got $%02X", data)
+	}
+
+	for i := uint8(0); i < 8; i++ {
+		fme7(c, i, 0x30+i)
+	}
+	for i := uint16(0); i < 8; i++ {
+		if data := c.PPURead(i * 0x400); data != uint8(0x30+i) {
+			t.Errorf("expected CHR bank $%02X at slot %d, got $%02X", 0x30+i, i, data)
+		}
+	}
+
+	fme7(c, 0xC, 1)
+	if location := c.PPUMap(0x2800); location != 0x2400 {
+		t.Errorf("expected horizontal mirroring, got $%04X", location)
+	}
+	fme7(c, 0xC, 3)
+	if location := c.PPUMap(0x2000); location != 0x2400 {
+		t.Errorf("expected one-screen mirroring of the upper bank, got $%04X", location)
+	}
+}
+
+func TestMapper069IRQ(t *testing.T) {
+	c, b := newTestCartridge(t, 69, 0, 16, 8)
+	fme7(c, 0xE, 5)
+	fme7(c, 0xF, 0)
+	// Counting without IRQ
+	fme7(c, 0xD, 0x80)
+	if cycle := clockCPU(c, b, 100); cycle != -1 {
+		t.Errorf("expected no IRQ, got one after %d cycles", cycle)
+	}
+	fme7(c, 0xE, 5)
+	fme7(c, 0xF, 0)
+	fme7(c, 0xD, 0x81)
+	if cycle := clockCPU(c, b, 100); cycle != 6 {
+		t.Errorf("expected the IRQ when the counter wraps after 6 cycles, got %d", cycle)
+	}
+	// Writing the IRQ control acknowledges the IRQ
+	fme7(c, 0xD, 0x00)
+	if cycle := clockCPU(c, b, 0x20000); cycle != -1 {
+		t.Errorf("expected no IRQ after the acknowledgement, got one after %d cycles", cycle)
+	}
+}
+
+// sunsoft5B writes a register of the 5B
+func sunsoft5B(c *cartridge.Cartridge, register uint8, data uint8) {
+	c.CPUWrite(0xC000, register)
+	c.CPUWrite(0xE000, data)
+}
+
+func TestMapper069Audio(t *testing.T) {
+	c, _ := newTestCartridge(t, 69, 0, 16, 8)
+	audio := c.Mapper.(apu.ExpansionAudio)
+
+	// Channel A with tone only, period 2 and volume 15
+	sunsoft5B(c, 0x07, 0b11_1110)
+	sunsoft5B(c, 0x00, 2)
+	sunsoft5B(c, 0x08, 0x0F)
+	var toggles []int
+	last := audio.AudioOutput()
+	for cycle := 1; cycle <= 200; cycle++ {
+		audio.ClockAudio()
+		if output := audio.AudioOutput(); output != last {
+			toggles = append(toggles, cycle)
+			last = output
+		}
+	}
+	if len(toggles) < 4 || toggles[2]-toggles[1] != 32 {
+		t.Errorf("expected the square to toggle every 32 cycles, got %v", toggles)
+	}
+	full := apu.PulseStep * 15
+	if last != 0 && (last < full-1e-9 || last > full+1e-9) {
+		t.Errorf("expected an amplitude of %f, got %f", full, last)
+	}
+
+	// A channel with tone and noise disabled outputs its volume constantly, the envelope decays in one ramp
+	sunsoft5B(c, 0x07, 0b11_1111)
+	sunsoft5B(c, 0x08, 0x10)
+	sunsoft5B(c, 0x0B, 1)
+	sunsoft5B(c, 0x0D, 0b0000)
+	previous := audio.AudioOutput()
+	if previous < full-1e-9 {
+		t.Errorf("expected the envelope to start at the highest level, got %f", previous)
+	}
+	for cycle := 0; cycle < 32*8; cycle++ {
+		audio.ClockAudio()
+		if output := audio.AudioOutput(); output > previous {
+			t.Fatalf("expected the envelope to decay, got %f after %f", output, previous)
+		} else {
+			previous = output
+		}
+	}
+	if previous != 0 {
+		t.Errorf("expected the envelope to hold at level 0, got %f", previous)
+	}
+
+	// Volume 1 is 42 dB below volume 15
+	sunsoft5B(c, 0x08, 0x01)
+	if output, expected := audio.AudioOutput(), full/125.89; output < expected*0.99 || output > expected*1.01 {
+		t.Errorf("expected %f for volume 1, got %f", expected, output)
+	}
+}
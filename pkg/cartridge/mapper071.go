@@ -0,0 +1,153 @@
+package cartridge
+
+import (
+	"fmt"
+	"github.com/exp625/gones/internal/plz"
+	"github.com/exp625/gones/internal/savestate"
+	"io"
+)
+
+// From NES DEV WIKI https://wiki.nesdev.org/w/index.php?title=INES_Mapper_071
+
+// Camerica and Codemasters boards with the BF9093 and BF9097. The BF9097 of Fire Hawk (submapper 1) has one-screen
+// mirroring. Without a submapper, the mirroring register is enabled by the first write to it.
+// Subject to bus conflicts: No
+
+type Mapper071 struct {
+	cartridge *Cartridge
+	prgBank   uint8
+	// One-screen mirroring of the BF9097 instead of the mirroring of the header
+	oneScreen     bool
+	nameTablePage uint8
+}
+
+func NewMapper071(c *Cartridge) *Mapper071 {
+	return &Mapper071{
+		cartridge: c,
+		oneScreen: c.Header.Submapper == 1,
+	}
+}
+
+func (m *Mapper071) CPUMap(location uint16) uint16 {
+	return location
+}
+
+// CPU $8000-$BFFF: 16 KB switchable PRG ROM bank
+// CPU $C000-$FFFF: 16 KB PRG ROM bank, fixed to the last bank
+
+func (m *Mapper071) CPURead(location uint16) uint8 {
+	switch {
+	case 0x8000 <= location && location <= 0xBFFF:
+		return m.cartridge.PrgRom[(int(m.prgBank)*0x4000+int(location-0x8000))%len(m.cartridge.PrgRom)]
+	case 0xC000 <= location:
+		return m.cartridge.PrgRom[len(m.cartridge.PrgRom)-0x4000+int(location-0xC000)]
+	}
+	// Mapper was not responsible for the location
+	return 0
+}
+
+func (m *Mapper071) CPUWrite(location uint16, data uint8) bool {
+	switch {
+	case 0x8000 <= location && location <= 0x9FFF:
+		// Mirroring ($8000-$9FFF, BF9097 only)
+		// 7  bit  0
+		// ---- ----
+		// xxxM xxxx
+		//    |
+		//    +------ Select 1 KB VRAM page for all 4 nametables
+		if m.cartridge.Header.Submapper == 0 && location >= 0x9000 {
+			m.oneScreen = true
+		}
+		m.nameTablePage = data >> 4 & 0b1
+	case 0xC000 <= location:
+		// Bank select ($C000-$FFFF)
+		// 7  bit  0
+		// ---- ----
+		// xxxx PPPP
+		//      ||||
+		//      ++++- Select 16 KB PRG ROM bank for CPU $8000-$BFFF
+		m.prgBank = data & 0x0F
+	case location < 0x8000:
+		return false
+	}
+	return true
+}
+
+func (m *Mapper071) PPUMap(location uint16) uint16 {
+	if 0x2000 <= location && location <= 0x3EFF {
+		if 0x3000 <= location && location <= 0x3FFF {
+			location -= 0x1000
+		}
+		switch {
+		case m.oneScreen:
+			location = 0x2000 + uint16(m.nameTablePage)*0x400 + location%0x400
+		case m.cartridge.MirrorBit == false:
+			// 1: horizontal mirroring
+			if location-0x2000 < 0x800 {
+				location = 0x2000 + location%0x400
+			} else {
+				location = 0x2400 + location%0x400
+			}
+		default:
+			// 1: vertical mirroring
+			location = 0x2000 + location%0x800
+		}
+	}
+	return location
+}
+
+func (m *Mapper071) PPURead(location uint16) uint8 {
+	if location <= 0x1FFF {
+		return m.cartridge.ChrRom[location]
+	}
+	return 0
+}
+
+func (m *Mapper071) PPUWrite(location uint16, data uint8) bool {
+	if location <= 0x1FFF {
+		if m.cartridge.ChrRam {
+			m.cartridge.ChrRom[location] = data
+		}
+		return true
+	}
+	return false
+}
+
+func (m *Mapper071) Load(data []uint8) error {
+	// No battery backed RAM
+	return loadRam(nil, data)
+}
+
+func (m *Mapper071) Save() []uint8 {
+	return []uint8{}
+}
+
+func (m *Mapper071) Serialize(s *savestate.Serializer) {
+	s.Uint8(&m.prgBank)
+	s.Bool(&m.oneScreen)
+	s.Uint8(&m.nameTablePage)
+}
+
+func (m *Mapper071) Reset() {
+	m.prgBank = 0
+	m.oneScreen = m.cartridge.Header.Submapper == 1
+	m.nameTablePage = 0
+}
+
+func (m *Mapper071) CPUClock() {
+}
+
+func (m *Mapper071) DebugDisplay(text io.Writer) {
+	plz.Just(fmt.Fprint(text, "Cartridge with Mapper 071\n"))
+	plz.Just(fmt.Fprintf(text, "PRG ROM Size: %d * 16 KB\n", m.cartridge.PrgRomSize))
+	plz.Just(fmt.Fprintf(text, "PRG BANK    : %d \n", m.prgBank))
+	plz.Just(fmt.Fprintf(text, "CHR ROM Size: %d * 8 KB\n", m.cartridge.ChrRomSize))
+	str := "Horizontal "
+	switch {
+	case m.oneScreen:
+		str = fmt.Sprintf("1 page, page %d ", m.nameTablePage)
+	case m.cartridge.MirrorBit:
+		str = "Vertical "
+	}
+	plz.Just(fmt.Fprint(text, "Mirror Mode : ", str, "\n"))
+}
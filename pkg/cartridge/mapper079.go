@@ -0,0 +1,123 @@
+package cartridge
+
+import (
+	"fmt"
+	"github.com/exp625/gones/internal/plz"
+	"github.com/exp625/gones/internal/savestate"
+	"io"
+)
+
+// From NES DEV WIKI https://wiki.nesdev.org/w/index.php?title=NINA-003-006
+
+// The AVE NINA-03 and NINA-06 boards. The register is in the expansion area at $4100-$5FFF.
+// Subject to bus conflicts: No
+
+type Mapper079 struct {
+	cartridge *Cartridge
+	prgBank   uint8
+	chrBank   uint8
+}
+
+func NewMapper079(c *Cartridge) *Mapper079 {
+	return &Mapper079{
+		cartridge: c,
+	}
+}
+
+func (m *Mapper079) CPUMap(location uint16) uint16 {
+	return location
+}
+
+// CPU $8000-$FFFF: 32 KB switchable PRG ROM bank
+
+func (m *Mapper079) CPURead(location uint16) uint8 {
+	if location >= 0x8000 {
+		return m.cartridge.PrgRom[(int(m.prgBank)*0x8000+int(location-0x8000))%len(m.cartridge.PrgRom)]
+	}
+	// Mapper was not responsible for the location
+	return 0
+}
+
+func (m *Mapper079) CPUWrite(location uint16, data uint8) bool {
+	// The register responds to all locations of the form 010x xxx1 xxxx xxxx
+	if location&0xE100 == 0x4100 {
+		// 7  bit  0
+		// ---- ----
+		// xxxx PCCC
+		//      ||||
+		//      |+++- Select 8 KB CHR ROM bank for PPU $0000-$1FFF
+		//      +---- Select 32 KB PRG ROM bank for CPU $8000-$FFFF
+		m.prgBank = data >> 3 & 0b1
+		m.chrBank = data & 0b111
+		return true
+	}
+	return location >= 0x8000
+}
+
+func (m *Mapper079) PPUMap(location uint16) uint16 {
+	if 0x2000 <= location && location <= 0x3EFF {
+		if 0x3000 <= location && location <= 0x3FFF {
+			location -= 0x1000
+		}
+		if m.cartridge.MirrorBit == false {
+			// 1: horizontal mirroring
+			if location-0x2000 < 0x800 {
+				location = 0x2000 + location%0x400
+
+			} else {
+				location = 0x2400 + location%0x400
+			}
+		} else {
+			// 1: vertical mirroring
+			location = 0x2000 + location%0x800
+		}
+	}
+	return location
+}
+
+func (m *Mapper079) PPURead(location uint16) uint8 {
+	if location <= 0x1FFF {
+		return m.cartridge.ChrRom[(int(m.chrBank)*0x2000+int(location))%len(m.cartridge.ChrRom)]
+	}
+	return 0
+}
+
+func (m *Mapper079) PPUWrite(location uint16, data uint8) bool {
+	if location <= 0x1FFF {
+		if m.cartridge.ChrRam {
+			m.cartridge.ChrRom[(int(m.chrBank)*0x2000+int(location))%len(m.cartridge.ChrRom)] = data
+		}
+		return true
+	}
+	return false
+}
+
+func (m *Mapper079) Load(data []uint8) error {
+	// No battery backed RAM
+	return loadRam(nil, data)
+}
+
+func (m *Mapper079) Save() []uint8 {
+	return []uint8{}
+}
+
+func (m *Mapper079) Serialize(s *savestate.Serializer) {
+	s.Uint8(&m.prgBank)
+	s.Uint8(&m.chrBank)
+}
+
+func (m *Mapper079) Reset() {
+	m.prgBank = 0
+	m.chrBank = 0
+}
+
+func (m *Mapper079) CPUClock() {
+}
+
+func (m *Mapper079) DebugDisplay(text io.Writer) {
+	plz.Just(fmt.Fprint(text, "Cartridge with Mapper 079\n"))
+	plz.Just(fmt.Fprintf(text, "PRG ROM Size: %d * 16 KB\n", m.cartridge.PrgRomSize))
+	plz.Just(fmt.Fprintf(text, "PRG BANK    : %d \n", m.prgBank))
+	plz.Just(fmt.Fprintf(text, "CHR ROM Size: %d * 8 KB\n", m.cartridge.ChrRomSize))
+	plz.Just(fmt.Fprintf(text, "CHR BANK    : %d \n", m.chrBank))
+}
@@ -0,0 +1,239 @@
+package cartridge
+
+import (
+	"fmt"
+	"github.com/exp625/gones/internal/plz"
+	"github.com/exp625/gones/internal/savestate"
+	"io"
+)
+
+// From NES DEV WIKI https://wiki.nesdev.org/w/index.php?title=VRC7
+
+// The Konami VRC7 with its FM sound chip. The VRC7a (submapper 2) selects the registers with A4, the VRC7b
+// (submapper 1) with A3. Without a submapper, both address lines are used.
+// Used by Lagrange Point and Tiny Toon Adventures 2.
+
+type Mapper085 struct {
+	cartridge *Cartridge
+	// CPU address lines connected to the register select pin
+	registerSelect uint16
+
+	// CPU $6000-$7FFF: 8 KB PRG RAM bank, enabled by $E000
+	prgRam []uint8
+
+	prgBanks [3]uint8
+	chrBanks [8]uint8
+	// Mirroring, sound reset and PRG RAM enable ($E000)
+	control uint8
+	irq     vrcIRQ
+
+	audio vrc7Audio
+}
+
+// NewMapper085 creates the VRC7a or VRC7b
+func NewMapper085(c *Cartridge) *Mapper085 {
+	m := &Mapper085{
+		cartridge: c,
+		prgRam:    make([]uint8, c.Header.PrgRamTotal()),
+	}
+	switch c.Header.Submapper {
+	case 1:
+		m.registerSelect = 0x08
+	case 2:
+		m.registerSelect = 0x10
+	default:
+		m.registerSelect = 0x18
+	}
+	m.audio.reset()
+	return m
+}
+
+func (m *Mapper085) CPUMap(location uint16) uint16 {
+	return location
+}
+
+// register returns the register of the CPU location as $x000 or $x010
+func (m *Mapper085) register(location uint16) uint16 {
+	if location&m.registerSelect != 0 {
+		return location&0xF000 | 0x10
+	}
+	return location & 0xF000
+}
+
+// CPU $6000-$7FFF: 8 KB PRG RAM bank
+// CPU $8000-$9FFF: 8 KB switchable PRG ROM bank
+// CPU $A000-$BFFF: 8 KB switchable PRG ROM bank
+// CPU $C000-$DFFF: 8 KB switchable PRG ROM bank
+// CPU $E000-$FFFF: 8 KB PRG ROM bank, fixed to the last bank
+
+func (m *Mapper085) CPURead(location uint16) uint8 {
+	switch {
+	case 0x6000 <= location && location <= 0x7FFF:
+		if m.control>>7 == 0 {
+			return uint8(location >> 8)
+		}
+		return readRam(m.prgRam, int(location-0x6000), location)
+	case 0x8000 <= location && location <= 0xDFFF:
+		bank := int(m.prgBanks[(location-0x8000)/0x2000])
+		return m.cartridge.PrgRom[(bank*0x2000+int(location&0x1FFF))%len(m.cartridge.PrgRom)]
+	case 0xE000 <= location:
+		return m.cartridge.PrgRom[len(m.cartridge.PrgRom)-0x2000+int(location&0x1FFF)]
+	}
+	// Mapper was not responsible for the location
+	return 0
+}
+
+func (m *Mapper085) CPUWrite(location uint16, data uint8) bool {
+	if 0x6000 <= location && location <= 0x7FFF {
+		if m.control>>7 == 1 {
+			writeRam(m.prgRam, int(location-0x6000), data)
+		}
+		return true
+	}
+	if location < 0x8000 {
+		return false
+	}
+
+	// The sound chip is always selected with A4 and A5
+	switch location & 0xF030 {
+	case 0x9010:
+		m.audio.selectRegister(data)
+		return true
+	case 0x9030:
+		m.audio.write(data)
+		return true
+	}
+
+	switch register := m.register(location); register {
+	case 0x8000:
+		m.prgBanks[0] = data & 0b11_1111
+	case 0x8010:
+		m.prgBanks[1] = data & 0b11_1111
+	case 0x9000:
+		m.prgBanks[2] = data & 0b11_1111
+	case 0xA000, 0xA010, 0xB000, 0xB010, 0xC000, 0xC010, 0xD000, 0xD010:
+		// 1 KB CHR banks
+		m.chrBanks[(register-0xA000)>>12*2+register>>4&0b1] = data
+	case 0xE000:
+		// 7  bit  0
+		// ---- ----
+		// RS.. ..MM
+		// ||     ||
+		// ||     ++- Mirroring (0: vertical; 1: horizontal; 2: one-screen, lower bank; 3: one-screen, upper bank)
+		// |+-------- Silence and reset the sound chip
+		// +--------- PRG RAM enable
+		m.control = data
+		if data&0b0100_0000 != 0 {
+			m.audio.reset()
+		}
+	case 0xE010:
+		m.irq.latch = data
+	case 0xF000:
+		m.irq.writeControl(data)
+	case 0xF010:
+		m.irq.acknowledge()
+	}
+	return true
+}
+
+func (m *Mapper085) CPUClock() {
+}
+
+func (m *Mapper085) CPUCycle() {
+	m.irq.clock()
+	if m.irq.pending {
+		m.cartridge.Bus.IRQ()
+	}
+}
+
+func (m *Mapper085) PPUMap(location uint16) uint16 {
+	if 0x2000 <= location && location <= 0x3EFF {
+		var page uint16
+		switch m.control & 0b11 {
+		case 0:
+			// Vertical mirroring
+			page = location >> 10 & 0b1
+		case 1:
+			// Horizontal mirroring
+			page = location >> 11 & 0b1
+		case 2:
+			page = 0
+		case 3:
+			page = 1
+		}
+		location = 0x2000 + page*0x400 + location%0x400
+	}
+	return location
+}
+
+// chrIndex returns the index into the CHR memory for the PPU location $0000-$1FFF
+func (m *Mapper085) chrIndex(location uint16) int {
+	return (int(m.chrBanks[location/0x400])*0x400 + int(location%0x400)) % len(m.cartridge.ChrRom)
+}
+
+func (m *Mapper085) PPURead(location uint16) uint8 {
+	if location <= 0x1FFF {
+		return m.cartridge.ChrRom[m.chrIndex(location)]
+	}
+	return 0
+}
+
+func (m *Mapper085) PPUWrite(location uint16, data uint8) bool {
+	if location <= 0x1FFF {
+		if m.cartridge.ChrRam {
+			m.cartridge.ChrRom[m.chrIndex(location)] = data
+		}
+		return true
+	}
+	return false
+}
+
+// ClockAudio clocks the FM sound chip, unless it is held in reset
+func (m *Mapper085) ClockAudio() {
+	if m.control&0b0100_0000 == 0 {
+		m.audio.clock()
+	}
+}
+
+func (m *Mapper085) AudioOutput() float64 {
+	return m.audio.output()
+}
+
+func (m *Mapper085) Load(data []uint8) error {
+	return loadRam(m.prgRam, data)
+}
+
+func (m *Mapper085) Save() []uint8 {
+	return m.prgRam
+}
+
+func (m *Mapper085) Serialize(s *savestate.Serializer) {
+	s.Bytes(m.prgRam)
+	s.Bytes(m.prgBanks[:])
+	s.Bytes(m.chrBanks[:])
+	s.Uint8(&m.control)
+	m.irq.serialize(s)
+	m.audio.serialize(s)
+}
+
+func (m *Mapper085) Reset() {
+	m.prgBanks = [3]uint8{}
+	m.chrBanks = [8]uint8{}
+	m.control = 0
+	m.irq.reset()
+	m.audio.reset()
+}
+
+func (m *Mapper085) DebugDisplay(text io.Writer) {
+	plz.Just(fmt.Fprintf(text, "Cartridge with Mapper %03d\n", m.cartridge.Header.Mapper))
+	plz.Just(fmt.Fprintf(text, "PRG ROM Size: %d * 16 KB\n", m.cartridge.PrgRomSize))
+	plz.Just(fmt.Fprintf(text, "PRG Banks   : %v\n", m.prgBanks))
+	plz.Just(fmt.Fprintf(text, "CHR ROM Size: %d * 8 KB\n", m.cartridge.ChrRomSize))
+	plz.Just(fmt.Fprintf(text, "CHR Banks   : %v\n", m.chrBanks))
+	plz.Just(fmt.Fprintf(text, "Control     : %08b\n", m.control))
+	m.irq.debugDisplay(text)
+	for i, c := range m.audio.channels {
+		plz.Just(fmt.Fprintf(text, "FM %d        : Instrument %2d, Volume %2d, Octave %d, F %3d, Key %t\n",
+			i, c.instrument, c.volume, c.block, c.fnum, c.keyOn))
+	}
+}
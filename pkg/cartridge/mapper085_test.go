@@ -0,0 +1,94 @@
+package cartridge_test
+
+import (
+	"testing"
+
+	"github.com/exp625/gones/pkg/apu"
+)
+
+func TestMapper085Banks(t *testing.T) {
+	for _, test := range []struct {
+		submapper uint8
+		// CPU address line of the register select pin
+		line uint16
+	}{{1, 0x08}, {2, 0x10}, {0, 0x08}, {0, 0x10}} {
+		c, _ := newTestCartridge(t, 85, test.submapper, 32, 256)
+		c.CPUWrite(0x8000, 3)
+		c.CPUWrite(0x8000|test.line, 4)
+		c.CPUWrite(0x9000, 5)
+		for _, bank := range []struct {
+			location uint16
+			bank     uint8
+		}{{0x8000, 3}, {0xA000, 4}, {0xC000, 5}, {0xE000, 31}} {
+			if data := c.CPURead(bank.location); data != bank.bank {
+				t.Errorf("submapper %d: expected PRG bank %d at $%04X, got %d", test.submapper, bank.bank, bank.location, data)
+			}
+		}
+		for i := uint16(0); i < 8; i++ {
+			c.CPUWrite(0xA000+i/2*0x1000+i%2*test.line, uint8(0x30+i))
+		}
+		for i := uint16(0); i < 8; i++ {
+			if data := c.PPURead(i * 0x400); data != uint8(0x30+i) {
+				t.Errorf("submapper %d: expected CHR bank $%02X at slot %d, got $%02X", test.submapper, 0x30+i, i, data)
+			}
+		}
+	}
+
+	c, _ := newTestCartridge(t, 85, 2, 16, 8)
+	c.CPUWrite(0x6000, 0x42)
+	if data := c.CPURead(0x6000); data != 0x60 {
+		t.Errorf("expected open bus for disabled PRG RAM, got $%02X", data)
+	}
+	c.CPUWrite(0xE000, 0b1000_0001)
+	c.CPUWrite(0x6000, 0x42)
+	if data := c.CPURead(0x6000); data != 0x42 {
+		t.Errorf("expected PRG RAM, got $%02X", data)
+	}
+	if location := c.PPUMap(0x2800); location != 0x2400 {
+		t.Errorf("expected horizontal mirroring, got $%04X", location)
+	}
+}
+
+func TestMapper085IRQ(t *testing.T) {
+	c, b := newTestCartridge(t, 85, 2, 16, 8)
+	c.CPUWrite(0xE010, 0xF0)
+	// Cycle mode, enabled
+	c.CPUWrite(0xF000, 0b110)
+	if cycle := clockCPU(c, b, 100); cycle != 16 {
+		t.Errorf("expected the IRQ after 16 cycles, got %d", cycle)
+	}
+	c.CPUWrite(0xF010, 0)
+	if cycle := clockCPU(c, b, 10); cycle != -1 {
+		t.Errorf("expected no IRQ after the acknowledgement, got one after %d cycles", cycle)
+	}
+}
+
+func TestMapper085Audio(t *testing.T) {
+	c, _ := newTestCartridge(t, 85, 2, 16, 8)
+	audio := c.Mapper.(apu.ExpansionAudio)
+
+	// Channel 0 with the built-in flute at full volume
+	for _, write := range [][2]uint8{{0x30, 0x40}, {0x10, 0x22}, {0x20, 0b11_1001}} {
+		c.CPUWrite(0x9010, write[0])
+		c.CPUWrite(0x9030, write[1])
+	}
+	var peak float64
+	for cycle := 0; cycle < 36*20000; cycle++ {
+		audio.ClockAudio()
+		if output := audio.AudioOutput(); output > peak {
+			peak = output
+		}
+	}
+	if peak < apu.PulseStep*10 || peak > apu.PulseStep*15 {
+		t.Errorf("expected about the amplitude of an APU pulse, got %f", peak)
+	}
+
+	// The sound chip is silenced and reset by $E000
+	c.CPUWrite(0xE000, 0b0100_0000)
+	for cycle := 0; cycle < 36*10; cycle++ {
+		audio.ClockAudio()
+	}
+	if output := audio.AudioOutput(); output != 0 {
+		t.Errorf("expected silence while the sound chip is reset, got %f", output)
+	}
+}
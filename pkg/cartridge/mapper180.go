@@ -0,0 +1,126 @@
+package cartridge
+
+import (
+	"fmt"
+	"github.com/exp625/gones/internal/plz"
+	"github.com/exp625/gones/internal/savestate"
+	"io"
+)
+
+// From NES DEV WIKI https://wiki.nesdev.org/w/index.php?title=INES_Mapper_180
+
+// UNROM with an AND gate instead of an OR gate, the first bank is fixed and the second bank is switchable.
+// Used by Crazy Climber.
+// Subject to bus conflicts: Yes
+
+type Mapper180 struct {
+	cartridge  *Cartridge
+	bankSelect uint8
+}
+
+func NewMapper180(c *Cartridge) *Mapper180 {
+	return &Mapper180{
+		cartridge: c,
+	}
+}
+
+func (m *Mapper180) CPUMap(location uint16) uint16 {
+	return location
+}
+
+// CPU $8000-$BFFF: 16 KB PRG ROM bank, fixed to the first bank
+// CPU $C000-$FFFF: 16 KB switchable PRG ROM bank
+
+func (m *Mapper180) CPURead(location uint16) uint8 {
+	switch {
+	case 0x8000 <= location && location <= 0xBFFF:
+		return m.cartridge.PrgRom[location-0x8000]
+	case 0xC000 <= location:
+		return m.cartridge.PrgRom[(int(m.bankSelect)*0x4000+int(location-0xC000))%len(m.cartridge.PrgRom)]
+	}
+	// Mapper was not responsible for the location
+	return 0
+}
+
+func (m *Mapper180) CPUWrite(location uint16, data uint8) bool {
+	if location >= 0x8000 {
+		// 7  bit  0
+		// ---- ----
+		// xxxx xPPP
+		//       |||
+		//       +++- Select 16 KB PRG ROM bank for CPU $C000-$FFFF
+		m.bankSelect = busConflict(m, location, data) & 0b111
+		return true
+	}
+	return false
+}
+
+func (m *Mapper180) PPUMap(location uint16) uint16 {
+	if 0x2000 <= location && location <= 0x3EFF {
+		if 0x3000 <= location && location <= 0x3FFF {
+			location -= 0x1000
+		}
+		if m.cartridge.MirrorBit == false {
+			// 1: horizontal mirroring
+			if location-0x2000 < 0x800 {
+				location = 0x2000 + location%0x400
+
+			} else {
+				location = 0x2400 + location%0x400
+			}
+		} else {
+			// 1: vertical mirroring
+			location = 0x2000 + location%0x800
+		}
+	}
+	return location
+}
+
+func (m *Mapper180) PPURead(location uint16) uint8 {
+	if location <= 0x1FFF {
+		return m.cartridge.ChrRom[location]
+	}
+	return 0
+}
+
+func (m *Mapper180) PPUWrite(location uint16, data uint8) bool {
+	if location <= 0x1FFF {
+		if m.cartridge.ChrRam {
+			m.cartridge.ChrRom[location] = data
+		}
+		return true
+	}
+	return false
+}
+
+func (m *Mapper180) Load(data []uint8) error {
+	// No battery backed RAM
+	return loadRam(nil, data)
+}
+
+func (m *Mapper180) Save() []uint8 {
+	return []uint8{}
+}
+
+func (m *Mapper180) Serialize(s *savestate.Serializer) {
+	s.Uint8(&m.bankSelect)
+}
+
+func (m *Mapper180) Reset() {
+	m.bankSelect = 0
+}
+
+func (m *Mapper180) CPUClock() {
+}
+
+func (m *Mapper180) DebugDisplay(text io.Writer) {
+	plz.Just(fmt.Fprint(text, "Cartridge with Mapper 180\n"))
+	plz.Just(fmt.Fprintf(text, "PRG ROM Size: %d * 16 KB\n", m.cartridge.PrgRomSize))
+	plz.Just(fmt.Fprintf(text, "PRG BANK    : %d \n", m.bankSelect))
+	plz.Just(fmt.Fprintf(text, "CHR ROM Size: %d * 8 KB\n", m.cartridge.ChrRomSize))
+	str := "Horizontal "
+	if m.cartridge.MirrorBit {
+		str = "Vertical "
+	}
+	plz.Just(fmt.Fprint(text, "Mirror Mode : ", str, "\n"))
+}
@@ -0,0 +1,155 @@
+package cartridge
+
+import (
+	"fmt"
+	"github.com/exp625/gones/internal/plz"
+	"github.com/exp625/gones/internal/savestate"
+	"io"
+)
+
+// From NES DEV WIKI https://wiki.nesdev.org/w/index.php?title=INES_Mapper_206
+
+// The Namco 108 and the DxROM boards, the predecessor of the MMC3. The banking works like the MMC3 in PRG and CHR mode
+// 0, but there is no IRQ, no PRG RAM and the mirroring is fixed.
+// Subject to bus conflicts: No
+
+type Mapper206 struct {
+	cartridge  *Cartridge
+	bankSelect uint8
+	// R0-R7
+	registers [8]uint8
+}
+
+func NewMapper206(c *Cartridge) *Mapper206 {
+	return &Mapper206{
+		cartridge: c,
+	}
+}
+
+func (m *Mapper206) CPUMap(location uint16) uint16 {
+	return location
+}
+
+// CPU $8000-$9FFF: 8 KB switchable PRG ROM bank (R6)
+// CPU $A000-$BFFF: 8 KB switchable PRG ROM bank (R7)
+// CPU $C000-$FFFF: Two 8 KB PRG ROM banks, fixed to the last two banks
+
+func (m *Mapper206) CPURead(location uint16) uint8 {
+	switch {
+	case 0x8000 <= location && location <= 0xBFFF:
+		bank := int(m.registers[6+(location-0x8000)/0x2000])
+		return m.cartridge.PrgRom[(bank*0x2000+int(location&0x1FFF))%len(m.cartridge.PrgRom)]
+	case 0xC000 <= location:
+		return m.cartridge.PrgRom[len(m.cartridge.PrgRom)-0x4000+int(location-0xC000)]
+	}
+	// Mapper was not responsible for the location
+	return 0
+}
+
+func (m *Mapper206) CPUWrite(location uint16, data uint8) bool {
+	switch {
+	case 0x8000 <= location && location <= 0x9FFF && location%2 == 0:
+		// Bank select ($8000-$9FFE, even)
+		// 7  bit  0
+		// ---- ----
+		// xxxx xRRR
+		//       |||
+		//       +++- Specify which bank register to update on next write to Bank Data register
+		m.bankSelect = data & 0b111
+	case 0x8000 <= location && location <= 0x9FFF:
+		// Bank data ($8001-$9FFF, odd)
+		// 7  bit  0
+		// ---- ----
+		// xxDD DDDD
+		//   || ||||
+		//   ++-++++- New bank value, based on last value written to Bank select register
+		//            R0 and R1 ignore the bottom bit, R6 and R7 ignore the top two bits
+		m.registers[m.bankSelect] = data & 0b11_1111
+	case location < 0x8000:
+		return false
+	}
+	return true
+}
+
+func (m *Mapper206) PPUMap(location uint16) uint16 {
+	if 0x2000 <= location && location <= 0x3EFF {
+		if 0x3000 <= location && location <= 0x3FFF {
+			location -= 0x1000
+		}
+		if m.cartridge.MirrorBit == false {
+			// 1: horizontal mirroring
+			if location-0x2000 < 0x800 {
+				location = 0x2000 + location%0x400
+
+			} else {
+				location = 0x2400 + location%0x400
+			}
+		} else {
+			// 1: vertical mirroring
+			location = 0x2000 + location%0x800
+		}
+	}
+	return location
+}
+
+// chrIndex returns the index into the CHR memory for the PPU location $0000-$1FFF
+//
+// PPU $0000-$07FF: 2 KB switchable CHR bank (R0)
+// PPU $0800-$0FFF: 2 KB switchable CHR bank (R1)
+// PPU $1000-$1FFF: Four 1 KB switchable CHR banks (R2-R5)
+func (m *Mapper206) chrIndex(location uint16) int {
+	var bank int
+	if location < 0x1000 {
+		bank = int(m.registers[location/0x800]&0b11_1110) + int(location/0x400%2)
+	} else {
+		bank = int(m.registers[2+(location-0x1000)/0x400])
+	}
+	return (bank*0x400 + int(location%0x400)) % len(m.cartridge.ChrRom)
+}
+
+func (m *Mapper206) PPURead(location uint16) uint8 {
+	if location <= 0x1FFF {
+		return m.cartridge.ChrRom[m.chrIndex(location)]
+	}
+	return 0
+}
+
+func (m *Mapper206) PPUWrite(location uint16, data uint8) bool {
+	if location <= 0x1FFF {
+		if m.cartridge.ChrRam {
+			m.cartridge.ChrRom[m.chrIndex(location)] = data
+		}
+		return true
+	}
+	return false
+}
+
+func (m *Mapper206) Load(data []uint8) error {
+	// No battery backed RAM
+	return loadRam(nil, data)
+}
+
+func (m *Mapper206) Save() []uint8 {
+	return []uint8{}
+}
+
+func (m *Mapper206) Serialize(s *savestate.Serializer) {
+	s.Uint8(&m.bankSelect)
+	s.Bytes(m.registers[:])
+}
+
+func (m *Mapper206) Reset() {
+	m.bankSelect = 0
+	m.registers = [8]uint8{}
+}
+
+func (m *Mapper206) CPUClock() {
+}
+
+func (m *Mapper206) DebugDisplay(text io.Writer) {
+	plz.Just(fmt.Fprint(text, "Cartridge with Mapper 206\n"))
+	plz.Just(fmt.Fprintf(text, "PRG ROM Size: %d * 16 KB\n", m.cartridge.PrgRomSize))
+	plz.Just(fmt.Fprintf(text, "PRG BANKS   : %v \n", m.registers[6:]))
+	plz.Just(fmt.Fprintf(text, "CHR ROM Size: %d * 8 KB\n", m.cartridge.ChrRomSize))
+	plz.Just(fmt.Fprintf(text, "CHR BANKS   : %v \n", m.registers[:6]))
+}
@@ -0,0 +1,141 @@
+package cartridge
+
+import (
+	"fmt"
+	"github.com/exp625/gones/internal/plz"
+	"github.com/exp625/gones/internal/savestate"
+	"io"
+)
+
+// From NES DEV WIKI https://wiki.nesdev.org/w/index.php?title=INES_Mapper_232
+
+// Camerica Quattro multicarts with four blocks of 64 KB. The Aladdin Deck Enhancer (submapper 1) swaps the bits of
+// the block.
+// Subject to bus conflicts: No
+
+type Mapper232 struct {
+	cartridge *Cartridge
+	block     uint8
+	page      uint8
+}
+
+func NewMapper232(c *Cartridge) *Mapper232 {
+	return &Mapper232{
+		cartridge: c,
+	}
+}
+
+func (m *Mapper232) CPUMap(location uint16) uint16 {
+	return location
+}
+
+// CPU $8000-$BFFF: 16 KB switchable PRG ROM bank in the selected block
+// CPU $C000-$FFFF: 16 KB PRG ROM bank, fixed to the last bank of the selected block
+
+func (m *Mapper232) CPURead(location uint16) uint8 {
+	switch {
+	case 0x8000 <= location && location <= 0xBFFF:
+		bank := int(m.block)*4 + int(m.page)
+		return m.cartridge.PrgRom[(bank*0x4000+int(location-0x8000))%len(m.cartridge.PrgRom)]
+	case 0xC000 <= location:
+		bank := int(m.block)*4 + 3
+		return m.cartridge.PrgRom[(bank*0x4000+int(location-0xC000))%len(m.cartridge.PrgRom)]
+	}
+	// Mapper was not responsible for the location
+	return 0
+}
+
+func (m *Mapper232) CPUWrite(location uint16, data uint8) bool {
+	switch {
+	case 0x8000 <= location && location <= 0xBFFF:
+		// Block select ($8000-$BFFF)
+		// 7  bit  0
+		// ---- ----
+		// xxxB Bxxx
+		//    | |
+		//    +-+---- Select 64 KB block
+		m.block = data >> 3 & 0b11
+		if m.cartridge.Header.Submapper == 1 {
+			m.block = m.block>>1 | m.block&0b1<<1
+		}
+	case 0xC000 <= location:
+		// Page select ($C000-$FFFF)
+		// 7  bit  0
+		// ---- ----
+		// xxxx xxPP
+		//        ||
+		//        ++- Select 16 KB PRG ROM bank for CPU $8000-$BFFF in the block
+		m.page = data & 0b11
+	default:
+		return false
+	}
+	return true
+}
+
+func (m *Mapper232) PPUMap(location uint16) uint16 {
+	if 0x2000 <= location && location <= 0x3EFF {
+		if 0x3000 <= location && location <= 0x3FFF {
+			location -= 0x1000
+		}
+		if m.cartridge.MirrorBit == false {
+			// 1: horizontal mirroring
+			if location-0x2000 < 0x800 {
+				location = 0x2000 + location%0x400
+
+			} else {
+				location = 0x2400 + location%0x400
+			}
+		} else {
+			// 1: vertical mirroring
+			location = 0x2000 + location%0x800
+		}
+	}
+	return location
+}
+
+func (m *Mapper232) PPURead(location uint16) uint8 {
+	if location <= 0x1FFF {
+		return m.cartridge.ChrRom[location]
+	}
+	return 0
+}
+
+func (m *Mapper232) PPUWrite(location uint16, data uint8) bool {
+	if location <= 0x1FFF {
+		if m.cartridge.ChrRam {
+			m.cartridge.ChrRom[location] = data
+		}
+		return true
+	}
+	return false
+}
+
+func (m *Mapper232) Load(data []uint8) error {
+	// No battery backed RAM
+	return loadRam(nil, data)
+}
+
+func (m *Mapper232) Save() []uint8 {
+	return []uint8{}
+}
+
+func (m *Mapper232) Serialize(s *savestate.Serializer) {
+	s.Uint8(&m.block)
+	s.Uint8(&m.page)
+}
+
+func (m *Mapper232) Reset() {
+	m.block = 0
+	m.page = 0
+}
+
+func (m *Mapper232) CPUClock() {
+}
+
+func (m *Mapper232) DebugDisplay(text io.Writer) {
+	plz.Just(fmt.Fprint(text, "Cartridge with Mapper 232\n"))
+	plz.Just(fmt.Fprintf(text, "PRG ROM Size: %d * 16 KB\n", m.cartridge.PrgRomSize))
+	plz.Just(fmt.Fprintf(text, "PRG BLOCK   : %d \n", m.block))
+	plz.Just(fmt.Fprintf(text, "PRG PAGE    : %d \n", m.page))
+	plz.Just(fmt.Fprintf(text, "CHR ROM Size: %d * 8 KB\n", m.cartridge.ChrRomSize))
+}
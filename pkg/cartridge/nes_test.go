@@ -0,0 +1,111 @@
+package cartridge_test
+
+import (
+	"testing"
+
+	"github.com/exp625/gones/pkg/cartridge"
+	"github.com/exp625/gones/pkg/nes"
+)
+
+// testROM returns a ROM with the header. The first byte of every 8 KB PRG ROM bank and every byte of a 1 KB CHR ROM
+// bank hold the number of the bank. The last PRG ROM bank contains an endless loop at $E001, that all vectors point
+// to, so the CPU never touches the registers of the mapper.
+func testROM(header []byte, prgBanks int, chrBanks int) []byte {
+	prg := make([]byte, prgBanks*0x2000)
+	for i := range prg {
+		prg[i] = 0xEA // NOP
+	}
+	for bank := 0; bank < prgBanks; bank++ {
+		prg[bank*0x2000] = uint8(bank)
+	}
+	last := prg[len(prg)-0x2000:]
+	copy(last[0x0001:], []byte{0x4C, 0x01, 0xE0}) // JMP $E001
+	copy(last[0x1FFA:], []byte{0x01, 0xE0, 0x01, 0xE0, 0x01, 0xE0})
+
+	chr := make([]byte, chrBanks*0x400)
+	for i := range chr {
+		chr[i] = uint8(i / 0x400)
+	}
+	return append(append(header, prg...), chr...)
+}
+
+// newTestNES inserts a cartridge with the mapper and an iNES header into a NES, see testROM
+func newTestNES(t *testing.T, mapper uint8, prgBanks int, chrBanks int) *nes.NES {
+	t.Helper()
+	header := make([]byte, cartridge.HeaderSize)
+	copy(header, "NES\x1a")
+	header[4] = uint8(prgBanks / 2)
+	header[5] = uint8(chrBanks / 8)
+	header[6] = mapper << 4
+	header[7] = mapper & 0xF0
+
+	n := nes.New(1.0/5369318.0, 1.0/44100)
+	c, err := cartridge.Load(testROM(header, prgBanks, chrBanks), n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.InsertCartridge(c)
+	return n
+}
+
+// testBus records the IRQs of a cartridge
+type testBus struct {
+	*nes.NES
+	irq bool
+}
+
+func (b *testBus) IRQ() {
+	b.irq = true
+}
+
+// newTestCartridge loads a cartridge with the mapper and a NES 2.0 header with 8 KB PRG RAM, see testROM. The
+// cartridge is not inserted into a NES and has to be clocked by the test.
+func newTestCartridge(t *testing.T, mapper uint16, submapper uint8, prgBanks int, chrBanks int) (*cartridge.Cartridge, *testBus) {
+	t.Helper()
+	header := make([]byte, cartridge.HeaderSize)
+	copy(header, "NES\x1a")
+	header[4] = uint8(prgBanks / 2)
+	header[5] = uint8(chrBanks / 8)
+	header[6] = uint8(mapper&0x0F) << 4
+	header[7] = uint8(mapper&0xF0) | 0b1000
+	header[8] = submapper<<4 | uint8(mapper>>8)
+	header[10] = 0x07
+
+	b := &testBus{NES: nes.New(1.0/5369318.0, 1.0/44100)}
+	c, err := cartridge.Load(testROM(header, prgBanks, chrBanks), b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Reset()
+	return c, b
+}
+
+// runUntil clocks the NES until the condition is met or the timeout in frames is reached
+func runUntil(t *testing.T, n *nes.NES, frames uint64, condition func() bool) {
+	t.Helper()
+	end := n.PPU.FrameCount + frames
+	for !condition() {
+		if n.PPU.FrameCount >= end {
+			t.Fatal("timeout")
+		}
+		n.Clock()
+	}
+}
+
+// clockCPU clocks the cartridge for the number of CPU cycles and returns the cycle of the first IRQ, or -1 if there
+// was no IRQ
+func clockCPU(c *cartridge.Cartridge, b *testBus, cycles int) int {
+	b.irq = false
+	for cycle := 1; cycle <= cycles; cycle++ {
+		for i := 0; i < 3; i++ {
+			c.CPUClock()
+		}
+		if cycler, ok := c.Mapper.(cartridge.CPUCycler); ok {
+			cycler.CPUCycle()
+		}
+		if b.irq {
+			return cycle
+		}
+	}
+	return -1
+}
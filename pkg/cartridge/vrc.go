@@ -0,0 +1,92 @@
+package cartridge
+
+import (
+	"fmt"
+	"github.com/exp625/gones/internal/plz"
+	"github.com/exp625/gones/internal/savestate"
+	"io"
+)
+
+// From NES DEV WIKI https://wiki.nesdev.org/w/index.php?title=VRC_IRQ
+
+// vrcIRQ is the IRQ counter of the Konami VRC4, VRC6 and VRC7. The 8-bit counter counts up to $FF, then it is reloaded
+// from the latch and an IRQ is triggered. In scanline mode, a prescaler divides the CPU clock by 113.667 to
+// approximate the length of a scanline. In cycle mode, the counter is clocked every CPU cycle.
+type vrcIRQ struct {
+	latch   uint8
+	counter uint8
+	// The prescaler counts down by 3 every CPU cycle and is reloaded with 341, the number of PPU dots of a scanline
+	prescaler int
+
+	enabled        bool
+	enableAfterAck bool
+	cycleMode      bool
+	pending        bool
+}
+
+// writeControl writes the IRQ control register
+func (i *vrcIRQ) writeControl(data uint8) {
+	// 7  bit  0
+	// ---- ----
+	// xxxx xMEA
+	//       |||
+	//       ||+- IRQ Enable after acknowledgement (see IRQ Acknowledge)
+	//       |+-- IRQ Enable (1 = enabled)
+	//       +--- IRQ Mode (1 = cycle mode, 0 = scanline mode)
+	i.enableAfterAck = data&0b001 != 0
+	i.enabled = data&0b010 != 0
+	i.cycleMode = data&0b100 != 0
+	if i.enabled {
+		i.counter = i.latch
+		i.prescaler = 341
+	}
+	i.pending = false
+}
+
+// acknowledge acknowledges the IRQ and copies the enable after acknowledgement flag to the enable flag
+func (i *vrcIRQ) acknowledge() {
+	i.pending = false
+	i.enabled = i.enableAfterAck
+}
+
+// clock is called on every CPU cycle
+func (i *vrcIRQ) clock() {
+	if !i.enabled {
+		return
+	}
+	if !i.cycleMode {
+		i.prescaler -= 3
+		if i.prescaler > 0 {
+			return
+		}
+		i.prescaler += 341
+	}
+	if i.counter == 0xFF {
+		i.counter = i.latch
+		i.pending = true
+	} else {
+		i.counter++
+	}
+}
+
+func (i *vrcIRQ) reset() {
+	*i = vrcIRQ{}
+}
+
+func (i *vrcIRQ) serialize(s *savestate.Serializer) {
+	s.Uint8(&i.latch)
+	s.Uint8(&i.counter)
+	s.Int(&i.prescaler)
+	s.Bool(&i.enabled)
+	s.Bool(&i.enableAfterAck)
+	s.Bool(&i.cycleMode)
+	s.Bool(&i.pending)
+}
+
+func (i *vrcIRQ) debugDisplay(text io.Writer) {
+	mode := "Scanline"
+	if i.cycleMode {
+		mode = "Cycle"
+	}
+	plz.Just(fmt.Fprintf(text, "IRQ: %t, Mode: %s, Latch: %d, Counter: %d\n", i.enabled, mode, i.latch, i.counter))
+}
@@ -0,0 +1,379 @@
+package cartridge
+
+import (
+	"github.com/exp625/gones/internal/savestate"
+	"github.com/exp625/gones/pkg/apu"
+	"math"
+)
+
+// From NES DEV WIKI https://wiki.nesdev.org/w/index.php?title=VRC7_audio
+
+// The sound chip of the VRC7 is derived from the Yamaha YM2413 (OPLL). It has six two-operator FM channels and 15
+// built-in instruments, but no rhythm mode. The output is computed like on the chip with a log-sin and an exponential
+// table: the attenuations of the operator are added in the logarithmic domain and converted once per sample.
+
+// vrc7Instruments are the built-in instruments of the VRC7. Instrument 0 is the custom instrument in registers
+// $00-$07.
+//
+// Byte 0: Modulator AM, vibrato, sustained envelope, key scale rate (KSR) and multiplier
+// Byte 1: Carrier AM, vibrato, sustained envelope, key scale rate (KSR) and multiplier
+// Byte 2: Modulator key scale level (KSL) and total level
+// Byte 3: Carrier key scale level (KSL), carrier and modulator half-wave rectification and feedback
+// Byte 4: Modulator attack and decay rate
+// Byte 5: Carrier attack and decay rate
+// Byte 6: Modulator sustain level and release rate
+// Byte 7: Carrier sustain level and release rate
+var vrc7Instruments = [16][8]uint8{
+	{},
+	{0x03, 0x21, 0x05, 0x06, 0xE8, 0x81, 0x42, 0x27},
+	{0x13, 0x41, 0x14, 0x0D, 0xD8, 0xF6, 0x23, 0x12},
+	{0x11, 0x11, 0x08, 0x08, 0xFA, 0xB2, 0x20, 0x12},
+	{0x31, 0x61, 0x0C, 0x07, 0xA8, 0x64, 0x61, 0x27},
+	{0x32, 0x21, 0x1E, 0x06, 0xE1, 0x76, 0x01, 0x28},
+	{0x02, 0x01, 0x06, 0x00, 0xA3, 0xE2, 0xF4, 0xF4},
+	{0x21, 0x61, 0x1D, 0x07, 0x82, 0x81, 0x11, 0x07},
+	{0x23, 0x21, 0x22, 0x17, 0xA2, 0x72, 0x01, 0x17},
+	{0x35, 0x11, 0x25, 0x00, 0x40, 0x73, 0x72, 0x01},
+	{0xB5, 0x01, 0x0F, 0x0F, 0xA8, 0xA5, 0x51, 0x02},
+	{0x17, 0xC1, 0x24, 0x07, 0xF8, 0xF8, 0x22, 0x12},
+	{0x71, 0x23, 0x11, 0x06, 0x65, 0x74, 0x18, 0x16},
+	{0x01, 0x02, 0xD3, 0x05, 0xC9, 0x95, 0x03, 0x02},
+	{0x61, 0x63, 0x0C, 0x00, 0x94, 0xC0, 0x33, 0xF6},
+	{0x21, 0x72, 0x0D, 0x00, 0xC1, 0xD5, 0x56, 0x06},
+}
+
+// The chip computes one sample every 72 clocks of its 3.58 MHz oscillator, every 36 CPU cycles
+const opllSampleCycles = 36
+
+// opllLogSin is the quarter of a sine wave as attenuation in 1/256 of a power of two, like the ROM of the chip
+var opllLogSin = func() [256]int32 {
+	var table [256]int32
+	for i := range table {
+		table[i] = int32(math.Round(-math.Log2(math.Sin((float64(i)+0.5)*math.Pi/512)) * 256))
+	}
+	return table
+}()
+
+// opllExp converts the fraction of an attenuation back to a linear value, like the ROM of the chip
+var opllExp = func() [256]int32 {
+	var table [256]int32
+	for i := range table {
+		table[i] = int32(math.Round((math.Pow(2, float64(i)/256) - 1) * 1024))
+	}
+	return table
+}()
+
+// opllMultipliers are the frequency multipliers times two
+var opllMultipliers = [16]uint32{1, 2, 4, 6, 8, 10, 12, 14, 16, 18, 20, 20, 24, 24, 30, 30}
+
+// opllKeyScaleLevels are the attenuations of the key scale level in 0.375 dB for the upper 4 bits of the frequency in
+// the highest octave
+var opllKeyScaleLevels = [16]int32{0, 48, 64, 74, 80, 86, 90, 94, 96, 100, 102, 104, 106, 108, 110, 112}
+
+// opllEnvelopeIncrements are the increments of the envelope in 8 consecutive steps for the lower 2 bits of the rate
+var opllEnvelopeIncrements = [4][8]int32{
+	{0, 1, 0, 1, 0, 1, 0, 1},
+	{0, 1, 0, 1, 1, 1, 0, 1},
+	{0, 1, 1, 1, 0, 1, 1, 1},
+	{0, 1, 1, 1, 1, 1, 1, 1},
+}
+
+// opllVibrato is the frequency deviation of the vibrato in 1/256 of the frequency
+var opllVibrato = [8]int32{0, 1, 2, 1, 0, -1, -2, -1}
+
+// States of the envelope generator
+const (
+	opllAttack = iota
+	opllDecay
+	opllSustain
+	opllRelease
+)
+
+// The envelope ranges from 0 (loudest) to 127 (silent) in steps of 0.375 dB
+const opllSilent = 127
+
+// opllSlot is one operator of a channel, the modulator or the carrier
+type opllSlot struct {
+	// 19-bit phase, the upper 10 bits are one cycle of the wave
+	phase    uint32
+	state    uint8
+	envelope int32
+	// Last two outputs, for the feedback of the modulator
+	outputs [2]int32
+}
+
+// opllChannel is one of the six channels, a modulator and a carrier
+type opllChannel struct {
+	fnum       uint16
+	block      uint8
+	sustain    bool
+	keyOn      bool
+	instrument uint8
+	volume     uint8
+	slots      [2]opllSlot
+}
+
+type vrc7Audio struct {
+	register uint8
+	custom   [8]uint8
+	channels [6]opllChannel
+
+	// CPU cycles since the last sample
+	divider uint8
+	// Counts the samples for the envelope generators and the LFOs
+	counter uint32
+	// Last sample
+	sample int32
+}
+
+// selectRegister selects the register for the next write ($9010)
+func (a *vrc7Audio) selectRegister(data uint8) {
+	a.register = data
+}
+
+// write writes to the selected register ($9030)
+//
+// $00-$07: Custom instrument
+// $10-$15: Lower 8 bits of the frequency of channel 0-5
+// $20-$25: Sustain (bit 5), key on (bit 4), octave (bits 1-3) and the high bit of the frequency of channel 0-5
+// $30-$35: Instrument (bits 4-7) and volume (bits 0-3) of channel 0-5
+func (a *vrc7Audio) write(data uint8) {
+	switch {
+	case a.register <= 0x07:
+		a.custom[a.register] = data
+	case 0x10 <= a.register && a.register <= 0x15:
+		c := &a.channels[a.register-0x10]
+		c.fnum = c.fnum&0x100 | uint16(data)
+	case 0x20 <= a.register && a.register <= 0x25:
+		c := &a.channels[a.register-0x20]
+		c.fnum = c.fnum&0xFF | uint16(data&0b1)<<8
+		c.block = data >> 1 & 0b111
+		c.sustain = data&0b10_0000 != 0
+		keyOn := data&0b1_0000 != 0
+		if keyOn && !c.keyOn {
+			for i := range c.slots {
+				c.slots[i].phase = 0
+				c.slots[i].state = opllAttack
+			}
+		} else if !keyOn && c.keyOn {
+			// Only the carrier is released, the modulator keeps its envelope
+			c.slots[1].state = opllRelease
+		}
+		c.keyOn = keyOn
+	case 0x30 <= a.register && a.register <= 0x35:
+		c := &a.channels[a.register-0x30]
+		c.instrument = data >> 4
+		c.volume = data & 0x0F
+	}
+}
+
+// instrument returns the instrument of the channel
+func (a *vrc7Audio) instrument(c *opllChannel) *[8]uint8 {
+	if c.instrument == 0 {
+		return &a.custom
+	}
+	return &vrc7Instruments[c.instrument]
+}
+
+// clock is called on every CPU cycle and computes a new sample every 36 cycles
+func (a *vrc7Audio) clock() {
+	a.divider++
+	if a.divider < opllSampleCycles {
+		return
+	}
+	a.divider = 0
+	a.counter++
+	a.sample = 0
+	for i := range a.channels {
+		a.sample += a.clockChannel(&a.channels[i])
+	}
+}
+
+// clockChannel advances the channel by one sample and returns the output of the carrier
+func (a *vrc7Audio) clockChannel(c *opllChannel) int32 {
+	instrument := a.instrument(c)
+	modulator, carrier := &c.slots[0], &c.slots[1]
+
+	// The modulator is fed back into itself
+	var feedback int32
+	if fb := instrument[3] & 0b111; fb != 0 {
+		feedback = (modulator.outputs[0] + modulator.outputs[1]) >> (9 - fb)
+	}
+	modulation := a.clockSlot(c, modulator, instrument[0], instrument[2]&0b11_1111, instrument[2]>>6,
+		instrument[3]&0b1000 != 0, instrument[4], instrument[6], feedback)
+	modulator.outputs[1] = modulator.outputs[0]
+	modulator.outputs[0] = modulation
+
+	// The carrier is phase modulated by the modulator, a full output of 4095 shifts it by 4 cycles. It uses the volume
+	// of the channel instead of the total level.
+	return a.clockSlot(c, carrier, instrument[1], c.volume<<2, instrument[3]>>6,
+		instrument[3]&0b1_0000 != 0, instrument[5], instrument[7], modulation)
+}
+
+// clockSlot advances the phase and the envelope of a slot and returns its output in the range of -4095 to 4095
+//
+// flags: AM, vibrato, sustained envelope, KSR and multiplier (byte 0 and 1 of the instrument)
+// level: Total level in 0.75 dB
+// ksl: Key scale level
+// rectified: Half-wave rectification
+// rates: Attack and decay rate
+// release: Sustain level and release rate
+// modulation: Phase offset in 1/1024 of a cycle
+func (a *vrc7Audio) clockSlot(c *opllChannel, s *opllSlot, flags uint8, level uint8, ksl uint8, rectified bool,
+	rates uint8, release uint8, modulation int32) int32 {
+	// Phase generator
+	fnum := int32(c.fnum)
+	if flags&0b0100_0000 != 0 {
+		// Vibrato, a triangle of about 6.1 Hz and 14 cents
+		fnum += fnum * opllVibrato[a.counter>>10&0b111] / 256
+	}
+	s.phase = (s.phase + uint32(fnum<<c.block)*opllMultipliers[flags&0x0F]/2) & 0x7FFFF
+
+	// Envelope generator
+	keyScale := int32(c.block)<<1 | int32(c.fnum>>8)
+	if flags&0b1_0000 == 0 {
+		keyScale >>= 2
+	}
+	rate := func(r uint8) int32 {
+		if r == 0 {
+			return 0
+		}
+		rate := int32(r)*4 + keyScale
+		if rate > 63 {
+			rate = 63
+		}
+		return rate
+	}
+	switch s.state {
+	case opllAttack:
+		if rates>>4 == 15 {
+			s.envelope = 0
+		} else {
+			for i := a.envelopeIncrement(rate(rates >> 4)); i > 0 && s.envelope > 0; i-- {
+				s.envelope -= s.envelope>>3 + 1
+			}
+		}
+		if s.envelope <= 0 {
+			s.envelope = 0
+			s.state = opllDecay
+		}
+	case opllDecay:
+		s.envelope += a.envelopeIncrement(rate(rates & 0x0F))
+		if sustainLevel := int32(release>>4) * 8; s.envelope >= sustainLevel {
+			s.envelope = sustainLevel
+			s.state = opllSustain
+		}
+	case opllSustain:
+		// Sustained envelopes hold the sustain level, percussive envelopes decay with the release rate
+		if flags&0b10_0000 == 0 {
+			s.envelope += a.envelopeIncrement(rate(release & 0x0F))
+		}
+	case opllRelease:
+		r := uint8(7)
+		switch {
+		case c.sustain:
+			r = 5
+		case flags&0b10_0000 != 0:
+			r = release & 0x0F
+		}
+		s.envelope += a.envelopeIncrement(rate(r))
+	}
+	if s.envelope > opllSilent {
+		s.envelope = opllSilent
+	}
+
+	// Total attenuation in 0.375 dB
+	attenuation := s.envelope + int32(level)*2
+	if ksl != 0 {
+		// 1.5, 3 or 6 dB per octave
+		if keyScaleLevel := opllKeyScaleLevels[c.fnum>>5] - int32(7-c.block)*16; keyScaleLevel > 0 {
+			attenuation += keyScaleLevel >> (3 - ksl)
+		}
+	}
+	if flags&0b1000_0000 != 0 {
+		// Tremolo, a triangle of about 3.7 Hz and 4.8 dB
+		step := int32(a.counter >> 9 % 26)
+		if step > 13 {
+			step = 26 - step
+		}
+		attenuation += step
+	}
+	if attenuation > 0xFF {
+		attenuation = 0xFF
+	}
+
+	// Look up the wave in the log-sin table and convert it to a linear value
+	index := int32(s.phase>>9) + modulation
+	negative := index&0x200 != 0
+	if negative && rectified {
+		return 0
+	}
+	quarter := index & 0xFF
+	if index&0x100 != 0 {
+		quarter = 0xFF - quarter
+	}
+	log := opllLogSin[quarter] + attenuation<<4
+	output := (opllExp[0xFF-log&0xFF] + 1024) << 1 >> (log >> 8)
+	if log>>8 > 31 {
+		output = 0
+	}
+	if negative {
+		return -output
+	}
+	return output
+}
+
+// envelopeIncrement returns how much the envelope changes in the current sample for the rate of 0-63
+func (a *vrc7Audio) envelopeIncrement(rate int32) int32 {
+	if rate < 4 {
+		return 0
+	}
+	shift := 13 - rate>>2
+	if shift < 0 {
+		return opllEnvelopeIncrements[rate&0b11][a.counter&0b111] << -shift
+	}
+	if a.counter&(1<<shift-1) != 0 {
+		return 0
+	}
+	return opllEnvelopeIncrements[rate&0b11][a.counter>>shift&0b111]
+}
+
+// output returns the last sample. A channel at full volume is about as loud as an APU pulse.
+func (a *vrc7Audio) output() float64 {
+	return float64(a.sample) * apu.PulseStep * 15 / 4096
+}
+
+func (a *vrc7Audio) reset() {
+	*a = vrc7Audio{}
+	for i := range a.channels {
+		for j := range a.channels[i].slots {
+			a.channels[i].slots[j].state = opllRelease
+			a.channels[i].slots[j].envelope = opllSilent
+		}
+	}
+}
+
+func (a *vrc7Audio) serialize(s *savestate.Serializer) {
+	s.Uint8(&a.register)
+	s.Bytes(a.custom[:])
+	for i := range a.channels {
+		c := &a.channels[i]
+		s.Uint16(&c.fnum)
+		s.Uint8(&c.block)
+		s.Bool(&c.sustain)
+		s.Bool(&c.keyOn)
+		s.Uint8(&c.instrument)
+		s.Uint8(&c.volume)
+		for j := range c.slots {
+			slot := &c.slots[j]
+			s.Uint32(&slot.phase)
+			s.Uint8(&slot.state)
+			s.Int32(&slot.envelope)
+			s.Int32(&slot.outputs[0])
+			s.Int32(&slot.outputs[1])
+		}
+	}
+	s.Uint8(&a.divider)
+	s.Uint32(&a.counter)
+	s.Int32(&a.sample)
+}
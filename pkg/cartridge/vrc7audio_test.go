@@ -0,0 +1,382 @@
+package cartridge
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Patch with a silent modulator and a carrier with instant attack and a sustained envelope, the carrier outputs a
+// pure sine
+var vrc7Sine = [8]uint8{0x01, 0x21, 0x3F, 0x00, 0x00, 0xF0, 0x00, 0x0F}
+
+// vrc7Write writes a register of the sound chip
+func vrc7Write(a *vrc7Audio, register uint8, data uint8) {
+	a.selectRegister(register)
+	a.write(data)
+}
+
+// vrc7Samples returns the next samples of the sound chip
+func vrc7Samples(a *vrc7Audio, n int) []int32 {
+	samples := make([]int32, n)
+	for i := range samples {
+		for cycle := 0; cycle < opllSampleCycles; cycle++ {
+			a.clock()
+		}
+		samples[i] = a.sample
+	}
+	return samples
+}
+
+// vrc7KeyOn plays the custom instrument on channel 0 with the volume
+func vrc7KeyOn(a *vrc7Audio, patch [8]uint8, fnum uint16, block uint8, volume uint8) {
+	a.reset()
+	for i, data := range patch {
+		vrc7Write(a, uint8(i), data)
+	}
+	vrc7Write(a, 0x30, volume)
+	vrc7Write(a, 0x10, uint8(fnum))
+	vrc7Write(a, 0x20, 0b1_0000|block<<1|uint8(fnum>>8))
+}
+
+func TestOPLLTables(t *testing.T) {
+	// Values of the ROMs of the YM2413
+	for i, expected := range map[int]int32{0: 2137, 1: 1731, 2: 1543, 3: 1419, 128: 127, 255: 0} {
+		if opllLogSin[i] != expected {
+			t.Errorf("expected log-sin %d at %d, got %d", expected, i, opllLogSin[i])
+		}
+	}
+	for i, expected := range map[int]int32{0: 0, 1: 3, 128: 424, 255: 1018} {
+		if opllExp[i] != expected {
+			t.Errorf("expected exp %d at %d, got %d", expected, i, opllExp[i])
+		}
+	}
+}
+
+func TestVRC7Sine(t *testing.T) {
+	var a vrc7Audio
+	// The phase increments by 4096 of 2^19 per sample, one cycle takes 128 samples
+	vrc7KeyOn(&a, vrc7Sine, 256, 4, 0)
+	for i, sample := range vrc7Samples(&a, 256) {
+		index := float64((i + 1) * 8 % 1024)
+		reference := 4096 * math.Sin(2*math.Pi*(index+0.5)/1024)
+		if math.Abs(float64(sample)-reference) > 32 {
+			t.Fatalf("expected %.0f at sample %d, got %d", reference, i, sample)
+		}
+	}
+
+	// Every step of the volume is 3 dB
+	peak := func(volume uint8) float64 {
+		vrc7KeyOn(&a, vrc7Sine, 256, 4, volume)
+		var peak int32
+		for _, sample := range vrc7Samples(&a, 128) {
+			if sample > peak {
+				peak = sample
+			}
+		}
+		return float64(peak)
+	}
+	if ratio := peak(2) / peak(0); math.Abs(ratio-0.5) > 0.01 {
+		t.Errorf("expected volume 2 at half of volume 0, got %f", ratio)
+	}
+}
+
+func TestVRC7Frequency(t *testing.T) {
+	var a vrc7Audio
+	// 440 Hz
+	fnum, block := uint16(290), uint8(4)
+	vrc7KeyOn(&a, vrc7Sine, fnum, block, 0)
+	samples := vrc7Samples(&a, 49716)
+	crossings := 0
+	for i := 1; i < len(samples); i++ {
+		if samples[i-1] < 0 && samples[i] >= 0 {
+			crossings++
+		}
+	}
+	expected := 49716 * float64(fnum<<block) / (1 << 19)
+	if math.Abs(float64(crossings)-expected) > 2 {
+		t.Errorf("expected %.1f Hz, got %d Hz", expected, crossings)
+	}
+}
+
+func TestVRC7Release(t *testing.T) {
+	var a vrc7Audio
+	vrc7KeyOn(&a, vrc7Sine, 256, 4, 0)
+	vrc7Samples(&a, 128)
+
+	// Key off releases the carrier with the release rate 15
+	vrc7Write(&a, 0x20, 0b000_1000)
+	var peak int32
+	for _, sample := range vrc7Samples(&a, 128) {
+		if sample > peak {
+			peak = sample
+		}
+	}
+	if peak == 0 || peak >= 4000 {
+		t.Errorf("expected the envelope to decay, got a peak of %d", peak)
+	}
+	for _, sample := range vrc7Samples(&a, 1024)[896:] {
+		if sample > 32 || sample < -32 {
+			t.Fatalf("expected silence after the release, got %d", sample)
+		}
+	}
+}
+
+// opllModel computes the output of a custom patch with a sustained envelope at its total level in floating point. It is
+// not a reference, it only checks that the integer pipeline of the core follows the intended formula: the modulator
+// output of up to ±4096 shifts the phase of the carrier in 1/1024 of a cycle, the sum of the last two modulator outputs
+// shifted right by 9 - feedback shifts the phase of the modulator.
+func opllModel(patch [8]uint8, fnum uint16, block uint8, volume uint8, n int) []float64 {
+	multiplier := func(flags uint8) float64 {
+		return float64(opllMultipliers[flags&0x0F]) / 2
+	}
+	// Each step of the total level is 0.75 dB, each step of the volume 3 dB, about a 1/8 and 1/2 of a power of two
+	modulatorAmplitude := 4096 * math.Pow(2, -float64(patch[2]&0x3F)/8)
+	carrierAmplitude := 4096 * math.Pow(2, -float64(volume)/2)
+	feedback := patch[3] & 0b111
+
+	var outputs [2]float64
+	samples := make([]float64, n)
+	for i := range samples {
+		modulatorPhase := math.Floor(math.Mod(float64(i+1)*float64(uint32(fnum)<<block)*multiplier(patch[0]), 1<<19) / 512)
+		carrierPhase := math.Floor(math.Mod(float64(i+1)*float64(uint32(fnum)<<block)*multiplier(patch[1]), 1<<19) / 512)
+		var offset float64
+		if feedback != 0 {
+			offset = math.Floor((outputs[0] + outputs[1]) / math.Pow(2, float64(9-feedback)))
+		}
+		modulation := math.Round(modulatorAmplitude * math.Sin(2*math.Pi*(modulatorPhase+offset+0.5)/1024))
+		outputs[1], outputs[0] = outputs[0], modulation
+		samples[i] = carrierAmplitude * math.Sin(2*math.Pi*(carrierPhase+modulation+0.5)/1024)
+	}
+	return samples
+}
+
+func TestVRC7Modulation(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		patch [8]uint8
+	}{
+		{"modulator at 12 dB", [8]uint8{0x22, 0x21, 0x10, 0x00, 0xF0, 0xF0, 0x00, 0x0F}},
+		{"modulator at full level", [8]uint8{0x21, 0x21, 0x00, 0x00, 0xF0, 0xF0, 0x00, 0x0F}},
+		{"multiplier 3", [8]uint8{0x26, 0x21, 0x14, 0x00, 0xF0, 0xF0, 0x00, 0x0F}},
+		{"feedback 3", [8]uint8{0x22, 0x21, 0x10, 0x03, 0xF0, 0xF0, 0x00, 0x0F}},
+		// Higher feedback turns the modulator into noise, which can not be compared sample by sample
+		{"feedback 5", [8]uint8{0x21, 0x21, 0x08, 0x05, 0xF0, 0xF0, 0x00, 0x0F}},
+	} {
+		var a vrc7Audio
+		vrc7KeyOn(&a, test.patch, 256, 4, 0)
+		samples := vrc7Samples(&a, 2048)
+		reference := opllModel(test.patch, 256, 4, 0, len(samples))
+		// The log-sin and exp tables round every output, which shifts the modulated phase of the carrier by a step
+		// now and then. Twice or half the modulation results in an error of more than 3000.
+		sum := 0.0
+		for i, sample := range samples {
+			sum += (float64(sample) - reference[i]) * (float64(sample) - reference[i])
+		}
+		if rms := math.Sqrt(sum / float64(len(samples))); rms > 256 {
+			t.Errorf("%s: expected the output of the reference, got an RMS error of %.1f", test.name, rms)
+		}
+	}
+}
+
+func TestVRC7Instruments(t *testing.T) {
+	// The built-in instruments sound exactly like the custom instrument with the same registers
+	for instrument := 1; instrument < len(vrc7Instruments); instrument++ {
+		var a, b vrc7Audio
+		vrc7KeyOn(&a, vrc7Instruments[instrument], 290, 4, 0)
+		vrc7KeyOn(&b, [8]uint8{}, 290, 4, 0)
+		vrc7Write(&b, 0x30, uint8(instrument)<<4)
+		custom, builtIn := vrc7Samples(&a, 4096), vrc7Samples(&b, 4096)
+		silent := true
+		for i := range custom {
+			if custom[i] != builtIn[i] {
+				t.Fatalf("instrument %d: expected %d at sample %d, got %d", instrument, custom[i], i, builtIn[i])
+			}
+			if custom[i] != 0 {
+				silent = false
+			}
+		}
+		if silent {
+			t.Errorf("instrument %d: expected sound", instrument)
+		}
+	}
+}
+
+// vrc7Peak returns the peak of the samples in dB relative to the full output
+func vrc7Peak(samples []int32) float64 {
+	var peak int32
+	for _, sample := range samples {
+		if sample > peak {
+			peak = sample
+		}
+	}
+	return 20 * math.Log10(float64(peak)/4096)
+}
+
+func TestVRC7KeyScaleLevel(t *testing.T) {
+	// Attenuations of the YM2413 application manual: 36 dB for the upper bits $8 of the frequency in octave 7 at
+	// 6 dB per octave, 1.5 and 3 dB per octave are a quarter and a half
+	for _, test := range []struct {
+		ksl         uint8
+		block       uint8
+		attenuation float64
+	}{
+		{1, 7, 9},
+		{2, 7, 18},
+		{3, 7, 36},
+		{3, 5, 24},
+		{2, 5, 12},
+		{3, 1, 0},
+	} {
+		var a vrc7Audio
+		patch := vrc7Sine
+		vrc7KeyOn(&a, patch, 256, test.block, 0)
+		reference := vrc7Peak(vrc7Samples(&a, 1024))
+		patch[3] |= test.ksl << 6
+		vrc7KeyOn(&a, patch, 256, test.block, 0)
+		attenuation := reference - vrc7Peak(vrc7Samples(&a, 1024))
+		if math.Abs(attenuation-test.attenuation) > 0.5 {
+			t.Errorf("KSL %d, octave %d: expected %.1f dB, got %.1f dB", test.ksl, test.block, test.attenuation,
+				attenuation)
+		}
+	}
+}
+
+func TestVRC7AmplitudeModulation(t *testing.T) {
+	var a vrc7Audio
+	patch := vrc7Sine
+	patch[1] |= 0b1000_0000
+	vrc7KeyOn(&a, patch, 256, 4, 0)
+	// One cycle of the carrier takes 128 samples
+	samples := vrc7Samples(&a, 2*13312)
+	var peaks []float64
+	for i := 0; i < len(samples); i += 128 {
+		peaks = append(peaks, vrc7Peak(samples[i:i+128]))
+	}
+	minimum := func(peaks []float64) (int, float64) {
+		index := 0
+		for i := range peaks {
+			if peaks[i] < peaks[index] {
+				index = i
+			}
+		}
+		return index, peaks[index]
+	}
+	first, depth := minimum(peaks[:len(peaks)/2])
+	second, _ := minimum(peaks[len(peaks)/2:])
+
+	// 4.8 dB at 3.7 Hz, as in the YM2413 application manual
+	if depth := -depth; math.Abs(depth-4.8) > 0.5 {
+		t.Errorf("expected a depth of 4.8 dB, got %.1f dB", depth)
+	}
+	rate := 49716 / float64((len(peaks)/2+second-first)*128)
+	if math.Abs(rate-3.7) > 0.1 {
+		t.Errorf("expected a rate of 3.7 Hz, got %.2f Hz", rate)
+	}
+}
+
+func TestVRC7Vibrato(t *testing.T) {
+	var a vrc7Audio
+	patch := vrc7Sine
+	patch[1] |= 0b0100_0000
+	vrc7KeyOn(&a, patch, 256, 4, 0)
+	// Frequency of the carrier relative to the frequency without vibrato for every sample
+	var deviations []float64
+	phase := a.channels[0].slots[1].phase
+	for i := 0; i < 2*8192; i++ {
+		vrc7Samples(&a, 1)
+		increment := (a.channels[0].slots[1].phase - phase) & 0x7FFFF
+		phase = a.channels[0].slots[1].phase
+		deviations = append(deviations, 1200*math.Log2(float64(increment)/(256<<4)))
+	}
+	depth, changes := 0.0, 0
+	for i := range deviations {
+		if deviations[i] > depth {
+			depth = deviations[i]
+		}
+		if i > 0 && deviations[i-1] <= 0 && deviations[i] > 0 {
+			changes++
+		}
+	}
+
+	// 14 cents at 6.4 Hz in the YM2413 application manual, the chip divides its clock to about 6.1 Hz
+	if math.Abs(depth-14) > 1 {
+		t.Errorf("expected a depth of 14 cents, got %.1f cents", depth)
+	}
+	if rate := 49716 * float64(changes) / float64(len(deviations)); rate < 6 || rate > 6.5 {
+		t.Errorf("expected a rate of about 6.4 Hz, got %.2f Hz", rate)
+	}
+}
+
+// TestVRC7Reference replays the sample dumps in testdata/vrc7 and compares them against the FM core. Every dump is a
+// text file with one entry per line:
+//
+//	# Comment, the first lines note the origin of the dump: core, version and how it was configured
+//	w 30 10   Write $10 to register $30 before the next sample
+//	-1234     Output of the reference for the next sample
+//
+// The dumps are recorded with the VRC7 patch set and one sample every 72 clocks of the chip, e.g. from emu2413 or
+// Nuked-OPLL. They should cover a built-in instrument from key on to release and a custom patch with feedback. As the
+// cores scale and round their outputs differently, the output is compared after fitting the volume.
+func TestVRC7Reference(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "vrc7", "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Skip("no reference dumps in testdata/vrc7")
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var a vrc7Audio
+		a.reset()
+		var samples, reference []float64
+		for i, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			switch {
+			case len(fields) == 0 || strings.HasPrefix(fields[0], "#"):
+			case fields[0] == "w" && len(fields) == 3:
+				register, err1 := strconv.ParseUint(fields[1], 16, 8)
+				data, err2 := strconv.ParseUint(fields[2], 16, 8)
+				if err1 != nil || err2 != nil {
+					t.Fatalf("%s:%d: invalid write %q", file, i+1, line)
+				}
+				vrc7Write(&a, uint8(register), uint8(data))
+			default:
+				sample, err := strconv.Atoi(fields[0])
+				if err != nil {
+					t.Fatalf("%s:%d: invalid sample %q", file, i+1, line)
+				}
+				samples = append(samples, float64(vrc7Samples(&a, 1)[0]))
+				reference = append(reference, float64(sample))
+			}
+		}
+
+		// Fit the volume with least squares, then allow an error of 5 % of the peak of the reference
+		var product, square, peak float64
+		for i := range samples {
+			product += samples[i] * reference[i]
+			square += samples[i] * samples[i]
+			peak = math.Max(peak, math.Abs(reference[i]))
+		}
+		if square == 0 || product <= 0 {
+			t.Errorf("%s: expected the output to follow the reference", file)
+			continue
+		}
+		gain := product / square
+		sum := 0.0
+		for i := range samples {
+			sum += (reference[i] - gain*samples[i]) * (reference[i] - gain*samples[i])
+		}
+		if rms := math.Sqrt(sum / float64(len(samples))); rms > 0.05*peak {
+			t.Errorf("%s: expected the output of the reference, got an RMS error of %.1f with a peak of %.0f", file,
+				rms, peak)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+// Package console provides the public API to embed the emulator. It wraps the NES and hides the clock handling, the
+// audio sampling and the frame buffers. The package has no dependencies on a window or audio library.
+package console
+
+import (
+	"errors"
+	"image"
+
+	"github.com/exp625/gones/pkg/cartridge"
+	"github.com/exp625/gones/pkg/nes"
+)
+
+// PPUFrequency is the frequency of the NTSC master clock, which equals the PPU clock
+const PPUFrequency = 5369318.0
+
+// ErrNoCartridge is returned if an operation requires a cartridge, but no ROM has been loaded
+var ErrNoCartridge = errors.New("no cartridge inserted")
+
+// Console is a NES that can be driven frame by frame or instruction by instruction.
+//
+// A Console is not safe for concurrent use.
+type Console struct {
+	// OnFrame is called whenever the PPU has completed a frame, before the step function returns
+	OnFrame func()
+
+	nes        *nes.NES
+	sampleRate int
+	samples    []int16
+}
+
+// New creates a console that produces audio samples with the given sample rate
+func New(sampleRate int) *Console {
+	return &Console{
+		nes:        nes.New(1/PPUFrequency, 1/float64(sampleRate)),
+		sampleRate: sampleRate,
+	}
+}
+
+// NES returns the emulated console. It allows debuggers to access the components directly, the fields of the NES are
+// not part of the stable API.
+func (c *Console) NES() *nes.NES {
+	return c.nes
+}
+
+// LoadROM inserts the cartridge of the iNES ROM image and powers on the console. The errors of cartridge.Load are
+// returned if the ROM can not be loaded.
+func (c *Console) LoadROM(rom []byte) error {
+	cart, err := cartridge.Load(rom, c.nes)
+	if err != nil {
+		return err
+	}
+	c.nes.InsertCartridge(cart)
+	c.samples = c.samples[:0]
+	return nil
+}
+
+// LoadDisk inserts the RAM adapter of the Famicom Disk System with side A of the FDS disk image and powers on the
+// console. The BIOS of the Famicom Disk System is not part of the image and must be provided. The errors of
+// cartridge.LoadDisk are returned if the image or the BIOS can not be loaded.
+func (c *Console) LoadDisk(image []byte, bios []byte) error {
+	cart, err := cartridge.LoadDisk(image, bios, c.nes)
+	if err != nil {
+		return err
+	}
+	c.nes.InsertCartridge(cart)
+	c.samples = c.samples[:0]
+	return nil
+}
+
+// Loaded returns true if a cartridge is inserted
+func (c *Console) Loaded() bool {
+	return c.nes.Cartridge != nil
+}
+
+// Reset presses the reset button
+func (c *Console) Reset() {
+	c.nes.Reset()
+}
+
+// FrameCount returns the number of frames completed since the console was powered on
+func (c *Console) FrameCount() uint64 {
+	return c.nes.PPU.FrameCount
+}
+
+// Clock advances the master clock by one cycle. The CPU executes one cycle on every third master clock.
+func (c *Console) Clock() {
+	frame := c.nes.PPU.FrameCount
+	if c.nes.Clock() {
+		c.samples = append(c.samples, int16(c.nes.APU.GetAudioSample()))
+		// Keep at most one second of audio if the samples are not collected
+		if len(c.samples) > 2*c.sampleRate {
+			c.samples = append(c.samples[:0], c.samples[len(c.samples)-c.sampleRate:]...)
+		}
+	}
+	if c.nes.PPU.FrameCount != frame && c.OnFrame != nil {
+		c.OnFrame()
+	}
+}
+
+// StepFrame runs the console until the PPU has completed the next frame
+func (c *Console) StepFrame() {
+	frame := c.nes.PPU.FrameCount
+	for c.nes.PPU.FrameCount == frame {
+		c.Clock()
+	}
+}
+
+// StepInstruction runs the console until the CPU has completed the next instruction
+func (c *Console) StepInstruction() {
+	c.stepCPU()
+	for c.nes.CPU.CycleCount != 0 {
+		c.stepCPU()
+	}
+}
+
+// stepCPU advances the master clock until the CPU has executed one cycle
+func (c *Console) stepCPU() {
+	c.Clock()
+	c.Clock()
+	c.Clock()
+}
+
+// Peek reads a byte from the address space of the CPU without changing the open bus value. Addresses should point to
+// RAM, because reads from registers can have side effects.
+func (c *Console) Peek(address uint16) uint8 {
+	openBus := c.nes.OpenBus
+	data := c.nes.CPURead(address)
+	c.nes.OpenBus = openBus
+	return data
+}
+
+// SetInput sets the pressed buttons of the controller in port 0 or 1. See controller.Button for the bits.
+func (c *Console) SetInput(port int, buttons uint8) {
+	if port == 0 {
+		c.nes.Controller1.Buttons = buttons
+	} else {
+		c.nes.Controller2.Buttons = buttons
+	}
+}
+
+// Framebuffer returns a copy of the last completed frame
+func (c *Console) Framebuffer() *image.RGBA {
+	frame := c.nes.PPU.ActiveFrame
+	return &image.RGBA{
+		Pix:    append([]uint8{}, frame.Pix...),
+		Stride: frame.Stride,
+		Rect:   frame.Rect,
+	}
+}
+
+// FramebufferIndices returns the palette indices of the last completed frame, 256 pixels per line. Bits 0-5 of every
+// pixel hold the color of the NES palette and bits 6-8 the color emphasis.
+func (c *Console) FramebufferIndices() []uint16 {
+	return append([]uint16{}, c.nes.PPU.ActiveIndices...)
+}
+
+// SampleRate returns the sample rate of the audio samples
+func (c *Console) SampleRate() int {
+	return c.sampleRate
+}
+
+// SamplesAvailable returns the number of audio samples that can be collected by AudioSamples
+func (c *Console) SamplesAvailable() int {
+	return len(c.samples)
+}
+
+// AudioSamples returns the mono audio samples produced since the last call. If the samples are not collected, only the
+// samples of the last second are kept.
+func (c *Console) AudioSamples() []int16 {
+	samples := append([]int16{}, c.samples...)
+	c.samples = c.samples[:0]
+	return samples
+}
+
+// SaveState returns the complete state of the console
+func (c *Console) SaveState() ([]byte, error) {
+	if !c.Loaded() {
+		return nil, ErrNoCartridge
+	}
+	return c.nes.SaveState(), nil
+}
+
+// LoadState restores a state returned by SaveState. The state must belong to the inserted cartridge.
+func (c *Console) LoadState(data []byte) error {
+	if !c.Loaded() {
+		return ErrNoCartridge
+	}
+	if err := c.nes.LoadState(data); err != nil {
+		return err
+	}
+	c.samples = c.samples[:0]
+	return nil
+}
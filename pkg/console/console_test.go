@@ -0,0 +1,112 @@
+package console
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"testing"
+
+	"github.com/exp625/gones/internal/testrom"
+	"github.com/exp625/gones/pkg/controller"
+)
+
+func newTestConsole(t *testing.T) *Console {
+	c := New(44100)
+	if err := c.LoadROM(testrom.ROM()); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestStepFrame(t *testing.T) {
+	c := newTestConsole(t)
+	frames := 0
+	c.OnFrame = func() { frames++ }
+	for i := 0; i < 10; i++ {
+		c.StepFrame()
+	}
+	if c.FrameCount() != 10 || frames != 10 {
+		t.Fatalf("expected 10 frames, got %d and %d callbacks", c.FrameCount(), frames)
+	}
+	// One frame lasts 1/60 s
+	if samples := len(c.AudioSamples()); samples < 7300 || samples > 7400 {
+		t.Errorf("unexpected number of audio samples: %d", samples)
+	}
+	if c.SamplesAvailable() != 0 {
+		t.Error("audio samples have not been collected")
+	}
+}
+
+func TestStepInstruction(t *testing.T) {
+	c := newTestConsole(t)
+	// The reset sequence takes 7 cycles, the first instruction starts at the reset vector
+	c.StepInstruction()
+	for i := 0; i < 3; i++ {
+		pc := c.NES().CPU.PC
+		c.StepInstruction()
+		if c.NES().CPU.PC == pc {
+			t.Fatalf("instruction at %04X did not complete", pc)
+		}
+		if c.NES().CPU.CycleCount != 0 {
+			t.Fatalf("stopped in the middle of an instruction")
+		}
+	}
+}
+
+func TestFramebuffer(t *testing.T) {
+	c := newTestConsole(t)
+	for i := 0; i < 5; i++ {
+		c.StepFrame()
+	}
+	frame := c.Framebuffer()
+	indices := c.FramebufferIndices()
+	if frame.Rect.Dx() != 256 || frame.Rect.Dy() != 240 || len(indices) != 256*240 {
+		t.Fatalf("unexpected frame size %v with %d indices", frame.Rect, len(indices))
+	}
+	palette := c.NES().PPU.Palette
+	for y := 0; y < 240; y++ {
+		for x := 0; x < 256; x++ {
+			index := indices[y*256+x]
+			expected := color.RGBAModel.Convert(palette[index&0x3F][index>>6])
+			if got := frame.RGBAAt(x, y); got != expected {
+				t.Fatalf("pixel %d,%d: index %03X is %v, expected %v", x, y, index, got, expected)
+			}
+		}
+	}
+
+	// The copy must not change with the next frames
+	pix := append([]uint8{}, frame.Pix...)
+	c.SetInput(0, uint8(controller.ButtonRIGHT))
+	c.StepFrame()
+	c.StepFrame()
+	if !bytes.Equal(pix, frame.Pix) {
+		t.Error("frame buffer copy has been changed")
+	}
+}
+
+func TestSaveState(t *testing.T) {
+	c := newTestConsole(t)
+	c.StepFrame()
+	state, err := c.SaveState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetInput(0, uint8(controller.ButtonRIGHT))
+	c.StepFrame()
+	c.StepFrame()
+	expected := c.Framebuffer().Pix
+
+	if err := c.LoadState(state); err != nil {
+		t.Fatal(err)
+	}
+	c.SetInput(0, uint8(controller.ButtonRIGHT))
+	c.StepFrame()
+	c.StepFrame()
+	if !bytes.Equal(expected, c.Framebuffer().Pix) {
+		t.Error("frame differs after loading the state")
+	}
+
+	if _, err := New(44100).SaveState(); !errors.Is(err, ErrNoCartridge) {
+		t.Errorf("expected ErrNoCartridge, got %v", err)
+	}
+}
@@ -1,6 +1,9 @@
 package controller
 
-import "github.com/exp625/gones/internal/shift_register"
+import (
+	"github.com/exp625/gones/internal/savestate"
+	"github.com/exp625/gones/internal/shift_register"
+)
 
 type Button uint8
 
@@ -49,3 +52,9 @@ func (c *Controller) Release(b Button) {
 func (c *Controller) IsPressed(b Button) bool {
 	return c.Buttons&uint8(b) == uint8(b)
 }
+
+func (c *Controller) Serialize(s *savestate.Serializer) {
+	s.Uint8(&c.Buttons)
+	c.register.Serialize(s)
+	s.Bool(&c.serialMode)
+}
@@ -1,6 +1,7 @@
 package cpu
 
 import (
+	"github.com/exp625/gones/internal/savestate"
 	"github.com/exp625/gones/pkg/bus"
 	"github.com/exp625/gones/pkg/logger"
 )
@@ -114,6 +115,23 @@ func (cpu *CPU) Reset() {
 	cpu.PC = (high << 8) | low
 }
 
+// Serialize saves or loads the registers and the internal state of the CPU
+func (cpu *CPU) Serialize(s *savestate.Serializer) {
+	s.Uint8(&cpu.A)
+	s.Uint8(&cpu.X)
+	s.Uint8(&cpu.Y)
+	s.Uint16(&cpu.PC)
+	s.Uint8(&cpu.S)
+	s.Uint8((*uint8)(&cpu.P))
+	s.Int64(&cpu.ClockCount)
+	s.Int(&cpu.CycleCount)
+	s.Bool(&cpu.RequestNMI)
+	s.Bool(&cpu.RequestIRQ)
+	s.Bool(&cpu.DMA)
+	s.Bool(&cpu.DMAPrepared)
+	s.Uint16(&cpu.DMAAddress)
+}
+
 func (cpu *CPU) IRQ() {
 	// Get current pc
 	pc := cpu.PC
@@ -160,6 +178,14 @@ func (cpu *CPU) NMI() {
 	cpu.PC = (high << 8) | low
 }
 
+// Stall delays the execution of the next instruction by the given number of cycles.
+// Like the OAM DMA, the DMC memory reader takes over the bus and the CPU has to wait.
+func (cpu *CPU) Stall(cycles int) {
+	cpu.CycleCount += cycles
+}
+
 func (cpu *CPU) log() {
-	cpu.Logger.Log()
+	if cpu.Logger != nil {
+		cpu.Logger.Log()
+	}
 }
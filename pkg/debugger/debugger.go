@@ -1,6 +1,7 @@
 package debugger
 
 import (
+	"github.com/exp625/gones/pkg/cartridge"
 	"github.com/exp625/gones/pkg/nes"
 )
 
@@ -53,13 +54,17 @@ func (nes *Debugger) CPURead(location uint16) uint8 {
 		// Debugger should not read advance the shift register
 		// return nes.Controller2.SerialRead()
 		return 1
-	case 0x4000 <= mappedLocation && mappedLocation <= 0x4015:
-		// TODO: APU and I/O Registers
-		return 0xFF
-	case 0x4018 <= mappedLocation && mappedLocation <= 0x401F:
-		// TODO: APU and I/O functionality that is normally disabled
-		return 0
+	case mappedLocation == 0x4015:
+		// Debugger should not clear the frame interrupt flag
+		return nes.APU.PeekStatus() | nes.OpenBus&0b0010_0000
+	case 0x4000 <= mappedLocation && mappedLocation <= 0x401F:
+		// All other APU and I/O registers are write only
+		return nes.OpenBus
 	case 0x4020 <= mappedLocation:
+		// Debugger should not acknowledge IRQs or change other state of the mapper
+		if peeker, ok := nes.Cartridge.Mapper.(cartridge.Peeker); ok {
+			return peeker.Peek(mappedLocation)
+		}
 		data := nes.Cartridge.CPURead(mappedLocation)
 		return data
 	default:
@@ -4,6 +4,9 @@ func (e *Emulator) Read(buf []byte) (int, error) {
 	// The function gets called if the audio hardware request new audio samples.
 	// The length of the sample array indicates how many sample are requested.
 
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
 	// Force the maximum sample time to be 0,016 s = 1/60
 	buf = make([]byte, AudioSampleRate*4/60)
 
@@ -19,26 +22,26 @@ func (e *Emulator) Read(buf []byte) (int, error) {
 		buf = make([]byte, len(origBuf)+4-len(origBuf)%4)
 	}
 
+	// Samples produced while stepping manually are not played
+	samples := e.Console.AudioSamples()[:0]
+	if e.AutoRunEnabled && !e.Rewinding {
+		for e.Console.SamplesAvailable() < len(buf)/4 {
+			e.Console.Clock()
+			e.AutoRunCycles++
+		}
+		samples = e.Console.AudioSamples()
+	}
+
 	for i := 0; i < len(buf)/4; i++ {
-		if e.AutoRunEnabled {
-			for !e.Clock() {
-				e.AutoRunCycles++
-			}
-
-			// Get the audio sample for the APU
-			sample := e.APU.GetAudioSample()
-			buf[4*i] = byte(sample)
-			buf[4*i+1] = byte(sample >> 8)
-			buf[4*i+2] = byte(sample)
-			buf[4*i+3] = byte(sample >> 8)
-		} else {
-			// No sound when auto run is false
-			sample := 0
-			buf[4*i] = byte(sample)
-			buf[4*i+1] = byte(sample >> 8)
-			buf[4*i+2] = byte(sample)
-			buf[4*i+3] = byte(sample >> 8)
+		// No sound when auto run is false or while rewinding
+		var sample int16
+		if i < len(samples) {
+			sample = samples[i]
 		}
+		buf[4*i] = byte(sample)
+		buf[4*i+1] = byte(sample >> 8)
+		buf[4*i+2] = byte(sample)
+		buf[4*i+3] = byte(sample >> 8)
 	}
 
 	if origBuf != nil {
@@ -27,22 +27,33 @@ func (e *Emulator) registerAllBindings() {
 }
 
 func (e *Emulator) registerEmulatorBindings() {
-	e.Bindings.Groups[input.Emulator][input.Reset].OnPressed = e.Reset
+	e.Bindings.Groups[input.Emulator][input.Reset].OnPressed = e.resetPressed
 	e.Bindings.Groups[input.Emulator][input.Load].OnPressed = func() { e.ChangeScreen(OverlayROMChooser) }
 	e.Bindings.Groups[input.Emulator][input.Save].OnPressed = e.SaveGame
+	e.Bindings.Groups[input.Emulator][input.SaveState].OnPressed = e.SaveStateSlot
+	e.Bindings.Groups[input.Emulator][input.LoadState].OnPressed = e.LoadStateSlot
+	e.Bindings.Groups[input.Emulator][input.NextStateSlot].OnPressed = func() { e.SelectStateSlot(e.StateSlot + 1) }
+	e.Bindings.Groups[input.Emulator][input.PrevStateSlot].OnPressed = func() { e.SelectStateSlot(e.StateSlot - 1) }
+	e.Bindings.Groups[input.Emulator][input.EjectDisk].OnPressed = e.ToggleDisk
+	e.Bindings.Groups[input.Emulator][input.SwitchDiskSide].OnPressed = e.SwitchDiskSide
+	e.Bindings.Groups[input.Emulator][input.Rewind].OnPressed = func() { e.Rewinding = true }
+	e.Bindings.Groups[input.Emulator][input.Rewind].OnReleased = func() { e.Rewinding = false }
+	e.Bindings.Groups[input.Emulator][input.RecordMovie].OnPressed = func() { e.ToggleRecording(false) }
+	e.Bindings.Groups[input.Emulator][input.RecordMovieFromState].OnPressed = func() { e.ToggleRecording(true) }
+	e.Bindings.Groups[input.Emulator][input.PlayMovie].OnPressed = e.TogglePlayback
 	e.Bindings.Groups[input.Emulator][input.ScreenKeyBindings].OnPressed = func() { e.ChangeScreen(OverlayKeybindings) }
 	e.Bindings.Groups[input.Emulator][input.ExecuteInstruction].OnPressed = e.executeOneCPUInstructionPressed
 	e.Bindings.Groups[input.Emulator][input.Pause].OnPressed = func() { e.AutoRunEnabled = !e.AutoRunEnabled }
 	e.Bindings.Groups[input.Emulator][input.ExecuteMasterClock].OnPressed = func() {
 		if !e.AutoRunEnabled {
-			e.Clock()
+			e.Console.Clock()
 		}
 	}
 	e.Bindings.Groups[input.Emulator][input.ExecuteCPUClock].OnPressed = func() {
 		if !e.AutoRunEnabled {
-			e.Clock()
-			e.Clock()
-			e.Clock()
+			e.Console.Clock()
+			e.Console.Clock()
+			e.Console.Clock()
 		}
 	}
 	e.Bindings.Groups[input.Emulator][input.Cancel].OnPressed = func() {
@@ -67,23 +78,23 @@ func (e *Emulator) registerDebugBindings() {
 }
 
 func (e *Emulator) registerControllerBindings() {
-	e.Bindings.Groups[input.Controller1][input.A].OnPressed = func() { e.Controller1.Press(controller.ButtonA) }
-	e.Bindings.Groups[input.Controller1][input.B].OnPressed = func() { e.Controller1.Press(controller.ButtonB) }
-	e.Bindings.Groups[input.Controller1][input.START].OnPressed = func() { e.Controller1.Press(controller.ButtonSTART) }
-	e.Bindings.Groups[input.Controller1][input.SELECT].OnPressed = func() { e.Controller1.Press(controller.ButtonSELECT) }
-	e.Bindings.Groups[input.Controller1][input.UP].OnPressed = func() { e.Controller1.Press(controller.ButtonUP) }
-	e.Bindings.Groups[input.Controller1][input.DOWN].OnPressed = func() { e.Controller1.Press(controller.ButtonDOWN) }
-	e.Bindings.Groups[input.Controller1][input.LEFT].OnPressed = func() { e.Controller1.Press(controller.ButtonLEFT) }
-	e.Bindings.Groups[input.Controller1][input.RIGHT].OnPressed = func() { e.Controller1.Press(controller.ButtonRIGHT) }
+	e.Bindings.Groups[input.Controller1][input.A].OnPressed = func() { e.pressButton(0, controller.ButtonA) }
+	e.Bindings.Groups[input.Controller1][input.B].OnPressed = func() { e.pressButton(0, controller.ButtonB) }
+	e.Bindings.Groups[input.Controller1][input.START].OnPressed = func() { e.pressButton(0, controller.ButtonSTART) }
+	e.Bindings.Groups[input.Controller1][input.SELECT].OnPressed = func() { e.pressButton(0, controller.ButtonSELECT) }
+	e.Bindings.Groups[input.Controller1][input.UP].OnPressed = func() { e.pressButton(0, controller.ButtonUP) }
+	e.Bindings.Groups[input.Controller1][input.DOWN].OnPressed = func() { e.pressButton(0, controller.ButtonDOWN) }
+	e.Bindings.Groups[input.Controller1][input.LEFT].OnPressed = func() { e.pressButton(0, controller.ButtonLEFT) }
+	e.Bindings.Groups[input.Controller1][input.RIGHT].OnPressed = func() { e.pressButton(0, controller.ButtonRIGHT) }
 
-	e.Bindings.Groups[input.Controller1][input.A].OnReleased = func() { e.Controller1.Release(controller.ButtonA) }
-	e.Bindings.Groups[input.Controller1][input.B].OnReleased = func() { e.Controller1.Release(controller.ButtonB) }
-	e.Bindings.Groups[input.Controller1][input.START].OnReleased = func() { e.Controller1.Release(controller.ButtonSTART) }
-	e.Bindings.Groups[input.Controller1][input.SELECT].OnReleased = func() { e.Controller1.Release(controller.ButtonSELECT) }
-	e.Bindings.Groups[input.Controller1][input.UP].OnReleased = func() { e.Controller1.Release(controller.ButtonUP) }
-	e.Bindings.Groups[input.Controller1][input.DOWN].OnReleased = func() { e.Controller1.Release(controller.ButtonDOWN) }
-	e.Bindings.Groups[input.Controller1][input.LEFT].OnReleased = func() { e.Controller1.Release(controller.ButtonLEFT) }
-	e.Bindings.Groups[input.Controller1][input.RIGHT].OnReleased = func() { e.Controller1.Release(controller.ButtonRIGHT) }
+	e.Bindings.Groups[input.Controller1][input.A].OnReleased = func() { e.releaseButton(0, controller.ButtonA) }
+	e.Bindings.Groups[input.Controller1][input.B].OnReleased = func() { e.releaseButton(0, controller.ButtonB) }
+	e.Bindings.Groups[input.Controller1][input.START].OnReleased = func() { e.releaseButton(0, controller.ButtonSTART) }
+	e.Bindings.Groups[input.Controller1][input.SELECT].OnReleased = func() { e.releaseButton(0, controller.ButtonSELECT) }
+	e.Bindings.Groups[input.Controller1][input.UP].OnReleased = func() { e.releaseButton(0, controller.ButtonUP) }
+	e.Bindings.Groups[input.Controller1][input.DOWN].OnReleased = func() { e.releaseButton(0, controller.ButtonDOWN) }
+	e.Bindings.Groups[input.Controller1][input.LEFT].OnReleased = func() { e.releaseButton(0, controller.ButtonLEFT) }
+	e.Bindings.Groups[input.Controller1][input.RIGHT].OnReleased = func() { e.releaseButton(0, controller.ButtonRIGHT) }
 }
 
 func (e *Emulator) registerNumberHandler() {
@@ -127,14 +138,7 @@ func (e *Emulator) executeOneCPUInstructionPressed() {
 		e.RequestedSteps = 1
 	}
 	for e.RequestedSteps != 0 {
-		e.Clock()
-		e.Clock()
-		e.Clock()
-		for e.CPU.CycleCount != 0 {
-			e.Clock()
-			e.Clock()
-			e.Clock()
-		}
+		e.Console.StepInstruction()
 		e.RequestedSteps--
 	}
 	e.RequestedSteps = 0
@@ -0,0 +1,120 @@
+package emulator
+
+import (
+	"errors"
+	"fmt"
+	"github.com/exp625/gones/internal/config"
+	"github.com/exp625/gones/pkg/cartridge"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// loadROM loads an iNES file or an FDS disk image into the console. Disk images are run with the BIOS configured in
+// the config file.
+func (e *Emulator) loadROM(romFile string) error {
+	rom, err := os.ReadFile(romFile)
+	if err != nil {
+		return err
+	}
+	if cartridge.IsDisk(rom) {
+		biosFile, ok := config.Get(config.FDSBIOSFile)
+		if !ok {
+			configFile, _ := config.FilePath()
+			return fmt.Errorf("no FDS BIOS configured, set %q in %s", config.FDSBIOSFile, configFile)
+		}
+		bios, err := os.ReadFile(biosFile)
+		if err != nil {
+			return err
+		}
+		err = e.Console.LoadDisk(rom, bios)
+	} else {
+		err = e.Console.LoadROM(rom)
+	}
+	if err != nil {
+		return err
+	}
+	e.ROMFile = romFile
+	return nil
+}
+
+// diskDrive returns the disk drive of the inserted cartridge, false if it has none
+func (e *Emulator) diskDrive() (cartridge.DiskDrive, bool) {
+	if e.Cartridge == nil {
+		return nil, false
+	}
+	drive, ok := e.Cartridge.Mapper.(cartridge.DiskDrive)
+	return drive, ok
+}
+
+// ToggleDisk ejects the disk or inserts the last inserted side again
+func (e *Emulator) ToggleDisk() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	drive, ok := e.diskDrive()
+	if !ok {
+		return
+	}
+	side, inserted := drive.Side()
+	if inserted {
+		drive.Eject()
+		log.Println("Disk ejected")
+		return
+	}
+	drive.Insert(side)
+	log.Printf("Inserted disk side %d", side+1)
+}
+
+// SwitchDiskSide inserts the next side of the disk. After the last side, the first side is inserted.
+func (e *Emulator) SwitchDiskSide() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	drive, ok := e.diskDrive()
+	if !ok {
+		return
+	}
+	side, _ := drive.Side()
+	side = (side + 1) % drive.Sides()
+	drive.Insert(side)
+	log.Printf("Inserting disk side %d of %d", side+1, drive.Sides())
+}
+
+// diskSaveFile returns the file next to the disk image that holds the changes to the disk as IPS patch
+func diskSaveFile(imageFile string) string {
+	return strings.TrimSuffix(imageFile, filepath.Ext(imageFile)) + ".ips"
+}
+
+// saveDisk writes the changes to the disk next to the disk image. The image itself is never modified. If the changes
+// can not be computed, the previous save is kept.
+func (e *Emulator) saveDisk(drive cartridge.DiskDrive) {
+	patch, err := drive.Patch()
+	if err != nil {
+		log.Println("error saving disk: ", err.Error())
+		return
+	}
+	fileName := diskSaveFile(e.ROMFile)
+	if err := os.WriteFile(fileName, patch, 0644); err != nil {
+		log.Println("error saving disk: ", err.Error())
+		return
+	}
+	log.Println("Disk saved to", fileName)
+}
+
+// loadDisk applies the changes saved next to the disk image
+func (e *Emulator) loadDisk() {
+	fileName := diskSaveFile(e.ROMFile)
+	patch, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		log.Println("error opening disk save: ", err.Error())
+		return
+	}
+	if err := e.Cartridge.Load(patch); err != nil {
+		log.Println("error loading disk save: ", err.Error())
+		return
+	}
+	log.Println("Found disk save", fileName)
+}
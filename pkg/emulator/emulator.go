@@ -3,27 +3,26 @@ package emulator
 import (
 	"fmt"
 	"github.com/exp625/gones/internal/config"
+	"github.com/exp625/gones/internal/rewind"
 	"github.com/exp625/gones/internal/textutil"
-	"github.com/exp625/gones/pkg/cartridge"
+	"github.com/exp625/gones/pkg/console"
 	"github.com/exp625/gones/pkg/debugger"
 	"github.com/exp625/gones/pkg/file_explorer"
 	"github.com/exp625/gones/pkg/input"
 	"github.com/exp625/gones/pkg/logger"
+	"github.com/exp625/gones/pkg/movie"
 	"github.com/exp625/gones/pkg/nes"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/audio"
-	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 )
 
 const (
-	AudioSampleRate    = 44100
-	PPUFrequency       = 5369318.0
-	NESAudioSampleTime = 1.0 / AudioSampleRate
-	NESClockTime       = 1.0 / PPUFrequency
+	AudioSampleRate = 44100
 
 	WindowWidth  = 256 * 4
 	WindowHeight = 240*4 + 20
@@ -31,6 +30,8 @@ const (
 
 // Emulator struct
 type Emulator struct {
+	// The emulator drives the console, the NES is accessed directly by the debug screens
+	Console *console.Console
 	*nes.NES
 	Debugger *debugger.Debugger
 	Logger   logger.Logger
@@ -40,10 +41,31 @@ type Emulator struct {
 
 	ActiveScreen Screen
 	FileExplorer *file_explorer.FileExplorer
+	// Path of the loaded ROM file, the changes to a disk image are saved next to it
+	ROMFile string
+	// Error of the last ROM chosen in the file explorer, shown until another ROM is chosen
+	LoadError error
 
 	RequestedSteps int
 	AutoRunCycles  int
 
+	// Selected save state slot
+	StateSlot int
+
+	// Snapshots for stepping backwards through time
+	Rewind            *rewind.Buffer
+	Rewinding         bool
+	lastSnapshotFrame uint64
+
+	// Input movie that is recorded or played
+	MovieRecorder *movie.Recorder
+	MoviePlayer   *movie.Player
+	// Buttons of both controllers as pressed by the user
+	input [2]uint8
+
+	// Guards the NES against concurrent access from the audio player and the game loop
+	mutex sync.Mutex
+
 	NanoSecondsSpentInAutoRun time.Duration
 	AutoRunStarted            time.Time
 
@@ -69,10 +91,14 @@ func New(romFile string, debug bool) (*Emulator, error) {
 		return nil, err
 	}
 
+	c := console.New(AudioSampleRate)
 	e := &Emulator{
-		NES:          nes.New(NESClockTime, NESAudioSampleTime),
+		Console:      c,
+		NES:          c.NES(),
 		FileExplorer: explorer,
+		Rewind:       rewind.New(rewindBufferSize()),
 	}
+	c.OnFrame = e.frameCompleted
 	e.Bindings = input.GetBindings()
 	e.Bindings.LoadCustomBindings()
 	e.registerAllBindings()
@@ -81,15 +107,9 @@ func New(romFile string, debug bool) (*Emulator, error) {
 	}
 
 	if romFile != "" {
-		bytes, err := os.ReadFile(romFile)
-		if err != nil {
-			return nil, err
+		if err := e.loadROM(romFile); err != nil {
+			return nil, fmt.Errorf("%s: %w", romFile, err)
 		}
-		c := cartridge.Load(bytes, e)
-		if c == nil {
-			return nil, fmt.Errorf("unsupported mapper")
-		}
-		e.InsertCartridge(c)
 		e.LoadGame()
 		e.ChangeScreen(ScreenGame)
 	} else {
@@ -142,6 +162,10 @@ func (e *Emulator) Update() error {
 	}
 	e.AutoRunStarted = time.Now()
 
+	if e.Rewinding {
+		e.stepBack()
+	}
+
 	if e.ActiveScreen == OverlayROMChooser {
 		if err := e.FileExplorer.Update(); err != nil {
 			return err
@@ -153,28 +177,16 @@ func (e *Emulator) Update() error {
 		if err != nil {
 			return err
 		}
-		file, err := os.Open(absolutePath)
-		if err != nil {
-			log.Println("could not open file: ", err.Error())
-			return nil
-		}
-		defer func() {
-			if err := file.Close(); err != nil {
-				log.Println("could not close file: ", err.Error())
-			}
-		}()
-		bytes, err := io.ReadAll(file)
-		if err != nil {
-			log.Println("failed to read file: ", err.Error())
-			return nil
-		}
-		c := cartridge.Load(bytes, e)
-		if c == nil {
+		if err := e.loadROM(absolutePath); err != nil {
+			log.Println("failed to load ROM: ", err.Error())
+			e.LoadError = fmt.Errorf("%s: %w", filepath.Base(absolutePath), err)
 			return nil
 		}
-		e.InsertCartridge(c)
+		e.LoadError = nil
 		e.LoadGame()
 		e.Reset()
+		e.Rewind.Clear()
+		e.stopMovie()
 
 		e.ChangeScreen(ScreenGame)
 		e.AutoRunEnabled = true
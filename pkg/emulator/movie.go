@@ -0,0 +1,158 @@
+package emulator
+
+import (
+	"encoding/hex"
+	"log"
+	"os"
+
+	"github.com/exp625/gones/internal/plz"
+	"github.com/exp625/gones/pkg/controller"
+	"github.com/exp625/gones/pkg/movie"
+)
+
+// movieFileName returns the file of the movie for the inserted cartridge
+func (e *Emulator) movieFileName() string {
+	return "movies/" + hex.EncodeToString(e.Cartridge.Identifier[:]) + ".movie"
+}
+
+// frameCompleted is called by the console whenever the PPU has completed a frame. It informs the movie and the rewind
+// buffer.
+func (e *Emulator) frameCompleted() {
+	if e.MovieRecorder != nil {
+		if err := e.MovieRecorder.Frame(e.input); err != nil {
+			log.Println("stopped recording: ", err.Error())
+			e.saveMovie(e.MovieRecorder.Movie)
+			e.stopMovie()
+		}
+	}
+	if e.MoviePlayer != nil {
+		if err := e.MoviePlayer.Frame(); err != nil {
+			log.Println("stopped movie: ", err.Error())
+			e.stopMovie()
+		} else if e.MoviePlayer.Finished() {
+			log.Println("Movie finished")
+			e.stopMovie()
+		}
+	}
+	e.recordSnapshot()
+}
+
+// pressButton presses a button of a controller. While a movie is recorded, the input is applied at the start of the
+// next frame. While a movie is played, the user input is ignored.
+func (e *Emulator) pressButton(port int, button controller.Button) {
+	e.input[port] |= uint8(button)
+	if e.MovieRecorder == nil && e.MoviePlayer == nil {
+		e.controller(port).Press(button)
+	}
+}
+
+// releaseButton releases a button of a controller, see pressButton
+func (e *Emulator) releaseButton(port int, button controller.Button) {
+	e.input[port] &^= uint8(button)
+	if e.MovieRecorder == nil && e.MoviePlayer == nil {
+		e.controller(port).Release(button)
+	}
+}
+
+func (e *Emulator) controller(port int) *controller.Controller {
+	if port == 0 {
+		return e.Controller1
+	}
+	return e.Controller2
+}
+
+// resetPressed resets the NES. While a movie is recorded, the reset is recorded and executed at the start of the next
+// frame.
+func (e *Emulator) resetPressed() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	switch {
+	case e.MovieRecorder != nil:
+		e.MovieRecorder.Command(movie.CommandReset)
+	case e.MoviePlayer != nil:
+	default:
+		e.Reset()
+	}
+}
+
+// ToggleRecording starts recording a movie, either from power-on or from the current state. If a movie is being
+// recorded, the recording is stopped and the movie is saved.
+func (e *Emulator) ToggleRecording(fromState bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.Cartridge == nil {
+		return
+	}
+	if e.MovieRecorder != nil {
+		e.saveMovie(e.MovieRecorder.Movie)
+		e.stopMovie()
+		return
+	}
+	e.stopMovie()
+	recorder, err := movie.NewRecorder(e.NES, fromState, e.input)
+	if err != nil {
+		log.Println("error starting the recording: ", err.Error())
+		return
+	}
+	e.MovieRecorder = recorder
+	log.Println("Started recording movie")
+}
+
+// TogglePlayback plays the movie of the inserted cartridge or stops the playback
+func (e *Emulator) TogglePlayback() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.Cartridge == nil {
+		return
+	}
+	if e.MoviePlayer != nil {
+		e.stopMovie()
+		return
+	}
+	e.stopMovie()
+	file, err := os.Open(e.movieFileName())
+	if err != nil {
+		log.Println("error opening movie: ", err.Error())
+		return
+	}
+	defer plz.Close(file)
+	m, err := movie.Read(file)
+	if err != nil {
+		log.Println("error reading movie: ", err.Error())
+		return
+	}
+	e.MoviePlayer, err = movie.NewPlayer(e.NES, m)
+	if err != nil {
+		log.Println("error playing movie: ", err.Error())
+		return
+	}
+	e.RemainingSamples = nil
+	log.Println("Started playing movie with", len(m.Frames), "frames")
+}
+
+// stopMovie stops recording or playing a movie and gives the controllers back to the user
+func (e *Emulator) stopMovie() {
+	if e.MovieRecorder == nil && e.MoviePlayer == nil {
+		return
+	}
+	e.MovieRecorder = nil
+	e.MoviePlayer = nil
+	e.Controller1.Buttons = e.input[0]
+	e.Controller2.Buttons = e.input[1]
+}
+
+func (e *Emulator) saveMovie(m *movie.Movie) {
+	fileName := e.movieFileName()
+	ensureSaveDir(fileName)
+	file, err := os.Create(fileName)
+	if err != nil {
+		log.Println("error creating movie: ", err.Error())
+		return
+	}
+	defer plz.Close(file)
+	if err := m.Write(file); err != nil {
+		log.Println("error saving movie: ", err.Error())
+		return
+	}
+	log.Println("Movie with", len(m.Frames), "frames saved")
+}
@@ -161,10 +161,17 @@ func (e *Emulator) DrawROMChooser(screen *ebiten.Image) {
 		"<A-Z>/<0-9> to quickly selected a file/directory starting with the letter/number \n",
 		"<ESC> close file explorer",
 	}
+	if e.LoadError != nil {
+		lines = append(lines, "\n")
+	}
 	text := textutil.New(basicfont.Face7x13, screen.Bounds().Dx()-2*pad, len(lines)*basicfont.Face7x13.Height+2*pad, pad, pad, 1)
 	for _, line := range lines {
 		plz.Just(text.WriteString(line))
 	}
+	if e.LoadError != nil {
+		text.Color(color.RGBA{R: 255, A: 255})
+		plz.Just(text.WriteString("Could not load " + e.LoadError.Error()))
+	}
 	text.Draw(screen)
 
 	img := ebiten.NewImage(screen.Bounds().Dx()-2*pad, screen.Bounds().Dy()-(len(lines)*basicfont.Face7x13.Height)-3*pad)
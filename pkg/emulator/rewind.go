@@ -0,0 +1,59 @@
+package emulator
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/exp625/gones/internal/config"
+)
+
+const (
+	// RewindInterval is the number of frames between two rewind snapshots
+	RewindInterval = 4
+	// DefaultRewindBufferSize is the memory budget of the rewind buffer in megabytes, if none is configured
+	DefaultRewindBufferSize = 64
+)
+
+// rewindBufferSize returns the configured memory budget of the rewind buffer in bytes
+func rewindBufferSize() int {
+	size := DefaultRewindBufferSize
+	if value, ok := config.Get(config.RewindBufferSize); ok {
+		configured, err := strconv.Atoi(value)
+		if err != nil || configured <= 0 {
+			log.Println("invalid rewind buffer size in config: ", value)
+		} else {
+			size = configured
+		}
+	}
+	return size * 1024 * 1024
+}
+
+// recordSnapshot adds a snapshot to the rewind buffer every RewindInterval frames
+func (e *Emulator) recordSnapshot() {
+	if e.PPU.FrameCount < e.lastSnapshotFrame+RewindInterval && e.PPU.FrameCount >= e.lastSnapshotFrame {
+		return
+	}
+	e.lastSnapshotFrame = e.PPU.FrameCount
+	e.Rewind.Push(e.NES.SaveState())
+}
+
+// stepBack restores the newest snapshot of the rewind buffer
+func (e *Emulator) stepBack() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.Cartridge == nil {
+		return
+	}
+	state, ok := e.Rewind.Pop()
+	if !ok {
+		return
+	}
+	if err := e.Console.LoadState(state); err != nil {
+		log.Println("error rewinding: ", err.Error())
+		e.Rewind.Clear()
+		return
+	}
+	e.lastSnapshotFrame = e.PPU.FrameCount
+	e.RemainingSamples = nil
+	e.stopMovie()
+}
@@ -11,6 +11,10 @@ import (
 )
 
 func (e *Emulator) SaveGame() {
+	if drive, ok := e.diskDrive(); ok {
+		e.saveDisk(drive)
+		return
+	}
 
 	saveName := time.Now().Format("saves/2006-01-02_15-04-05.") + hex.EncodeToString(e.Cartridge.Identifier[:]) + ".save"
 	ensureSaveDir(saveName)
@@ -29,6 +33,10 @@ func (e *Emulator) SaveGame() {
 }
 
 func (e *Emulator) LoadGame() {
+	if _, ok := e.diskDrive(); ok {
+		e.loadDisk()
+		return
+	}
 	entries, err := os.ReadDir("saves/")
 	if err != nil {
 		entries = make([]os.DirEntry, 0)
@@ -54,7 +62,10 @@ func (e *Emulator) LoadGame() {
 			log.Print("error opening savefile", err)
 			return
 		}
-		e.Cartridge.Load(saveFileBytes)
+		if err := e.Cartridge.Load(saveFileBytes); err != nil {
+			log.Println("error loading savefile: ", err.Error())
+			return
+		}
 		log.Println("Found save file from ", newestTime.String())
 	}
 
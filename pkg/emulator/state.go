@@ -0,0 +1,66 @@
+package emulator
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+)
+
+// StateSlots is the number of save state slots per cartridge
+const StateSlots = 10
+
+// stateFileName returns the file of the save state slot for the inserted cartridge
+func (e *Emulator) stateFileName(slot int) string {
+	return fmt.Sprintf("states/%s.%d.state", hex.EncodeToString(e.Cartridge.Identifier[:]), slot)
+}
+
+// SelectStateSlot selects the slot used by SaveStateSlot and LoadStateSlot. The slot wraps around.
+func (e *Emulator) SelectStateSlot(slot int) {
+	e.StateSlot = (slot%StateSlots + StateSlots) % StateSlots
+	log.Println("Selected save state slot", e.StateSlot)
+}
+
+// SaveStateSlot saves the complete state of the NES into the selected slot
+func (e *Emulator) SaveStateSlot() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.Cartridge == nil {
+		return
+	}
+	data, err := e.Console.SaveState()
+	if err != nil {
+		log.Println("error saving state: ", err.Error())
+		return
+	}
+	fileName := e.stateFileName(e.StateSlot)
+	ensureSaveDir(fileName)
+	if err := os.WriteFile(fileName, data, 0644); err != nil {
+		log.Println("error saving state: ", err.Error())
+		return
+	}
+	log.Println("State saved to slot", e.StateSlot)
+}
+
+// LoadStateSlot restores the state of the NES from the selected slot
+func (e *Emulator) LoadStateSlot() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.Cartridge == nil {
+		return
+	}
+	data, err := os.ReadFile(e.stateFileName(e.StateSlot))
+	if err != nil {
+		log.Println("error opening state: ", err.Error())
+		return
+	}
+	if err := e.Console.LoadState(data); err != nil {
+		log.Println("error loading state: ", err.Error())
+		return
+	}
+	// Drop the audio samples and snapshots of the old state
+	e.RemainingSamples = nil
+	e.lastSnapshotFrame = e.PPU.FrameCount
+	e.stopMovie()
+	log.Println("State loaded from slot", e.StateSlot)
+}
@@ -20,6 +20,19 @@ const (
 	Pause              = "Pause"
 	Cancel             = "Cancel"
 
+	SaveState     = "Save State"
+	LoadState     = "Load State"
+	NextStateSlot = "Next State Slot"
+	PrevStateSlot = "Previous State Slot"
+	Rewind        = "Rewind"
+
+	EjectDisk      = "Eject Disk"
+	SwitchDiskSide = "Switch Disk Side"
+
+	RecordMovie          = "Record Movie"
+	RecordMovieFromState = "Record Movie From State"
+	PlayMovie            = "Play Movie"
+
 	ScreenKeyBindings   = "Key Bindings Screen"
 	ExecuteInstruction  = "Execute Instruction"
 	ExecuteCPUClock     = "ExecuteCPUClock"
@@ -124,6 +137,46 @@ func GetBindings() *Bindings {
 					Help:       "Save Game",
 					DefaultKey: ebiten.KeyF12,
 				},
+				SaveState: &Binding{
+					Help:       "Save the state of the emulator into the selected slot",
+					DefaultKey: ebiten.KeyF8,
+				},
+				LoadState: &Binding{
+					Help:       "Load the state of the emulator from the selected slot",
+					DefaultKey: ebiten.KeyF9,
+				},
+				NextStateSlot: &Binding{
+					Help:       "Select the next save state slot",
+					DefaultKey: ebiten.KeyF10,
+				},
+				PrevStateSlot: &Binding{
+					Help:       "Select the previous save state slot",
+					DefaultKey: ebiten.KeyF11,
+				},
+				EjectDisk: &Binding{
+					Help:       "Eject the disk of the Famicom Disk System or insert it again",
+					DefaultKey: ebiten.KeyEnd,
+				},
+				SwitchDiskSide: &Binding{
+					Help:       "Insert the next side of the disk of the Famicom Disk System",
+					DefaultKey: ebiten.KeyPageDown,
+				},
+				Rewind: &Binding{
+					Help:       "Step backwards through time while held",
+					DefaultKey: ebiten.KeyBackspace,
+				},
+				RecordMovie: &Binding{
+					Help:       "Start or stop recording a movie from power-on",
+					DefaultKey: ebiten.KeyM,
+				},
+				RecordMovieFromState: &Binding{
+					Help:       "Start or stop recording a movie from the current state",
+					DefaultKey: ebiten.KeyN,
+				},
+				PlayMovie: &Binding{
+					Help:       "Start or stop playing the recorded movie",
+					DefaultKey: ebiten.KeyB,
+				},
 				ScreenKeyBindings: &Binding{
 					Help:       "Show the key bindings screen",
 					DefaultKey: ebiten.KeyF7,
@@ -0,0 +1,254 @@
+package movie
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/exp625/gones/internal/plz"
+	"github.com/exp625/gones/pkg/controller"
+)
+
+// Files of a .bk2 archive
+const (
+	bk2Header   = "Header.txt"
+	bk2InputLog = "Input Log.txt"
+)
+
+// bk2LogKey is the log key of the NES core of BizHawk. It names the button of every column of the input log, groups of
+// buttons are separated by '#'.
+const bk2LogKey = "#Reset|Power|#P1 Up|P1 Down|P1 Left|P1 Right|P1 Start|P1 Select|P1 B|P1 A|" +
+	"#P2 Up|P2 Down|P2 Left|P2 Right|P2 Start|P2 Select|P2 B|P2 A|"
+
+// bk2Mnemonics are the characters BizHawk uses in the input log for the buttons of bk2LogKey
+var bk2Mnemonics = map[string]byte{
+	"Reset": 'r', "Power": 'P',
+	"Up": 'U', "Down": 'D', "Left": 'L', "Right": 'R', "Start": 'S', "Select": 's', "B": 'B', "A": 'A',
+}
+
+// bk2Buttons maps the button names of BizHawk to the controller buttons
+var bk2Buttons = map[string]controller.Button{
+	"Up":     controller.ButtonUP,
+	"Down":   controller.ButtonDOWN,
+	"Left":   controller.ButtonLEFT,
+	"Right":  controller.ButtonRIGHT,
+	"Start":  controller.ButtonSTART,
+	"Select": controller.ButtonSELECT,
+	"B":      controller.ButtonB,
+	"A":      controller.ButtonA,
+}
+
+// ReadBK2 reads the input log of a BizHawk .bk2 movie.
+//
+// From BizHawk documentation https://tasvideos.org/Bizhawk/BK2Format
+//
+// A bk2 file is a zip archive. The archive contains a Header.txt with key value pairs and the Input Log.txt:
+//
+// [Input]
+// LogKey:#Reset|Power|#P1 Up|P1 Down|...
+// |..|........|........|
+// [/Input]
+//
+// Every line of the input log holds one frame. The log key names the button of every column. A pressed button is shown
+// by its mnemonic, a released button by '.'. Movies that start from a savestate can not be imported.
+func ReadBK2(r io.Reader) (*Movie, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMovie, err)
+	}
+
+	var header, inputLog *zip.File
+	for _, file := range archive.File {
+		switch file.Name {
+		case bk2Header:
+			header = file
+		case bk2InputLog:
+			inputLog = file
+		}
+	}
+	if inputLog == nil {
+		return nil, fmt.Errorf("%w: bk2 archive contains no input log", ErrInvalidMovie)
+	}
+
+	if header != nil {
+		err := readBK2File(header, func(line string) error {
+			key, value := line, ""
+			if i := strings.IndexByte(line, ' '); i >= 0 {
+				key, value = line[:i], line[i+1:]
+			}
+			switch {
+			case key == "Platform" && value != "NES":
+				return fmt.Errorf("%w: bk2 movie for platform %s", ErrUnsupportedMovie, value)
+			case key == "StartsFromSavestate" && strings.EqualFold(value, "true"):
+				return fmt.Errorf("%w: bk2 movie starts from a savestate", ErrUnsupportedMovie)
+			case key == "StartsFromSaveRam" && strings.EqualFold(value, "true"):
+				return fmt.Errorf("%w: bk2 movie starts from save RAM", ErrUnsupportedMovie)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	m := &Movie{}
+	logKey := parseBK2LogKey(bk2LogKey)
+	err = readBK2File(inputLog, func(line string) error {
+		switch {
+		case strings.HasPrefix(line, "LogKey:"):
+			logKey = parseBK2LogKey(strings.TrimPrefix(line, "LogKey:"))
+		case strings.HasPrefix(line, "|"):
+			frame, err := parseBK2Frame(line, logKey)
+			if err != nil {
+				return fmt.Errorf("%w: bk2 frame %d: %v", ErrInvalidMovie, len(m.Frames), err)
+			}
+			m.Frames = append(m.Frames, frame)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// WriteBK2 writes the movie as BizHawk .bk2 archive. Movies that start from a state can not be exported.
+func (m *Movie) WriteBK2(w io.Writer) error {
+	if len(m.StartState) > 0 {
+		return fmt.Errorf("%w: movie starts from a state", ErrUnsupportedMovie)
+	}
+	archive := zip.NewWriter(w)
+
+	header, err := archive.Create(bk2Header)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprint(header, "MovieVersion BizHawk v2.0.0\nPlatform NES\nCore NesHawk\n"); err != nil {
+		return err
+	}
+
+	inputLog, err := archive.Create(bk2InputLog)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	b.WriteString("[Input]\nLogKey:" + bk2LogKey + "\n")
+	logKey := parseBK2LogKey(bk2LogKey)
+	for _, frame := range m.Frames {
+		b.WriteByte('|')
+		for _, group := range logKey {
+			for _, name := range group {
+				if bk2Pressed(frame, name) {
+					b.WriteByte(bk2Mnemonics[buttonName(name)])
+				} else {
+					b.WriteByte('.')
+				}
+			}
+			b.WriteByte('|')
+		}
+		b.WriteByte('\n')
+	}
+	b.WriteString("[/Input]\n")
+	if _, err := io.WriteString(inputLog, b.String()); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// readBK2File calls handle for every line of a file of the archive
+func readBK2File(file *zip.File, handle func(line string) error) error {
+	reader, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidMovie, err)
+	}
+	defer plz.Close(reader)
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		if err := handle(strings.TrimRight(scanner.Text(), "\r")); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidMovie, err)
+	}
+	return nil
+}
+
+// parseBK2LogKey splits the log key into the groups of button names
+func parseBK2LogKey(logKey string) [][]string {
+	var groups [][]string
+	for _, group := range strings.Split(logKey, "#") {
+		var names []string
+		for _, name := range strings.Split(group, "|") {
+			if name != "" {
+				names = append(names, name)
+			}
+		}
+		if len(names) > 0 {
+			groups = append(groups, names)
+		}
+	}
+	return groups
+}
+
+// parseBK2Frame parses one line of the input log
+func parseBK2Frame(line string, logKey [][]string) (Frame, error) {
+	var frame Frame
+	fields := strings.Split(strings.Trim(line, "|"), "|")
+	if len(fields) != len(logKey) {
+		return frame, fmt.Errorf("expected %d fields, got %d", len(logKey), len(fields))
+	}
+	for i, field := range fields {
+		if len(field) != len(logKey[i]) {
+			return frame, fmt.Errorf("expected %d buttons, got %q", len(logKey[i]), field)
+		}
+		for j := 0; j < len(field); j++ {
+			if field[j] == '.' || field[j] == ' ' {
+				continue
+			}
+			name := logKey[i][j]
+			switch {
+			case name == "Reset":
+				frame.Command |= CommandReset
+			case name == "Power":
+				frame.Command |= CommandPower
+			case strings.HasPrefix(name, "P1 "):
+				frame.Buttons[0] |= uint8(bk2Buttons[buttonName(name)])
+			case strings.HasPrefix(name, "P2 "):
+				frame.Buttons[1] |= uint8(bk2Buttons[buttonName(name)])
+			}
+		}
+	}
+	return frame, nil
+}
+
+// bk2Pressed returns true if the button of the log key is pressed in the frame
+func bk2Pressed(frame Frame, name string) bool {
+	switch {
+	case name == "Reset":
+		return frame.Command&CommandReset != 0
+	case name == "Power":
+		return frame.Command&CommandPower != 0
+	case strings.HasPrefix(name, "P1 "):
+		return frame.Buttons[0]&uint8(bk2Buttons[buttonName(name)]) != 0
+	case strings.HasPrefix(name, "P2 "):
+		return frame.Buttons[1]&uint8(bk2Buttons[buttonName(name)]) != 0
+	}
+	return false
+}
+
+// buttonName removes the player prefix from the name of a button
+func buttonName(name string) string {
+	if strings.HasPrefix(name, "P1 ") || strings.HasPrefix(name, "P2 ") {
+		return name[3:]
+	}
+	return name
+}
@@ -0,0 +1,106 @@
+package movie
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/exp625/gones/pkg/controller"
+)
+
+// bk2 creates a bk2 archive with the given header and input log
+func bk2(t *testing.T, header string, inputLog string) []byte {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, content := range map[string]string{bk2Header: header, bk2InputLog: inputLog} {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadBK2(t *testing.T) {
+	data := bk2(t, "MovieVersion BizHawk v2.0.0\r\nPlatform NES\r\nCore NesHawk\r\n",
+		"[Input]\r\n"+
+			"LogKey:#Reset|Power|#P1 Up|P1 Down|P1 Left|P1 Right|P1 Start|P1 Select|P1 B|P1 A|#P2 Up|P2 Down|P2 Left|P2 Right|P2 Start|P2 Select|P2 B|P2 A|\r\n"+
+			"|..|........|........|\r\n"+
+			"|..|U......A|........|\r\n"+
+			"|r.|...RS...|.D....B.|\r\n"+
+			"|.P|.....s..|UDLRSsBA|\r\n"+
+			"[/Input]\r\n")
+	m, err := ReadBK2(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Frame{
+		{},
+		{Buttons: [2]uint8{uint8(controller.ButtonUP | controller.ButtonA), 0}},
+		{Command: CommandReset, Buttons: [2]uint8{uint8(controller.ButtonRIGHT | controller.ButtonSTART), uint8(controller.ButtonDOWN | controller.ButtonB)}},
+		{Command: CommandPower, Buttons: [2]uint8{uint8(controller.ButtonSELECT), 0xFF}},
+	}
+	if !reflect.DeepEqual(m.Frames, expected) {
+		t.Fatalf("unexpected frames:\n%v\nexpected:\n%v", m.Frames, expected)
+	}
+}
+
+func TestReadBK2LogKey(t *testing.T) {
+	// Columns follow the log key, not a fixed order
+	data := bk2(t, "Platform NES\n", "[Input]\nLogKey:#P1 A|P1 B|#Power|\n|A.|P|\n[/Input]\n")
+	m, err := ReadBK2(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Frame{{Command: CommandPower, Buttons: [2]uint8{uint8(controller.ButtonA), 0}}}
+	if !reflect.DeepEqual(m.Frames, expected) {
+		t.Fatalf("unexpected frames: %v", m.Frames)
+	}
+}
+
+func TestBK2RoundTrip(t *testing.T) {
+	m := &Movie{}
+	for i := 0; i < 300; i++ {
+		frame := Frame{Buttons: [2]uint8{uint8(i * 3), uint8(i)}}
+		if i%40 == 5 {
+			frame.Command = CommandReset
+		}
+		if i%40 == 25 {
+			frame.Command = CommandPower
+		}
+		m.Frames = append(m.Frames, frame)
+	}
+	var buf bytes.Buffer
+	if err := m.WriteBK2(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadBK2(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read.Frames, m.Frames) {
+		t.Fatal("frames differ after round trip")
+	}
+}
+
+func TestBK2Unsupported(t *testing.T) {
+	data := bk2(t, "Platform NES\nStartsFromSavestate True\n", "[Input]\n[/Input]\n")
+	if _, err := ReadBK2(bytes.NewReader(data)); !errors.Is(err, ErrUnsupportedMovie) {
+		t.Errorf("expected ErrUnsupportedMovie, got %v", err)
+	}
+	data = bk2(t, "Platform SNES\n", "[Input]\n[/Input]\n")
+	if _, err := ReadBK2(bytes.NewReader(data)); !errors.Is(err, ErrUnsupportedMovie) {
+		t.Errorf("expected ErrUnsupportedMovie, got %v", err)
+	}
+	if _, err := ReadBK2(bytes.NewReader([]byte("not a zip"))); !errors.Is(err, ErrInvalidMovie) {
+		t.Errorf("expected ErrInvalidMovie, got %v", err)
+	}
+}
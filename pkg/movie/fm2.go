@@ -0,0 +1,179 @@
+package movie
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupportedMovie is returned if a movie uses features that can not be imported or exported
+var ErrUnsupportedMovie = errors.New("unsupported movie")
+
+// FM2 commands
+const (
+	fm2SoftReset = 1
+	fm2HardReset = 2
+)
+
+// fm2ButtonOrder is the order of the buttons in the input log of FCEUX (RLDUTSBA), from the highest to the lowest bit
+// of controller.Button
+const fm2ButtonOrder = "RLDUTSBA"
+
+// ReadFM2 reads a FCEUX .fm2 movie.
+//
+// From FCEUX documentation https://fceux.com/web/help/fm2.html
+//
+// An fm2 file consists of a header with key value pairs, one per line, followed by the input log. Every line of the
+// input log starts with a '|' and holds one frame:
+//
+// |c|port0|port1|port2|
+//
+// c is a bitfield of commands (1: soft reset, 2: hard reset, ...). The ports hold the buttons in the order RLDUTSBA.
+// A pressed button is shown by its letter, a released button by '.' or ' '.
+//
+// Movies that start from a savestate can not be imported, because FCEUX savestates can not be loaded. The cartridge of
+// the movie is not known, because FCEUX identifies ROMs by a different checksum.
+func ReadFM2(r io.Reader) (*Movie, error) {
+	m := &Movie{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if text == "" {
+			continue
+		}
+		if text[0] != '|' {
+			// Header
+			key, value := text, ""
+			if i := strings.IndexByte(text, ' '); i >= 0 {
+				key, value = text[:i], text[i+1:]
+			}
+			switch key {
+			case "version":
+				if value != "3" {
+					return nil, fmt.Errorf("%w: fm2 version %s", ErrUnsupportedMovie, value)
+				}
+			case "savestate":
+				return nil, fmt.Errorf("%w: fm2 movie starts from a savestate", ErrUnsupportedMovie)
+			case "fourscore":
+				if value == "1" {
+					return nil, fmt.Errorf("%w: fm2 movie uses the four score", ErrUnsupportedMovie)
+				}
+			case "binary":
+				if value == "1" {
+					return nil, fmt.Errorf("%w: binary fm2 input log", ErrUnsupportedMovie)
+				}
+			}
+			continue
+		}
+
+		// Input log
+		fields := strings.Split(text, "|")
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("%w: fm2 line %d: missing fields", ErrInvalidMovie, line)
+		}
+		var frame Frame
+		command, err := strconv.Atoi(strings.TrimSpace(fields[1]))
+		if err != nil {
+			return nil, fmt.Errorf("%w: fm2 line %d: invalid command %q", ErrInvalidMovie, line, fields[1])
+		}
+		if command&fm2SoftReset != 0 {
+			frame.Command |= CommandReset
+		}
+		if command&fm2HardReset != 0 {
+			frame.Command |= CommandPower
+		}
+		for port := 0; port < 2 && port+2 < len(fields); port++ {
+			buttons, err := parseButtons(fields[port+2], fm2ButtonOrder)
+			if err != nil {
+				return nil, fmt.Errorf("%w: fm2 line %d: %v", ErrInvalidMovie, line, err)
+			}
+			frame.Buttons[port] = buttons
+		}
+		m.Frames = append(m.Frames, frame)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// WriteFM2 writes the movie as FCEUX .fm2 movie. Movies that start from a state can not be exported.
+func (m *Movie) WriteFM2(w io.Writer) error {
+	if len(m.StartState) > 0 {
+		return fmt.Errorf("%w: movie starts from a state", ErrUnsupportedMovie)
+	}
+	b := bufio.NewWriter(w)
+	header := []string{
+		"version 3",
+		"emuVersion 22020",
+		"rerecordCount 0",
+		"palFlag 0",
+		// FCEUX only warns about a different checksum
+		"romChecksum base64:" + base64.StdEncoding.EncodeToString(m.Identifier[:]),
+		"guid 00000000-0000-0000-0000-000000000000",
+		"fourscore 0",
+		"microphone 0",
+		"port0 1",
+		"port1 1",
+		"port2 0",
+		"FDS 0",
+		"NewPPU 0",
+	}
+	for _, line := range header {
+		if _, err := fmt.Fprintln(b, line); err != nil {
+			return err
+		}
+	}
+	for _, frame := range m.Frames {
+		command := 0
+		if frame.Command&CommandReset != 0 {
+			command |= fm2SoftReset
+		}
+		if frame.Command&CommandPower != 0 {
+			command |= fm2HardReset
+		}
+		_, err := fmt.Fprintf(b, "|%d|%s|%s||\n", command,
+			formatButtons(frame.Buttons[0], fm2ButtonOrder), formatButtons(frame.Buttons[1], fm2ButtonOrder))
+		if err != nil {
+			return err
+		}
+	}
+	return b.Flush()
+}
+
+// parseButtons converts the buttons of one port of an input log. order lists the mnemonics of the buttons from the
+// highest to the lowest bit.
+func parseButtons(field string, order string) (uint8, error) {
+	if field == "" {
+		// Port is not connected
+		return 0, nil
+	}
+	if len(field) != len(order) {
+		return 0, fmt.Errorf("expected %d buttons, got %q", len(order), field)
+	}
+	var buttons uint8
+	for i := 0; i < len(field); i++ {
+		if field[i] != '.' && field[i] != ' ' {
+			buttons |= 1 << (len(order) - 1 - i)
+		}
+	}
+	return buttons, nil
+}
+
+// formatButtons converts the buttons of one port for an input log, see parseButtons
+func formatButtons(buttons uint8, order string) string {
+	field := []byte(order)
+	for i := range field {
+		if buttons&(1<<(len(order)-1-i)) == 0 {
+			field[i] = '.'
+		}
+	}
+	return string(field)
+}
@@ -0,0 +1,87 @@
+package movie
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/exp625/gones/pkg/controller"
+)
+
+const testFM2 = `version 3
+emuVersion 22020
+rerecordCount 12
+palFlag 0
+romFilename test
+romChecksum base64:AAAAAAAAAAAAAAAAAAAAAA==
+guid 452DE2C3-EF43-2FA9-77AC-0677FC51543B
+fourscore 0
+port0 1
+port1 1
+port2 0
+comment author someone
+|0|........|........||
+|0|R......A|........||
+|1|...U....|.L..T...||
+|2|....TS..|RLDUTSBA||
+|0|        |        ||
+`
+
+func TestReadFM2(t *testing.T) {
+	m, err := ReadFM2(strings.NewReader(testFM2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Frame{
+		{},
+		{Buttons: [2]uint8{uint8(controller.ButtonRIGHT | controller.ButtonA), 0}},
+		{Command: CommandReset, Buttons: [2]uint8{uint8(controller.ButtonUP), uint8(controller.ButtonLEFT | controller.ButtonSTART)}},
+		{Command: CommandPower, Buttons: [2]uint8{uint8(controller.ButtonSTART | controller.ButtonSELECT), 0xFF}},
+		{},
+	}
+	if !reflect.DeepEqual(m.Frames, expected) {
+		t.Fatalf("unexpected frames:\n%v\nexpected:\n%v", m.Frames, expected)
+	}
+}
+
+func TestFM2RoundTrip(t *testing.T) {
+	m := &Movie{}
+	for i := 0; i < 300; i++ {
+		frame := Frame{Buttons: [2]uint8{uint8(i), uint8(i * 7)}}
+		if i%50 == 10 {
+			frame.Command = CommandReset
+		}
+		if i%50 == 20 {
+			frame.Command = CommandPower
+		}
+		m.Frames = append(m.Frames, frame)
+	}
+	var buf bytes.Buffer
+	if err := m.WriteFM2(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadFM2(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read.Frames, m.Frames) {
+		t.Fatal("frames differ after round trip")
+	}
+}
+
+func TestFM2Unsupported(t *testing.T) {
+	_, err := ReadFM2(strings.NewReader("version 3\nsavestate base64:AAAA\n|0|........|........||\n"))
+	if !errors.Is(err, ErrUnsupportedMovie) {
+		t.Errorf("expected ErrUnsupportedMovie, got %v", err)
+	}
+	_, err = ReadFM2(strings.NewReader("version 3\n|x|........|........||\n"))
+	if !errors.Is(err, ErrInvalidMovie) {
+		t.Errorf("expected ErrInvalidMovie, got %v", err)
+	}
+	err = (&Movie{StartState: []byte{1}}).WriteFM2(&bytes.Buffer{})
+	if !errors.Is(err, ErrUnsupportedMovie) {
+		t.Errorf("expected ErrUnsupportedMovie, got %v", err)
+	}
+}
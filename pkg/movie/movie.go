@@ -0,0 +1,148 @@
+package movie
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/exp625/gones/internal/plz"
+	"github.com/exp625/gones/internal/savestate"
+)
+
+// Version is the version of the movie file format
+const Version uint16 = 1
+
+// magic identifies a movie file of gones
+var magic = []byte("GONESMV\x1a")
+
+var (
+	// ErrInvalidMovie is returned if the data is not a movie
+	ErrInvalidMovie = errors.New("not a movie file")
+	// ErrMovieVersion is returned if the movie was written by an incompatible version
+	ErrMovieVersion = errors.New("unsupported movie version")
+	// ErrMovieCartridge is returned if the movie was recorded with a different cartridge
+	ErrMovieCartridge = errors.New("movie belongs to a different cartridge")
+)
+
+// Command is a console command that is executed at the start of a frame, before the input is applied
+type Command uint8
+
+const (
+	// CommandReset presses the reset button
+	CommandReset Command = 1 << iota
+	// CommandPower turns the console off and on again
+	CommandPower
+)
+
+// Frame holds the input of both controller ports for one frame
+type Frame struct {
+	Command Command
+	// Buttons of controller 1 and 2, see controller.Button
+	Buttons [2]uint8
+	// Hash of the picture at the end of the frame, used to detect desyncs. Zero if unknown.
+	Hash uint64
+}
+
+// Movie is a recording of the controller input of every frame. Replaying the input from the same starting point
+// reproduces the recording exactly.
+//
+// Frame 0 starts at power-on or at the start state and ends when the PPU finishes its current frame. Every following
+// frame lasts from one completed PPU frame to the next.
+type Movie struct {
+	// Identifier of the cartridge the movie was recorded with. Zero if unknown, e.g. for imported movies.
+	Identifier [16]byte
+	// Save state the movie starts from. Empty if the movie starts at power-on.
+	StartState []byte
+	Frames     []Frame
+}
+
+// Read reads a movie in the native format
+func Read(r io.Reader) (*Movie, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	s := savestate.NewReader(data)
+	header := make([]byte, len(magic))
+	s.Bytes(header)
+	if s.Err() != nil || !bytes.Equal(header, magic) {
+		return nil, ErrInvalidMovie
+	}
+	var version uint16
+	s.Uint16(&version)
+	if s.Err() != nil {
+		return nil, ErrInvalidMovie
+	}
+	if version != Version {
+		return nil, fmt.Errorf("%w: %d", ErrMovieVersion, version)
+	}
+	m := &Movie{}
+	m.serialize(s)
+	if err := s.Finish(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMovie, err)
+	}
+	return m, nil
+}
+
+// ReadFile reads a movie file. The format is chosen by the extension: .fm2 for FCEUX, .bk2 for BizHawk and the native
+// format otherwise.
+func ReadFile(name string) (*Movie, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer plz.Close(f)
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".fm2":
+		return ReadFM2(f)
+	case ".bk2":
+		return ReadBK2(f)
+	default:
+		return Read(f)
+	}
+}
+
+// Write writes the movie in the native format
+func (m *Movie) Write(w io.Writer) error {
+	s := savestate.NewWriter()
+	s.Bytes(magic)
+	version := Version
+	s.Uint16(&version)
+	m.serialize(s)
+	_, err := w.Write(s.Data())
+	return err
+}
+
+func (m *Movie) serialize(s *savestate.Serializer) {
+	s.Bytes(m.Identifier[:])
+
+	length := uint32(len(m.StartState))
+	s.Uint32(&length)
+	if s.Loading() {
+		if s.Err() != nil || int(length) > s.Remaining() {
+			return
+		}
+		m.StartState = make([]byte, length)
+	}
+	s.Bytes(m.StartState)
+
+	count := uint32(len(m.Frames))
+	s.Uint32(&count)
+	if s.Loading() {
+		// Every frame takes 11 bytes
+		if s.Err() != nil || int(count) > s.Remaining()/11 {
+			return
+		}
+		m.Frames = make([]Frame, count)
+	}
+	for i := range m.Frames {
+		s.Uint8((*uint8)(&m.Frames[i].Command))
+		s.Uint8(&m.Frames[i].Buttons[0])
+		s.Uint8(&m.Frames[i].Buttons[1])
+		s.Uint64(&m.Frames[i].Hash)
+	}
+}
@@ -0,0 +1,137 @@
+package movie
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/exp625/gones/internal/testrom"
+	"github.com/exp625/gones/pkg/cartridge"
+	"github.com/exp625/gones/pkg/nes"
+)
+
+func newTestNES(t *testing.T) *nes.NES {
+	n := nes.New(1.0/5369318.0, 1.0/44100)
+	c, err := cartridge.Load(testrom.ROM(), n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.InsertCartridge(c)
+	return n
+}
+
+// runFrame runs the NES until the PPU has finished the next frame
+func runFrame(n *nes.NES) {
+	frame := n.PPU.FrameCount
+	for n.PPU.FrameCount == frame {
+		n.Clock()
+	}
+}
+
+// record records a movie with changing input, a reset and a power cycle
+func record(t *testing.T, n *nes.NES, fromState bool) *Movie {
+	r, err := NewRecorder(n, fromState, [2]uint8{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 120; i++ {
+		runFrame(n)
+		switch i {
+		case 70:
+			r.Command(CommandReset)
+		case 90:
+			r.Command(CommandPower)
+		}
+		if err := r.Frame([2]uint8{uint8(i / 10), uint8(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Write and read the movie to test the file format as well
+	var buf bytes.Buffer
+	if err := r.Movie.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	m, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Frames) != 120 || m.Frames[71].Command != CommandReset || m.Frames[91].Command != CommandPower {
+		t.Fatalf("unexpected movie: %d frames", len(m.Frames))
+	}
+	return m
+}
+
+// play plays the movie and returns the first error
+func play(n *nes.NES, m *Movie) error {
+	p, err := NewPlayer(n, m)
+	if err != nil {
+		return err
+	}
+	for !p.Finished() {
+		runFrame(n)
+		if err := p.Frame(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestPlaybackFromPowerOn(t *testing.T) {
+	n := newTestNES(t)
+	for i := 0; i < 30; i++ {
+		runFrame(n)
+	}
+	m := record(t, n, false)
+
+	// Change the state of the console before playing the movie
+	n.Controller1.Buttons = 0xFF
+	for i := 0; i < 17; i++ {
+		runFrame(n)
+	}
+	if err := play(n, m); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlaybackFromState(t *testing.T) {
+	n := newTestNES(t)
+	n.Controller1.Buttons = 0x10
+	for i := 0; i < 30; i++ {
+		runFrame(n)
+	}
+	// Start in the middle of a frame
+	for i := 0; i < 5000; i++ {
+		n.Clock()
+	}
+	m := record(t, n, true)
+	if len(m.StartState) == 0 {
+		t.Fatal("movie has no start state")
+	}
+	if err := play(n, m); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDesync(t *testing.T) {
+	n := newTestNES(t)
+	m := record(t, n, false)
+	// Change the input of one frame, the picture has to differ shortly after
+	m.Frames[40].Buttons[0] ^= 0xFF
+	err := play(n, m)
+	var desync *DesyncError
+	if !errors.As(err, &desync) {
+		t.Fatalf("expected desync, got %v", err)
+	}
+	if desync.Frame < 40 || desync.Frame > 43 {
+		t.Fatalf("unexpected desync frame %d", desync.Frame)
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	if _, err := Read(bytes.NewReader([]byte("GONESMV\x1a\x01\x00"))); !errors.Is(err, ErrInvalidMovie) {
+		t.Errorf("expected ErrInvalidMovie, got %v", err)
+	}
+	if _, err := Read(bytes.NewReader([]byte("GONESMV\x1a\x02\x00"))); !errors.Is(err, ErrMovieVersion) {
+		t.Errorf("expected ErrMovieVersion, got %v", err)
+	}
+}
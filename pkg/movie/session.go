@@ -0,0 +1,162 @@
+package movie
+
+import (
+	"fmt"
+	"hash/fnv"
+
+	"github.com/exp625/gones/pkg/controller"
+	"github.com/exp625/gones/pkg/nes"
+)
+
+// DesyncError is returned by Player.Frame if the picture of a frame differs from the recording
+type DesyncError struct {
+	Frame    int
+	Expected uint64
+	Got      uint64
+}
+
+func (e *DesyncError) Error() string {
+	return fmt.Sprintf("movie desync in frame %d: expected frame hash %016x, got %016x", e.Frame, e.Expected, e.Got)
+}
+
+// FrameHash returns the hash of the last completed frame of the NES
+func FrameHash(n *nes.NES) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write(n.PPU.ActiveFrame.Pix)
+	return h.Sum64()
+}
+
+// Recorder records the controller input of a NES into a movie.
+//
+// The recorder has to be informed by calling Frame whenever the PPU completes a frame. To be able to replay the input
+// exactly, the input of the controllers must only change at frame boundaries. Therefore, the recorder applies the
+// input passed to Frame to the controllers itself.
+type Recorder struct {
+	Movie *Movie
+
+	nes     *nes.NES
+	current Frame
+	// Command for the next frame
+	pending Command
+}
+
+// NewRecorder starts a new recording. If fromState is true, the movie starts from the current state of the NES,
+// otherwise the NES is powered on. buttons is the input of both controllers for the first frame.
+func NewRecorder(n *nes.NES, fromState bool, buttons [2]uint8) (*Recorder, error) {
+	r := &Recorder{
+		Movie: &Movie{Identifier: n.Cartridge.Identifier},
+		nes:   n,
+	}
+	if fromState {
+		r.Movie.StartState = n.SaveState()
+	} else if err := n.Power(); err != nil {
+		return nil, err
+	}
+	r.current = Frame{Buttons: buttons}
+	if err := applyInput(n, r.current); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Command executes a console command at the start of the next frame. Commands are never executed in the middle of a
+// frame, otherwise they could not be replayed.
+func (r *Recorder) Command(command Command) {
+	r.pending |= command
+}
+
+// Frame completes the current frame and starts the next frame with the given input. Returns the error of a power
+// command, see nes.NES.Power.
+func (r *Recorder) Frame(buttons [2]uint8) error {
+	r.current.Hash = FrameHash(r.nes)
+	r.Movie.Frames = append(r.Movie.Frames, r.current)
+	r.current = Frame{Command: r.pending, Buttons: buttons}
+	r.pending = 0
+	return applyInput(r.nes, r.current)
+}
+
+// Player replays a movie by driving the controllers of a NES.
+//
+// Like the recorder, the player has to be informed by calling Frame whenever the PPU completes a frame.
+type Player struct {
+	Movie *Movie
+	// Index of the current frame
+	Current int
+
+	nes *nes.NES
+}
+
+// NewPlayer starts the playback of the movie. The NES is powered on or the start state of the movie is loaded.
+func NewPlayer(n *nes.NES, m *Movie) (*Player, error) {
+	if m.Identifier != ([16]byte{}) && m.Identifier != n.Cartridge.Identifier {
+		return nil, ErrMovieCartridge
+	}
+	if len(m.StartState) > 0 {
+		if err := n.LoadState(m.StartState); err != nil {
+			return nil, err
+		}
+	} else if err := n.Power(); err != nil {
+		return nil, err
+	}
+	p := &Player{Movie: m, nes: n}
+	if len(m.Frames) > 0 {
+		if err := applyInput(n, m.Frames[0]); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Finished returns true if all frames of the movie have been played
+func (p *Player) Finished() bool {
+	return p.Current >= len(p.Movie.Frames)
+}
+
+// Frame completes the current frame and applies the input of the next frame. Returns a DesyncError if the picture of
+// the completed frame differs from the recording.
+func (p *Player) Frame() error {
+	if p.Finished() {
+		return nil
+	}
+	frame := p.Movie.Frames[p.Current]
+	var err error
+	if frame.Hash != 0 {
+		if got := FrameHash(p.nes); got != frame.Hash {
+			err = &DesyncError{Frame: p.Current, Expected: frame.Hash, Got: got}
+		}
+	}
+	p.Current++
+	if !p.Finished() {
+		if err := applyInput(p.nes, p.Movie.Frames[p.Current]); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// applyInput executes the command of the frame and sets the buttons of both controllers. A power command turns the
+// NES off and on again, a reset command only presses the reset button.
+func applyInput(n *nes.NES, frame Frame) error {
+	if frame.Command&CommandPower != 0 {
+		if err := n.Power(); err != nil {
+			return err
+		}
+	} else if frame.Command&CommandReset != 0 {
+		n.Reset()
+	}
+	setButtons(n.Controller1, frame.Buttons[0])
+	setButtons(n.Controller2, frame.Buttons[1])
+	return nil
+}
+
+// setButtons presses and releases the buttons of a controller to match the given state
+func setButtons(c *controller.Controller, buttons uint8) {
+	for i := 0; i < 8; i++ {
+		button := controller.Button(1 << i)
+		if buttons&uint8(button) != 0 {
+			c.Press(button)
+		} else {
+			c.Release(button)
+		}
+	}
+}
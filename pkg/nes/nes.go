@@ -1,7 +1,9 @@
 package nes
 
 import (
+	"github.com/exp625/gones/internal/savestate"
 	"github.com/exp625/gones/pkg/apu"
+	"github.com/exp625/gones/pkg/bus"
 	"github.com/exp625/gones/pkg/cartridge"
 	"github.com/exp625/gones/pkg/controller"
 	"github.com/exp625/gones/pkg/cpu"
@@ -21,12 +23,21 @@ type NES struct {
 	Controller2 *controller.Controller
 
 	Cartridge *cartridge.Cartridge
+	// Optional interfaces of the mapper of the cartridge, nil if not implemented
+	cpuCycler  cartridge.CPUCycler
+	renderer   cartridge.Renderer
+	nametables cartridge.NametableMapper
+	// State of the cartridge when it was inserted, restored by Power
+	cartridgeState []byte
 
 	ClockTime       float64
 	AudioSampleTime float64
 
 	MasterClockCount uint64
-	EmulatedTime     float64
+
+	// OpenBus holds the last value that was transferred over the CPU data bus. Reads from addresses that are not
+	// driven by any device return this value.
+	OpenBus uint8
 }
 
 // New creates a new NES instance
@@ -47,39 +58,32 @@ func New(clockTime float64, audioSampleTime float64) *NES {
 	nes.CPU.Bus = nes
 	nes.PPU.AddBus(nes)
 	nes.APU.Bus = nes
+	// The APU runs at one third of the master clock and resamples its output to the audio sample rate
+	nes.APU.SetSampleRate(1/(clockTime*3), 1/audioSampleTime)
 	return nes
 }
 
 // Clock will advance the master clock count by one.
-// If the emulated time is greater than the time needed for one audio sample, the function returns true.
+// If the APU has produced a new audio sample, the function returns true.
 func (nes *NES) Clock() bool {
-	audioSampleReady := false
-
 	// Advance master clock count
 	nes.MasterClockCount++
 
-	// CPUClock the PPU, APU and Cartridge
+	// CPUClock the PPU and Cartridge
 	nes.Cartridge.CPUClock()
 	nes.PPU.Clock()
-	nes.APU.Clock()
 
-	// The NES CPU runs a one third of the frequency of the master clock
+	// The NES CPU and APU run at one third of the frequency of the master clock
 	if nes.MasterClockCount%3 == 0 {
+		if nes.cpuCycler != nil {
+			nes.cpuCycler.CPUCycle()
+		}
 		nes.CPU.Clock()
-	}
-
-	// Add the time for one master clock cycle to the emulated time.
-	nes.EmulatedTime += nes.ClockTime
-
-	// If the emulated time is greater than the time needed for one audio sample:
-	// Reset the emulated time and set the audioSampleReady flag to true
-	if nes.EmulatedTime >= nes.AudioSampleTime {
-		nes.EmulatedTime -= nes.AudioSampleTime
-		audioSampleReady = true
+		nes.APU.Clock()
 	}
 
 	// Return if an audio sample is ready
-	return audioSampleReady
+	return nes.APU.SampleReady()
 }
 
 // Reset resets the NES to a known state
@@ -91,13 +95,34 @@ func (nes *NES) Reset() {
 	nes.RAM.Reset()
 	nes.VRAM.Reset()
 	nes.MasterClockCount = 0
-	nes.EmulatedTime = 0
 }
 
 // InsertCartridge inserts the cartridge into the NES and resets the NES.
 func (nes *NES) InsertCartridge(c *cartridge.Cartridge) {
 	nes.Cartridge = c
+	nes.renderer, _ = c.Mapper.(cartridge.Renderer)
+	nes.nametables, _ = c.Mapper.(cartridge.NametableMapper)
+	nes.cpuCycler, _ = c.Mapper.(cartridge.CPUCycler)
+	nes.APU.Expansion, _ = c.Mapper.(apu.ExpansionAudio)
+	state := savestate.NewWriter()
+	c.Serialize(state)
+	nes.cartridgeState = state.Data()
+	nes.Reset()
+}
+
+// Power turns the NES off and on again. In addition to Reset, the cartridge returns to the state it had when it was
+// inserted, e.g. the CHR RAM is cleared and mappers without reset line return to their power-on banks. The memory
+// saved by the cartridge, the PRG RAM or the disk of the Famicom Disk System, keeps its content.
+func (nes *NES) Power() error {
+	save := append([]uint8{}, nes.Cartridge.Save()...)
+	nes.Cartridge.Serialize(savestate.NewReader(nes.cartridgeState))
+	if len(save) > 0 {
+		if err := nes.Cartridge.Load(save); err != nil {
+			return err
+		}
+	}
 	nes.Reset()
+	return nil
 }
 
 func (nes *NES) CPUMap(location uint16) uint16 {
@@ -106,45 +131,49 @@ func (nes *NES) CPUMap(location uint16) uint16 {
 
 func (nes *NES) CPURead(location uint16) uint8 {
 	mappedLocation := nes.CPUMap(location)
+	var data uint8
 	switch {
 	case mappedLocation <= 0x1FFF:
-		data := nes.RAM.Read(mappedLocation % 0x0800)
-		return data
+		data = nes.RAM.Read(mappedLocation % 0x0800)
 	case 0x2000 <= mappedLocation && mappedLocation <= 0x3FFF:
-		data := nes.PPU.CPURead(mappedLocation)
-		return data
+		data = nes.PPU.CPURead(mappedLocation)
 	case mappedLocation == 0x4016:
-		return nes.Controller1.SerialRead()
+		// Only the lowest bits are driven by the controller ports, the upper three bits are open bus
+		data = nes.Controller1.SerialRead() | nes.OpenBus&0b1110_0000
 	case mappedLocation == 0x4017:
-		return nes.Controller2.SerialRead()
-	case 0x4000 <= mappedLocation && mappedLocation <= 0x4015:
-		// TODO: APU and I/O Registers
-		return 0xFF
-	case 0x4018 <= mappedLocation && mappedLocation <= 0x401F:
-		// TODO: APU and I/O functionality that is normally disabled
-		return 0
+		data = nes.Controller2.SerialRead() | nes.OpenBus&0b1110_0000
+	case mappedLocation == 0x4015:
+		// The status register is internal to the CPU and does not update the open bus value. Bit 5 is open bus.
+		return nes.APU.ReadStatus() | nes.OpenBus&0b0010_0000
+	case 0x4000 <= mappedLocation && mappedLocation <= 0x401F:
+		// All other APU and I/O registers are write only
+		data = nes.OpenBus
 	case 0x4020 <= mappedLocation:
-		data := nes.Cartridge.CPURead(mappedLocation)
-		return data
+		data = nes.Cartridge.CPURead(mappedLocation)
 	default:
 		panic("go is wrong")
 	}
+	nes.OpenBus = data
+	return data
 }
 
 func (nes *NES) CPUWrite(location uint16, data uint8) {
+	nes.OpenBus = data
 	mappedLocation := nes.CPUMap(location)
 	switch {
 	case mappedLocation <= 0x1FFF:
 		nes.RAM.Write(mappedLocation%0x0800, data)
 	case 0x2000 <= mappedLocation && mappedLocation <= 0x3FFF:
 		nes.PPU.CPUWrite(mappedLocation, data)
+		// The cartridge sees the write as well, the MMC5 watches the PPU control register
+		nes.Cartridge.CPUWrite(mappedLocation, data)
 	case mappedLocation == 0x4014:
 		nes.DMA(data)
 	case mappedLocation == 0x4016:
 		nes.Controller1.SetMode(data&0b1 == 0)
 		nes.Controller2.SetMode(data&0b1 == 0)
 	case 0x4000 <= mappedLocation && mappedLocation <= 0x4015 || mappedLocation == 0x4017:
-		// TODO: APU and I/O Registers
+		nes.APU.CPUWrite(mappedLocation, data)
 	case 0x4018 <= mappedLocation && mappedLocation <= 0x401F:
 		// TODO: APU and I/O functionality that is normally disabled
 	case 0x4020 <= mappedLocation:
@@ -159,20 +188,36 @@ func (nes *NES) PPUMap(location uint16) uint16 {
 }
 
 func (nes *NES) PPURead(location uint16) uint8 {
-	location = nes.PPUMap(location)
+	mappedLocation := nes.PPUMap(location)
 	switch {
-	case location <= 0x1FFF:
-		data := nes.Cartridge.PPURead(location)
+	case mappedLocation <= 0x1FFF:
+		data := nes.Cartridge.PPURead(mappedLocation)
 		return data
-	case 0x2000 <= location && location <= 0x3EFF:
-		return nes.PPUReadRam(location)
+	case 0x2000 <= mappedLocation && mappedLocation <= 0x3EFF:
+		if nes.nametables != nil {
+			if data, ok := nes.nametables.NametableRead(location); ok {
+				return data
+			}
+		}
+		return nes.PPUReadRam(mappedLocation)
 	// $3F00-3FFF is not configurable, always mapped to the internal palette control.
-	case 0x3F00 <= location:
-		return nes.PPUReadPalette(location)
+	case 0x3F00 <= mappedLocation:
+		return nes.PPUReadPalette(mappedLocation)
 	}
 	return 0
 }
 
+// PPUFetch performs a memory access of the PPU while rendering. Mappers that take part in the rendering can replace
+// the data.
+func (nes *NES) PPUFetch(location uint16, fetch bus.Fetch) uint8 {
+	if nes.renderer != nil {
+		if data, ok := nes.renderer.PPUFetch(location, fetch); ok {
+			return data
+		}
+	}
+	return nes.PPURead(location)
+}
+
 func (nes *NES) PPUReadPalette(location uint16) uint8 {
 	// $3F00-$3F1F 	Palette RAM indexes
 	// $3F20-$3FFF  Mirrors of $3F00-$3F1F
@@ -195,15 +240,18 @@ func (nes *NES) PPUReadRam(location uint16) uint8 {
 }
 
 func (nes *NES) PPUWrite(location uint16, data uint8) {
-	location = nes.PPUMap(location)
+	mappedLocation := nes.PPUMap(location)
 	switch {
-	case location <= 0x1FFF:
-		nes.Cartridge.PPUWrite(location, data)
-	case 0x2000 <= location && location <= 0x3EFF:
-		nes.PPUWriteRam(location, data)
+	case mappedLocation <= 0x1FFF:
+		nes.Cartridge.PPUWrite(mappedLocation, data)
+	case 0x2000 <= mappedLocation && mappedLocation <= 0x3EFF:
+		if nes.nametables != nil && nes.nametables.NametableWrite(location, data) {
+			return
+		}
+		nes.PPUWriteRam(mappedLocation, data)
 	// $3F00-3FFF is not configurable, always mapped to the internal palette control.
-	case 0x3F00 <= location:
-		nes.PPUWritePalette(location, data)
+	case 0x3F00 <= mappedLocation:
+		nes.PPUWritePalette(mappedLocation, data)
 	}
 }
 
@@ -229,6 +277,11 @@ func (nes *NES) DMA(page uint8) {
 	nes.CPU.DMAAddress = uint16(page) << 8
 }
 
+// Stall halts the CPU for the given number of cycles. Used by the DMC to fetch sample bytes.
+func (nes *NES) Stall(cycles int) {
+	nes.CPU.Stall(cycles)
+}
+
 func (nes *NES) NMI() {
 	nes.CPU.RequestNMI = true
 }
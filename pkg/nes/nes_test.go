@@ -0,0 +1,45 @@
+package nes
+
+import (
+	"testing"
+
+	"github.com/exp625/gones/internal/testrom"
+	"github.com/exp625/gones/pkg/cartridge"
+)
+
+func TestPower(t *testing.T) {
+	// The test ROM with 8 KB CHR RAM instead of the CHR ROM
+	rom := testrom.ROM()[:0x10+0x4000]
+	rom[5] = 0
+	nes := New(1.0/5369318.0, 1.0/44100)
+	c, err := cartridge.Load(rom, nes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nes.InsertCartridge(c)
+	runFrame(nes)
+
+	nes.PPUWrite(0x0123, 0x42)
+	nes.CPUWrite(0x6123, 0x24)
+	nes.RAM.Data[0x0123] = 0x11
+	nes.Reset()
+	if data := nes.PPURead(0x0123); data != 0x42 {
+		t.Errorf("expected the CHR RAM to keep its content on reset, got $%02X", data)
+	}
+
+	if err := nes.Power(); err != nil {
+		t.Fatal(err)
+	}
+	if data := nes.PPURead(0x0123); data != 0 {
+		t.Errorf("expected the CHR RAM to be cleared on power-on, got $%02X", data)
+	}
+	if data := nes.CPURead(0x6123); data != 0x24 {
+		t.Errorf("expected the PRG RAM to keep its content, got $%02X", data)
+	}
+	if data := nes.RAM.Data[0x0123]; data != 0 {
+		t.Errorf("expected the RAM to be cleared, got $%02X", data)
+	}
+	if nes.PPU.FrameCount != 0 || nes.MasterClockCount != 0 {
+		t.Error("expected the console to start from power-on")
+	}
+}
@@ -0,0 +1,90 @@
+package nes
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/exp625/gones/internal/savestate"
+)
+
+// StateVersion is the version of the save state format. It has to be increased whenever a component changes the
+// fields it serializes.
+const StateVersion uint16 = 7
+
+// stateMagic identifies a save state of gones
+var stateMagic = []byte("GONES\x1a")
+
+var (
+	// ErrInvalidState is returned if the data is not a save state
+	ErrInvalidState = errors.New("not a save state")
+	// ErrStateVersion is returned if the save state was written by an incompatible version
+	ErrStateVersion = errors.New("unsupported save state version")
+	// ErrStateCartridge is returned if the save state was created with a different cartridge
+	ErrStateCartridge = errors.New("save state belongs to a different cartridge")
+)
+
+// SaveState serializes the complete state of the NES into one binary blob.
+//
+// The state starts with a header containing the magic bytes, the format version and the identifier of the inserted
+// cartridge, followed by the state of every component.
+func (nes *NES) SaveState() []byte {
+	s := savestate.NewWriter()
+	header := append([]byte{}, stateMagic...)
+	s.Bytes(header)
+	version := StateVersion
+	s.Uint16(&version)
+	s.Bytes(nes.Cartridge.Identifier[:])
+	nes.Serialize(s)
+	return s.Data()
+}
+
+// LoadState restores the state of the NES from a blob created by SaveState. If the state can not be loaded, the NES
+// is left unchanged.
+func (nes *NES) LoadState(data []byte) error {
+	s := savestate.NewReader(data)
+	header := make([]byte, len(stateMagic))
+	s.Bytes(header)
+	if s.Err() != nil || !bytes.Equal(header, stateMagic) {
+		return ErrInvalidState
+	}
+	var version uint16
+	s.Uint16(&version)
+	if s.Err() != nil {
+		return ErrInvalidState
+	}
+	if version != StateVersion {
+		return fmt.Errorf("%w: %d", ErrStateVersion, version)
+	}
+	var identifier [16]byte
+	s.Bytes(identifier[:])
+	if s.Err() != nil {
+		return ErrInvalidState
+	}
+	if identifier != nes.Cartridge.Identifier {
+		return ErrStateCartridge
+	}
+
+	// Keep the current state to restore it if the state is corrupted
+	backup := nes.SaveState()
+	nes.Serialize(s)
+	if err := s.Finish(); err != nil {
+		nes.Serialize(savestate.NewReader(backup[len(stateMagic)+2+len(identifier):]))
+		return fmt.Errorf("%w: %v", ErrInvalidState, err)
+	}
+	return nil
+}
+
+// Serialize saves or loads the state of all components of the NES
+func (nes *NES) Serialize(s *savestate.Serializer) {
+	s.Uint64(&nes.MasterClockCount)
+	s.Uint8(&nes.OpenBus)
+	nes.CPU.Serialize(s)
+	nes.RAM.Serialize(s)
+	nes.VRAM.Serialize(s)
+	nes.PPU.Serialize(s)
+	nes.APU.Serialize(s)
+	nes.Controller1.Serialize(s)
+	nes.Controller2.Serialize(s)
+	nes.Cartridge.Serialize(s)
+}
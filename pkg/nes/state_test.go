@@ -0,0 +1,109 @@
+package nes
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"testing"
+
+	"github.com/exp625/gones/internal/testrom"
+	"github.com/exp625/gones/pkg/cartridge"
+)
+
+func newTestNES(t *testing.T) *NES {
+	nes := New(1.0/5369318.0, 1.0/44100)
+	c, err := cartridge.Load(testrom.ROM(), nes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nes.InsertCartridge(c)
+	return nes
+}
+
+// runFrame runs the NES until the PPU has finished the next frame and returns the hash of the frame
+func runFrame(nes *NES) [sha1.Size]byte {
+	frame := nes.PPU.FrameCount
+	for nes.PPU.FrameCount == frame {
+		nes.Clock()
+	}
+	return sha1.Sum(nes.PPU.ActiveFrame.Pix)
+}
+
+func TestSaveStateRoundTrip(t *testing.T) {
+	nes := newTestNES(t)
+	for i := 0; i < 60; i++ {
+		runFrame(nes)
+	}
+	// Save in the middle of a frame
+	for i := 0; i < 12345; i++ {
+		nes.Clock()
+	}
+	state := nes.SaveState()
+	frame := append([]uint8{}, nes.PPU.ActiveFrame.Pix...)
+
+	// The frame buffers are rebuilt from the palette indices
+	restored := newTestNES(t)
+	if err := restored.LoadState(state); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(restored.PPU.ActiveFrame.Pix, frame) {
+		t.Fatal("frame differs after loading the state")
+	}
+
+	var expected [][sha1.Size]byte
+	for i := 0; i < 30; i++ {
+		expected = append(expected, runFrame(nes))
+	}
+	if expected[0] == expected[len(expected)-1] {
+		t.Fatal("test ROM does not change the frame output")
+	}
+	expectedState := nes.SaveState()
+
+	if err := nes.LoadState(state); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(nes.SaveState(), state) {
+		t.Fatal("state after loading differs from the saved state")
+	}
+	for i := 0; i < 30; i++ {
+		if got := runFrame(nes); got != expected[i] {
+			t.Fatalf("frame %d differs after restoring the state: got %x, expected %x", i, got, expected[i])
+		}
+	}
+	if !bytes.Equal(nes.SaveState(), expectedState) {
+		t.Fatal("state after running the restored state differs")
+	}
+}
+
+func TestLoadStateErrors(t *testing.T) {
+	nes := newTestNES(t)
+	for i := 0; i < 10; i++ {
+		runFrame(nes)
+	}
+	state := nes.SaveState()
+
+	if err := nes.LoadState([]byte("not a state")); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("expected ErrInvalidState, got %v", err)
+	}
+
+	version := append([]byte{}, state...)
+	version[len(stateMagic)]++
+	if err := nes.LoadState(version); !errors.Is(err, ErrStateVersion) {
+		t.Errorf("expected ErrStateVersion, got %v", err)
+	}
+
+	cartridge := append([]byte{}, state...)
+	cartridge[len(stateMagic)+2]++
+	if err := nes.LoadState(cartridge); !errors.Is(err, ErrStateCartridge) {
+		t.Errorf("expected ErrStateCartridge, got %v", err)
+	}
+
+	runFrame(nes)
+	current := nes.SaveState()
+	if err := nes.LoadState(state[:len(state)-1]); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("expected ErrInvalidState, got %v", err)
+	}
+	if !bytes.Equal(nes.SaveState(), current) {
+		t.Error("failed load changed the state")
+	}
+}
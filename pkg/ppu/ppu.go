@@ -1,6 +1,7 @@
 package ppu
 
 import (
+	"github.com/exp625/gones/internal/savestate"
 	"github.com/exp625/gones/internal/shift_register"
 	"github.com/exp625/gones/pkg/bus"
 	"image"
@@ -53,6 +54,9 @@ type PPU struct {
 	// Frame buffer
 	ActiveFrame *image.RGBA
 	RenderFrame *image.RGBA
+	// Palette index of every pixel of the frame buffers. Bits 0-5 hold the color and bits 6-8 the color emphasis.
+	ActiveIndices []uint16
+	RenderIndices []uint16
 
 	// The PPU has an internal data bus name GenLatch that it uses for communication with the CPU. This bus behaves as an 8-bit
 	// dynamic latch due to capacitance of very long traces that run to various parts of the PPU.
@@ -107,6 +111,8 @@ func New() *PPU {
 	lowRight := image.Point{X: 256, Y: 240}
 	p.ActiveFrame = image.NewRGBA(image.Rectangle{Min: upLeft, Max: lowRight})
 	p.RenderFrame = image.NewRGBA(image.Rectangle{Min: upLeft, Max: lowRight})
+	p.ActiveIndices = make([]uint16, 256*240)
+	p.RenderIndices = make([]uint16, 256*240)
 	return p
 }
 
@@ -151,28 +157,30 @@ func (ppu *PPU) Clock() {
 			}
 
 			// Memory access
-			switch ppu.Dot % 8 {
-			case 1:
-				// Fill nametable latch
-				ppu.NameTableLatch = ppu.Bus.PPURead(0x2000 | (uint16(ppu.CurrVRAM) & 0x0FFF))
-			case 3:
-				// Fill attribute latch
-				attributeByte := ppu.Bus.PPURead(0x2000 | 0x03C0 | uint16(ppu.CurrVRAM.NameTable())<<10 |
-					(uint16(ppu.CurrVRAM.CoarseYScroll()>>2) << 3) | (uint16(ppu.CurrVRAM.CoarseXScroll()) >> 2))
-				shift := 0
-				if ppu.CurrVRAM.CoarseYScroll()&0b10 == 0b10 {
-					shift += 4
+			if ppu.IsRendering() {
+				switch ppu.Dot % 8 {
+				case 1:
+					// Fill nametable latch
+					ppu.NameTableLatch = ppu.Bus.PPUFetch(0x2000|(uint16(ppu.CurrVRAM)&0x0FFF), bus.FetchNametable)
+				case 3:
+					// Fill attribute latch
+					attributeByte := ppu.Bus.PPUFetch(0x2000|0x03C0|uint16(ppu.CurrVRAM.NameTable())<<10|
+						(uint16(ppu.CurrVRAM.CoarseYScroll()>>2)<<3)|(uint16(ppu.CurrVRAM.CoarseXScroll())>>2), bus.FetchAttribute)
+					shift := 0
+					if ppu.CurrVRAM.CoarseYScroll()&0b10 == 0b10 {
+						shift += 4
+					}
+					if ppu.CurrVRAM.CoarseXScroll()&0b10 == 0b10 {
+						shift += 2
+					}
+					ppu.AttributeLatch = attributeByte >> shift & 0b11
+				case 5:
+					// Fill BG low tile
+					ppu.BGTileLowLatch = ppu.Bus.PPUFetch(uint16(ppu.Control.PatternTable())<<12|uint16(ppu.NameTableLatch)<<4|uint16(ppu.CurrVRAM.FineYScroll()), bus.FetchBackground)
+				case 7:
+					// Fill BG low tile
+					ppu.BGTileHighLatch = ppu.Bus.PPUFetch(uint16(ppu.Control.PatternTable())<<12|uint16(ppu.NameTableLatch)<<4|uint16(ppu.CurrVRAM.FineYScroll())+8, bus.FetchBackground)
 				}
-				if ppu.CurrVRAM.CoarseXScroll()&0b10 == 0b10 {
-					shift += 2
-				}
-				ppu.AttributeLatch = attributeByte >> shift & 0b11
-			case 5:
-				// Fill BG low tile
-				ppu.BGTileLowLatch = ppu.Bus.PPURead(uint16(ppu.Control.PatternTable())<<12 | uint16(ppu.NameTableLatch)<<4 | uint16(ppu.CurrVRAM.FineYScroll()))
-			case 7:
-				// Fill BG low tile
-				ppu.BGTileHighLatch = ppu.Bus.PPURead(uint16(ppu.Control.PatternTable())<<12 | uint16(ppu.NameTableLatch)<<4 | uint16(ppu.CurrVRAM.FineYScroll()) + 8)
 			}
 
 			// Between dot 328 of a scanline, and 256 of the next scanline increment horizontal position every 8 time
@@ -181,6 +189,12 @@ func (ppu *PPU) Clock() {
 			}
 		}
 
+		// The nametable byte fetched on dot 337 is fetched twice more on dot 339 and on dot 1 of the next line. The data
+		// is not used, but the MMC5 detects the start of a scanline by these fetches.
+		if (ppu.Dot == 339 || ppu.Dot == 1) && ppu.IsRendering() {
+			_ = ppu.Bus.PPUFetch(0x2000|(uint16(ppu.CurrVRAM)&0x0FFF), bus.FetchNametable)
+		}
+
 		if ppu.Mask.ShowBackground() || ppu.Mask.ShowSprites() {
 
 			// Increment vertical position on dot 256 of each scanline
@@ -299,7 +313,7 @@ func (ppu *PPU) Clock() {
 	if ppu.IsSpriteFetches() {
 		// Cycles 257-320: Sprite fetches (8 sprites total, 8 cycles per sprite)
 		if ppu.Dot == 257 {
-			ppu.SpriteZeroVisible = ppu.SpriteZeroVisibleEvaluation
+			ppu.SpriteZeroVisible = ppu.SpriteZeroVisibleEvaluation && !ppu.IsPrerenderLine()
 		}
 		actionIndex := ppu.Dot & 0b111
 		spriteIndex := ppu.Dot >> 3 & 0b111
@@ -309,7 +323,13 @@ func (ppu *PPU) Clock() {
 		case 1:
 			// Read the Y-coordinate
 			// Also Garbage NT Fetch that is unimplemented
-			ppu.SpriteYCoordinate[spriteIndex] = ppu.SecondaryOAM[spriteIndex*4+0]
+			// The pre-render line fetches sprites as well, but never shows them. Slots that were not filled by the sprite
+			// evaluation can still hold the Y-coordinate of a sprite out of range.
+			if ppu.IsPrerenderLine() || !ppu.SpriteInRange(ppu.SecondaryOAM[spriteIndex*4+0]) {
+				ppu.SpriteYCoordinate[spriteIndex] = 0xFF
+			} else {
+				ppu.SpriteYCoordinate[spriteIndex] = ppu.SecondaryOAM[spriteIndex*4+0]
+			}
 		case 2:
 			// Tile number
 			if ppu.SpriteYCoordinate[spriteIndex] != 0xFF {
@@ -336,6 +356,7 @@ func (ppu *PPU) Clock() {
 			// For convenience, we do the memory fetches that happen on actionIndex 5 and 7 together. Both only access secondaryOAM
 			// memory which can not be updated mid-frame by external devices
 			yPos := ppu.ScanLine - uint16(ppu.SpriteYCoordinate[spriteIndex])
+			tileIndex := ppu.SpriteTileIndex[spriteIndex]
 			if (ppu.SpriteAttribute[spriteIndex]>>7)&0b1 == 1 {
 				if ppu.Control.SpriteSize() == 0 {
 					yPos = 7 - yPos // Vertical flipping in 8x8 mode
@@ -344,22 +365,32 @@ func (ppu *PPU) Clock() {
 				}
 			}
 			if ppu.SpriteYCoordinate[spriteIndex] == 0xFF {
-				ppu.SpritePatternLow[spriteIndex].Set(0)
-				ppu.SpritePatternHigh[spriteIndex].Set(0)
-			} else if ppu.Control.SpriteSize() == 0 {
-				ppu.SpritePatternLow[spriteIndex].Set(ppu.Bus.PPURead(uint16(ppu.Control.SpriteTable())<<12 | uint16(ppu.SpriteTileIndex[spriteIndex])<<4 | 0<<3 | yPos))
-				ppu.SpritePatternHigh[spriteIndex].Set(ppu.Bus.PPURead(uint16(ppu.Control.SpriteTable())<<12 | uint16(ppu.SpriteTileIndex[spriteIndex])<<4 | 1<<3 | yPos))
-			} else if ppu.Control.SpriteSize() == 1 {
+				// Unused slots fetch the pattern of tile $FF. The data is discarded, but mappers see the fetches.
+				tileIndex = 0xFF
+				yPos = 0
+			}
+			var address uint16
+			if ppu.Control.SpriteSize() == 0 {
+				address = uint16(ppu.Control.SpriteTable())<<12 | uint16(tileIndex)<<4 | yPos
+			} else {
 				partIndex := uint16(0)
 				if yPos >= 8 {
 					// We are displaying the second part of the 8x16 Sprite
 					partIndex = 1
 					yPos -= 8
 				}
-				ppu.SpritePatternLow[spriteIndex].Set(ppu.Bus.PPURead(uint16(ppu.SpriteTileIndex[spriteIndex]&0b1)<<12 | uint16(ppu.SpriteTileIndex[spriteIndex]&0b1111_1110)<<4 | partIndex<<4 | 0<<3 | yPos))
-				ppu.SpritePatternHigh[spriteIndex].Set(ppu.Bus.PPURead(uint16(ppu.SpriteTileIndex[spriteIndex]&0b1)<<12 | uint16(ppu.SpriteTileIndex[spriteIndex]&0b1111_1110)<<4 | partIndex<<4 | 1<<3 | yPos))
+				address = uint16(tileIndex&0b1)<<12 | uint16(tileIndex&0b1111_1110)<<4 | partIndex<<4 | yPos
 			}
-
+			var low, high uint8
+			if ppu.IsRendering() {
+				low = ppu.Bus.PPUFetch(address|0<<3, bus.FetchSprite)
+				high = ppu.Bus.PPUFetch(address|1<<3, bus.FetchSprite)
+			}
+			if ppu.SpriteYCoordinate[spriteIndex] == 0xFF {
+				low, high = 0, 0
+			}
+			ppu.SpritePatternLow[spriteIndex].Set(low)
+			ppu.SpritePatternHigh[spriteIndex].Set(high)
 		}
 	}
 
@@ -437,6 +468,81 @@ func (ppu *PPU) Reset() {
 	ppu.PaletteRAM = [32]uint8{}
 }
 
+// Serialize saves or loads the registers, latches, shift registers, OAM, palette RAM and the palette indices of both
+// frame buffers of the PPU
+func (ppu *PPU) Serialize(s *savestate.Serializer) {
+	s.Uint16(&ppu.ScanLine)
+	s.Uint16(&ppu.Dot)
+	s.Uint64(&ppu.FrameCount)
+
+	s.Uint8((*uint8)(&ppu.Control))
+	s.Uint8((*uint8)(&ppu.Mask))
+	s.Uint8((*uint8)(&ppu.Status))
+	s.Uint8(&ppu.OAMAddress)
+	s.Uint16((*uint16)(&ppu.CurrVRAM))
+	s.Uint16((*uint16)(&ppu.TempVRAM))
+	s.Uint16((*uint16)(&ppu.DebugVRAM))
+	s.Uint8(&ppu.FineXScroll)
+	s.Uint8(&ppu.AddressLatch)
+
+	ppu.TileAHigh.Serialize(s)
+	ppu.TileALow.Serialize(s)
+	ppu.TileBHigh.Serialize(s)
+	ppu.TileBLow.Serialize(s)
+	ppu.AttributeAHigh.Serialize(s)
+	ppu.AttributeALow.Serialize(s)
+	ppu.AttributeBHigh.Serialize(s)
+	ppu.AttributeBLow.Serialize(s)
+
+	s.Uint8(&ppu.NameTableLatch)
+	s.Uint8(&ppu.AttributeLatch)
+	s.Uint8(&ppu.BGTileLowLatch)
+	s.Uint8(&ppu.BGTileHighLatch)
+	s.Uint8(&ppu.GenLatch)
+	s.Uint8(&ppu.ReadLatch)
+
+	s.Bytes(ppu.OAM[:])
+	s.Bytes(ppu.SecondaryOAM[:])
+	s.Uint8(&ppu.SecondaryOAMAddress)
+
+	for i := range ppu.SpritePatternLow {
+		ppu.SpritePatternLow[i].Serialize(s)
+		ppu.SpritePatternHigh[i].Serialize(s)
+	}
+	s.Bytes(ppu.SpriteAttribute[:])
+	s.Bytes(ppu.SpriteCounters[:])
+
+	s.Uint8(&ppu.SpriteEvaluationMode)
+	s.Uint8(&ppu.EvalN)
+	s.Uint8(&ppu.EvalM)
+	s.Bool(&ppu.SpriteZeroVisibleEvaluation)
+	s.Bool(&ppu.SpriteZeroVisible)
+	s.Bytes(ppu.SpriteYCoordinate[:])
+	s.Bytes(ppu.SpriteTileIndex[:])
+
+	s.Bytes(ppu.PaletteRAM[:])
+
+	// The frame buffers are part of the state, because a state can be saved in the middle of a frame. Only the palette
+	// indices are stored, the colors are looked up again when the state is loaded.
+	for i := range ppu.ActiveIndices {
+		s.Uint16(&ppu.ActiveIndices[i])
+	}
+	for i := range ppu.RenderIndices {
+		s.Uint16(&ppu.RenderIndices[i])
+	}
+	if s.Loading() {
+		ppu.fillFrame(ppu.ActiveFrame, ppu.ActiveIndices)
+		ppu.fillFrame(ppu.RenderFrame, ppu.RenderIndices)
+	}
+}
+
+// fillFrame sets every pixel of the frame to the color of its palette index
+func (ppu *PPU) fillFrame(frame *image.RGBA, indices []uint16) {
+	for i, index := range indices {
+		frame.Set(i%256, i/256, ppu.Palette[index&0x3F][index>>6&0b111])
+	}
+}
+
 // CPURead performs a read operation coming from the cpu bus
 func (ppu *PPU) CPURead(location uint16) uint8 {
 	if location >= 0x2000 && location <= 0x3FFF {
@@ -1,14 +1,11 @@
 package ppu
 
-import (
-	"image/color"
-)
-
 // SwapFrameBuffer swaps the frame buffer. The PPU implementation has two frame buffers, one witch the ppu renders the
 // current frame on and one that is displayed. After a new rendered frame is complete, the frame buffers are swapped to
 // display the new frame and the next frame gets drawn on the old frame.
 func (ppu *PPU) SwapFrameBuffer() {
 	ppu.ActiveFrame, ppu.RenderFrame = ppu.RenderFrame, ppu.ActiveFrame
+	ppu.ActiveIndices, ppu.RenderIndices = ppu.RenderIndices, ppu.ActiveIndices
 }
 
 // ShiftRegisters will shift all internal shift registers used for rendering
@@ -24,16 +21,16 @@ func (ppu *PPU) ShiftRegisters() {
 
 // Render will generate exactly one pixel on the current frame position
 func (ppu *PPU) Render() {
-	var backgroundPixelColor color.Color
+	var backgroundPixelColor uint8
 	var backgroundColorIndex uint8
-	var spritePixelColor color.Color
+	var spritePixelColor uint8
 	var spriteColorIndex uint8
 	var spritePriority uint8
-	var pixelColor color.Color
+	var pixelColor uint8
 
 	backgroundColorIndex = ppu.TileAHigh.GetBit(7-ppu.FineXScroll)<<1 | ppu.TileALow.GetBit(7-ppu.FineXScroll)
 	attributeIndex := ppu.AttributeAHigh.GetBit(7-ppu.FineXScroll)<<1 | ppu.AttributeALow.GetBit(7-ppu.FineXScroll)
-	backgroundPixelColor = ppu.Palette[ppu.PaletteRAM[attributeIndex*4+backgroundColorIndex]%0x40][ppu.Mask.Emphasize()]
+	backgroundPixelColor = ppu.PaletteRAM[attributeIndex*4+backgroundColorIndex] % 0x40
 
 	for i := 0; i < 8; i++ {
 		if ppu.SpriteCounters[i] != 0 {
@@ -58,7 +55,7 @@ func (ppu *PPU) Render() {
 		}
 		spritePriority = currentSpritePriority
 		spriteColorIndex = currentSpriteColorIndex
-		spritePixelColor = ppu.Palette[ppu.PaletteRAM[4*spriteAttributeIndex+currentSpriteColorIndex+4*4]%0x40][ppu.Mask.Emphasize()]
+		spritePixelColor = ppu.PaletteRAM[4*spriteAttributeIndex+currentSpriteColorIndex+4*4] % 0x40
 
 		// Only the first visible sprite is shown, exit the loop now
 		// break
@@ -82,10 +79,12 @@ func (ppu *PPU) Render() {
 			pixelColor = backgroundPixelColor
 		}
 	default:
-		pixelColor = ppu.Palette[ppu.PaletteRAM[0]%0x40][ppu.Mask.Emphasize()]
+		pixelColor = ppu.PaletteRAM[0] % 0x40
 	}
 
-	ppu.RenderFrame.Set(int(ppu.Dot-1), int(ppu.ScanLine), pixelColor)
+	emphasis := ppu.Mask.Emphasize()
+	ppu.RenderIndices[int(ppu.ScanLine)*256+int(ppu.Dot-1)] = uint16(emphasis)<<6 | uint16(pixelColor)
+	ppu.RenderFrame.Set(int(ppu.Dot-1), int(ppu.ScanLine), ppu.Palette[pixelColor][emphasis])
 }
 
 // IsVisibleLine return true if the ppu is currently on a visible scan line
@@ -98,6 +97,11 @@ func (ppu *PPU) IsPrerenderLine() bool {
 	return ppu.ScanLine == 261
 }
 
+// IsRendering returns true if the background or the sprites are shown. Otherwise, the PPU does not access the memory.
+func (ppu *PPU) IsRendering() bool {
+	return ppu.Mask.ShowBackground() || ppu.Mask.ShowSprites()
+}
+
 // IsOAMClear return true if the ppu is currently clearing oam memory
 func (ppu *PPU) IsOAMClear() bool {
 	return ppu.IsVisibleLine() && 1 <= ppu.Dot && ppu.Dot <= 64
@@ -110,7 +114,7 @@ func (ppu *PPU) IsSpriteEvaluation() bool {
 
 // IsSpriteFetches returns true if the ppu is currently fetching sprites
 func (ppu *PPU) IsSpriteFetches() bool {
-	return ppu.IsVisibleLine() && 257 <= ppu.Dot && ppu.Dot <= 320
+	return (ppu.IsVisibleLine() || ppu.IsPrerenderLine()) && 257 <= ppu.Dot && ppu.Dot <= 320
 }
 
 // IncrementVerticalPosition increments fine Y, overflowing to coarse Y, and finally adjusted to wrap among
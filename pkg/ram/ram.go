@@ -1,5 +1,7 @@
 package ram
 
+import "github.com/exp625/gones/internal/savestate"
+
 type RAM struct {
 	Data [0x0800]uint8
 }
@@ -20,3 +22,7 @@ func (ram *RAM) Write(location uint16, data uint8) bool {
 	ram.Data[location] = data
 	return true
 }
+
+func (ram *RAM) Serialize(s *savestate.Serializer) {
+	s.Bytes(ram.Data[:])
+}